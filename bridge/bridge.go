@@ -0,0 +1,163 @@
+// Package bridge implements the synchronisation of tickets with other bug trackers
+package bridge
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/daedaleanai/git-ticket/cache"
+)
+
+// configKeyPrefix is the prefix of the keys of the bridge configurations in the git config,
+// followed by the name of the target
+const configKeyPrefix = "git-bug.bridge."
+
+// OriginMetadataKey is the metadata key of the create operation recording the bug tracker a
+// ticket was imported from or exported to
+const OriginMetadataKey = "origin"
+
+// Param is a configuration parameter of a target
+type Param struct {
+	Name        string
+	Description string
+	Required    bool
+	Default     string
+}
+
+// Result counts the tickets changed by a pull or a push
+type Result struct {
+	Created   int
+	Updated   int
+	Unchanged int
+}
+
+// Target is a bug tracker tickets can be imported from and exported to
+type Target struct {
+	// Name of the target, e.g. "github"
+	Name string
+	// Params are the parameters of the configuration of the target
+	Params []Param
+	// Pull imports the issues of the bug tracker, and their updates, as tickets
+	Pull func(repo *cache.RepoCache, conf Config) (Result, error)
+	// Push exports the changes made to the tickets to the bug tracker
+	Push func(repo *cache.RepoCache, conf Config) (Result, error)
+}
+
+var targets = map[string]*Target{}
+
+// Register makes a target available, it's called by the init function of each target
+func Register(t *Target) {
+	if _, ok := targets[t.Name]; ok {
+		panic("bridge target registered twice: " + t.Name)
+	}
+	targets[t.Name] = t
+}
+
+// Targets returns the available targets sorted by name
+func Targets() []*Target {
+	result := make([]*Target, 0, len(targets))
+	for _, t := range targets {
+		result = append(result, t)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+// TargetFor returns the target with the given name
+func TargetFor(name string) (*Target, error) {
+	t, ok := targets[name]
+	if !ok {
+		names := make([]string, 0, len(targets))
+		for _, t := range Targets() {
+			names = append(names, t.Name)
+		}
+		return nil, fmt.Errorf("unknown bridge target %s, valid targets are [%s]", name, strings.Join(names, ","))
+	}
+	return t, nil
+}
+
+// Config is the configuration of a target, including the state of the synchronisation
+type Config struct {
+	target string
+	values map[string]string
+}
+
+// LoadConfig reads the configuration of the target from the git config of the repository
+func LoadConfig(repo *cache.RepoCache, target string) (Config, error) {
+	conf := Config{target: target, values: make(map[string]string)}
+
+	prefix := configKeyPrefix + target + "."
+	values, err := repo.LocalConfig().ReadAll(prefix)
+	if err != nil {
+		return conf, err
+	}
+	for key, value := range values {
+		conf.values[strings.TrimPrefix(key, prefix)] = value
+	}
+
+	if len(conf.values) == 0 {
+		return conf, fmt.Errorf("the %s bridge isn't configured, run \"bridge configure %s\"", target, target)
+	}
+	return conf, nil
+}
+
+// NewConfig returns a configuration of the target with the given parameters
+func NewConfig(target string, values map[string]string) Config {
+	conf := Config{target: target, values: make(map[string]string)}
+	for key, value := range values {
+		conf.values[key] = value
+	}
+	return conf
+}
+
+// Get returns the value of a parameter, or an empty string if it isn't set
+func (c Config) Get(key string) string {
+	return c.values[key]
+}
+
+// Store sets the value of a parameter and writes it to the git config of the repository
+func (c Config) Store(repo *cache.RepoCache, key string, value string) error {
+	c.values[key] = value
+	return repo.LocalConfig().StoreString(configKeyPrefix+c.target+"."+key, value)
+}
+
+// Configure checks the parameters of the target and writes them to the git config of the repository
+func Configure(repo *cache.RepoCache, t *Target, values map[string]string) error {
+	conf := Config{target: t.Name, values: make(map[string]string)}
+
+	for _, p := range t.Params {
+		value := values[p.Name]
+		if value == "" {
+			value = p.Default
+		}
+		if value == "" && p.Required {
+			return fmt.Errorf("the %s bridge needs the parameter %s: %s", t.Name, p.Name, p.Description)
+		}
+		conf.values[p.Name] = value
+	}
+
+	for _, p := range t.Params {
+		if conf.values[p.Name] == "" {
+			continue
+		}
+		if err := conf.Store(repo, p.Name, conf.values[p.Name]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// issueNumber returns the number of the issue of the project, as owner/name, with the given web URL
+func issueNumber(project string, issueUrl string) (int, bool) {
+	i := strings.LastIndex(issueUrl, "/"+project+"/issues/")
+	if i < 0 {
+		return 0, false
+	}
+	number, err := strconv.Atoi(issueUrl[i+len(project)+len("//issues/"):])
+	return number, err == nil
+}
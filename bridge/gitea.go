@@ -0,0 +1,453 @@
+package bridge
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"code.gitea.io/sdk/gitea"
+	"github.com/pkg/errors"
+
+	"github.com/daedaleanai/git-ticket/bug"
+	"github.com/daedaleanai/git-ticket/cache"
+	"github.com/daedaleanai/git-ticket/entity"
+	"github.com/daedaleanai/git-ticket/identity"
+	"github.com/daedaleanai/git-ticket/repository"
+)
+
+// The metadata of the operations imported from or exported to Gitea
+const (
+	// giteaUrlMetadataKey is the web URL of an issue or comment, on create and comment operations
+	giteaUrlMetadataKey = "gitea-url"
+	// giteaIdMetadataKey is the Gitea id of an issue or comment
+	giteaIdMetadataKey = "gitea-id"
+	// giteaCursorMetadataKey is set by the metadata operations recording the update time of
+	// the issue when it was last pulled, the latest one is the sync cursor of the ticket
+	giteaCursorMetadataKey = "gitea-synced"
+	// giteaStateMetadataKey is set with the sync cursor to the state of the issue when it was
+	// pulled, so that the ticket is only closed or reopened when the issue was since
+	giteaStateMetadataKey = "gitea-state"
+)
+
+// giteaLabelPrefix prefixes the Gitea labels of the issues in the labels of the tickets
+const giteaLabelPrefix = "gitea:"
+
+// giteaPageSize is the default maximum number of items of a response of the Gitea API
+const giteaPageSize = 50
+
+func init() {
+	Register(&Target{
+		Name: "gitea",
+		Params: []Param{
+			{Name: "project", Required: true, Description: "the Gitea repository of the issues, as owner/name"},
+			{Name: "workflow", Required: true, Default: "workflow:eng", Description: "the workflow of the imported tickets"},
+			{Name: "repo", Required: true, Description: "the repo label of the imported tickets"},
+		},
+		Pull: pullGitea,
+		Push: pushGitea,
+	})
+}
+
+func pullGitea(repo *cache.RepoCache, conf Config) (Result, error) {
+	client, err := repository.GetGiteaClient()
+	if err != nil {
+		return Result{}, err
+	}
+	return giteaPull(repo, client, conf, time.Now())
+}
+
+func pushGitea(repo *cache.RepoCache, conf Config) (Result, error) {
+	client, err := repository.GetGiteaClient()
+	if err != nil {
+		return Result{}, err
+	}
+	return giteaPush(repo, client, conf, time.Now())
+}
+
+// giteaProject returns the owner and the name of the Gitea repository of the bridge
+func giteaProject(conf Config) (string, string, error) {
+	owner, name, ok := strings.Cut(conf.Get("project"), "/")
+	if !ok || owner == "" || name == "" {
+		return "", "", fmt.Errorf("invalid Gitea project %q, expected owner/name", conf.Get("project"))
+	}
+	return owner, name, nil
+}
+
+// giteaCursor returns the update time and the state of the issue when the ticket was last pulled,
+// zero if never
+func giteaCursor(snap *bug.Snapshot) (time.Time, gitea.StateType) {
+	for i := len(snap.Operations) - 1; i >= 0; i-- {
+		op, ok := snap.Operations[i].(*bug.SetMetadataOperation)
+		if !ok {
+			continue
+		}
+		if value, ok := op.NewMetadata[giteaCursorMetadataKey]; ok {
+			cursor, _ := time.Parse(time.RFC3339, value)
+			return cursor, gitea.StateType(op.NewMetadata[giteaStateMetadataKey])
+		}
+	}
+	return time.Time{}, ""
+}
+
+// giteaImporter imports the issues of a Gitea repository
+type giteaImporter struct {
+	repo   *cache.RepoCache
+	client *gitea.Client
+	conf   Config
+	owner  string
+	name   string
+	// user runs the bridge, the changes of the issues are attributed to them as Gitea
+	// doesn't record who made them in the issues
+	user *cache.IdentityCache
+	now  time.Time
+}
+
+// giteaPull imports the issues updated since the sync cursor of their ticket
+func giteaPull(repo *cache.RepoCache, client *gitea.Client, conf Config, now time.Time) (Result, error) {
+	var result Result
+
+	owner, name, err := giteaProject(conf)
+	if err != nil {
+		return result, err
+	}
+	user, err := repo.GetUserIdentity()
+	if err != nil {
+		return result, err
+	}
+
+	gi := &giteaImporter{repo: repo, client: client, conf: conf, owner: owner, name: name, user: user, now: now}
+
+	for page := 1; ; page++ {
+		issues, _, err := client.ListRepoIssues(owner, name, gitea.ListIssueOption{
+			ListOptions: gitea.ListOptions{Page: page, PageSize: giteaPageSize},
+			State:       gitea.StateAll,
+			Type:        gitea.IssueTypeIssue,
+		})
+		if err != nil {
+			return result, err
+		}
+
+		for _, issue := range issues {
+			created, updated, err := gi.importIssue(issue)
+			if err != nil {
+				return result, errors.Wrapf(err, "issue #%d", issue.Index)
+			}
+
+			switch {
+			case created:
+				result.Created++
+			case updated:
+				result.Updated++
+			default:
+				result.Unchanged++
+			}
+		}
+
+		if len(issues) < giteaPageSize {
+			return result, nil
+		}
+	}
+}
+
+// identity returns the identity of the Gitea user, creating it if there isn't any with its id
+func (gi *giteaImporter) identity(user *gitea.User) (*cache.IdentityCache, error) {
+	// Deleted users are replaced by the ghost user, whose id can't be matched as it's the one
+	// of the identities without a Gitea id
+	if user == nil || user.ID <= 0 {
+		i, err := gi.repo.ResolveIdentityMatcher(func(excerpt *cache.IdentityExcerpt) bool {
+			return excerpt.Login == "ghost" && excerpt.ImmutableMetadata[OriginMetadataKey] == "gitea"
+		})
+		if err != identity.ErrIdentityNotExist {
+			return i, err
+		}
+		return gi.repo.NewIdentityRaw("Ghost", "", "ghost", "", map[string]string{OriginMetadataKey: "gitea"}, true, true, "")
+	}
+
+	i, err := gi.repo.ResolveIdentityGiteaID(user.ID)
+	if err == nil {
+		return gi.repo.ResolveIdentity(i.Id())
+	}
+	if err != identity.ErrIdentityNotExist {
+		return nil, err
+	}
+
+	name := user.FullName
+	if name == "" {
+		name = user.UserName
+	}
+	return gi.repo.NewIdentityWithGiteaIdRaw(name, user.Email, user.UserName, user.AvatarURL,
+		map[string]string{OriginMetadataKey: "gitea"}, user.ID)
+}
+
+// importIssue imports an issue as a ticket, or the changes made to it since its sync cursor
+func (gi *giteaImporter) importIssue(issue *gitea.Issue) (created bool, updated bool, err error) {
+	var cursor time.Time
+	var state gitea.StateType
+
+	b, err := gi.repo.ResolveBugCreateMetadata(giteaUrlMetadataKey, issue.HTMLURL)
+	switch {
+	case err == bug.ErrBugNotExist:
+		author, err := gi.identity(issue.Poster)
+		if err != nil {
+			return false, false, err
+		}
+
+		b, _, err = gi.repo.NewBugRaw(author, issue.Created.Unix(), cache.NewBugOpts{
+			Title:    issue.Title,
+			Message:  issue.Body,
+			Workflow: gi.conf.Get("workflow"),
+			Repo:     gi.conf.Get("repo"),
+		}, nil, map[string]string{
+			OriginMetadataKey:   "gitea",
+			giteaUrlMetadataKey: issue.HTMLURL,
+			giteaIdMetadataKey:  strconv.FormatInt(issue.ID, 10),
+		})
+		if err != nil {
+			return false, false, err
+		}
+		created = true
+	case err != nil:
+		return false, false, err
+	default:
+		cursor, state = giteaCursor(b.Snapshot())
+		if !issue.Updated.After(cursor) {
+			return false, false, nil
+		}
+	}
+
+	if err := gi.importComments(b, issue, cursor); err != nil {
+		return false, false, err
+	}
+	if err := gi.importFields(b, issue, state); err != nil {
+		return false, false, err
+	}
+
+	updated = b.NeedCommit()
+
+	_, err = b.SetMetadataRaw(gi.user, gi.now.Unix(), b.Snapshot().Operations[0].Id(), map[string]string{
+		giteaCursorMetadataKey: issue.Updated.UTC().Format(time.RFC3339),
+		giteaStateMetadataKey:  string(issue.State),
+	})
+	if err != nil {
+		return false, false, err
+	}
+
+	return created, updated && !created, b.CommitAsNeeded()
+}
+
+// importComments adds the comments of the issue updated since the cursor to the ticket, or the
+// edits of the ones already imported
+func (gi *giteaImporter) importComments(b *cache.BugCache, issue *gitea.Issue, cursor time.Time) error {
+	for page := 1; ; page++ {
+		comments, _, err := gi.client.ListIssueComments(gi.owner, gi.name, issue.Index, gitea.ListIssueCommentOptions{
+			ListOptions: gitea.ListOptions{Page: page, PageSize: giteaPageSize},
+			Since:       cursor,
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, c := range comments {
+			if err := gi.importComment(b, c); err != nil {
+				return errors.Wrapf(err, "comment %d", c.ID)
+			}
+		}
+
+		if len(comments) < giteaPageSize {
+			return nil
+		}
+	}
+}
+
+// importComment adds a comment of the issue to the ticket, or the edit of an imported comment
+func (gi *giteaImporter) importComment(b *cache.BugCache, c *gitea.Comment) error {
+	author, err := gi.identity(c.Poster)
+	if err != nil {
+		return err
+	}
+
+	target, err := b.ResolveOperationWithMetadata(giteaUrlMetadataKey, c.HTMLURL)
+	if err == cache.ErrNoMatchingOp {
+		_, err = b.AddCommentRaw(author, c.Created.Unix(), c.Body, nil, map[string]string{
+			giteaUrlMetadataKey: c.HTMLURL,
+			giteaIdMetadataKey:  strconv.FormatInt(c.ID, 10),
+		})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, comment := range b.Snapshot().Comments {
+		if comment.Id() == target && comment.Message != c.Body {
+			_, err = b.EditCommentRaw(author, c.Updated.Unix(), target, c.Body, nil)
+			return err
+		}
+	}
+	return nil
+}
+
+// importFields applies the title, description and labels of the issue to the ticket where they
+// differ, and its state if it changed since it was last pulled in the given one
+func (gi *giteaImporter) importFields(b *cache.BugCache, issue *gitea.Issue, pulledState gitea.StateType) error {
+	snap := b.Snapshot()
+	unixTime := issue.Updated.Unix()
+
+	if issue.Title != snap.Title {
+		if _, err := b.SetTitleRaw(gi.user, unixTime, issue.Title, nil); err != nil {
+			return err
+		}
+	}
+	if issue.Body != snap.Comments[0].Message {
+		if _, err := b.EditCreateCommentRaw(gi.user, unixTime, issue.Body, nil); err != nil {
+			return err
+		}
+	}
+
+	closed := issue.State == gitea.StateClosed
+	if issue.State != pulledState && closed != isClosedStatus(snap.Status) {
+		var err error
+		if closed {
+			if issue.Closed != nil {
+				unixTime = issue.Closed.Unix()
+			}
+			_, err = b.CloseRaw(gi.user, unixTime, nil)
+		} else {
+			_, err = b.OpenRaw(gi.user, unixTime, nil)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	labels := make(map[string]bool)
+	for _, l := range issue.Labels {
+		labels[giteaLabelPrefix+l.Name] = true
+	}
+	var added, removed []string
+	for l := range labels {
+		if !snap.HasLabel(bug.Label(l)) {
+			added = append(added, l)
+		}
+	}
+	for _, l := range snap.Labels {
+		if strings.HasPrefix(string(l), giteaLabelPrefix) && !labels[string(l)] {
+			removed = append(removed, string(l))
+		}
+	}
+	if len(added) > 0 || len(removed) > 0 {
+		sort.Strings(added)
+		if _, err := b.ForceChangeLabelsRaw(gi.user, unixTime, added, removed, nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// giteaPush closes or reopens the issues of the tickets imported from the project according to
+// their status, and posts the comments added to them
+func giteaPush(repo *cache.RepoCache, client *gitea.Client, conf Config, now time.Time) (Result, error) {
+	var result Result
+
+	owner, name, err := giteaProject(conf)
+	if err != nil {
+		return result, err
+	}
+	author, err := repo.GetUserIdentity()
+	if err != nil {
+		return result, err
+	}
+
+	for _, id := range repo.AllBugsIds() {
+		b, err := repo.ResolveBug(id)
+		if err != nil {
+			return result, err
+		}
+
+		snap := b.Snapshot()
+		issueUrl, ok := snap.Operations[0].GetMetadata(giteaUrlMetadataKey)
+		if !ok {
+			continue
+		}
+		number, ok := issueNumber(conf.Get("project"), issueUrl)
+		if !ok {
+			continue
+		}
+		index := int64(number)
+
+		issue, _, err := client.GetIssue(owner, name, index)
+		if err != nil {
+			return result, errors.Wrapf(err, "ticket %s", id.Human())
+		}
+
+		changed := false
+
+		state := gitea.StateOpen
+		if isClosedStatus(snap.Status) {
+			state = gitea.StateClosed
+		}
+		if issue.State != state {
+			if _, _, err := client.EditIssue(owner, name, index, gitea.EditIssueOption{State: &state}); err != nil {
+				return result, errors.Wrapf(err, "ticket %s", id.Human())
+			}
+			changed = true
+		}
+
+		posted, err := pushGiteaComments(client, b, author, owner, name, index, now)
+		if err != nil {
+			return result, errors.Wrapf(err, "ticket %s", id.Human())
+		}
+
+		if changed || posted {
+			result.Updated++
+		} else {
+			result.Unchanged++
+		}
+
+		if err := b.CommitAsNeeded(); err != nil {
+			return result, err
+		}
+	}
+
+	return result, nil
+}
+
+// pushGiteaComments posts the comments added to the ticket, returning true if there was any
+func pushGiteaComments(client *gitea.Client, b *cache.BugCache, author *cache.IdentityCache, owner string, name string, index int64, now time.Time) (bool, error) {
+	snap := b.Snapshot()
+
+	messages := make(map[entity.Id]string)
+	for _, c := range snap.Comments {
+		messages[c.Id()] = c.Message
+	}
+
+	posted := false
+
+	for _, op := range snap.Operations {
+		if _, ok := op.(*bug.AddCommentOperation); !ok {
+			continue
+		}
+		if _, ok := op.GetMetadata(giteaUrlMetadataKey); ok {
+			continue
+		}
+
+		comment, _, err := client.CreateIssueComment(owner, name, index, gitea.CreateIssueCommentOption{Body: messages[op.Id()]})
+		if err != nil {
+			return posted, err
+		}
+
+		_, err = b.SetMetadataRaw(author, now.Unix(), op.Id(), map[string]string{
+			giteaUrlMetadataKey: comment.HTMLURL,
+			giteaIdMetadataKey:  strconv.FormatInt(comment.ID, 10),
+		})
+		if err != nil {
+			return posted, err
+		}
+		posted = true
+	}
+
+	return posted, nil
+}
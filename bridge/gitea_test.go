@@ -0,0 +1,253 @@
+package bridge
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"code.gitea.io/sdk/gitea"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/daedaleanai/git-ticket/bug"
+)
+
+// fakeGitea is an in-memory Gitea repository serving the issues API
+type fakeGitea struct {
+	mu       sync.Mutex
+	project  string
+	nextId   int64
+	clock    time.Time
+	issues   []*gitea.Issue
+	comments map[int64][]*gitea.Comment
+}
+
+var (
+	fakeGiteaIssuePath   = regexp.MustCompile(`^/api/v1/repos/[^/]+/[^/]+/issues/(\d+)$`)
+	fakeGiteaCommentPath = regexp.MustCompile(`^/api/v1/repos/[^/]+/[^/]+/issues/(\d+)/comments$`)
+)
+
+func newFakeGitea(t *testing.T, clock time.Time) (*fakeGitea, *gitea.Client) {
+	fake := &fakeGitea{
+		project:  "acme/rocket",
+		nextId:   1000,
+		clock:    clock,
+		comments: make(map[int64][]*gitea.Comment),
+	}
+	server := httptest.NewServer(http.HandlerFunc(fake.serve))
+	t.Cleanup(server.Close)
+
+	client, err := gitea.NewClient(server.URL, gitea.SetToken("secret"), gitea.SetGiteaVersion(""))
+	require.NoError(t, err)
+	return fake, client
+}
+
+// tick advances the clock of the server by a minute and returns it
+func (f *fakeGitea) tick() time.Time {
+	f.clock = f.clock.Add(time.Minute)
+	return f.clock
+}
+
+func (f *fakeGitea) addIssue(user *gitea.User, title string, body string, labels ...string) *gitea.Issue {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.nextId++
+	now := f.tick()
+	issue := &gitea.Issue{
+		ID: f.nextId, Index: int64(len(f.issues) + 1), Poster: user, Title: title, Body: body,
+		State: gitea.StateOpen, Created: now, Updated: now,
+	}
+	issue.HTMLURL = fmt.Sprintf("https://gitea.example.com/%s/issues/%d", f.project, issue.Index)
+	for _, l := range labels {
+		issue.Labels = append(issue.Labels, &gitea.Label{Name: l})
+	}
+	f.issues = append(f.issues, issue)
+	return issue
+}
+
+func (f *fakeGitea) addComment(index int64, user *gitea.User, body string) *gitea.Comment {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.comment(index, user, body)
+}
+
+func (f *fakeGitea) comment(index int64, user *gitea.User, body string) *gitea.Comment {
+	f.nextId++
+	now := f.tick()
+	comment := &gitea.Comment{ID: f.nextId, Poster: user, Body: body, Created: now, Updated: now}
+	comment.HTMLURL = fmt.Sprintf("https://gitea.example.com/%s/issues/%d#issuecomment-%d", f.project, index, comment.ID)
+	f.comments[index] = append(f.comments[index], comment)
+	f.issues[index-1].Updated = now
+	return comment
+}
+
+func (f *fakeGitea) setState(index int64, state gitea.StateType) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	issue := f.issues[index-1]
+	issue.State, issue.Updated = state, f.tick()
+	if state == gitea.StateClosed {
+		issue.Closed = &issue.Updated
+	}
+}
+
+func (f *fakeGitea) serve(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if r.Header.Get("Authorization") != "token secret" {
+		http.Error(w, "bad credentials", http.StatusUnauthorized)
+		return
+	}
+
+	// Everything fits in the first page
+	if page := r.URL.Query().Get("page"); page != "" && page != "1" {
+		_ = json.NewEncoder(w).Encode([]interface{}{})
+		return
+	}
+
+	index := func(re *regexp.Regexp) int64 {
+		n, _ := strconv.ParseInt(re.FindStringSubmatch(r.URL.Path)[1], 10, 64)
+		return n
+	}
+
+	var out interface{}
+
+	switch {
+	case r.URL.Path == "/api/v1/repos/"+f.project+"/issues" && r.Method == http.MethodGet:
+		out = f.issues
+	case fakeGiteaIssuePath.MatchString(r.URL.Path):
+		issue := f.issues[index(fakeGiteaIssuePath)-1]
+		if r.Method == http.MethodPatch {
+			var in gitea.EditIssueOption
+			_ = json.NewDecoder(r.Body).Decode(&in)
+			if in.State != nil {
+				issue.State, issue.Updated = *in.State, f.tick()
+			}
+		}
+		out = issue
+	case fakeGiteaCommentPath.MatchString(r.URL.Path):
+		n := index(fakeGiteaCommentPath)
+		if r.Method == http.MethodPost {
+			var in gitea.CreateIssueCommentOption
+			_ = json.NewDecoder(r.Body).Decode(&in)
+			out = f.comment(n, &gitea.User{ID: 1, UserName: "carol"}, in.Body)
+			break
+		}
+
+		since, _ := time.Parse(time.RFC3339, r.URL.Query().Get("since"))
+		comments := []*gitea.Comment{}
+		for _, c := range f.comments[n] {
+			if !c.Updated.Before(since) {
+				comments = append(comments, c)
+			}
+		}
+		out = comments
+	}
+
+	if out == nil {
+		http.NotFound(w, r)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(out)
+}
+
+func TestGiteaPullPush(t *testing.T) {
+	repoCache := newBridgeTestCache(t)
+	day := time.Date(2021, 3, 4, 10, 0, 0, 0, time.UTC)
+	fake, client := newFakeGitea(t, day)
+	conf := NewConfig("gitea", map[string]string{"project": "acme/rocket", "workflow": "workflow:eng", "repo": "repo:rocket"})
+
+	alice := &gitea.User{ID: 7, UserName: "alice", FullName: "Alice"}
+	bob := &gitea.User{ID: 8, UserName: "bob"}
+	bobIdentity, err := repoCache.NewIdentityWithGiteaIdRaw("Bob", "bob@example.com", "", "", nil, 8)
+	require.NoError(t, err)
+
+	fake.addIssue(alice, "Engine explodes", "On ignition.", "bug")
+	fake.addComment(1, bob, "Can't reproduce")
+	fake.addIssue(alice, "Paint the fins", "Red.")
+	fake.setState(2, gitea.StateClosed)
+
+	result, err := giteaPull(repoCache, client, conf, day.Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, Result{Created: 2}, result)
+	require.Len(t, repoCache.AllBugsIds(), 2)
+
+	engine, err := repoCache.ResolveBugCreateMetadata(giteaUrlMetadataKey, fake.issues[0].HTMLURL)
+	require.NoError(t, err)
+	snap := engine.Snapshot()
+	assert.Equal(t, "Engine explodes", snap.Title)
+	assert.Equal(t, "On ignition.", snap.Comments[0].Message)
+	assert.Equal(t, "Alice", snap.Author.Name())
+	aliceIdentity, err := repoCache.ResolveIdentityGiteaID(7)
+	require.NoError(t, err)
+	assert.Equal(t, aliceIdentity.Id(), snap.Author.Id())
+	require.Len(t, snap.Comments, 2)
+	assert.Equal(t, bobIdentity.Id(), snap.Comments[1].Author.Id())
+	assert.True(t, snap.HasLabel("gitea:bug"))
+	assert.True(t, snap.HasLabel("repo:rocket"))
+	assert.Equal(t, bug.ProposedStatus, snap.Status)
+	assert.Equal(t, "gitea", snap.Operations[0].AllMetadata()[OriginMetadataKey])
+
+	fins, err := repoCache.ResolveBugCreateMetadata(giteaUrlMetadataKey, fake.issues[1].HTMLURL)
+	require.NoError(t, err)
+	assert.Equal(t, bug.MergedStatus, fins.Snapshot().Status)
+
+	// Nothing changed since the sync cursors, the issues are skipped
+	operations := len(engine.Snapshot().Operations)
+	result, err = giteaPull(repoCache, client, conf, day.Add(2*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, Result{Unchanged: 2}, result)
+	assert.Len(t, engine.Snapshot().Operations, operations)
+
+	// The ticket is closed and commented locally while the issue is commented on Gitea, pulling
+	// imports the comment but doesn't reopen the ticket
+	_, err = engine.Close()
+	require.NoError(t, err)
+	_, err = engine.AddComment("Fixed by the new igniter")
+	require.NoError(t, err)
+	require.NoError(t, engine.Commit())
+	fake.addComment(1, alice, "Happens on the test stand")
+
+	result, err = giteaPull(repoCache, client, conf, day.Add(3*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, Result{Updated: 1, Unchanged: 1}, result)
+	snap = engine.Snapshot()
+	assert.Equal(t, bug.MergedStatus, snap.Status)
+	require.Len(t, snap.Comments, 4)
+	assert.Equal(t, "Happens on the test stand", snap.Comments[3].Message)
+
+	result, err = giteaPush(repoCache, client, conf, day.Add(4*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, Result{Updated: 1, Unchanged: 1}, result)
+	assert.Equal(t, gitea.StateClosed, fake.issues[0].State)
+	require.Len(t, fake.comments[1], 3)
+	assert.Equal(t, "Fixed by the new igniter", fake.comments[1][2].Body)
+
+	// The closed issue is reopened and relabelled on Gitea, the exported changes aren't
+	// imported back
+	fake.setState(2, gitea.StateOpen)
+	fake.issues[1].Labels = []*gitea.Label{{Name: "cosmetic"}}
+
+	result, err = giteaPull(repoCache, client, conf, day.Add(5*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, Result{Updated: 1, Unchanged: 1}, result)
+	snap = fins.Snapshot()
+	assert.Equal(t, bug.ProposedStatus, snap.Status)
+	assert.True(t, snap.HasLabel("gitea:cosmetic"))
+	assert.Equal(t, bug.MergedStatus, engine.Snapshot().Status)
+	assert.Len(t, engine.Snapshot().Comments, 4)
+
+	result, err = giteaPush(repoCache, client, conf, day.Add(6*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, Result{Unchanged: 2}, result)
+}
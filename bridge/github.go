@@ -0,0 +1,591 @@
+package bridge
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/daedaleanai/git-ticket/bug"
+	"github.com/daedaleanai/git-ticket/bug/review"
+	"github.com/daedaleanai/git-ticket/cache"
+	"github.com/daedaleanai/git-ticket/entity"
+	"github.com/daedaleanai/git-ticket/identity"
+	"github.com/daedaleanai/git-ticket/repository"
+	"github.com/daedaleanai/git-ticket/util/rest"
+)
+
+// The metadata of the operations imported from or exported to GitHub
+const (
+	// githubUrlMetadataKey is the web URL of an issue or comment, on create and comment operations
+	githubUrlMetadataKey = "github-url"
+	// githubIdMetadataKey is the GitHub id of an issue or comment
+	githubIdMetadataKey = "github-id"
+	// githubEventMetadataKey is the id of the issue event an operation was imported from
+	githubEventMetadataKey = "github-event-id"
+)
+
+// githubLabelPrefix prefixes the GitHub labels of the issues in the labels of the tickets
+const githubLabelPrefix = "github:"
+
+func init() {
+	Register(&Target{
+		Name: "github",
+		Params: []Param{
+			{Name: "project", Required: true, Description: "the GitHub repository of the issues, as owner/name"},
+			{Name: "workflow", Required: true, Default: "workflow:eng", Description: "the workflow of the imported tickets"},
+			{Name: "repo", Required: true, Description: "the repo label of the imported tickets, the tickets with it are exported"},
+		},
+		Pull: pullGithub,
+		Push: pushGithub,
+	})
+}
+
+type githubUser struct {
+	Login     string `json:"login"`
+	AvatarUrl string `json:"avatar_url"`
+}
+
+type githubLabel struct {
+	Name string `json:"name"`
+}
+
+type githubIssue struct {
+	Id          int64         `json:"id"`
+	Number      int           `json:"number"`
+	Title       string        `json:"title"`
+	Body        string        `json:"body"`
+	User        githubUser    `json:"user"`
+	Labels      []githubLabel `json:"labels"`
+	Assignees   []githubUser  `json:"assignees"`
+	State       string        `json:"state"`
+	HtmlUrl     string        `json:"html_url"`
+	CreatedAt   time.Time     `json:"created_at"`
+	UpdatedAt   time.Time     `json:"updated_at"`
+	PullRequest *struct{}     `json:"pull_request,omitempty"`
+}
+
+type githubIssueComment struct {
+	Id        int64      `json:"id"`
+	HtmlUrl   string     `json:"html_url"`
+	User      githubUser `json:"user"`
+	Body      string     `json:"body"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+type githubIssueEvent struct {
+	Id        int64        `json:"id"`
+	Event     string       `json:"event"`
+	Actor     *githubUser  `json:"actor"`
+	CreatedAt time.Time    `json:"created_at"`
+	Label     *githubLabel `json:"label,omitempty"`
+	Assignee  *githubUser  `json:"assignee,omitempty"`
+	Rename    *struct {
+		From string `json:"from"`
+		To   string `json:"to"`
+	} `json:"rename,omitempty"`
+}
+
+// newGithubBridgeClient returns a client of the GitHub API configured by github.url and
+// github.api-token, as for the reviews
+func newGithubBridgeClient() (*rest.Client, error) {
+	githubUrl, apiToken, err := repository.GetGithubConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	return rest.NewClient(githubUrl, map[string]string{
+		"Accept":        "application/vnd.github+json",
+		"Authorization": "Bearer " + apiToken,
+	}), nil
+}
+
+func pullGithub(repo *cache.RepoCache, conf Config) (Result, error) {
+	client, err := newGithubBridgeClient()
+	if err != nil {
+		return Result{}, err
+	}
+	return githubPull(repo, client, conf, time.Now())
+}
+
+func pushGithub(repo *cache.RepoCache, conf Config) (Result, error) {
+	client, err := newGithubBridgeClient()
+	if err != nil {
+		return Result{}, err
+	}
+	return githubPush(repo, client, conf, time.Now())
+}
+
+// githubImporter imports the issues of a GitHub repository
+type githubImporter struct {
+	repo   *cache.RepoCache
+	client *rest.Client
+	conf   Config
+	// since is the time of the previous pull, zero if none
+	since time.Time
+}
+
+// githubPull imports the issues updated since the previous pull, which started at now
+func githubPull(repo *cache.RepoCache, client *rest.Client, conf Config, now time.Time) (Result, error) {
+	var result Result
+
+	gi := &githubImporter{repo: repo, client: client, conf: conf}
+
+	path := fmt.Sprintf("/repos/%s/issues?state=all&sort=updated&direction=asc", conf.Get("project"))
+	if since := conf.Get("pull-since"); since != "" {
+		var err error
+		if gi.since, err = time.Parse(time.RFC3339, since); err != nil {
+			return result, errors.Wrap(err, "invalid pull-since")
+		}
+		path += "&since=" + url.QueryEscape(since)
+	}
+
+	var issues []githubIssue
+	if err := client.GetList(path, &issues); err != nil {
+		return result, err
+	}
+
+	for _, issue := range issues {
+		// The pull requests are issues too for GitHub
+		if issue.PullRequest != nil {
+			continue
+		}
+
+		created, updated, err := gi.importIssue(issue)
+		if err != nil {
+			return result, errors.Wrapf(err, "issue #%d", issue.Number)
+		}
+
+		switch {
+		case created:
+			result.Created++
+		case updated:
+			result.Updated++
+		default:
+			result.Unchanged++
+		}
+	}
+
+	return result, conf.Store(repo, "pull-since", now.UTC().Format(time.RFC3339))
+}
+
+// identity returns the identity of the GitHub user, creating it if there isn't any with its login
+func (gi *githubImporter) identity(user *githubUser) (*cache.IdentityCache, error) {
+	// Deleted users are replaced by the ghost user
+	login, avatarUrl := "ghost", ""
+	if user != nil && user.Login != "" {
+		login, avatarUrl = user.Login, user.AvatarUrl
+	}
+
+	i, err := gi.repo.ResolveIdentityMetadata(review.GithubLoginMetadataKey, login)
+	if err != identity.ErrIdentityNotExist {
+		if err != nil {
+			return nil, err
+		}
+		return gi.repo.ResolveIdentity(i.Id())
+	}
+
+	return gi.repo.NewIdentityRaw(login, "", login, avatarUrl,
+		map[string]string{review.GithubLoginMetadataKey: login}, true, true, "")
+}
+
+// importIssue imports an issue as a ticket, or the changes made to it since it was imported
+func (gi *githubImporter) importIssue(issue githubIssue) (created bool, updated bool, err error) {
+	issuePath := fmt.Sprintf("/repos/%s/issues/%d", gi.conf.Get("project"), issue.Number)
+
+	var comments []githubIssueComment
+	if err := gi.client.GetList(issuePath+"/comments", &comments); err != nil {
+		return false, false, err
+	}
+	var events []githubIssueEvent
+	if err := gi.client.GetList(issuePath+"/events", &events); err != nil {
+		return false, false, err
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].CreatedAt.Before(events[j].CreatedAt)
+	})
+
+	author, err := gi.identity(&issue.User)
+	if err != nil {
+		return false, false, err
+	}
+
+	b, err := gi.repo.ResolveBugCreateMetadata(githubUrlMetadataKey, issue.HtmlUrl)
+	switch {
+	case err == bug.ErrBugNotExist:
+		// The title the issue was created with is the one it had before the first rename
+		title := issue.Title
+		for _, e := range events {
+			if e.Event == "renamed" && e.Rename != nil {
+				title = e.Rename.From
+				break
+			}
+		}
+
+		b, _, err = gi.repo.NewBugRaw(author, issue.CreatedAt.Unix(), cache.NewBugOpts{
+			Title:    title,
+			Message:  issue.Body,
+			Workflow: gi.conf.Get("workflow"),
+			Repo:     gi.conf.Get("repo"),
+		}, nil, map[string]string{
+			OriginMetadataKey:    "github",
+			githubUrlMetadataKey: issue.HtmlUrl,
+			githubIdMetadataKey:  strconv.FormatInt(issue.Id, 10),
+		})
+		if err != nil {
+			return false, false, err
+		}
+		created = true
+	case err != nil:
+		return false, false, err
+	default:
+		if b.Snapshot().Comments[0].Message != issue.Body {
+			if _, err := b.EditCreateCommentRaw(author, issue.UpdatedAt.Unix(), issue.Body, nil); err != nil {
+				return false, false, err
+			}
+		}
+	}
+
+	for _, c := range comments {
+		if err := gi.importComment(b, c); err != nil {
+			return false, false, errors.Wrapf(err, "comment %d", c.Id)
+		}
+	}
+
+	for _, e := range events {
+		// Events from before the previous pull which weren't imported were skipped as they
+		// didn't change the ticket, they must not be applied over the later changes
+		if !created && !gi.since.IsZero() && e.CreatedAt.Before(gi.since) {
+			continue
+		}
+		if err := gi.importEvent(b, e); err != nil {
+			return false, false, errors.Wrapf(err, "event %d", e.Id)
+		}
+	}
+
+	updated = b.NeedCommit()
+	return created, updated && !created, b.CommitAsNeeded()
+}
+
+// importComment adds a comment of the issue to the ticket, or the edit of an imported comment
+func (gi *githubImporter) importComment(b *cache.BugCache, c githubIssueComment) error {
+	author, err := gi.identity(&c.User)
+	if err != nil {
+		return err
+	}
+
+	target, err := b.ResolveOperationWithMetadata(githubUrlMetadataKey, c.HtmlUrl)
+	if err == cache.ErrNoMatchingOp {
+		_, err = b.AddCommentRaw(author, c.CreatedAt.Unix(), c.Body, nil, map[string]string{
+			githubUrlMetadataKey: c.HtmlUrl,
+			githubIdMetadataKey:  strconv.FormatInt(c.Id, 10),
+		})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, comment := range b.Snapshot().Comments {
+		if comment.Id() == target && comment.Message != c.Body {
+			_, err = b.EditCommentRaw(author, c.UpdatedAt.Unix(), target, c.Body, nil)
+			return err
+		}
+	}
+	return nil
+}
+
+// importEvent applies an event of the issue to the ticket, unless it was already imported or
+// the ticket is already in the state it results in, e.g. because the event was exported from it
+func (gi *githubImporter) importEvent(b *cache.BugCache, e githubIssueEvent) error {
+	_, err := b.ResolveOperationWithMetadata(githubEventMetadataKey, strconv.FormatInt(e.Id, 10))
+	if err == nil {
+		return nil
+	}
+	if err != cache.ErrNoMatchingOp {
+		return err
+	}
+
+	switch e.Event {
+	case "closed", "reopened", "renamed", "labeled", "unlabeled", "assigned":
+	default:
+		return nil
+	}
+
+	author, err := gi.identity(e.Actor)
+	if err != nil {
+		return err
+	}
+	metadata := map[string]string{githubEventMetadataKey: strconv.FormatInt(e.Id, 10)}
+	unixTime := e.CreatedAt.Unix()
+	snap := b.Snapshot()
+
+	switch e.Event {
+	case "closed":
+		if !isClosedStatus(snap.Status) {
+			_, err = b.CloseRaw(author, unixTime, metadata)
+		}
+	case "reopened":
+		if isClosedStatus(snap.Status) {
+			_, err = b.OpenRaw(author, unixTime, metadata)
+		}
+	case "renamed":
+		if e.Rename != nil && e.Rename.To != snap.Title {
+			_, err = b.SetTitleRaw(author, unixTime, e.Rename.To, metadata)
+		}
+	case "labeled":
+		if e.Label != nil && !snap.HasLabel(bug.Label(githubLabelPrefix+e.Label.Name)) {
+			_, err = b.ForceChangeLabelsRaw(author, unixTime, []string{githubLabelPrefix + e.Label.Name}, nil, metadata)
+		}
+	case "unlabeled":
+		if e.Label != nil && snap.HasLabel(bug.Label(githubLabelPrefix+e.Label.Name)) {
+			_, err = b.ForceChangeLabelsRaw(author, unixTime, nil, []string{githubLabelPrefix + e.Label.Name}, metadata)
+		}
+	case "assigned":
+		if e.Assignee == nil {
+			return nil
+		}
+		assignee, err := gi.identity(e.Assignee)
+		if err != nil {
+			return err
+		}
+		if snap.Assignee == nil || snap.Assignee.Id() != assignee.Id() {
+			_, err = b.SetAssigneeRaw(author, unixTime, metadata, assignee)
+		}
+		return err
+	}
+
+	return err
+}
+
+// isClosedStatus returns true if the status is the end of the workflow, which is a closed issue
+func isClosedStatus(status bug.Status) bool {
+	return status == bug.MergedStatus || status == bug.DoneStatus || status == bug.RejectedStatus
+}
+
+// githubIssueState returns the fields of an issue the ticket is exported to
+func githubIssueState(repo *cache.RepoCache, snap *bug.Snapshot) map[string]interface{} {
+	state := "open"
+	if isClosedStatus(snap.Status) {
+		state = "closed"
+	}
+
+	labels := []string{}
+	for _, l := range snap.Labels {
+		if strings.HasPrefix(string(l), githubLabelPrefix) {
+			labels = append(labels, strings.TrimPrefix(string(l), githubLabelPrefix))
+		}
+	}
+	sort.Strings(labels)
+
+	fields := map[string]interface{}{
+		"title":  snap.Title,
+		"body":   snap.Comments[0].Message,
+		"state":  state,
+		"labels": labels,
+	}
+
+	if snap.Assignee != nil {
+		if excerpt, err := repo.ResolveIdentityExcerpt(snap.Assignee.Id()); err == nil && excerpt.MutableMetadata[review.GithubLoginMetadataKey] != "" {
+			fields["assignees"] = []string{excerpt.MutableMetadata[review.GithubLoginMetadataKey]}
+		}
+	}
+
+	return fields
+}
+
+// githubPush creates issues for the new tickets with the repo label of the bridge, and updates
+// the issues of the tickets imported from or exported to the project with their changes
+func githubPush(repo *cache.RepoCache, client *rest.Client, conf Config, now time.Time) (Result, error) {
+	var result Result
+
+	author, err := repo.GetUserIdentity()
+	if err != nil {
+		return result, err
+	}
+
+	project := conf.Get("project")
+
+	for _, id := range repo.AllBugsIds() {
+		b, err := repo.ResolveBug(id)
+		if err != nil {
+			return result, err
+		}
+
+		snap := b.Snapshot()
+		createMetadata := snap.Operations[0].AllMetadata()
+		fields := githubIssueState(repo, snap)
+
+		var issue githubIssue
+		created, patched := false, false
+
+		if issueUrl, ok := createMetadata[githubUrlMetadataKey]; ok {
+			number, ok := issueNumber(project, issueUrl)
+			if !ok {
+				continue
+			}
+			if err := client.Get(fmt.Sprintf("/repos/%s/issues/%d", project, number), &issue); err != nil {
+				return result, errors.Wrapf(err, "ticket %s", id.Human())
+			}
+
+			changes := githubIssueChanges(issue, fields)
+			if len(changes) > 0 {
+				if err := client.Do(http.MethodPatch, fmt.Sprintf("/repos/%s/issues/%d", project, number), changes, &issue); err != nil {
+					return result, errors.Wrapf(err, "ticket %s", id.Human())
+				}
+				patched = true
+			}
+		} else {
+			if createMetadata[OriginMetadataKey] != "" || !snap.HasLabel(bug.Label(conf.Get("repo"))) {
+				continue
+			}
+
+			// The state can only be set by an update
+			state := fields["state"]
+			delete(fields, "state")
+			if err := client.Do(http.MethodPost, fmt.Sprintf("/repos/%s/issues", project), fields, &issue); err != nil {
+				return result, errors.Wrapf(err, "ticket %s", id.Human())
+			}
+			if state != issue.State {
+				if err := client.Do(http.MethodPatch, fmt.Sprintf("/repos/%s/issues/%d", project, issue.Number), map[string]interface{}{"state": state}, &issue); err != nil {
+					return result, errors.Wrapf(err, "ticket %s", id.Human())
+				}
+			}
+
+			_, err = b.SetMetadataRaw(author, now.Unix(), snap.Operations[0].Id(), map[string]string{
+				OriginMetadataKey:    "github",
+				githubUrlMetadataKey: issue.HtmlUrl,
+				githubIdMetadataKey:  strconv.FormatInt(issue.Id, 10),
+			})
+			if err != nil {
+				return result, err
+			}
+			created = true
+		}
+
+		commentsChanged, err := pushGithubComments(client, b, author, project, issue.Number, now)
+		if err != nil {
+			return result, errors.Wrapf(err, "ticket %s", id.Human())
+		}
+
+		switch {
+		case created:
+			result.Created++
+		case patched || commentsChanged:
+			result.Updated++
+		default:
+			result.Unchanged++
+		}
+
+		if err := b.CommitAsNeeded(); err != nil {
+			return result, err
+		}
+	}
+
+	return result, nil
+}
+
+// githubIssueChanges returns the fields of the issue which differ from the ones of the ticket
+func githubIssueChanges(issue githubIssue, fields map[string]interface{}) map[string]interface{} {
+	changes := make(map[string]interface{})
+
+	if issue.Title != fields["title"] {
+		changes["title"] = fields["title"]
+	}
+	if issue.Body != fields["body"] {
+		changes["body"] = fields["body"]
+	}
+	if issue.State != fields["state"] {
+		changes["state"] = fields["state"]
+	}
+
+	labels := []string{}
+	for _, l := range issue.Labels {
+		labels = append(labels, l.Name)
+	}
+	sort.Strings(labels)
+	if strings.Join(labels, "\n") != strings.Join(fields["labels"].([]string), "\n") {
+		changes["labels"] = fields["labels"]
+	}
+
+	if assignees, ok := fields["assignees"]; ok {
+		assigned := false
+		for _, a := range issue.Assignees {
+			assigned = assigned || strings.EqualFold(a.Login, assignees.([]string)[0])
+		}
+		if !assigned {
+			changes["assignees"] = assignees
+		}
+	}
+
+	return changes
+}
+
+// pushGithubComments posts the comments added to the ticket and updates the ones edited,
+// returning true if any was
+func pushGithubComments(client *rest.Client, b *cache.BugCache, author *cache.IdentityCache, project string, number int, now time.Time) (bool, error) {
+	snap := b.Snapshot()
+
+	var remote []githubIssueComment
+	if err := client.GetList(fmt.Sprintf("/repos/%s/issues/%d/comments", project, number), &remote); err != nil {
+		return false, err
+	}
+	bodies := make(map[string]string)
+	for _, c := range remote {
+		bodies[c.HtmlUrl] = c.Body
+	}
+
+	messages := make(map[entity.Id]string)
+	for _, c := range snap.Comments {
+		messages[c.Id()] = c.Message
+	}
+
+	changed := false
+
+	for _, op := range snap.Operations {
+		if _, ok := op.(*bug.AddCommentOperation); !ok {
+			continue
+		}
+		message := messages[op.Id()]
+
+		commentUrl, exported := op.GetMetadata(githubUrlMetadataKey)
+		if !exported {
+			var comment githubIssueComment
+			err := client.Do(http.MethodPost, fmt.Sprintf("/repos/%s/issues/%d/comments", project, number),
+				map[string]string{"body": message}, &comment)
+			if err != nil {
+				return changed, err
+			}
+
+			_, err = b.SetMetadataRaw(author, now.Unix(), op.Id(), map[string]string{
+				githubUrlMetadataKey: comment.HtmlUrl,
+				githubIdMetadataKey:  strconv.FormatInt(comment.Id, 10),
+			})
+			if err != nil {
+				return changed, err
+			}
+			changed = true
+			continue
+		}
+
+		body, ok := bodies[commentUrl]
+		if !ok || body == message {
+			continue
+		}
+		commentId, _ := op.GetMetadata(githubIdMetadataKey)
+		err := client.Do(http.MethodPatch, fmt.Sprintf("/repos/%s/issues/comments/%s", project, commentId),
+			map[string]string{"body": message}, nil)
+		if err != nil {
+			return changed, err
+		}
+		changed = true
+	}
+
+	return changed, nil
+}
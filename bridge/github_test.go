@@ -0,0 +1,325 @@
+package bridge
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/daedaleanai/git-ticket/bug"
+	"github.com/daedaleanai/git-ticket/bug/review"
+	"github.com/daedaleanai/git-ticket/cache"
+	"github.com/daedaleanai/git-ticket/config"
+	"github.com/daedaleanai/git-ticket/repository"
+	"github.com/daedaleanai/git-ticket/util/rest"
+)
+
+// fakeGithub is an in-memory GitHub repository serving the issues API
+type fakeGithub struct {
+	mu       sync.Mutex
+	project  string
+	nextId   int64
+	issues   []*githubIssue
+	comments map[int][]*githubIssueComment
+	events   map[int][]githubIssueEvent
+}
+
+var (
+	fakeIssuePath   = regexp.MustCompile(`^/repos/[^/]+/[^/]+/issues/(\d+)$`)
+	fakeCommentPath = regexp.MustCompile(`^/repos/[^/]+/[^/]+/issues/(\d+)/comments$`)
+	fakeEventPath   = regexp.MustCompile(`^/repos/[^/]+/[^/]+/issues/(\d+)/events$`)
+	fakeEditPath    = regexp.MustCompile(`^/repos/[^/]+/[^/]+/issues/comments/(\d+)$`)
+)
+
+func newFakeGithub(t *testing.T) (*fakeGithub, *rest.Client) {
+	fake := &fakeGithub{
+		project:  "acme/rocket",
+		nextId:   1000,
+		comments: make(map[int][]*githubIssueComment),
+		events:   make(map[int][]githubIssueEvent),
+	}
+	server := httptest.NewServer(http.HandlerFunc(fake.serve))
+	t.Cleanup(server.Close)
+
+	return fake, rest.NewClient(server.URL, map[string]string{"Authorization": "Bearer secret"})
+}
+
+func (f *fakeGithub) id() int64 {
+	f.nextId++
+	return f.nextId
+}
+
+func (f *fakeGithub) addIssue(user string, title string, body string, created time.Time) *githubIssue {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	issue := &githubIssue{
+		Id: f.id(), Number: len(f.issues) + 1, Title: title, Body: body, User: githubUser{Login: user},
+		Labels: []githubLabel{}, State: "open", CreatedAt: created, UpdatedAt: created,
+	}
+	issue.HtmlUrl = fmt.Sprintf("https://github.com/%s/issues/%d", f.project, issue.Number)
+	f.issues = append(f.issues, issue)
+	return issue
+}
+
+func (f *fakeGithub) addComment(number int, user string, body string, created time.Time) *githubIssueComment {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	comment := &githubIssueComment{Id: f.id(), User: githubUser{Login: user}, Body: body, CreatedAt: created, UpdatedAt: created}
+	comment.HtmlUrl = fmt.Sprintf("https://github.com/%s/issues/%d#issuecomment-%d", f.project, number, comment.Id)
+	f.comments[number] = append(f.comments[number], comment)
+	return comment
+}
+
+func (f *fakeGithub) addEvent(number int, e githubIssueEvent) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	e.Id = f.id()
+	f.events[number] = append(f.events[number], e)
+}
+
+func (f *fakeGithub) serve(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if r.Header.Get("Authorization") != "Bearer secret" {
+		http.Error(w, "bad credentials", http.StatusUnauthorized)
+		return
+	}
+
+	// Everything fits in the first page
+	if page := r.URL.Query().Get("page"); page != "" && page != "1" {
+		_ = json.NewEncoder(w).Encode([]interface{}{})
+		return
+	}
+
+	var in map[string]interface{}
+	if r.Body != nil {
+		_ = json.NewDecoder(r.Body).Decode(&in)
+	}
+
+	number := func(re *regexp.Regexp) int {
+		n, _ := strconv.Atoi(re.FindStringSubmatch(r.URL.Path)[1])
+		return n
+	}
+
+	var out interface{}
+
+	switch {
+	case r.URL.Path == "/repos/"+f.project+"/issues" && r.Method == http.MethodGet:
+		out = f.issues
+	case r.URL.Path == "/repos/"+f.project+"/issues" && r.Method == http.MethodPost:
+		issue := &githubIssue{Id: f.id(), Number: len(f.issues) + 1, State: "open", User: githubUser{Login: "carol"}, CreatedAt: time.Now()}
+		issue.HtmlUrl = fmt.Sprintf("https://github.com/%s/issues/%d", f.project, issue.Number)
+		f.issues = append(f.issues, issue)
+		f.update(issue, in)
+		out = issue
+	case fakeIssuePath.MatchString(r.URL.Path):
+		issue := f.issues[number(fakeIssuePath)-1]
+		if r.Method == http.MethodPatch {
+			f.update(issue, in)
+		}
+		out = issue
+	case fakeCommentPath.MatchString(r.URL.Path):
+		n := number(fakeCommentPath)
+		if r.Method == http.MethodPost {
+			comment := &githubIssueComment{Id: f.id(), User: githubUser{Login: "carol"}, Body: in["body"].(string), CreatedAt: time.Now()}
+			comment.HtmlUrl = fmt.Sprintf("https://github.com/%s/issues/%d#issuecomment-%d", f.project, n, comment.Id)
+			f.comments[n] = append(f.comments[n], comment)
+			out = comment
+		} else {
+			out = f.comments[n]
+		}
+	case fakeEventPath.MatchString(r.URL.Path):
+		out = f.events[number(fakeEventPath)]
+	case fakeEditPath.MatchString(r.URL.Path) && r.Method == http.MethodPatch:
+		id := int64(number(fakeEditPath))
+		for _, comments := range f.comments {
+			for _, c := range comments {
+				if c.Id == id {
+					c.Body = in["body"].(string)
+					out = c
+				}
+			}
+		}
+	}
+
+	if out == nil {
+		http.NotFound(w, r)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(out)
+}
+
+// update sets the fields of the issue sent in a request
+func (f *fakeGithub) update(issue *githubIssue, in map[string]interface{}) {
+	for key, value := range in {
+		switch key {
+		case "title":
+			issue.Title = value.(string)
+		case "body":
+			issue.Body = value.(string)
+		case "state":
+			issue.State = value.(string)
+		case "labels":
+			issue.Labels = []githubLabel{}
+			for _, l := range value.([]interface{}) {
+				issue.Labels = append(issue.Labels, githubLabel{Name: l.(string)})
+			}
+		case "assignees":
+			issue.Assignees = nil
+			for _, a := range value.([]interface{}) {
+				issue.Assignees = append(issue.Assignees, githubUser{Login: a.(string)})
+			}
+		}
+	}
+}
+
+func newBridgeTestCache(t *testing.T) *cache.RepoCache {
+	repo := repository.CreateTestRepo(false)
+	t.Cleanup(func() { repository.CleanupTestRepos(repo) })
+
+	repository.SetupSigningKey(t, repo, "carol@example.com")
+
+	repoCache, err := cache.NewRepoCache(repo, false)
+	require.NoError(t, err)
+
+	carol, err := repoCache.NewIdentityRaw("Carol", "carol@example.com", "", "",
+		map[string]string{"github-login": "carol"}, true, true, "")
+	require.NoError(t, err)
+	require.NoError(t, repoCache.SetUserIdentity(carol))
+
+	require.NoError(t, repoCache.DoWithLockedConfigCache(func(c *config.ConfigCache) error {
+		if err := c.LabelConfig.AppendLabelToConfiguration(config.Label("repo:rocket")); err != nil {
+			return err
+		}
+		return c.LabelConfig.Store(repo)
+	}))
+
+	return repoCache
+}
+
+func TestGithubPullPush(t *testing.T) {
+	repoCache := newBridgeTestCache(t)
+	fake, client := newFakeGithub(t)
+	conf := NewConfig("github", map[string]string{"project": "acme/rocket", "workflow": "workflow:eng", "repo": "repo:rocket"})
+
+	day := time.Date(2021, 3, 4, 10, 0, 0, 0, time.UTC)
+	issue := fake.addIssue("alice", "Engine explodes", "On ignition.", day)
+	fake.addComment(1, "bob", "Can't reproduce", day.Add(time.Hour))
+	fake.addEvent(1, githubIssueEvent{Event: "renamed", Actor: &githubUser{Login: "alice"}, CreatedAt: day.Add(2 * time.Hour),
+		Rename: &struct {
+			From string `json:"from"`
+			To   string `json:"to"`
+		}{From: "Engine explodes", To: "Engine explodes on ignition"}})
+	fake.addEvent(1, githubIssueEvent{Event: "labeled", Actor: &githubUser{Login: "bob"}, CreatedAt: day.Add(3 * time.Hour), Label: &githubLabel{Name: "bug"}})
+	fake.addEvent(1, githubIssueEvent{Event: "assigned", Actor: &githubUser{Login: "bob"}, CreatedAt: day.Add(3 * time.Hour), Assignee: &githubUser{Login: "bob"}})
+	fake.addEvent(1, githubIssueEvent{Event: "closed", Actor: &githubUser{Login: "bob"}, CreatedAt: day.Add(4 * time.Hour)})
+	issue.Title, issue.State = "Engine explodes on ignition", "closed"
+
+	pr := fake.addIssue("alice", "Fix engine", "", day)
+	pr.PullRequest = &struct{}{}
+
+	result, err := githubPull(repoCache, client, conf, day.Add(5*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, Result{Created: 1}, result)
+	require.Len(t, repoCache.AllBugsIds(), 1)
+
+	b, err := repoCache.ResolveBugCreateMetadata(githubUrlMetadataKey, issue.HtmlUrl)
+	require.NoError(t, err)
+	snap := b.Snapshot()
+	assert.Equal(t, "Engine explodes on ignition", snap.Title)
+	assert.Equal(t, "On ignition.", snap.Comments[0].Message)
+	assert.Equal(t, "alice", snap.Author.Login())
+	require.Len(t, snap.Comments, 2)
+	assert.Equal(t, "Can't reproduce", snap.Comments[1].Message)
+	assert.True(t, snap.HasLabel("github:bug"))
+	assert.True(t, snap.HasLabel("repo:rocket"))
+	require.NotNil(t, snap.Assignee)
+	assert.Equal(t, "bob", snap.Assignee.Login())
+	assert.Equal(t, bug.MergedStatus, snap.Status)
+	assert.Equal(t, "github", snap.Operations[0].AllMetadata()[OriginMetadataKey])
+
+	bob, err := repoCache.ResolveIdentityMetadata(review.GithubLoginMetadataKey, "bob")
+	require.NoError(t, err)
+	assert.Equal(t, snap.Assignee.Id(), bob.Id())
+
+	// Pulling everything again doesn't duplicate anything
+	operations := len(snap.Operations)
+	result, err = githubPull(repoCache, client, NewConfig("github", map[string]string{"project": "acme/rocket", "workflow": "workflow:eng", "repo": "repo:rocket"}), day.Add(5*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, Result{Unchanged: 1}, result)
+	assert.Len(t, b.Snapshot().Operations, operations)
+
+	// A comment is edited and another added on GitHub
+	fake.comments[1][0].Body = "Can't reproduce on master"
+	fake.comments[1][0].UpdatedAt = day.Add(6 * time.Hour)
+	fake.addComment(1, "alice", "Happens on the test stand", day.Add(6*time.Hour))
+
+	result, err = githubPull(repoCache, client, conf, day.Add(7*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, Result{Updated: 1}, result)
+	snap = b.Snapshot()
+	require.Len(t, snap.Comments, 3)
+	assert.Equal(t, "Can't reproduce on master", snap.Comments[1].Message)
+	assert.Equal(t, "Happens on the test stand", snap.Comments[2].Message)
+
+	// Local changes are exported: a comment and a new title on the imported ticket, and a new ticket
+	_, err = b.AddComment("Fixed by the new igniter")
+	require.NoError(t, err)
+	_, err = b.SetTitle("Engine explodes on ignition at low temperature")
+	require.NoError(t, err)
+	require.NoError(t, b.Commit())
+
+	local, _, err := repoCache.NewBug(cache.NewBugOpts{Title: "Add a parachute", Message: "For the landing.", Workflow: "workflow:eng", Repo: "repo:rocket"})
+	require.NoError(t, err)
+	_, err = local.ForceChangeLabels([]string{"github:feature"}, nil)
+	require.NoError(t, err)
+	require.NoError(t, local.Commit())
+
+	result, err = githubPush(repoCache, client, conf, day.Add(8*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, Result{Created: 1, Updated: 1}, result)
+
+	assert.Equal(t, "Engine explodes on ignition at low temperature", fake.issues[0].Title)
+	assert.Equal(t, "closed", fake.issues[0].State)
+	require.Len(t, fake.comments[1], 3)
+	assert.Equal(t, "Fixed by the new igniter", fake.comments[1][2].Body)
+
+	require.Len(t, fake.issues, 3)
+	created := fake.issues[2]
+	assert.Equal(t, "Add a parachute", created.Title)
+	assert.Equal(t, "For the landing.", created.Body)
+	assert.Equal(t, []githubLabel{{Name: "feature"}}, created.Labels)
+	assert.Equal(t, "open", created.State)
+	found, err := repoCache.ResolveBugCreateMetadata(githubUrlMetadataKey, created.HtmlUrl)
+	require.NoError(t, err)
+	assert.Equal(t, local.Id(), found.Id())
+
+	// A local edit of an exported comment is exported too
+	_, err = b.EditComment(b.Snapshot().Comments[3].Id(), "Fixed by the new igniter, see the test report")
+	require.NoError(t, err)
+	require.NoError(t, b.Commit())
+
+	result, err = githubPush(repoCache, client, conf, day.Add(9*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, Result{Updated: 1, Unchanged: 1}, result)
+	assert.Equal(t, "Fixed by the new igniter, see the test report", fake.comments[1][2].Body)
+
+	// Pulling the exported changes back doesn't duplicate them
+	result, err = githubPull(repoCache, client, conf, day.Add(10*time.Hour))
+	require.NoError(t, err)
+	assert.Len(t, repoCache.AllBugsIds(), 2)
+	assert.Len(t, b.Snapshot().Comments, 4)
+	assert.Len(t, local.Snapshot().Comments, 1)
+}
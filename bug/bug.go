@@ -68,6 +68,9 @@ type Bug struct {
 	// a temporary pack of operations used for convenience to pile up new operations
 	// before a commit
 	staging OperationPack
+
+	// the operations the last merge brought from the other version of the bug
+	merged []Operation
 }
 
 // NewBug create a new Bug
@@ -151,18 +154,24 @@ func PeekLocalBugEditTime(repo repository.ClockedRepo, id entity.Id) (time.Time,
 
 // ReadLocalBug will read a local bug from its hash
 func ReadLocalBug(repo repository.ClockedRepo, id entity.Id) (*Bug, error) {
+	return ReadLocalBugWithResolver(repo, identity.NewSimpleResolver(repo), id)
+}
+
+// ReadLocalBugWithResolver will read a local bug from its hash, loading its identities
+// with the given resolver
+func ReadLocalBugWithResolver(repo repository.ClockedRepo, resolver identity.Resolver, id entity.Id) (*Bug, error) {
 	ref := bugsRefPattern + id.String()
-	return readBug(repo, ref)
+	return readBug(repo, resolver, ref)
 }
 
 // ReadRemoteBug will read a remote bug from its hash
 func ReadRemoteBug(repo repository.ClockedRepo, remote string, id entity.Id) (*Bug, error) {
 	ref := fmt.Sprintf(bugsRemoteRefPattern, remote) + id.String()
-	return readBug(repo, ref)
+	return readBug(repo, identity.NewSimpleResolver(repo), ref)
 }
 
 // readBug will read and parse a Bug from git
-func readBug(repo repository.ClockedRepo, ref string) (*Bug, error) {
+func readBug(repo repository.ClockedRepo, resolver identity.Resolver, ref string) (*Bug, error) {
 	refSplit := strings.Split(ref, "/")
 	id := entity.Id(refSplit[len(refSplit)-1])
 
@@ -275,12 +284,12 @@ func readBug(repo repository.ClockedRepo, ref string) (*Bug, error) {
 
 		// tag the pack with the commit hash
 		opp.commitHash = hash
+		opp.editTime = lamport.Time(editTime)
 
 		bug.packs = append(bug.packs, *opp)
 	}
 
 	// Make sure that the identities are properly loaded
-	resolver := identity.NewSimpleResolver(repo)
 	err = bug.EnsureIdentities(resolver)
 	if err != nil {
 		return nil, err
@@ -428,8 +437,10 @@ func readAllBugs(repo repository.ClockedRepo, refPrefix string) <-chan StreamedB
 			return
 		}
 
+		resolver := identity.NewSimpleResolver(repo)
+
 		for _, ref := range refs {
-			b, err := readBug(repo, ref)
+			b, err := readBug(repo, resolver, ref)
 
 			if err != nil {
 				out <- StreamedBug{Err: err}
@@ -649,6 +660,7 @@ func (bug *Bug) Commit(repo repository.ClockedRepo) error {
 	}
 
 	bug.staging.commitHash = hash
+	bug.staging.editTime = bug.editTime
 	bug.packs = append(bug.packs, bug.staging)
 	bug.staging = OperationPack{}
 
@@ -666,6 +678,16 @@ func (bug *Bug) NeedCommit() bool {
 	return !bug.staging.IsEmpty()
 }
 
+// StagedOperations returns the operations not committed yet
+func (bug *Bug) StagedOperations() []Operation {
+	return bug.staging.Operations
+}
+
+// DiscardStaging drops the operations not committed yet
+func (bug *Bug) DiscardStaging() {
+	bug.staging = OperationPack{}
+}
+
 func makeMediaTree(pack OperationPack) []repository.TreeEntry {
 	var tree []repository.TreeEntry
 	counter := 0
@@ -737,6 +759,7 @@ func (bug *Bug) Merge(repo repository.Repo, other Interface) (bool, error) {
 	}
 
 	var remoteIncludesStatusChange bool
+	var merged []Operation
 
 	// get other bug's extra packs
 	for i := ancestorIndex + 1; i < len(otherBug.packs); i++ {
@@ -748,6 +771,7 @@ func (bug *Bug) Merge(repo repository.Repo, other Interface) (bool, error) {
 		}
 
 		newPacks = append(newPacks, newPack)
+		merged = append(merged, newPack.Operations...)
 		bug.lastCommit = newPack.commitHash
 	}
 
@@ -783,6 +807,7 @@ func (bug *Bug) Merge(repo repository.Repo, other Interface) (bool, error) {
 	}
 
 	bug.packs = newPacks
+	bug.merged = merged
 
 	// Update the git ref
 	err = repo.UpdateRef(bugsRefPattern+bug.id.String(), bug.lastCommit)
@@ -793,6 +818,12 @@ func (bug *Bug) Merge(repo repository.Repo, other Interface) (bool, error) {
 	return true, nil
 }
 
+// MergedOperations returns the operations the last Merge brought from the other version of the
+// bug
+func (bug *Bug) MergedOperations() []Operation {
+	return bug.merged
+}
+
 // Id return the Bug identifier
 func (bug *Bug) Id() entity.Id {
 	if bug.id == "" {
@@ -849,6 +880,33 @@ func (bug *Bug) LastOp() Operation {
 	return lastPack.Operations[len(lastPack.Operations)-1]
 }
 
+// OperationCommit returns the hash of the git commit which stored the given operation.
+// It returns false if the operation is unknown or not committed yet.
+func (bug *Bug) OperationCommit(id entity.Id) (repository.Hash, bool) {
+	for _, pack := range bug.packs {
+		for _, op := range pack.Operations {
+			if op.Id() == id {
+				return pack.commitHash, true
+			}
+		}
+	}
+
+	return "", false
+}
+
+// OperationsSince returns the committed operations stored with an edit lamport time after the
+// given one
+func (bug *Bug) OperationsSince(time lamport.Time) []Operation {
+	var result []Operation
+	for _, pack := range bug.packs {
+		if pack.editTime > time {
+			result = append(result, pack.Operations...)
+		}
+	}
+
+	return result
+}
+
 // Compile a bug in a easily usable snapshot
 func (bug *Bug) Compile() Snapshot {
 	snap := Snapshot{
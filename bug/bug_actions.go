@@ -5,6 +5,7 @@ import (
 	"path"
 
 	"github.com/daedaleanai/git-ticket/entity"
+	"github.com/daedaleanai/git-ticket/identity"
 	"github.com/daedaleanai/git-ticket/repository"
 	"github.com/pkg/errors"
 )
@@ -32,6 +33,8 @@ func MergeAll(repo repository.ClockedRepo, remote string) <-chan entity.MergeRes
 			return
 		}
 
+		resolver := identity.NewSimpleResolver(repo)
+
 		for _, remoteRef := range remoteRefs {
 			hashes, err := repo.CommitsBetween(bugsRefPattern+path.Base(remoteRef), remoteRef)
 			if err == nil && hashes == nil {
@@ -48,7 +51,7 @@ func MergeAll(repo repository.ClockedRepo, remote string) <-chan entity.MergeRes
 				continue
 			}
 
-			remoteBug, err := readBug(repo, remoteRef)
+			remoteBug, err := readBug(repo, resolver, remoteRef)
 
 			if err != nil {
 				out <- entity.NewMergeInvalidStatus(id, errors.Wrap(err, "remote bug is not readable").Error())
@@ -82,7 +85,7 @@ func MergeAll(repo repository.ClockedRepo, remote string) <-chan entity.MergeRes
 				continue
 			}
 
-			localBug, err := readBug(repo, localRef)
+			localBug, err := readBug(repo, resolver, localRef)
 
 			if err != nil {
 				out <- entity.NewMergeError(errors.Wrap(err, "local bug is not readable"), id)
@@ -178,3 +178,63 @@ func TestBugRemove(t *testing.T) {
 	require.NoError(t, err)
 	require.Len(t, ids, 100)
 }
+
+func TestBugOperationCommit(t *testing.T) {
+	bug1 := NewBug()
+
+	rene := identity.NewIdentity("René Descartes", "rene@descartes.fr")
+	createOp := NewCreateOp(rene, time.Now().Unix(), "title", "message", nil)
+	addCommentOp := NewAddCommentOp(rene, time.Now().Unix(), "message2", nil)
+
+	repo := repository.NewMockRepoForTest()
+
+	bug1.Append(createOp)
+	err := bug1.Commit(repo)
+	assert.NoError(t, err)
+
+	bug1.Append(addCommentOp)
+	_, found := bug1.OperationCommit(addCommentOp.Id())
+	assert.False(t, found)
+
+	err = bug1.Commit(repo)
+	assert.NoError(t, err)
+
+	commits, err := repo.ListCommits(bugsRefPattern + bug1.Id().String())
+	assert.NoError(t, err)
+	assert.Len(t, commits, 2)
+
+	commit, found := bug1.OperationCommit(createOp.Id())
+	assert.True(t, found)
+	assert.Equal(t, commits[0], commit)
+
+	commit, found = bug1.OperationCommit(addCommentOp.Id())
+	assert.True(t, found)
+	assert.Equal(t, commits[1], commit)
+}
+
+func TestBugOperationsSince(t *testing.T) {
+	bug1 := NewBug()
+
+	rene := identity.NewIdentity("René Descartes", "rene@descartes.fr")
+	createOp := NewCreateOp(rene, time.Now().Unix(), "title", "message", nil)
+	addCommentOp := NewAddCommentOp(rene, time.Now().Unix(), "message2", nil)
+
+	repo := repository.NewMockRepoForTest()
+
+	bug1.Append(createOp)
+	require.NoError(t, bug1.Commit(repo))
+	created := bug1.EditLamportTime()
+
+	bug1.Append(addCommentOp)
+	assert.Empty(t, bug1.OperationsSince(created))
+
+	require.NoError(t, bug1.Commit(repo))
+	assert.Equal(t, []Operation{createOp, addCommentOp}, bug1.OperationsSince(0))
+	assert.Equal(t, []Operation{addCommentOp}, bug1.OperationsSince(created))
+
+	// the times are read back from git
+	bug2, err := ReadLocalBug(repo, bug1.Id())
+	require.NoError(t, err)
+	require.Len(t, bug2.OperationsSince(created), 1)
+	assert.Equal(t, addCommentOp.Id(), bug2.OperationsSince(created)[0].Id())
+}
@@ -1,6 +1,9 @@
 package bug
 
 import (
+	"fmt"
+
+	"github.com/daedaleanai/git-ticket/entity"
 	"github.com/daedaleanai/git-ticket/identity"
 	"github.com/daedaleanai/git-ticket/util/colors"
 )
@@ -24,6 +27,18 @@ type CcbInfo struct {
 	State  CcbState           // The state of the approval
 }
 
+// CcbSignature is a detached PGP signature made by an approver over the ticket id,
+// the approved status and the hash of the ticket snapshot the approver has seen
+type CcbSignature struct {
+	SnapshotHash string `json:"snapshot"`
+	Armored      string `json:"signature"`
+}
+
+// CcbApprovalPayload returns the data signed by an approver when approving a ticket status
+func CcbApprovalPayload(id entity.Id, status Status, snapshotHash string) []byte {
+	return []byte(fmt.Sprintf("git-ticket ccb approval\nticket: %s\nstatus: %s\nsnapshot: %s\n", id, status, snapshotHash))
+}
+
 // CcbInfoByStatus provides functions to fulfill the sort interface
 type CcbInfoByStatus []CcbInfo
 
@@ -0,0 +1,169 @@
+package bug
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/daedaleanai/git-ticket/config"
+)
+
+// ChecklistStateCounts counts the answers given to a question by state
+type ChecklistStateCounts struct {
+	Passed        int `json:"passed"`
+	Failed        int `json:"failed"`
+	NotApplicable int `json:"na"`
+	TBD           int `json:"tbd"`
+}
+
+func (c *ChecklistStateCounts) add(state config.ChecklistState) {
+	switch state {
+	case config.Passed:
+		c.Passed++
+	case config.Failed:
+		c.Failed++
+	case config.NotApplicable:
+		c.NotApplicable++
+	default:
+		c.TBD++
+	}
+}
+
+// Total returns the number of answers counted
+func (c ChecklistStateCounts) Total() int {
+	return c.Passed + c.Failed + c.NotApplicable + c.TBD
+}
+
+// ChecklistMonthStats counts the answers given during a month, formatted as 2006-01
+type ChecklistMonthStats struct {
+	Month string `json:"month"`
+	ChecklistStateCounts
+}
+
+// ChecklistCommentCount is a comment and the number of answers it was given to
+type ChecklistCommentCount struct {
+	Comment string `json:"comment"`
+	Count   int    `json:"count"`
+}
+
+// ChecklistQuestionStats aggregates the answers given to a question
+type ChecklistQuestionStats struct {
+	Section  string `json:"section"`
+	Question string `json:"question"`
+	ChecklistStateCounts
+	Months   []ChecklistMonthStats   `json:"months"`
+	Comments []ChecklistCommentCount `json:"comments"`
+}
+
+// ChecklistStats aggregates the answers given to a checklist across tickets
+type ChecklistStats struct {
+	Label Label `json:"label"`
+	// Tickets is the number of tickets carrying the checklist
+	Tickets int `json:"tickets"`
+	// Reviews is the number of reviewers answers, a ticket can be reviewed by several people
+	Reviews   int                      `json:"reviews"`
+	Questions []ChecklistQuestionStats `json:"questions"`
+}
+
+// ComputeChecklistStats aggregates the answers to the checklist with the label of the template
+// given in the snapshots, keeping the maxComments most common comments of each question.
+// Questions are identified by their section and text, so answers to older versions of the
+// checklist are accounted with the current ones when the question didn't change. Questions
+// which don't apply to a ticket are ignored.
+func ComputeChecklistStats(template config.Checklist, snaps []*Snapshot, maxComments int) ChecklistStats {
+	label := Label(template.Label)
+	stats := ChecklistStats{Label: label}
+
+	type questionKey struct {
+		section, question string
+	}
+
+	questions := make(map[questionKey]*ChecklistQuestionStats)
+	months := make(map[questionKey]map[string]*ChecklistMonthStats)
+	comments := make(map[questionKey]map[string]*ChecklistCommentCount)
+	var order []questionKey
+
+	addQuestion := func(key questionKey) *ChecklistQuestionStats {
+		if q, present := questions[key]; present {
+			return q
+		}
+		questions[key] = &ChecklistQuestionStats{Section: key.section, Question: key.question}
+		months[key] = make(map[string]*ChecklistMonthStats)
+		comments[key] = make(map[string]*ChecklistCommentCount)
+		order = append(order, key)
+		return questions[key]
+	}
+
+	// The questions of the current template come first, in order
+	for _, s := range template.Sections {
+		for _, q := range s.Questions {
+			addQuestion(questionKey{s.Title, q.Question})
+		}
+	}
+
+	for _, snap := range snaps {
+		if !snap.HasLabel(label) {
+			continue
+		}
+		stats.Tickets++
+
+		for _, cl := range snap.Checklists[label] {
+			stats.Reviews++
+			month := cl.LastEdit.Format("2006-01")
+
+			for _, s := range snap.EvaluateChecklist(cl.Checklist).Sections {
+				for _, q := range s.Questions {
+					if s.Hidden || q.Hidden {
+						continue
+					}
+
+					key := questionKey{s.Title, q.Question}
+					addQuestion(key).add(q.State)
+
+					if months[key][month] == nil {
+						months[key][month] = &ChecklistMonthStats{Month: month}
+					}
+					months[key][month].add(q.State)
+
+					comment := strings.TrimSpace(q.Comment)
+					if comment == "" {
+						continue
+					}
+					// Comments differing only by case are the same
+					normalized := strings.ToLower(comment)
+					if comments[key][normalized] == nil {
+						comments[key][normalized] = &ChecklistCommentCount{Comment: comment}
+					}
+					comments[key][normalized].Count++
+				}
+			}
+		}
+	}
+
+	for _, key := range order {
+		q := questions[key]
+
+		for _, m := range months[key] {
+			q.Months = append(q.Months, *m)
+		}
+		sort.Slice(q.Months, func(i, j int) bool {
+			return q.Months[i].Month < q.Months[j].Month
+		})
+
+		for _, c := range comments[key] {
+			q.Comments = append(q.Comments, *c)
+		}
+		sort.Slice(q.Comments, func(i, j int) bool {
+			if q.Comments[i].Count != q.Comments[j].Count {
+				return q.Comments[i].Count > q.Comments[j].Count
+			}
+			return q.Comments[i].Comment < q.Comments[j].Comment
+		})
+		if len(q.Comments) > maxComments {
+			q.Comments = q.Comments[:maxComments]
+		}
+
+		stats.Questions = append(stats.Questions, *q)
+	}
+
+	return stats
+}
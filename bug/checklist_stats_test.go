@@ -0,0 +1,85 @@
+package bug
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/daedaleanai/git-ticket/config"
+	"github.com/daedaleanai/git-ticket/entity"
+)
+
+func TestComputeChecklistStats(t *testing.T) {
+	template := config.Checklist{
+		Label: "checklist:XYZ",
+		Sections: []config.ChecklistSection{
+			{Title: "ABC", Questions: []config.ChecklistQuestion{{Question: "1?"}, {Question: "2?"}}},
+		},
+	}
+
+	answered := func(edit time.Time, state1, state2 config.ChecklistState, comment string) ChecklistSnapshot {
+		cl := template.Copy()
+		cl.Sections[0].Questions[0].State = state1
+		cl.Sections[0].Questions[0].Comment = comment
+		cl.Sections[0].Questions[1].State = state2
+		return ChecklistSnapshot{Checklist: cl, LastEdit: edit}
+	}
+
+	march := time.Date(2021, 3, 4, 12, 0, 0, 0, time.UTC)
+	april := time.Date(2021, 4, 5, 12, 0, 0, 0, time.UTC)
+
+	// an older version of the checklist had a different question
+	older := answered(march, config.Passed, config.Passed, "")
+	older.Sections[0].Questions[1].Question = "old 2?"
+
+	snaps := []*Snapshot{
+		{
+			Labels: []Label{"checklist:XYZ"},
+			Checklists: map[Label]map[entity.Id]ChecklistSnapshot{"checklist:XYZ": {
+				"123": answered(march, config.Failed, config.Passed, "No tests"),
+				"456": answered(april, config.Failed, config.NotApplicable, "no tests "),
+			}},
+		},
+		{
+			Labels: []Label{"checklist:XYZ"},
+			Checklists: map[Label]map[entity.Id]ChecklistSnapshot{"checklist:XYZ": {
+				"123": older,
+			}},
+		},
+		{
+			Labels: []Label{"checklist:XYZ"},
+		},
+		{
+			// the checklist was removed from the ticket
+			Checklists: map[Label]map[entity.Id]ChecklistSnapshot{"checklist:XYZ": {
+				"123": answered(april, config.Failed, config.Failed, ""),
+			}},
+		},
+	}
+
+	stats := ComputeChecklistStats(template, snaps, 1)
+
+	assert.Equal(t, Label("checklist:XYZ"), stats.Label)
+	assert.Equal(t, 3, stats.Tickets)
+	assert.Equal(t, 3, stats.Reviews)
+	require.Len(t, stats.Questions, 3)
+
+	q1 := stats.Questions[0]
+	assert.Equal(t, "1?", q1.Question)
+	assert.Equal(t, ChecklistStateCounts{Passed: 1, Failed: 2}, q1.ChecklistStateCounts)
+	assert.Equal(t, []ChecklistMonthStats{
+		{Month: "2021-03", ChecklistStateCounts: ChecklistStateCounts{Passed: 1, Failed: 1}},
+		{Month: "2021-04", ChecklistStateCounts: ChecklistStateCounts{Failed: 1}},
+	}, q1.Months)
+	assert.Len(t, q1.Comments, 1)
+	assert.Equal(t, 2, q1.Comments[0].Count)
+
+	assert.Equal(t, "2?", stats.Questions[1].Question)
+	assert.Equal(t, ChecklistStateCounts{Passed: 1, NotApplicable: 1}, stats.Questions[1].ChecklistStateCounts)
+	assert.Empty(t, stats.Questions[1].Comments)
+
+	assert.Equal(t, "old 2?", stats.Questions[2].Question)
+	assert.Equal(t, ChecklistStateCounts{Passed: 1}, stats.Questions[2].ChecklistStateCounts)
+}
@@ -2,15 +2,20 @@ package bug
 
 import (
 	"fmt"
+	"regexp"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/daedaleanai/git-ticket/config"
+	"github.com/daedaleanai/git-ticket/entity"
 )
 
 type ChecklistSnapshot struct {
 	config.Checklist
 	LastEdit time.Time
+	// OperationId is the id of the operation which recorded the checklist
+	OperationId entity.Id
 }
 
 func StateFromString(str string) (config.ChecklistState, error) {
@@ -28,3 +33,153 @@ func StateFromString(str string) (config.ChecklistState, error) {
 
 	return 0, fmt.Errorf("unknown state")
 }
+
+// ChecklistAnswer is the answer given to a checklist question, in the form used to
+// complete checklists from files or the command line
+type ChecklistAnswer struct {
+	State   string `json:"state" yaml:"state"`
+	Value   string `json:"value,omitempty" yaml:"value,omitempty"`
+	Comment string `json:"comment,omitempty" yaml:"comment,omitempty"`
+}
+
+// ChecklistAnswers holds answers by checklist label, section and question. Sections and
+// questions are referenced either by their title and text, or by their number.
+type ChecklistAnswers map[Label]map[string]map[string]ChecklistAnswer
+
+// ExportChecklistAnswers returns the answers of all the visible questions of the given checklists
+func ExportChecklistAnswers(checklists map[Label]config.Checklist) ChecklistAnswers {
+	answers := make(ChecklistAnswers)
+
+	for label, cl := range checklists {
+		answers[label] = make(map[string]map[string]ChecklistAnswer)
+		for _, s := range cl.Sections {
+			if s.Hidden {
+				continue
+			}
+			answers[label][s.Title] = make(map[string]ChecklistAnswer)
+			for _, q := range s.Questions {
+				if q.Hidden {
+					continue
+				}
+				answers[label][s.Title][q.Question] = ChecklistAnswer{
+					State:   q.State.String(),
+					Value:   q.Value,
+					Comment: q.Comment,
+				}
+			}
+		}
+	}
+
+	return answers
+}
+
+// ParseChecklistAnswer parses an answer given as "section/question=state:comment", where the
+// state may be followed by a value in braces, as in "coverage/ratio=passed{85}:measured by CI".
+func ParseChecklistAnswer(spec string) (string, string, ChecklistAnswer, error) {
+	answerRegex := regexp.MustCompile(`^(\w+)(?:\{(.*?)\})?(?::([\s\S]*))?$`)
+
+	sep := strings.Index(spec, "/")
+	if sep <= 0 {
+		return "", "", ChecklistAnswer{}, fmt.Errorf("invalid answer %q, expected section/question=state:comment", spec)
+	}
+	section, rest := spec[:sep], spec[sep+1:]
+
+	// The question text may contain '=', the answer starts at the first one followed by a state
+	for i := strings.Index(rest, "="); i >= 0; {
+		if matches := answerRegex.FindStringSubmatch(rest[i+1:]); matches != nil && i > 0 {
+			if _, err := StateFromString(matches[1]); err == nil {
+				return section, rest[:i], ChecklistAnswer{State: matches[1], Value: matches[2], Comment: matches[3]}, nil
+			}
+		}
+
+		next := strings.Index(rest[i+1:], "=")
+		if next < 0 {
+			break
+		}
+		i += next + 1
+	}
+
+	return "", "", ChecklistAnswer{}, fmt.Errorf("invalid answer %q, expected section/question=state:comment", spec)
+}
+
+// ApplyChecklistAnswers sets the answers on the checklist and validates them against the
+// question types. If all is set every visible question of the checklist must be answered. All the
+// unknown, missing or invalid answers are reported in the returned error.
+func ApplyChecklistAnswers(cl *config.Checklist, answers map[string]map[string]ChecklistAnswer, all bool) error {
+	var errs []string
+	answered := make(map[*config.ChecklistQuestion]bool)
+
+	// References are handled in a stable order so that errors are reported consistently
+	sectionRefs := make([]string, 0, len(answers))
+	for sectionRef := range answers {
+		sectionRefs = append(sectionRefs, sectionRef)
+	}
+	sort.Strings(sectionRefs)
+
+	for _, sectionRef := range sectionRefs {
+		questions := answers[sectionRef]
+		sn := cl.SectionIndex(sectionRef)
+		if sn < 0 {
+			errs = append(errs, fmt.Sprintf("unknown section %q", sectionRef))
+			continue
+		}
+		section := &cl.Sections[sn]
+
+		questionRefs := make([]string, 0, len(questions))
+		for questionRef := range questions {
+			questionRefs = append(questionRefs, questionRef)
+		}
+		sort.Strings(questionRefs)
+
+		for _, questionRef := range questionRefs {
+			answer := questions[questionRef]
+			qn := section.QuestionIndex(questionRef)
+			if qn < 0 {
+				errs = append(errs, fmt.Sprintf("unknown question %q in section %q", questionRef, section.Title))
+				continue
+			}
+			question := &section.Questions[qn]
+			if question.Hidden {
+				errs = append(errs, fmt.Sprintf("question %d.%d: %q does not apply to the ticket", sn+1, qn+1, question.Question))
+				continue
+			}
+
+			// An empty state would otherwise match any state by prefix
+			if strings.TrimSpace(answer.State) == "" {
+				errs = append(errs, fmt.Sprintf("question %d.%d: missing state for %q", sn+1, qn+1, question.Question))
+				continue
+			}
+			state, err := StateFromString(answer.State)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("question %d.%d: invalid state %q", sn+1, qn+1, answer.State))
+				continue
+			}
+
+			question.State = state
+			question.Value = strings.TrimSpace(answer.Value)
+			question.Comment = answer.Comment
+			answered[question] = true
+
+			if err := question.ValidateAnswer(); err != nil {
+				errs = append(errs, fmt.Sprintf("question %d.%d: %s", sn+1, qn+1, err))
+			}
+		}
+	}
+
+	if all {
+		for sn := range cl.Sections {
+			for qn := range cl.Sections[sn].Questions {
+				if !cl.Sections[sn].Questions[qn].Hidden && !answered[&cl.Sections[sn].Questions[qn]] {
+					errs = append(errs, fmt.Sprintf("question %d.%d: missing answer to %q in section %q",
+						sn+1, qn+1, cl.Sections[sn].Questions[qn].Question, cl.Sections[sn].Title))
+				}
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid answers to %s:\n%s", cl.Label, strings.Join(errs, "\n"))
+	}
+
+	return nil
+}
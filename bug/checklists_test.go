@@ -38,3 +38,86 @@ func TestChecklists_ChecklistCompoundState(t *testing.T) {
 	testChecklist.Sections[0].Questions[2].State = config.Failed
 	assert.Equal(t, testChecklist.CompoundState(), config.Failed)
 }
+
+func TestChecklists_ParseChecklistAnswer(t *testing.T) {
+	section, question, answer, err := ParseChecklistAnswer("Design/Is the design documented?=passed:see the wiki")
+	assert.NoError(t, err)
+	assert.Equal(t, "Design", section)
+	assert.Equal(t, "Is the design documented?", question)
+	assert.Equal(t, ChecklistAnswer{State: "passed", Comment: "see the wiki"}, answer)
+
+	section, question, answer, err = ParseChecklistAnswer("2/Is coverage >= 80%?=p{85.5}:x=y")
+	assert.NoError(t, err)
+	assert.Equal(t, "2", section)
+	assert.Equal(t, "Is coverage >= 80%?", question)
+	assert.Equal(t, ChecklistAnswer{State: "p", Value: "85.5", Comment: "x=y"}, answer)
+
+	_, _, answer, err = ParseChecklistAnswer("1/1=na")
+	assert.NoError(t, err)
+	assert.Equal(t, ChecklistAnswer{State: "na"}, answer)
+
+	_, _, _, err = ParseChecklistAnswer("no section=passed")
+	assert.Error(t, err)
+	_, _, _, err = ParseChecklistAnswer("1/1=unknown")
+	assert.Error(t, err)
+}
+
+func TestChecklists_ApplyChecklistAnswers(t *testing.T) {
+	template := config.Checklist{Label: "XYZ",
+		Title: "XYZ Checklist",
+		Sections: []config.ChecklistSection{
+			{Title: "ABC",
+				Questions: []config.ChecklistQuestion{
+					{Question: "1?"},
+					{Question: "2?", Type: config.TextQuestion},
+				},
+			},
+		},
+	}
+
+	cl := template.Copy()
+	err := ApplyChecklistAnswers(&cl, map[string]map[string]ChecklistAnswer{
+		"ABC": {
+			"1?": {State: "passed", Comment: "fine"},
+			"2":  {State: "passed", Value: "test report"},
+		},
+	}, true)
+	assert.NoError(t, err)
+	assert.Equal(t, config.Passed, cl.CompoundState())
+	assert.Equal(t, "fine", cl.Sections[0].Questions[0].Comment)
+	assert.Equal(t, "test report", cl.Sections[0].Questions[1].Value)
+	assert.Equal(t, config.TBD, template.Sections[0].Questions[0].State)
+
+	// missing, unknown and invalid answers
+	cl = template.Copy()
+	err = ApplyChecklistAnswers(&cl, map[string]map[string]ChecklistAnswer{
+		"ABC": {
+			"2?": {State: "passed"},
+			"3?": {State: "passed"},
+		},
+		"DEF": {
+			"1?": {State: "passed"},
+		},
+	}, true)
+	assert.EqualError(t, err, `invalid answers to XYZ:
+question 1.2: an evidence is required
+unknown question "3?" in section "ABC"
+unknown section "DEF"
+question 1.1: missing answer to "1?" in section "ABC"`)
+
+	// partial answers are accepted when not all are required
+	cl = template.Copy()
+	err = ApplyChecklistAnswers(&cl, map[string]map[string]ChecklistAnswer{
+		"1": {"1": {State: "failed", Comment: "broken"}},
+	}, false)
+	assert.NoError(t, err)
+	assert.Equal(t, config.Failed, cl.CompoundState())
+
+	// an answer without state is rejected rather than reset to TBD
+	cl = template.Copy()
+	err = ApplyChecklistAnswers(&cl, map[string]map[string]ChecklistAnswer{
+		"1": {"1": {Comment: "looked at it"}},
+	}, false)
+	assert.EqualError(t, err, `invalid answers to XYZ:
+question 1.1: missing state for "1?"`)
+}
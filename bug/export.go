@@ -0,0 +1,195 @@
+package bug
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/daedaleanai/git-ticket/entity"
+	"github.com/daedaleanai/git-ticket/repository"
+	"github.com/daedaleanai/git-ticket/util/lamport"
+)
+
+// ExportedPack holds an OperationPack of a bug with everything needed to store the exact same
+// git commit again in another repository, so that the bug keeps its id and its history.
+type ExportedPack struct {
+	// Commit is the hash of the git commit of the pack
+	Commit repository.Hash `json:"commit"`
+	// CommitData is the raw git commit, with its author, date and signature
+	CommitData []byte `json:"commit_data"`
+	// CreateTime is only set on the first pack
+	CreateTime lamport.Time    `json:"create_time,omitempty"`
+	EditTime   lamport.Time    `json:"edit_time"`
+	Operations json.RawMessage `json:"ops"`
+	// Media are the files referenced by the operations, by hash
+	Media map[repository.Hash][]byte `json:"media,omitempty"`
+}
+
+// Export reads all the OperationPacks of a local bug as stored in git
+func Export(repo repository.ClockedRepo, id entity.Id) ([]ExportedPack, error) {
+	hashes, err := repo.ListCommits(bugsRefPattern + id.String())
+	if err != nil {
+		return nil, ErrBugNotExist
+	}
+
+	var packs []ExportedPack
+
+	for _, hash := range hashes {
+		pack := ExportedPack{Commit: hash}
+
+		pack.CommitData, err = repo.ReadCommitData(hash)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read git commit")
+		}
+
+		entries, err := repo.ReadTree(hash)
+		if err != nil {
+			return nil, errors.Wrap(err, "can't list git tree entries")
+		}
+
+		for _, entry := range entries {
+			switch {
+			case entry.Name == opsEntryName:
+				pack.Operations, err = repo.ReadData(entry.Hash)
+				if err != nil {
+					return nil, errors.Wrap(err, "failed to read git blob data")
+				}
+
+			case entry.Name == mediaEntryName:
+				pack.Media, err = readMedia(repo, entry.Hash)
+				if err != nil {
+					return nil, err
+				}
+
+			case strings.HasPrefix(entry.Name, createClockEntryPrefix):
+				if _, err := fmt.Sscanf(entry.Name, createClockEntryPattern, &pack.CreateTime); err != nil {
+					return nil, errors.Wrap(err, "can't read create lamport time")
+				}
+
+			case strings.HasPrefix(entry.Name, editClockEntryPrefix):
+				if _, err := fmt.Sscanf(entry.Name, editClockEntryPattern, &pack.EditTime); err != nil {
+					return nil, errors.Wrap(err, "can't read edit lamport time")
+				}
+			}
+		}
+
+		if pack.Operations == nil {
+			return nil, errors.New("invalid tree, missing the ops entry")
+		}
+
+		packs = append(packs, pack)
+	}
+
+	return packs, nil
+}
+
+func readMedia(repo repository.Repo, treeHash repository.Hash) (map[repository.Hash][]byte, error) {
+	entries, err := repo.ReadTree(treeHash)
+	if err != nil {
+		return nil, errors.Wrap(err, "can't list git tree entries")
+	}
+
+	media := make(map[repository.Hash][]byte)
+	for _, entry := range entries {
+		media[entry.Hash], err = repo.ReadData(entry.Hash)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read git blob data")
+		}
+	}
+
+	return media, nil
+}
+
+// Import stores the exported OperationPacks of a bug, and creates its ref or fast-forwards it if
+// the local bug is behind. The git commits are stored exactly as they were exported, or not at all.
+func Import(repo repository.ClockedRepo, id entity.Id, packs []ExportedPack) (entity.MergeStatus, error) {
+	if len(packs) == 0 {
+		return entity.MergeStatusInvalid, errors.New("bug has no operations")
+	}
+	if packs[0].Commit.String() != id.String() {
+		return entity.MergeStatusInvalid, fmt.Errorf("bug id should be the first commit hash")
+	}
+
+	emptyBlobHash, err := repo.StoreData([]byte{})
+	if err != nil {
+		return entity.MergeStatusError, err
+	}
+
+	var rootPack repository.Hash
+
+	for _, pack := range packs {
+		opp := &OperationPack{}
+		if err := json.Unmarshal(pack.Operations, &opp); err != nil {
+			return entity.MergeStatusInvalid, errors.Wrap(err, "failed to decode OperationPack json")
+		}
+
+		hash, err := repo.StoreData(pack.Operations)
+		if err != nil {
+			return entity.MergeStatusError, err
+		}
+		if rootPack == "" {
+			rootPack = hash
+		}
+
+		tree := []repository.TreeEntry{
+			{ObjectType: repository.Blob, Hash: hash, Name: opsEntryName},
+			{ObjectType: repository.Blob, Hash: rootPack, Name: rootEntryName},
+			{ObjectType: repository.Blob, Hash: emptyBlobHash, Name: fmt.Sprintf(editClockEntryPattern, pack.EditTime)},
+		}
+		if pack.CreateTime != 0 {
+			tree = append(tree, repository.TreeEntry{
+				ObjectType: repository.Blob,
+				Hash:       emptyBlobHash,
+				Name:       fmt.Sprintf(createClockEntryPattern, pack.CreateTime),
+			})
+		}
+
+		for hash, data := range pack.Media {
+			stored, err := repo.StoreData(data)
+			if err != nil {
+				return entity.MergeStatusError, err
+			}
+			if stored != hash {
+				return entity.MergeStatusInvalid, fmt.Errorf("media %s doesn't match its content", hash)
+			}
+		}
+
+		mediaTree := makeMediaTree(*opp)
+		if len(mediaTree) > 0 {
+			mediaTreeHash, err := repo.StoreTree(mediaTree)
+			if err != nil {
+				return entity.MergeStatusError, err
+			}
+			tree = append(tree, repository.TreeEntry{
+				ObjectType: repository.Tree,
+				Hash:       mediaTreeHash,
+				Name:       mediaEntryName,
+			})
+		}
+
+		treeHash, err := repo.StoreTree(tree)
+		if err != nil {
+			return entity.MergeStatusError, err
+		}
+
+		commit, err := repo.StoreCommitData(pack.CommitData)
+		if err != nil {
+			return entity.MergeStatusError, err
+		}
+		if commit != pack.Commit {
+			return entity.MergeStatusInvalid, fmt.Errorf("commit %s doesn't match its content", pack.Commit)
+		}
+
+		commitTree, err := repo.GetTreeHash(commit)
+		if err != nil {
+			return entity.MergeStatusError, err
+		}
+		if commitTree != treeHash {
+			return entity.MergeStatusInvalid, fmt.Errorf("commit %s doesn't match its operations", pack.Commit)
+		}
+	}
+
+	return entity.FastForwardRef(repo, bugsRefPattern+id.String(), packs[len(packs)-1].Commit)
+}
@@ -44,6 +44,7 @@ func (op *CreateOperation) Apply(snapshot *Snapshot) {
 	comment := Comment{
 		id:       op.Id(),
 		Message:  op.Message,
+		Files:    op.Files,
 		Author:   op.Author,
 		UnixTime: timestamp.Timestamp(op.UnixTime),
 	}
@@ -0,0 +1,143 @@
+package bug
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	termtext "github.com/MichaelMure/go-term-text"
+
+	"github.com/daedaleanai/git-ticket/entity"
+	"github.com/daedaleanai/git-ticket/identity"
+	"github.com/daedaleanai/git-ticket/util/text"
+	"github.com/daedaleanai/git-ticket/util/timestamp"
+)
+
+var _ Operation = &MoveOperation{}
+
+// MoveOperation is the tombstone of a bug copied to another repository, it records where the
+// bug now lives
+type MoveOperation struct {
+	OpBase
+	// To is the path or the url of the repository the bug was moved to
+	To string `json:"to"`
+}
+
+// Sign-post method for gqlgen
+func (op *MoveOperation) IsOperation() {}
+
+func (op *MoveOperation) base() *OpBase {
+	return &op.OpBase
+}
+
+func (op *MoveOperation) Id() entity.Id {
+	return idOperation(op)
+}
+
+func (op *MoveOperation) Apply(snapshot *Snapshot) {
+	snapshot.MovedTo = op.To
+	snapshot.addActor(op.Author)
+
+	item := &MoveTimelineItem{
+		id:       op.Id(),
+		Author:   op.Author,
+		UnixTime: timestamp.Timestamp(op.UnixTime),
+		To:       op.To,
+	}
+
+	snapshot.Timeline = append(snapshot.Timeline, item)
+}
+
+func (op *MoveOperation) Validate() error {
+	if err := opBaseValidate(op, MoveOp); err != nil {
+		return err
+	}
+
+	if text.Empty(op.To) {
+		return fmt.Errorf("destination is empty")
+	}
+
+	if strings.Contains(op.To, "\n") {
+		return fmt.Errorf("destination should be a single line")
+	}
+
+	if !text.Safe(op.To) {
+		return fmt.Errorf("destination should be fully printable")
+	}
+
+	return nil
+}
+
+// UnmarshalJSON is a two step JSON unmarshaling
+// This workaround is necessary to avoid the inner OpBase.MarshalJSON
+// overriding the outer op's MarshalJSON
+func (op *MoveOperation) UnmarshalJSON(data []byte) error {
+	// Unmarshal OpBase and the op separately
+
+	base := OpBase{}
+	err := json.Unmarshal(data, &base)
+	if err != nil {
+		return err
+	}
+
+	aux := struct {
+		To string `json:"to"`
+	}{}
+
+	err = json.Unmarshal(data, &aux)
+	if err != nil {
+		return err
+	}
+
+	op.OpBase = base
+	op.To = aux.To
+
+	return nil
+}
+
+// Sign post method for gqlgen
+func (op *MoveOperation) IsAuthored() {}
+
+func NewMoveOp(author identity.Interface, unixTime int64, to string) *MoveOperation {
+	return &MoveOperation{
+		OpBase: newOpBase(MoveOp, author, unixTime),
+		To:     to,
+	}
+}
+
+type MoveTimelineItem struct {
+	id       entity.Id
+	Author   identity.Interface
+	UnixTime timestamp.Timestamp
+	To       string
+}
+
+func (m MoveTimelineItem) Id() entity.Id {
+	return m.id
+}
+
+func (m MoveTimelineItem) When() timestamp.Timestamp {
+	return m.UnixTime
+}
+
+func (m MoveTimelineItem) String() string {
+	return fmt.Sprintf("(%s) %s: moved to %s",
+		m.UnixTime.Time().Format("2006-01-02 15:04:05"),
+		termtext.LeftPadMaxLine(m.Author.DisplayName(), timelineDisplayNameWidth, 0),
+		m.To)
+}
+
+// Sign post method for gqlgen
+func (m *MoveTimelineItem) IsAuthored() {}
+
+// Convenience function to apply the operation
+func Move(b Interface, author identity.Interface, unixTime int64, to string) (*MoveOperation, error) {
+	moveOp := NewMoveOp(author, unixTime, to)
+
+	if err := moveOp.Validate(); err != nil {
+		return nil, err
+	}
+
+	b.Append(moveOp)
+	return moveOp, nil
+}
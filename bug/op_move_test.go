@@ -0,0 +1,42 @@
+package bug
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/daedaleanai/git-ticket/identity"
+)
+
+func TestMoveSerialize(t *testing.T) {
+	var rene = identity.NewBare("René Descartes", "rene@descartes.fr")
+	unix := time.Now().Unix()
+	before := NewMoveOp(rene, unix, "/src/rocket")
+
+	data, err := json.Marshal(before)
+	assert.NoError(t, err)
+
+	var after MoveOperation
+	err = json.Unmarshal(data, &after)
+	assert.NoError(t, err)
+
+	// enforce creating the IDs
+	before.Id()
+	rene.Id()
+
+	assert.Equal(t, before, &after)
+}
+
+func TestMoveApply(t *testing.T) {
+	var rene = identity.NewBare("René Descartes", "rene@descartes.fr")
+	unix := time.Now().Unix()
+
+	snap := Snapshot{}
+	NewMoveOp(rene, unix, "git@example.com:rocket.git").Apply(&snap)
+
+	assert.Equal(t, "git@example.com:rocket.git", snap.MovedTo)
+	assert.Len(t, snap.Timeline, 1)
+	assert.Error(t, NewMoveOp(rene, unix, "").Validate())
+}
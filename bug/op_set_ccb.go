@@ -16,6 +16,8 @@ import (
 type SetCcbOperation struct {
 	OpBase
 	Ccb CcbInfo `json:"ccb"`
+	// Signature is only set for signed approvals
+	Signature *CcbSignature `json:"signature,omitempty"`
 }
 
 // Sign-post method for gqlgen
@@ -89,6 +91,18 @@ func (op *SetCcbOperation) Validate() error {
 		return err
 	}
 
+	if op.Signature != nil {
+		if op.Ccb.State != ApprovedCcbState {
+			return fmt.Errorf("only approvals can be signed")
+		}
+		if op.Ccb.User.Id() != op.Author.Id() {
+			return fmt.Errorf("approval signed on behalf of another user")
+		}
+		if op.Signature.SnapshotHash == "" || op.Signature.Armored == "" {
+			return fmt.Errorf("incomplete signature")
+		}
+	}
+
 	return nil
 }
 
@@ -110,7 +124,8 @@ func (op *SetCcbOperation) UnmarshalJSON(data []byte) error {
 		State  CcbState        `json:"state"`
 	}
 	aux := struct {
-		Ccb CcbInfoJson `json:"ccb"`
+		Ccb       CcbInfoJson   `json:"ccb"`
+		Signature *CcbSignature `json:"signature"`
 	}{}
 
 	err = json.Unmarshal(data, &aux)
@@ -128,6 +143,7 @@ func (op *SetCcbOperation) UnmarshalJSON(data []byte) error {
 	op.Ccb.User = user
 	op.Ccb.Status = aux.Ccb.Status
 	op.Ccb.State = aux.Ccb.State
+	op.Signature = aux.Signature
 
 	return nil
 }
@@ -142,6 +158,13 @@ func NewSetCcbOp(author identity.Interface, unixTime int64, user identity.Interf
 	}
 }
 
+// NewSetCcbSignedOp creates an approval of the given status carrying the approver signature
+func NewSetCcbSignedOp(author identity.Interface, unixTime int64, status Status, signature *CcbSignature) *SetCcbOperation {
+	op := NewSetCcbOp(author, unixTime, author, status, ApprovedCcbState)
+	op.Signature = signature
+	return op
+}
+
 type SetCcbTimelineItem struct {
 	id       entity.Id
 	Author   identity.Interface
@@ -189,6 +212,17 @@ func SetCcb(b Interface, author identity.Interface, unixTime int64, user identit
 	return op, nil
 }
 
+// Convenience function to apply a signed CCB approval
+func SetCcbSigned(b Interface, author identity.Interface, unixTime int64, status Status, signature *CcbSignature) (*SetCcbOperation, error) {
+	op := NewSetCcbSignedOp(author, unixTime, status, signature)
+	if err := op.Validate(); err != nil {
+		return nil, err
+	}
+
+	b.Append(op)
+	return op, nil
+}
+
 // Clear CCB approvals of the given user and status
 func ClearCcbApprovals(b Interface, author identity.Interface, unixTime int64, user identity.Interface, status Status) (*SetCcbOperation, error) {
 	op := NewSetCcbOp(author, unixTime, user, status, RemovedCcbState)
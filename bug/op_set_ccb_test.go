@@ -29,3 +29,24 @@ func TestSetCcbSerialize(t *testing.T) {
 
 	assert.Equal(t, before, &after)
 }
+
+func TestSetCcbSignedSerialize(t *testing.T) {
+	var rene = identity.NewBare("René Descartes", "rene@descartes.fr")
+	unix := time.Now().Unix()
+	signature := &CcbSignature{SnapshotHash: "1234", Armored: "signature"}
+	before := NewSetCcbSignedOp(rene, unix, VettedStatus, signature)
+	assert.NoError(t, before.Validate())
+
+	data, err := json.Marshal(before)
+	assert.NoError(t, err)
+
+	var after SetCcbOperation
+	err = json.Unmarshal(data, &after)
+	assert.NoError(t, err)
+
+	// enforce creating the IDs
+	before.Id()
+	rene.Id()
+
+	assert.Equal(t, before, &after)
+}
@@ -37,7 +37,7 @@ func (op *SetChecklistOperation) Apply(snapshot *Snapshot) {
 	if snapshot.Checklists[label] == nil {
 		snapshot.Checklists[label] = make(map[entity.Id]ChecklistSnapshot)
 	}
-	snapshot.Checklists[label][op.Author.Id()] = ChecklistSnapshot{Checklist: op.Checklist, LastEdit: op.Time()}
+	snapshot.Checklists[label][op.Author.Id()] = ChecklistSnapshot{Checklist: op.Checklist, LastEdit: op.Time(), OperationId: op.Id()}
 	snapshot.addActor(op.Author)
 
 	item := &SetChecklistTimelineItem{
@@ -55,12 +55,8 @@ func (op *SetChecklistOperation) Validate() error {
 		return err
 	}
 
-	for _, s := range op.Checklist.Sections {
-		for _, q := range s.Questions {
-			if err := q.State.Validate(); err != nil {
-				return errors.Wrap(err, "state")
-			}
-		}
+	if errs := op.Checklist.ValidateAnswers(); len(errs) > 0 {
+		return errors.Wrap(errs[0], "checklist")
 	}
 
 	return nil
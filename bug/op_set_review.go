@@ -1,6 +1,7 @@
 package bug
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"sort"
@@ -106,25 +107,37 @@ func (op *SetReviewOperation) Validate() error {
 	return nil
 }
 
-// MarshalJSON serializes to json preserving type information
+// MarshalJSON serializes to json preserving type information: the review is stored
+// under the JSON tag of its provider
 func (op *SetReviewOperation) MarshalJSON() ([]byte, error) {
-	wrapper := struct {
-		OpBase
-		Phabricator *review.PhabReviewInfo `json:"review"`
-		Gitea       *review.GiteaInfo      `json:"reviewGitea"`
-		Remove      *review.RemoveReview   `json:"removeReview"`
-	}{}
-	wrapper.OpBase = op.OpBase
-	if phab, ok := op.Review.(*review.PhabReviewInfo); ok {
-		wrapper.Phabricator = phab
-	} else if gitea, ok := op.Review.(*review.GiteaInfo); ok {
-		wrapper.Gitea = gitea
-	} else if remove, ok := op.Review.(*review.RemoveReview); ok {
-		wrapper.Remove = remove
-	} else {
+	provider, ok := review.ProviderFor(op.Review)
+	if !ok {
 		panic("Unknown review info")
 	}
-	return json.Marshal(wrapper)
+
+	base, err := json.Marshal(op.OpBase)
+	if err != nil {
+		return nil, err
+	}
+	tag, err := json.Marshal(provider.Tag)
+	if err != nil {
+		return nil, err
+	}
+	info, err := json.Marshal(op.Review)
+	if err != nil {
+		return nil, err
+	}
+
+	// Append the review to the fields of OpBase, keeping them in order
+	var buf bytes.Buffer
+	buf.Write(bytes.TrimSuffix(base, []byte("}")))
+	buf.WriteString(",")
+	buf.Write(tag)
+	buf.WriteString(":")
+	buf.Write(info)
+	buf.WriteString("}")
+
+	return buf.Bytes(), nil
 }
 
 // UnmarshalJSON is a two step JSON unmarshaling
@@ -139,27 +152,23 @@ func (op *SetReviewOperation) UnmarshalJSON(data []byte) error {
 		return err
 	}
 
-	wrapper := struct {
-		Phabricator *review.PhabReviewInfo `json:"review"`
-		Gitea       *review.GiteaInfo      `json:"reviewGitea"`
-		Remove      *review.RemoveReview   `json:"removeReview"`
-	}{}
-
-	err = json.Unmarshal(data, &wrapper)
+	fields := make(map[string]json.RawMessage)
+	err = json.Unmarshal(data, &fields)
 	if err != nil {
 		return err
 	}
+
 	op.OpBase = base
-	if wrapper.Phabricator != nil {
-		op.Review = wrapper.Phabricator
-	} else if wrapper.Gitea != nil {
-		op.Review = wrapper.Gitea
-	} else if wrapper.Remove != nil {
-		op.Review = wrapper.Remove
-	} else {
-		return fmt.Errorf("Unable to parse review info")
+	for _, provider := range review.Providers() {
+		raw, ok := fields[provider.Tag]
+		if !ok || string(raw) == "null" {
+			continue
+		}
+		op.Review = provider.New()
+		return json.Unmarshal(raw, op.Review)
 	}
-	return nil
+
+	return fmt.Errorf("Unable to parse review info")
 }
 
 // Sign post method for gqlgen
@@ -140,3 +140,39 @@ func TestOpSetReview_Apply(t *testing.T) {
 
 	assert.NotContains(t, snapshot.Reviews, "D1234")
 }
+
+func TestOpSetReview_Providers(t *testing.T) {
+	unix := time.Now().Unix()
+
+	for _, info := range []review.PullRequest{
+		&review.GiteaInfo{Owner: "daedalean", Repository: "git-ticket", PullId: 9},
+		&review.GithubInfo{Owner: "daedalean", Repository: "git-ticket", Number: 9},
+		&review.GitlabInfo{Project: "daedalean/git-ticket", Iid: 9},
+		&review.GerritInfo{Number: 42},
+		&review.LocalInfo{Ref: "feature", Head: "abc"},
+		&review.RemoveReview{ReviewId: "D1234"},
+	} {
+		before := NewSetReviewOp(rene, unix, info)
+
+		data, err := json.Marshal(before)
+		assert.NoError(t, err)
+
+		var after SetReviewOperation
+		err = json.Unmarshal(data, &after)
+		assert.NoError(t, err)
+
+		before.Id()
+		rene.Id()
+
+		assert.Equal(t, before, &after)
+	}
+
+	// operations written before the provider registry hold a null for each other provider
+	data := []byte(`{"type":11,"author":{"id":"` + rene.Id().String() + `"},"timestamp":1,"review":null,"reviewGitea":{"Owner":"daedalean","Repository":"git-ticket","PullId":9},"removeReview":null}`)
+
+	var op SetReviewOperation
+	assert.NoError(t, json.Unmarshal(data, &op))
+	assert.Equal(t, "daedalean/git-ticket#9", op.Review.Id())
+
+	assert.Error(t, json.Unmarshal([]byte(`{"type":11,"timestamp":1,"unknown":{}}`), &op))
+}
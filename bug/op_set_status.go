@@ -154,3 +154,16 @@ func SetStatus(b Interface, author identity.Interface, unixTime int64, status St
 
 	return op, nil
 }
+
+// ForceSetStatus is a convenience function to apply the operation
+// The difference with SetStatus is that the transition isn't validated against the workflow of
+// the ticket, and no workflow action is taken. The intended use of this function is to allow
+// importers to record status changes which happened in other workflows.
+func ForceSetStatus(b Interface, author identity.Interface, unixTime int64, status Status) (*SetStatusOperation, error) {
+	op := NewSetStatusOp(author, unixTime, status)
+	if err := op.Validate(); err != nil {
+		return nil, err
+	}
+	b.Append(op)
+	return op, nil
+}
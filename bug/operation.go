@@ -30,6 +30,7 @@ const (
 	SetAssigneeOp
 	SetReviewOp
 	SetCcbOp
+	MoveOp
 )
 
 // Operation define the interface to fulfill for an edit operation of a Bug
@@ -7,6 +7,7 @@ import (
 	"github.com/pkg/errors"
 
 	"github.com/daedaleanai/git-ticket/repository"
+	"github.com/daedaleanai/git-ticket/util/lamport"
 )
 
 const formatVersion = 1
@@ -22,6 +23,7 @@ type OperationPack struct {
 
 	// Private field so not serialized
 	commitHash repository.Hash
+	editTime   lamport.Time
 }
 
 func (opp *OperationPack) MarshalJSON() ([]byte, error) {
@@ -119,6 +121,10 @@ func (opp *OperationPack) unmarshalOp(raw []byte, _type OperationType) (Operatio
 		op := &SetCcbOperation{}
 		err := json.Unmarshal(raw, &op)
 		return op, err
+	case MoveOp:
+		op := &MoveOperation{}
+		err := json.Unmarshal(raw, &op)
+		return op, err
 	default:
 		return nil, fmt.Errorf("unknown operation type %v", _type)
 	}
@@ -197,6 +203,7 @@ func (opp *OperationPack) Clone() OperationPack {
 	clone := OperationPack{
 		Operations: make([]Operation, len(opp.Operations)),
 		commitHash: opp.commitHash,
+		editTime:   opp.editTime,
 	}
 
 	for i, op := range opp.Operations {
@@ -0,0 +1,53 @@
+package review
+
+import (
+	"strings"
+)
+
+// FileChange is the change of a file by a pull request, with the number of lines added
+// and deleted
+type FileChange struct {
+	Path      string
+	Additions int
+	Deletions int
+}
+
+// parseDiffStat returns the files changed by a unified diff, as output by git diff
+func parseDiffStat(diff string) []FileChange {
+	var result []FileChange
+	var current *FileChange
+	inHunk := false
+
+	for _, line := range strings.Split(diff, "\n") {
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			// diff --git a/<old path> b/<new path>, the new path is kept
+			path := line[len("diff --git "):]
+			if i := strings.LastIndex(path, " b/"); i >= 0 {
+				path = path[i+len(" b/"):]
+			}
+			result = append(result, FileChange{Path: path})
+			current = &result[len(result)-1]
+			inHunk = false
+		case current == nil:
+			continue
+		case strings.HasPrefix(line, "@@"):
+			inHunk = true
+		case !inHunk:
+			// Header lines, e.g. "+++ b/path", aren't changes
+			continue
+		case strings.HasPrefix(line, "+"):
+			current.Additions++
+		case strings.HasPrefix(line, "-"):
+			current.Deletions++
+		}
+	}
+
+	return result
+}
+
+// countDiffLines returns the number of lines added and deleted by the hunks of a diff of a file
+func countDiffLines(diff string) (int, int) {
+	changes := parseDiffStat("diff --git a/file b/file\n" + diff)
+	return changes[0].Additions, changes[0].Deletions
+}
@@ -0,0 +1,41 @@
+package review
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDiffStat(t *testing.T) {
+	diff := `diff --git a/src/main.go b/src/main.go
+index 83db48f..bf269f4 100644
+--- a/src/main.go
++++ b/src/main.go
+@@ -1,4 +1,5 @@
+ package main
+-
++import "fmt"
++
+ func main() {
+diff --git a/old.txt b/docs/new.txt
+similarity index 90%
+rename from old.txt
+rename to docs/new.txt
+--- a/old.txt
++++ b/docs/new.txt
+@@ -1 +1 @@
+--- a title
++-- the title
+`
+
+	assert.Equal(t, []FileChange{
+		{Path: "src/main.go", Additions: 2, Deletions: 1},
+		{Path: "docs/new.txt", Additions: 1, Deletions: 1},
+	}, parseDiffStat(diff))
+
+	additions, deletions := countDiffLines("@@ -1,2 +1,2 @@\n-a\n+b\n+c\n c\n")
+	assert.Equal(t, 2, additions)
+	assert.Equal(t, 1, deletions)
+
+	assert.Empty(t, parseDiffStat(""))
+}
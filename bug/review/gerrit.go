@@ -0,0 +1,781 @@
+package review
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	termtext "github.com/MichaelMure/go-term-text"
+
+	"github.com/daedaleanai/git-ticket/entity"
+	"github.com/daedaleanai/git-ticket/identity"
+	"github.com/daedaleanai/git-ticket/repository"
+	"github.com/daedaleanai/git-ticket/util/colors"
+	"github.com/daedaleanai/git-ticket/util/rest"
+	"github.com/daedaleanai/git-ticket/util/timestamp"
+)
+
+// GerritUsernameMetadataKey is the identity metadata holding the username of the user on Gerrit
+const GerritUsernameMetadataKey = "gerrit-username"
+
+const gerritTimeLayout = "2006-01-02 15:04:05.000000000"
+
+// gerritNewPatchSetTag is the tag of the messages Gerrit generates on patch set uploads
+const gerritNewPatchSetTag = "autogenerated:gerrit:newPatchSet"
+
+// GerritTime is a timestamp in the format of the Gerrit API, always UTC
+type GerritTime struct {
+	time.Time
+}
+
+// MarshalJSON formats the timestamp as Gerrit does
+func (t GerritTime) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.UTC().Format(gerritTimeLayout))
+}
+
+// UnmarshalJSON parses a timestamp formatted by Gerrit
+func (t *GerritTime) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	parsed, err := time.Parse(gerritTimeLayout, s)
+	if err != nil {
+		return err
+	}
+	t.Time = parsed
+	return nil
+}
+
+// GerritAccount is a Gerrit account, as returned by the Gerrit API
+type GerritAccount struct {
+	AccountId int64  `json:"_account_id"`
+	Name      string `json:"name,omitempty"`
+	Email     string `json:"email,omitempty"`
+	Username  string `json:"username,omitempty"`
+}
+
+// GerritVoteData is a vote on a label, as returned by the Gerrit API
+type GerritVoteData struct {
+	GerritAccount
+	Value int        `json:"value"`
+	Date  GerritTime `json:"date"`
+}
+
+// GerritLabelData is a label of a change and its votes, as returned by the Gerrit API
+type GerritLabelData struct {
+	All      []GerritVoteData `json:"all,omitempty"`
+	Approved *GerritAccount   `json:"approved,omitempty"`
+	Rejected *GerritAccount   `json:"rejected,omitempty"`
+}
+
+// GerritMessageData is a change message, as returned by the Gerrit API
+type GerritMessageData struct {
+	Id             string        `json:"id"`
+	Author         GerritAccount `json:"author"`
+	Date           GerritTime    `json:"date"`
+	Message        string        `json:"message"`
+	Tag            string        `json:"tag,omitempty"`
+	RevisionNumber int           `json:"_revision_number"`
+}
+
+// GerritRevisionData is a patch set of a change, as returned by the Gerrit API
+type GerritRevisionData struct {
+	Number   int           `json:"_number"`
+	Created  GerritTime    `json:"created"`
+	Uploader GerritAccount `json:"uploader"`
+	Ref      string        `json:"ref"`
+	Commit   struct {
+		Subject string `json:"subject"`
+	} `json:"commit"`
+}
+
+// GerritChangeData is a change, as returned by the Gerrit API
+type GerritChangeData struct {
+	Project   string                        `json:"project"`
+	Branch    string                        `json:"branch"`
+	ChangeId  string                        `json:"change_id"`
+	Subject   string                        `json:"subject"`
+	Status    string                        `json:"status"`
+	Number    int64                         `json:"_number"`
+	Owner     GerritAccount                 `json:"owner"`
+	Labels    map[string]GerritLabelData    `json:"labels,omitempty"`
+	Messages  []GerritMessageData           `json:"messages,omitempty"`
+	Revisions map[string]GerritRevisionData `json:"revisions,omitempty"`
+}
+
+// GerritCommentData is an inline comment, as returned by the Gerrit API
+type GerritCommentData struct {
+	Id        string        `json:"id"`
+	Path      string        `json:"path"`
+	PatchSet  int           `json:"patch_set"`
+	Line      int           `json:"line"`
+	Message   string        `json:"message"`
+	Updated   GerritTime    `json:"updated"`
+	Author    GerritAccount `json:"author"`
+	InReplyTo string        `json:"in_reply_to,omitempty"`
+	CommitId  string        `json:"commit_id"`
+}
+
+// GerritFileData is a file changed by a patch set, as returned by the Gerrit API
+type GerritFileData struct {
+	LinesInserted int `json:"lines_inserted"`
+	LinesDeleted  int `json:"lines_deleted"`
+}
+
+// GerritComment holds an inline comment on a patch set
+type GerritComment struct {
+	RawComment GerritCommentData
+}
+
+// Summary returns a string containing the comment text, the file & line details and the
+// patch set, on a single line. Comments over 50 characters are truncated.
+func (c *GerritComment) Summary() string {
+	// Put the comment on one line and output the first 50 characters
+	output := termtext.LeftPadMaxLine(strings.ReplaceAll(strings.ReplaceAll(c.RawComment.Message, "\n", " "), "\r", ""), 50, 0)
+
+	return output + fmt.Sprintf(" [%s:%d@patchset %d]", c.RawComment.Path, c.RawComment.Line, c.RawComment.PatchSet)
+}
+
+// CommentId returns the Gerrit id of the comment
+func (c *GerritComment) CommentId() string {
+	return c.RawComment.Id
+}
+
+// Body returns the complete text of the comment
+func (c *GerritComment) Body() string {
+	return c.RawComment.Message
+}
+
+// Location returns the file & line details and the patch set of the comment
+func (c *GerritComment) Location() string {
+	return fmt.Sprintf("%s:%d@patchset %d", c.RawComment.Path, c.RawComment.Line, c.RawComment.PatchSet)
+}
+
+// InReplyTo returns the id of the comment replied to
+func (c *GerritComment) InReplyTo() string {
+	return c.RawComment.InReplyTo
+}
+
+// GerritMessage holds a change message, e.g. a review with its votes, and the inline comments
+// published with it
+type GerritMessage struct {
+	RawMessage GerritMessageData
+	Comments   []GerritComment
+	AuthorId   identity.Interface
+}
+
+// Author returns the author of the message
+func (m *GerritMessage) Author() identity.Interface {
+	return m.AuthorId
+}
+
+// Timestamp returns the time of the message
+func (m *GerritMessage) Timestamp() timestamp.Timestamp {
+	return timestamp.Timestamp(m.RawMessage.Date.Unix())
+}
+
+// Changes returns the inline comments published with the message
+func (m *GerritMessage) Changes() []Change {
+	result := []Change{}
+	for _, c := range m.Comments {
+		cc := c // Without it golang store pointer to changing loop variable
+		result = append(result, &cc)
+	}
+	return result
+}
+
+// Summary returns the first line of the message, e.g. "Patch Set 2: Code-Review+2", and
+// the number of inline comments
+func (m *GerritMessage) Summary() string {
+	summary := strings.SplitN(strings.TrimSpace(m.RawMessage.Message), "\n", 2)[0]
+
+	switch len(m.Comments) {
+	case 0:
+		return summary
+	case 1:
+		return summary + " [1 comment]"
+	default:
+		return summary + " [" + strconv.Itoa(len(m.Comments)) + " comments]"
+	}
+}
+
+// GerritPatchSet holds the upload of a patch set
+type GerritPatchSet struct {
+	RawRevision GerritRevisionData
+	Sha         string
+	AuthorId    identity.Interface
+}
+
+// Author returns the uploader of the patch set
+func (p *GerritPatchSet) Author() identity.Interface {
+	return p.AuthorId
+}
+
+// Timestamp returns the time of the upload
+func (p *GerritPatchSet) Timestamp() timestamp.Timestamp {
+	return timestamp.Timestamp(p.RawRevision.Created.Unix())
+}
+
+// Changes returns no change, the commit is in the summary
+func (p *GerritPatchSet) Changes() []Change {
+	return nil
+}
+
+// Summary returns the patch set number and its commit
+func (p *GerritPatchSet) Summary() string {
+	subject := termtext.LeftPadMaxLine(p.RawRevision.Commit.Subject, 50, 0)
+
+	return fmt.Sprintf("[PATCHSET %d commit %s] %s", p.RawRevision.Number, p.Sha, subject)
+}
+
+// GerritVote holds the current vote of a user on a label
+type GerritVote struct {
+	Label    string
+	RawVote  GerritVoteData
+	AuthorId identity.Interface
+}
+
+// gerritUserStatus gathers the current votes of a user on all the labels
+type gerritUserStatus struct {
+	author    identity.Interface
+	timestamp timestamp.Timestamp
+	votes     []string
+}
+
+func (s *gerritUserStatus) Author() identity.Interface {
+	return s.author
+}
+
+func (s *gerritUserStatus) Timestamp() timestamp.Timestamp {
+	return s.timestamp
+}
+
+func (s *gerritUserStatus) Status() string {
+	return strings.Join(s.votes, " ")
+}
+
+// GerritInfo is Gerrit-specific implementation of PullRequest
+type GerritInfo struct {
+	Number int64
+
+	// RawChange holds the state of the change, without its messages and revisions
+	RawChange GerritChangeData
+	// Votes holds the current votes on the change
+	Votes     []GerritVote
+	Messages  []GerritMessage
+	PatchSets []GerritPatchSet
+	// Files holds the files changed by the current patch set
+	Files []FileChange `json:",omitempty"`
+}
+
+// Id returns the Gerrit change number
+func (g *GerritInfo) Id() string {
+	return strconv.FormatInt(g.Number, 10)
+}
+
+// ReviewUrl returns Gerrit change URL, if known. Otherwise it returns the review id
+func (g *GerritInfo) ReviewUrl() string {
+	gerritUrl, _, _, _ := repository.GetGerritConfig()
+	if gerritUrl != "" {
+		return fmt.Sprintf("%s/c/%s/+/%d", gerritUrl, g.RawChange.Project, g.Number)
+	}
+
+	// Fallback to the ID if URL is unknown
+	return g.Id()
+}
+
+// Title returns Gerrit change subject
+func (g *GerritInfo) Title() string {
+	return g.RawChange.Subject
+}
+
+// History returns all events from the change
+func (g *GerritInfo) History() []TimelineEvent {
+	result := []TimelineEvent{}
+
+	for _, m := range g.Messages {
+		upd := m
+		result = append(result, &upd)
+	}
+	for _, p := range g.PatchSets {
+		upd := p
+		result = append(result, &upd)
+	}
+	return result
+}
+
+// IsEmpty check if there is any changes
+func (g *GerritInfo) IsEmpty() bool {
+	return len(g.Messages)+len(g.PatchSets)+len(g.Files) == 0
+}
+
+// EnsureIdentities validated if all users are resolved
+func (g *GerritInfo) EnsureIdentities(resolver identity.Resolver, found map[entity.Id]identity.Interface) error {
+	ensure := func(author *identity.Interface) error {
+		entity := (*author).Id()
+
+		if _, ok := found[entity]; !ok {
+			id, err := resolver.ResolveIdentity(entity)
+			if err != nil {
+				return err
+			}
+			found[entity] = id
+		}
+
+		*author = found[entity]
+		return nil
+	}
+
+	for i := range g.Votes {
+		if err := ensure(&g.Votes[i].AuthorId); err != nil {
+			return err
+		}
+	}
+	for i := range g.Messages {
+		if err := ensure(&g.Messages[i].AuthorId); err != nil {
+			return err
+		}
+	}
+	for i := range g.PatchSets {
+		if err := ensure(&g.PatchSets[i].AuthorId); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FetchIdentities resolves users from the change to git-ticket identities
+func (g *GerritInfo) FetchIdentities(resolver IdentityResolver) error {
+	resolve := func(account GerritAccount) (identity.Interface, error) {
+		user, err := resolver.ResolveIdentityMetadata(GerritUsernameMetadataKey, account.Username)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s (Gerrit username: %s)", err, account.Name, account.Username)
+		}
+		return user, nil
+	}
+
+	var err error
+	for i, v := range g.Votes {
+		if g.Votes[i].AuthorId, err = resolve(v.RawVote.GerritAccount); err != nil {
+			return err
+		}
+	}
+	for i, m := range g.Messages {
+		if g.Messages[i].AuthorId, err = resolve(m.RawMessage.Author); err != nil {
+			return err
+		}
+	}
+	for i, p := range g.PatchSets {
+		if g.PatchSets[i].AuthorId, err = resolve(p.RawRevision.Uploader); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Merge updates state from new one
+func (g *GerritInfo) Merge(update PullRequest) {
+	if update == nil {
+		return
+	}
+
+	u := update.(*GerritInfo)
+	g.Number = u.Number
+	g.RawChange = u.RawChange
+	g.Votes = u.Votes
+	g.Messages = append(g.Messages, u.Messages...)
+	g.PatchSets = append(g.PatchSets, u.PatchSets...)
+	if u.Files != nil {
+		g.Files = u.Files
+	}
+}
+
+// ChangedFiles returns the files changed by the current patch set
+func (g *GerritInfo) ChangedFiles() []FileChange {
+	return g.Files
+}
+
+// LatestOverallStatus returns the status of the change, or the state of its labels
+func (g *GerritInfo) LatestOverallStatus() string {
+	switch g.RawChange.Status {
+	case "MERGED":
+		return colors.Green("MERGED")
+	case "ABANDONED":
+		return "ABANDONED"
+	}
+
+	labels := make([]string, 0, len(g.RawChange.Labels))
+	for l := range g.RawChange.Labels {
+		labels = append(labels, l)
+	}
+	sort.Strings(labels)
+
+	for _, l := range labels {
+		if g.RawChange.Labels[l].Rejected != nil {
+			return colors.Red(strings.ToUpper(l) + " REJECTED")
+		}
+	}
+
+	if codeReview, ok := g.RawChange.Labels["Code-Review"]; ok && codeReview.Approved != nil {
+		if verified, ok := g.RawChange.Labels["Verified"]; !ok || verified.Approved != nil {
+			return colors.Green("APPROVED")
+		}
+	}
+
+	return "PENDING"
+}
+
+// LatestUserStatuses returns a map of users and their current votes on the change,
+// e.g. "Code-Review+2 Verified+1"
+func (g *GerritInfo) LatestUserStatuses() map[string]UserStatus {
+	statuses := map[string]*gerritUserStatus{}
+
+	for _, v := range g.Votes {
+		s, ok := statuses[v.RawVote.Username]
+		if !ok {
+			s = &gerritUserStatus{author: v.AuthorId}
+			statuses[v.RawVote.Username] = s
+		}
+		s.votes = append(s.votes, fmt.Sprintf("%s%+d", v.Label, v.RawVote.Value))
+		if ts := timestamp.Timestamp(v.RawVote.Date.Unix()); ts > s.timestamp {
+			s.timestamp = ts
+		}
+	}
+
+	result := map[string]UserStatus{}
+	for user, s := range statuses {
+		result[user] = s
+	}
+	return result
+}
+
+func init() {
+	Register(&Provider{
+		Name:      "gerrit",
+		Tag:       "reviewGerrit",
+		Formats:   []string{"<change number>", "I<change id>", "https://<host>/c/<project>/+/<change number>"},
+		IdPattern: gerritIdRegex,
+		Configured: func() bool {
+			_, _, _, err := repository.GetGerritConfig()
+			return err == nil
+		},
+		Incremental:   true,
+		New:           func() PullRequest { return &GerritInfo{} },
+		Fetch:         fetchGerrit,
+		SameReview:    sameGerritReview,
+		FixupIdentity: fixupGerritIdentity,
+	})
+}
+
+var gerritIdRegex = regexp.MustCompile(`^(?:https?://[^/]+/(?:#/)?c/(?:.+/\+/)?)?(\d+|I[0-9a-f]{40})/?$`)
+
+func fetchGerrit(id string, since PullRequest) (PullRequest, error) {
+	matched := gerritIdRegex.FindStringSubmatch(id)
+	if matched == nil {
+		return nil, fmt.Errorf("unable to parse id: %s", id)
+	}
+	var old *GerritInfo
+	if since != nil {
+		old = since.(*GerritInfo)
+	}
+	return FetchGerritReviewInfo(matched[1], old)
+}
+
+// fixupGerritIdentity prompts for the Gerrit username of identities which don't have one
+func fixupGerritIdentity(i *identity.Identity, prompt Prompt) (func(identity.Mutator) identity.Mutator, error) {
+	if i.MutableMetadata()[GerritUsernameMetadataKey] != "" {
+		return nil, nil
+	}
+
+	fmt.Println("No Gerrit username for ", i.Name(), ", please insert it, or leave empty to skip.")
+	username, err := prompt("Gerrit username", "gerrit username", i.Login())
+	if err != nil || username == "" {
+		return nil, err
+	}
+
+	client, err := newGerritClient()
+	if err != nil {
+		return nil, err
+	}
+	var account GerritAccount
+	if err := client.Get("/accounts/"+url.PathEscape(username), &account); err != nil {
+		return nil, fmt.Errorf("no Gerrit user %s: %s", username, err)
+	}
+
+	return func(mutator identity.Mutator) identity.Mutator {
+		mutator.Metadata[GerritUsernameMetadataKey] = username
+		return mutator
+	}, nil
+}
+
+// newGerritClient returns a client ready to be queried. Must be called within a git repo
+// which has the Gerrit URL and credentials set in the git config.
+func newGerritClient() (*rest.Client, error) {
+	gerritUrl, username, password, err := repository.GetGerritConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	return newGerritRestClient(gerritUrl, username, password), nil
+}
+
+func newGerritRestClient(gerritUrl, username, password string) *rest.Client {
+	credentials := base64.StdEncoding.EncodeToString([]byte(username + ":" + password))
+
+	// The authenticated API is under /a
+	client := rest.NewClient(gerritUrl+"/a", map[string]string{"Authorization": "Basic " + credentials})
+	client.Prefix = ")]}'"
+	return client
+}
+
+// FetchGerritReviewInfo exports change messages, inline comments, votes and patch set uploads
+// from Gerrit for the given change, by number or Change-Id, and returns in a PullRequest object.
+// If since review is specified only updates will be returned
+func FetchGerritReviewInfo(id string, since *GerritInfo) (PullRequest, error) {
+	client, err := newGerritClient()
+	if err != nil {
+		return nil, err
+	}
+
+	return fetchGerritReviewInfo(client, id, since)
+}
+
+func fetchGerritReviewInfo(client *rest.Client, id string, since *GerritInfo) (*GerritInfo, error) {
+	knownMessages := map[string]struct{}{}
+	knownComments := map[string]struct{}{}
+	knownPatchSets := map[int]struct{}{}
+
+	if since != nil {
+		for _, m := range since.Messages {
+			knownMessages[m.RawMessage.Id] = struct{}{}
+			for _, c := range m.Comments {
+				knownComments[c.RawComment.Id] = struct{}{}
+			}
+		}
+		for _, p := range since.PatchSets {
+			knownPatchSets[p.RawRevision.Number] = struct{}{}
+		}
+	}
+
+	changePath := "/changes/" + url.PathEscape(id)
+
+	var change GerritChangeData
+	err := client.Get(changePath+"?o=DETAILED_ACCOUNTS&o=DETAILED_LABELS&o=MESSAGES&o=ALL_REVISIONS&o=ALL_COMMITS", &change)
+	if err != nil {
+		return nil, err
+	}
+
+	var comments map[string][]GerritCommentData
+	if err := client.Get(changePath+"/comments", &comments); err != nil {
+		return nil, err
+	}
+
+	result := GerritInfo{Number: change.Number}
+
+	// Published comments are attached to the message of the review publishing them, which
+	// has the same author and time
+	type publication struct {
+		author int64
+		date   time.Time
+	}
+	published := map[publication][]GerritComment{}
+
+	paths := make([]string, 0, len(comments))
+	for path := range comments {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		for _, c := range comments[path] {
+			if _, ok := knownComments[c.Id]; ok {
+				continue
+			}
+			c.Path = path
+			key := publication{c.Author.AccountId, c.Updated.Time}
+			published[key] = append(published[key], GerritComment{RawComment: c})
+		}
+	}
+
+	for _, m := range change.Messages {
+		if _, ok := knownMessages[m.Id]; ok || strings.HasPrefix(m.Tag, gerritNewPatchSetTag) {
+			continue
+		}
+		key := publication{m.Author.AccountId, m.Date.Time}
+		result.Messages = append(result.Messages, GerritMessage{
+			RawMessage: m,
+			Comments:   published[key],
+		})
+		delete(published, key)
+	}
+
+	// Comments not published with a message, if any, stand on their own
+	for key, c := range published {
+		result.Messages = append(result.Messages, GerritMessage{
+			RawMessage: GerritMessageData{
+				Id:             "comments-" + c[0].RawComment.Id,
+				Author:         c[0].RawComment.Author,
+				Date:           GerritTime{key.date},
+				RevisionNumber: c[0].RawComment.PatchSet,
+			},
+			Comments: c,
+		})
+	}
+	sort.SliceStable(result.Messages, func(i, j int) bool {
+		return result.Messages[i].RawMessage.Date.Before(result.Messages[j].RawMessage.Date.Time)
+	})
+
+	for sha, r := range change.Revisions {
+		if _, ok := knownPatchSets[r.Number]; ok {
+			continue
+		}
+		result.PatchSets = append(result.PatchSets, GerritPatchSet{RawRevision: r, Sha: sha})
+	}
+	sort.Slice(result.PatchSets, func(i, j int) bool {
+		return result.PatchSets[i].RawRevision.Number < result.PatchSets[j].RawRevision.Number
+	})
+
+	labels := make([]string, 0, len(change.Labels))
+	for l := range change.Labels {
+		labels = append(labels, l)
+	}
+	sort.Strings(labels)
+
+	for _, l := range labels {
+		for _, v := range change.Labels[l].All {
+			if v.Value != 0 {
+				result.Votes = append(result.Votes, GerritVote{Label: l, RawVote: v})
+			}
+		}
+	}
+
+	// The files are only downloaded when they are unknown, e.g. for the reviews stored before they
+	// were recorded, or when a new patch set was uploaded
+	if since == nil || since.Files == nil || len(result.PatchSets) > 0 {
+		var files map[string]GerritFileData
+		if err := client.Get(changePath+"/revisions/current/files", &files); err != nil {
+			return nil, err
+		}
+
+		result.Files = []FileChange{}
+		for path, f := range files {
+			// Skip the magic files, e.g. /COMMIT_MSG
+			if strings.HasPrefix(path, "/") {
+				continue
+			}
+			result.Files = append(result.Files, FileChange{Path: path, Additions: f.LinesInserted, Deletions: f.LinesDeleted})
+		}
+		sort.Slice(result.Files, func(i, j int) bool {
+			return result.Files[i].Path < result.Files[j].Path
+		})
+	}
+
+	change.Messages = nil
+	change.Revisions = nil
+	result.RawChange = change
+
+	return &result, nil
+}
+
+// UnmarshalJSON fulfils the Marshaler interface so that we can handle the author identity
+func (v *GerritVote) UnmarshalJSON(data []byte) error {
+	type rawUpdate struct {
+		Label    string
+		RawVote  GerritVoteData
+		AuthorId json.RawMessage
+	}
+
+	var raw rawUpdate
+	err := json.Unmarshal(data, &raw)
+	if err != nil {
+		return err
+	}
+
+	v.Label = raw.Label
+	v.RawVote = raw.RawVote
+
+	if raw.AuthorId != nil {
+		author, err := identity.UnmarshalJSON(raw.AuthorId)
+		if err != nil {
+			return err
+		}
+		v.AuthorId = author
+	}
+
+	return nil
+}
+
+// UnmarshalJSON fulfils the Marshaler interface so that we can handle the author identity
+func (m *GerritMessage) UnmarshalJSON(data []byte) error {
+	type rawUpdate struct {
+		RawMessage GerritMessageData
+		Comments   []GerritComment
+		AuthorId   json.RawMessage
+	}
+
+	var raw rawUpdate
+	err := json.Unmarshal(data, &raw)
+	if err != nil {
+		return err
+	}
+
+	m.RawMessage = raw.RawMessage
+	m.Comments = raw.Comments
+
+	if raw.AuthorId != nil {
+		author, err := identity.UnmarshalJSON(raw.AuthorId)
+		if err != nil {
+			return err
+		}
+		m.AuthorId = author
+	}
+
+	return nil
+}
+
+// UnmarshalJSON fulfils the Marshaler interface so that we can handle the author identity
+func (p *GerritPatchSet) UnmarshalJSON(data []byte) error {
+	type rawUpdate struct {
+		RawRevision GerritRevisionData
+		Sha         string
+		AuthorId    json.RawMessage
+	}
+
+	var raw rawUpdate
+	err := json.Unmarshal(data, &raw)
+	if err != nil {
+		return err
+	}
+
+	p.RawRevision = raw.RawRevision
+	p.Sha = raw.Sha
+
+	if raw.AuthorId != nil {
+		author, err := identity.UnmarshalJSON(raw.AuthorId)
+		if err != nil {
+			return err
+		}
+		p.AuthorId = author
+	}
+
+	return nil
+}
+
+// sameGerritReview tells if the id, a change number or a change id in any of the accepted forms,
+// designates the change
+func sameGerritReview(id string, pr PullRequest) bool {
+	matched := gerritIdRegex.FindStringSubmatch(id)
+	if matched == nil {
+		return false
+	}
+	info := pr.(*GerritInfo)
+	return matched[1] == info.Id() || matched[1] == info.RawChange.ChangeId
+}
@@ -0,0 +1,159 @@
+package review
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/daedaleanai/git-ticket/identity"
+	"github.com/daedaleanai/git-ticket/util/rest"
+)
+
+func newGerritTestServer(t *testing.T, responses map[string]string) *rest.Client {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, ok := r.BasicAuth()
+		assert.True(t, ok)
+		assert.Equal(t, "alice", user)
+		assert.Equal(t, "secret", password)
+
+		response, ok := responses[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(")]}'\n" + response))
+	}))
+	t.Cleanup(server.Close)
+
+	return newGerritRestClient(server.URL, "alice", "secret")
+}
+
+func TestFetchGerritReviewInfo(t *testing.T) {
+	responses := map[string]string{
+		"/a/changes/42": `{"project": "tools/hello", "branch": "master", "subject": "Add greeting",
+			"status": "NEW", "_number": 42, "owner": {"_account_id": 1, "username": "alice"},
+			"labels": {
+				"Code-Review": {"all": [
+					{"_account_id": 2, "username": "bob", "value": -1, "date": "2021-03-04 12:00:00.000000000"},
+					{"_account_id": 1, "username": "alice", "value": 0}],
+					"rejected": {"_account_id": 2}},
+				"Verified": {"all": [
+					{"_account_id": 3, "username": "ci", "value": 1, "date": "2021-03-04 11:00:00.000000000"}]}},
+			"messages": [
+				{"id": "m1", "author": {"_account_id": 1, "username": "alice"}, "date": "2021-03-04 10:00:00.000000000",
+				 "message": "Uploaded patch set 1.", "tag": "autogenerated:gerrit:newPatchSet", "_revision_number": 1},
+				{"id": "m2", "author": {"_account_id": 3, "username": "ci"}, "date": "2021-03-04 11:00:00.000000000",
+				 "message": "Patch Set 1: Verified+1", "_revision_number": 1},
+				{"id": "m3", "author": {"_account_id": 2, "username": "bob"}, "date": "2021-03-04 12:00:00.000000000",
+				 "message": "Patch Set 1: Code-Review-1\n\n(1 comment)", "_revision_number": 1}],
+			"revisions": {"abc": {"_number": 1, "created": "2021-03-04 10:00:00.000000000",
+				"uploader": {"_account_id": 1, "username": "alice"}, "commit": {"subject": "Add greeting"}}}}`,
+		"/a/changes/42/comments": `{"hello.go": [
+			{"id": "c1", "patch_set": 1, "line": 3, "message": "Typo", "updated": "2021-03-04 12:00:00.000000000",
+			 "author": {"_account_id": 2, "username": "bob"}}]}`,
+		"/a/changes/42/revisions/current/files": `{"/COMMIT_MSG": {"lines_inserted": 7},
+			"hello.go": {"lines_inserted": 4, "lines_deleted": 1}}`,
+	}
+	client := newGerritTestServer(t, responses)
+
+	info, err := fetchGerritReviewInfo(client, "42", nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, "42", info.Id())
+	assert.Equal(t, "Add greeting", info.Title())
+	require.Len(t, info.Messages, 2)
+	assert.Empty(t, info.Messages[0].Comments)
+	require.Len(t, info.Messages[1].Comments, 1)
+	assert.Equal(t, "Patch Set 1: Code-Review-1 [1 comment]", info.Messages[1].Summary())
+	assert.Contains(t, info.Messages[1].Comments[0].Summary(), "[hello.go:3@patchset 1]")
+	require.Len(t, info.PatchSets, 1)
+	assert.Contains(t, info.PatchSets[0].Summary(), "[PATCHSET 1 commit abc] Add greeting")
+	assert.Len(t, info.Votes, 2)
+	assert.Len(t, info.History(), 3)
+	assert.Empty(t, info.RawChange.Messages)
+	assert.Contains(t, info.LatestOverallStatus(), "CODE-REVIEW REJECTED")
+	assert.Equal(t, []FileChange{{Path: "hello.go", Additions: 4, Deletions: 1}}, info.ChangedFiles())
+
+	alice := identity.NewBare("Alice", "alice@example.com")
+	bob := identity.NewBare("Bob", "bob@example.com")
+	ci := identity.NewBare("CI", "ci@example.com")
+	resolver := githubTestResolver{"alice": alice, "bob": bob, "ci": ci}
+
+	require.NoError(t, info.FetchIdentities(resolver))
+	assert.Equal(t, bob, info.Messages[1].Author())
+	assert.Equal(t, alice, info.PatchSets[0].Author())
+
+	statuses := info.LatestUserStatuses()
+	require.Contains(t, statuses, "bob")
+	assert.Equal(t, "Code-Review-1", statuses["bob"].Status())
+	assert.Equal(t, bob, statuses["bob"].Author())
+	assert.NotContains(t, statuses, "alice")
+
+	// A second patch set is uploaded and bob approves it with a reply to his comment
+	responses["/a/changes/42"] = `{"project": "tools/hello", "subject": "Add greeting", "status": "NEW", "_number": 42,
+		"labels": {
+			"Code-Review": {"all": [
+				{"_account_id": 2, "username": "bob", "value": 2, "date": "2021-03-05 12:00:00.000000000"}],
+				"approved": {"_account_id": 2}},
+			"Verified": {"all": [
+				{"_account_id": 3, "username": "ci", "value": 1, "date": "2021-03-05 11:00:00.000000000"}],
+				"approved": {"_account_id": 3}}},
+		"messages": [
+			{"id": "m2", "author": {"_account_id": 3, "username": "ci"}, "date": "2021-03-04 11:00:00.000000000",
+			 "message": "Patch Set 1: Verified+1", "_revision_number": 1},
+			{"id": "m3", "author": {"_account_id": 2, "username": "bob"}, "date": "2021-03-04 12:00:00.000000000",
+			 "message": "Patch Set 1: Code-Review-1\n\n(1 comment)", "_revision_number": 1},
+			{"id": "m4", "author": {"_account_id": 2, "username": "bob"}, "date": "2021-03-05 12:00:00.000000000",
+			 "message": "Patch Set 2: Code-Review+2", "_revision_number": 2}],
+		"revisions": {
+			"abc": {"_number": 1, "created": "2021-03-04 10:00:00.000000000", "uploader": {"_account_id": 1, "username": "alice"}},
+			"def": {"_number": 2, "created": "2021-03-05 10:00:00.000000000", "uploader": {"_account_id": 1, "username": "alice"}}}}`
+	responses["/a/changes/42/comments"] = `{"hello.go": [
+		{"id": "c1", "patch_set": 1, "line": 3, "message": "Typo", "updated": "2021-03-04 12:00:00.000000000",
+		 "author": {"_account_id": 2, "username": "bob"}},
+		{"id": "c2", "patch_set": 2, "line": 3, "message": "Fixed, thanks", "updated": "2021-03-05 09:00:00.000000000",
+		 "author": {"_account_id": 2, "username": "bob"}, "in_reply_to": "c1"}]}`
+
+	update, err := fetchGerritReviewInfo(client, "42", info)
+	require.NoError(t, err)
+
+	require.Len(t, update.Messages, 2)
+	// The reply isn't published with a message so it stands on its own
+	assert.Equal(t, "c2", update.Messages[0].Comments[0].RawComment.Id)
+	assert.Equal(t, "m4", update.Messages[1].RawMessage.Id)
+	require.Len(t, update.PatchSets, 1)
+	assert.Equal(t, 2, update.PatchSets[0].RawRevision.Number)
+	// The files of the new patch set are fetched again
+	assert.NotNil(t, update.ChangedFiles())
+
+	require.NoError(t, update.FetchIdentities(resolver))
+	info.Merge(update)
+	assert.Len(t, info.History(), 6)
+	assert.Contains(t, info.LatestOverallStatus(), "APPROVED")
+	assert.Equal(t, "Code-Review+2", info.LatestUserStatuses()["bob"].Status())
+
+	// Nothing new
+	update, err = fetchGerritReviewInfo(client, "42", info)
+	require.NoError(t, err)
+	assert.True(t, update.IsEmpty())
+	assert.Nil(t, update.ChangedFiles())
+
+	_, err = fetchGerritReviewInfo(client, "43", nil)
+	assert.Error(t, err)
+}
+
+func TestGerritProviderForId(t *testing.T) {
+	for _, id := range []string{
+		"42",
+		"I8473b95934b5732ac55d26311a706c9c2bde9940",
+		"https://review.example.com/c/tools/hello/+/42",
+		"https://review.example.com/#/c/42/",
+	} {
+		p, err := ProviderForId(id)
+		require.NoError(t, err, id)
+		assert.Equal(t, "gerrit", p.Name, id)
+	}
+}
@@ -3,6 +3,7 @@ package review
 import (
 	"encoding/json"
 	"fmt"
+	"regexp"
 	"strconv"
 	"strings"
 
@@ -36,6 +37,29 @@ func (c *Comment) Summary() string {
 	return output
 }
 
+// CommentId returns the Gitea id of the comment
+func (c *Comment) CommentId() string {
+	return strconv.FormatInt(c.RawComment.ID, 10)
+}
+
+// Body returns the complete text of the comment
+func (c *Comment) Body() string {
+	return c.RawComment.Body
+}
+
+// Location returns the file & line details of an inline comment
+func (c *Comment) Location() string {
+	if c.RawComment.Path == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s:%d@%s", c.RawComment.Path, c.RawComment.LineNum, c.RawComment.CommitID)
+}
+
+// InReplyTo returns an empty string, Gitea doesn't record which comment a comment replies to
+func (c *Comment) InReplyTo() string {
+	return ""
+}
+
 // GiteaCommitChange is a wrapper to represent Change made by commit
 type GiteaCommitChange struct {
 	RawCommit gitea.Commit
@@ -154,6 +178,7 @@ type GiteaInfo struct {
 	RawPull gitea.PullRequest
 	Reviews []GiteaReview
 	Commits []GiteaCommit
+	Files   []FileChange `json:",omitempty"`
 }
 
 // Id returns Gitea revision id
@@ -194,7 +219,7 @@ func (g *GiteaInfo) History() []TimelineEvent {
 
 // IsEmpty check if there is any changes
 func (g *GiteaInfo) IsEmpty() bool {
-	return len(g.Reviews)+len(g.Commits) == 0
+	return len(g.Reviews)+len(g.Commits)+len(g.Files) == 0
 }
 
 // EnsureIdentities validated if all users are resolved
@@ -267,6 +292,14 @@ func (g *GiteaInfo) Merge(update PullRequest) {
 	g.RawPull = u.RawPull
 	g.Reviews = append(g.Reviews, u.Reviews...)
 	g.Commits = append(g.Commits, u.Commits...)
+	if u.Files != nil {
+		g.Files = u.Files
+	}
+}
+
+// ChangedFiles returns the files changed by the pull request
+func (g *GiteaInfo) ChangedFiles() []FileChange {
+	return g.Files
 }
 
 // LatestOverallStatus returns the latest overall status set for this review.
@@ -315,6 +348,110 @@ func (g *GiteaInfo) LatestUserStatuses() map[string]UserStatus {
 	return result
 }
 
+func init() {
+	Register(&Provider{
+		Name:      "gitea",
+		Tag:       "reviewGitea",
+		Formats:   []string{"<owner>/<repo>#<id>", "<owner>/<repo>/pulls/<id>"},
+		IdPattern: giteaIdRegex,
+		Configured: func() bool {
+			_, _, err := repository.GetGiteaConfig()
+			return err == nil
+		},
+		Incremental:   true,
+		New:           func() PullRequest { return &GiteaInfo{} },
+		Fetch:         fetchGitea,
+		SameReview:    sameGiteaReview,
+		FixupIdentity: fixupGiteaIdentity,
+	})
+}
+
+var giteaIdRegex = regexp.MustCompile(`^([a-zA-Z0-9-_]+)/([a-zA-Z0-9-_]+)(#|/pulls/)(\d+)$`)
+
+func fetchGitea(id string, since PullRequest) (PullRequest, error) {
+	matched := giteaIdRegex.FindStringSubmatch(id)
+	if matched == nil {
+		return nil, fmt.Errorf("unable to parse id: %s", id)
+	}
+	idx, err := strconv.ParseInt(matched[4], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse id: %s", err)
+	}
+	var old *GiteaInfo
+	if since != nil {
+		old = since.(*GiteaInfo)
+	}
+	return FetchGiteaReviewInfo(matched[1], matched[2], idx, old)
+}
+
+// SearchGiteaUsers returns the Gitea users matching the user name
+func SearchGiteaUsers(userName string) ([]*gitea.User, error) {
+	giteaClient, err := repository.GetGiteaClient()
+	if err != nil {
+		return nil, err
+	}
+
+	users, _, err := giteaClient.SearchUsers(gitea.SearchUsersOption{
+		KeyWord: userName,
+	})
+	return users, err
+}
+
+// fixupGiteaIdentity looks for the Gitea ID of identities which don't have one, first by
+// the name of the identity and then prompting for the Gitea user name or ID
+func fixupGiteaIdentity(i *identity.Identity, prompt Prompt) (func(identity.Mutator) identity.Mutator, error) {
+	if i.GiteaID() != -1 {
+		return nil, nil
+	}
+
+	setId := func(giteaId int64) func(identity.Mutator) identity.Mutator {
+		return func(mutator identity.Mutator) identity.Mutator {
+			mutator.GiteaID = giteaId
+			return mutator
+		}
+	}
+
+	// Attempt to find the user by name, without prompting the user. This will often be sufficient.
+	users, err := SearchGiteaUsers(i.Name())
+	if err != nil {
+		return nil, err
+	}
+	if len(users) == 1 {
+		return setId(users[0].ID), nil
+	}
+
+	// If we didn't manage to find the gitea id for the user, let's try this time prompting for the user name
+	fmt.Println("Could not get Gitea ID for ", i.Name(), ", please insert the Gitea user name manually.")
+	userName, err := prompt("Gitea Username", "gitea username", i.Name())
+	if err != nil {
+		return nil, err
+	}
+
+	users, err = SearchGiteaUsers(userName)
+	if err != nil {
+		return nil, err
+	}
+	if len(users) == 1 {
+		return setId(users[0].ID), nil
+	}
+	for _, user := range users {
+		fmt.Println("matched user", user.FullName, "id", user.ID)
+	}
+
+	fmt.Println("I still did not find the user. If you know the Gitea user ID can provide it directly")
+	giteaIdStr, err := prompt("Gitea ID", "gitea id", "-1")
+	if err != nil {
+		return nil, err
+	}
+
+	giteaId, err := strconv.ParseInt(giteaIdStr, 0, 64)
+	if err != nil {
+		return nil, fmt.Errorf("The Gitea user ID must be a signed integral number")
+	}
+
+	return setId(giteaId), nil
+}
+
 // FetchGiteaReviewInfo exports review comments and status info from Gitea for
 // the given pull request and returns in a PullRequest object. If since review is specified
 // only updates will be returned
@@ -434,9 +571,27 @@ func FetchGiteaReviewInfo(owner string, repo string, id int64, since *GiteaInfo)
 
 	}
 
+	// The diff is only downloaded when the files changed are unknown, e.g. for the reviews stored
+	// before they were recorded, or when the head of the pull request moved
+	if since == nil || since.Files == nil || giteaHeadSha(since.RawPull) != giteaHeadSha(*pull) {
+		diff, _, err := giteaClient.GetPullRequestDiff(owner, repo, id)
+		if err != nil {
+			return nil, err
+		}
+		result.Files = append([]FileChange{}, parseDiffStat(string(diff))...)
+	}
+
 	return &result, nil
 }
 
+// giteaHeadSha returns the commit at the head of the pull request, if known
+func giteaHeadSha(pull gitea.PullRequest) string {
+	if pull.Head == nil {
+		return ""
+	}
+	return pull.Head.Sha
+}
+
 // UnmarshalJSON fulfils the Marshaler interface so that we can handle the author identity
 func (u *GiteaCommit) UnmarshalJSON(data []byte) error {
 	type rawUpdate struct {
@@ -490,3 +645,14 @@ func (u *GiteaReview) UnmarshalJSON(data []byte) error {
 
 	return nil
 }
+
+// sameGiteaReview tells if the id, in any of the accepted forms, designates the pull request
+func sameGiteaReview(id string, pr PullRequest) bool {
+	matched := giteaIdRegex.FindStringSubmatch(id)
+	if matched == nil {
+		return false
+	}
+	pullId, err := strconv.ParseInt(matched[4], 10, 64)
+	info := pr.(*GiteaInfo)
+	return err == nil && matched[1] == info.Owner && matched[2] == info.Repository && pullId == info.PullId
+}
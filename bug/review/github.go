@@ -0,0 +1,803 @@
+package review
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	termtext "github.com/MichaelMure/go-term-text"
+
+	"github.com/daedaleanai/git-ticket/entity"
+	"github.com/daedaleanai/git-ticket/identity"
+	"github.com/daedaleanai/git-ticket/repository"
+	"github.com/daedaleanai/git-ticket/util/colors"
+	"github.com/daedaleanai/git-ticket/util/rest"
+	"github.com/daedaleanai/git-ticket/util/timestamp"
+)
+
+// GithubLoginMetadataKey is the identity metadata holding the login of the user on GitHub
+const GithubLoginMetadataKey = "github-login"
+
+// GitHub review states
+const (
+	GithubReviewApproved         = "APPROVED"
+	GithubReviewChangesRequested = "CHANGES_REQUESTED"
+	GithubReviewCommented        = "COMMENTED"
+	GithubReviewDismissed        = "DISMISSED"
+	GithubReviewPending          = "PENDING"
+)
+
+// githubStateEvents are the issue events changing the state of a pull request
+var githubStateEvents = map[string]bool{
+	"closed":           true,
+	"reopened":         true,
+	"merged":           true,
+	"ready_for_review": true,
+	"convert_to_draft": true,
+}
+
+// GithubUser is a GitHub account, as returned by the GitHub API
+type GithubUser struct {
+	Login string `json:"login"`
+	Id    int64  `json:"id"`
+}
+
+// GithubPullRequestData is a pull request, as returned by the GitHub API
+type GithubPullRequestData struct {
+	Number    int64      `json:"number"`
+	Title     string     `json:"title"`
+	State     string     `json:"state"`
+	Draft     bool       `json:"draft"`
+	Merged    bool       `json:"merged"`
+	HtmlUrl   string     `json:"html_url"`
+	User      GithubUser `json:"user"`
+	Head      GithubRef  `json:"head"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// GithubRef is a branch of a pull request, as returned by the GitHub API
+type GithubRef struct {
+	Sha string `json:"sha"`
+}
+
+// GithubReviewData is a pull request review, as returned by the GitHub API
+type GithubReviewData struct {
+	Id          int64      `json:"id"`
+	User        GithubUser `json:"user"`
+	Body        string     `json:"body"`
+	State       string     `json:"state"`
+	CommitId    string     `json:"commit_id"`
+	HtmlUrl     string     `json:"html_url"`
+	SubmittedAt time.Time  `json:"submitted_at"`
+}
+
+// GithubCommentData is a pull request review comment, as returned by the GitHub API
+type GithubCommentData struct {
+	Id        int64      `json:"id"`
+	ReviewId  int64      `json:"pull_request_review_id"`
+	InReplyTo int64      `json:"in_reply_to_id,omitempty"`
+	User      GithubUser `json:"user"`
+	Body      string     `json:"body"`
+	Path      string     `json:"path"`
+	Line      int        `json:"line"`
+	CommitId  string     `json:"commit_id"`
+	HtmlUrl   string     `json:"html_url"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// GithubCommitData is a pull request commit, as returned by the GitHub API
+type GithubCommitData struct {
+	Sha     string `json:"sha"`
+	HtmlUrl string `json:"html_url"`
+	// Author is the GitHub account of the commit author, nil if the email isn't linked to any
+	Author *GithubUser `json:"author"`
+	Commit struct {
+		Message string `json:"message"`
+		Author  struct {
+			Name  string    `json:"name"`
+			Email string    `json:"email"`
+			Date  time.Time `json:"date"`
+		} `json:"author"`
+	} `json:"commit"`
+}
+
+// GithubEventData is an issue event, as returned by the GitHub API
+type GithubEventData struct {
+	Id        int64      `json:"id"`
+	Event     string     `json:"event"`
+	Actor     GithubUser `json:"actor"`
+	CommitId  string     `json:"commit_id"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// GithubFileData is a file changed by a pull request, as returned by the GitHub API
+type GithubFileData struct {
+	Filename  string `json:"filename"`
+	Additions int    `json:"additions"`
+	Deletions int    `json:"deletions"`
+}
+
+// GithubComment holds data about single review comment
+type GithubComment struct {
+	RawComment GithubCommentData
+	Update     bool
+}
+
+// Summary returns a string containing the comment text, and it's an inline
+// comment the file & line details, on a single line. Comments over 50 characters
+// are truncated.
+func (c *GithubComment) Summary() string {
+	// Put the comment on one line and output the first 50 characters
+	output := termtext.LeftPadMaxLine(strings.ReplaceAll(strings.ReplaceAll(c.RawComment.Body, "\n", " "), "\r", ""), 50, 0)
+
+	// If it's an inline comment append the file and line number
+	if c.RawComment.Path != "" {
+		output = output + fmt.Sprintf(" [%s:%d@%s]", c.RawComment.Path, c.RawComment.Line, c.RawComment.CommitId)
+	}
+
+	return output
+}
+
+// CommentId returns the GitHub id of the comment
+func (c *GithubComment) CommentId() string {
+	return strconv.FormatInt(c.RawComment.Id, 10)
+}
+
+// Body returns the complete text of the comment
+func (c *GithubComment) Body() string {
+	return c.RawComment.Body
+}
+
+// Location returns the file & line details of an inline comment
+func (c *GithubComment) Location() string {
+	if c.RawComment.Path == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s:%d@%s", c.RawComment.Path, c.RawComment.Line, c.RawComment.CommitId)
+}
+
+// InReplyTo returns the id of the comment replied to
+func (c *GithubComment) InReplyTo() string {
+	if c.RawComment.InReplyTo == 0 {
+		return ""
+	}
+	return strconv.FormatInt(c.RawComment.InReplyTo, 10)
+}
+
+// GithubReview holds single review event from GitHub
+type GithubReview struct {
+	RawReview GithubReviewData
+	Comments  []GithubComment
+	AuthorId  identity.Interface
+}
+
+// Author returns author of the change
+func (r *GithubReview) Author() identity.Interface {
+	return r.AuthorId
+}
+
+// Timestamp returns timestamp of the event
+func (r *GithubReview) Timestamp() timestamp.Timestamp {
+	return timestamp.Timestamp(r.RawReview.SubmittedAt.Unix())
+}
+
+// Status returns status change by this event (possibly just comment)
+func (r *GithubReview) Status() string {
+	return fmt.Sprintf("%s @ %s", r.RawReview.State, r.RawReview.CommitId)
+}
+
+// Changes returns list of all changes in event (e.g. all comments)
+func (r *GithubReview) Changes() []Change {
+	result := []Change{}
+	for _, c := range r.Comments {
+		cc := c // Without it golang store pointer to changing loop variable
+		result = append(result, &cc)
+	}
+	return result
+}
+
+// Summary returns a short description of the event
+func (r *GithubReview) Summary() string {
+	var output strings.Builder
+
+	if r.RawReview.State != GithubReviewCommented {
+		output.WriteString("[" + r.RawReview.State + "] ")
+	}
+
+	comments := len(r.Comments)
+	if r.RawReview.Body != "" {
+		comments = comments + 1
+	}
+
+	if comments > 1 {
+		output.WriteString("[" + strconv.Itoa(comments) + " comments] ")
+	} else if comments > 0 {
+		output.WriteString("[1 comment] ")
+	}
+
+	return output.String()
+}
+
+// GithubCommitChange is a wrapper to represent Change made by commit
+type GithubCommitChange struct {
+	RawCommit GithubCommitData
+}
+
+// Summary returns a string containing the commit message. Comments over 50 characters
+// are truncated.
+func (c *GithubCommitChange) Summary() string {
+	// Put the comment on one line and output the first 50 characters
+	message := termtext.LeftPadMaxLine(strings.ReplaceAll(c.RawCommit.Commit.Message, "\n", " "), 50, 0)
+
+	return fmt.Sprintf("%s [commit %s]", message, c.RawCommit.Sha)
+}
+
+// GithubCommit holds info about commit from GitHub pull request
+type GithubCommit struct {
+	RawCommit GithubCommitData
+	AuthorId  identity.Interface
+}
+
+// Author returns commit author
+func (c *GithubCommit) Author() identity.Interface {
+	return c.AuthorId
+}
+
+// Timestamp returns commit time
+func (c *GithubCommit) Timestamp() timestamp.Timestamp {
+	return timestamp.Timestamp(c.RawCommit.Commit.Author.Date.Unix())
+}
+
+// Changes returns list of a single change representing this commit
+func (c *GithubCommit) Changes() []Change {
+	return []Change{&GithubCommitChange{c.RawCommit}}
+}
+
+// Summary returns a string containing the commit message. Comments over 50 characters
+// are truncated.
+func (c *GithubCommit) Summary() string {
+	// Put the comment on one line and output the first 50 characters
+	message := termtext.LeftPadMaxLine(strings.ReplaceAll(c.RawCommit.Commit.Message, "\n", " "), 50, 0)
+
+	return fmt.Sprintf("[commit %s] %s", c.RawCommit.Sha, message)
+}
+
+// GithubEvent holds a change of the state of a GitHub pull request, e.g. it was merged
+type GithubEvent struct {
+	RawEvent GithubEventData
+	AuthorId identity.Interface
+}
+
+// Author returns the user who changed the state
+func (e *GithubEvent) Author() identity.Interface {
+	return e.AuthorId
+}
+
+// Timestamp returns timestamp of the event
+func (e *GithubEvent) Timestamp() timestamp.Timestamp {
+	return timestamp.Timestamp(e.RawEvent.CreatedAt.Unix())
+}
+
+// Changes returns no change, the event is a state change only
+func (e *GithubEvent) Changes() []Change {
+	return nil
+}
+
+// Summary returns a short description of the event
+func (e *GithubEvent) Summary() string {
+	return "[" + strings.ToUpper(e.RawEvent.Event) + "]"
+}
+
+// GithubInfo is GitHub-specific implementation of PullRequest
+type GithubInfo struct {
+	Owner      string
+	Repository string
+	Number     int64
+
+	RawPull GithubPullRequestData
+	Reviews []GithubReview
+	Commits []GithubCommit
+	Events  []GithubEvent
+	Files   []FileChange `json:",omitempty"`
+}
+
+// Id returns GitHub pull request id
+func (g *GithubInfo) Id() string {
+	return fmt.Sprintf("%s/%s#%d", g.Owner, g.Repository, g.Number)
+}
+
+// ReviewUrl returns GitHub pull request URL, if known. Otherwise it returns the review id
+func (g *GithubInfo) ReviewUrl() string {
+	if g.RawPull.HtmlUrl != "" {
+		return g.RawPull.HtmlUrl
+	}
+
+	githubUrl, _, _ := repository.GetGithubConfig()
+	if githubUrl != "" {
+		return fmt.Sprintf("%s/%s/%s/pull/%d", repository.GithubWebUrl(githubUrl), g.Owner, g.Repository, g.Number)
+	}
+
+	// Fallback to the ID if URL is unknown
+	return g.Id()
+}
+
+// Title returns GitHub pull request title
+func (g *GithubInfo) Title() string {
+	return g.RawPull.Title
+}
+
+// History returns all events from pull request
+func (g *GithubInfo) History() []TimelineEvent {
+	result := []TimelineEvent{}
+
+	for _, r := range g.Reviews {
+		upd := r
+		result = append(result, &upd)
+	}
+	for _, c := range g.Commits {
+		upd := c
+		result = append(result, &upd)
+	}
+	for _, e := range g.Events {
+		upd := e
+		result = append(result, &upd)
+	}
+	return result
+}
+
+// IsEmpty check if there is any changes
+func (g *GithubInfo) IsEmpty() bool {
+	return len(g.Reviews)+len(g.Commits)+len(g.Events)+len(g.Files) == 0
+}
+
+// EnsureIdentities validated if all users are resolved
+func (g *GithubInfo) EnsureIdentities(resolver identity.Resolver, found map[entity.Id]identity.Interface) error {
+	ensure := func(author *identity.Interface) error {
+		entity := (*author).Id()
+
+		if _, ok := found[entity]; !ok {
+			id, err := resolver.ResolveIdentity(entity)
+			if err != nil {
+				return err
+			}
+			found[entity] = id
+		}
+
+		*author = found[entity]
+		return nil
+	}
+
+	for i := range g.Reviews {
+		if err := ensure(&g.Reviews[i].AuthorId); err != nil {
+			return err
+		}
+	}
+	for i := range g.Commits {
+		if err := ensure(&g.Commits[i].AuthorId); err != nil {
+			return err
+		}
+	}
+	for i := range g.Events {
+		if err := ensure(&g.Events[i].AuthorId); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FetchIdentities resolves users from pull request to git-ticket identities
+func (g *GithubInfo) FetchIdentities(resolver IdentityResolver) error {
+	for i, r := range g.Reviews {
+		user, err := resolver.ResolveIdentityMetadata(GithubLoginMetadataKey, r.RawReview.User.Login)
+		if err != nil {
+			return fmt.Errorf("%s (GitHub login: %s)", err, r.RawReview.User.Login)
+		}
+
+		g.Reviews[i].AuthorId = user
+	}
+
+	for i, c := range g.Commits {
+		if c.RawCommit.Author == nil {
+			return fmt.Errorf("the author of commit %s, %s <%s>, has no GitHub account",
+				c.RawCommit.Sha, c.RawCommit.Commit.Author.Name, c.RawCommit.Commit.Author.Email)
+		}
+
+		user, err := resolver.ResolveIdentityMetadata(GithubLoginMetadataKey, c.RawCommit.Author.Login)
+		if err != nil {
+			return fmt.Errorf("%s: %s (GitHub login: %s)", err, c.RawCommit.Commit.Author.Name, c.RawCommit.Author.Login)
+		}
+
+		g.Commits[i].AuthorId = user
+	}
+
+	for i, e := range g.Events {
+		user, err := resolver.ResolveIdentityMetadata(GithubLoginMetadataKey, e.RawEvent.Actor.Login)
+		if err != nil {
+			return fmt.Errorf("%s (GitHub login: %s)", err, e.RawEvent.Actor.Login)
+		}
+
+		g.Events[i].AuthorId = user
+	}
+
+	return nil
+}
+
+// Merge updates state from new one
+func (g *GithubInfo) Merge(update PullRequest) {
+	if update == nil {
+		return
+	}
+
+	u := update.(*GithubInfo)
+	g.Owner = u.Owner
+	g.Repository = u.Repository
+	g.Number = u.Number
+	g.RawPull = u.RawPull
+	g.Reviews = append(g.Reviews, u.Reviews...)
+	g.Commits = append(g.Commits, u.Commits...)
+	g.Events = append(g.Events, u.Events...)
+	if u.Files != nil {
+		g.Files = u.Files
+	}
+}
+
+// ChangedFiles returns the files changed by the pull request
+func (g *GithubInfo) ChangedFiles() []FileChange {
+	return g.Files
+}
+
+// LatestOverallStatus returns the latest overall status set for this review.
+func (g *GithubInfo) LatestOverallStatus() string {
+	if g.RawPull.Merged {
+		return colors.Green("MERGED")
+	}
+	if g.RawPull.State == "closed" {
+		return "CLOSED"
+	}
+
+	// Comments don't change the review state of a reviewer
+	result := map[string]*GithubReview{}
+	for _, r := range g.Reviews {
+		switch r.RawReview.State {
+		case GithubReviewApproved, GithubReviewChangesRequested, GithubReviewDismissed:
+		default:
+			continue
+		}
+		if s, ok := result[r.RawReview.User.Login]; !ok || s.Timestamp() < r.Timestamp() {
+			us := r
+			result[r.RawReview.User.Login] = &us
+		}
+	}
+
+	approved := false
+	rejected := false
+
+	for _, r := range result {
+		if r.RawReview.State == GithubReviewApproved {
+			approved = true
+		} else if r.RawReview.State == GithubReviewChangesRequested {
+			rejected = true
+		}
+	}
+
+	if approved && !rejected {
+		return colors.Green(GithubReviewApproved)
+	} else if rejected {
+		return colors.Red(GithubReviewChangesRequested)
+	} else {
+		return GithubReviewPending
+	}
+}
+
+// LatestUserStatuses returns a map of users and the latest status they set for
+// this review.
+func (g *GithubInfo) LatestUserStatuses() map[string]UserStatus {
+	result := map[string]UserStatus{}
+
+	for _, r := range g.Reviews {
+		if s, ok := result[r.RawReview.User.Login]; !ok || s.Timestamp() < r.Timestamp() {
+			us := r // Without it golang store pointer to changing loop variable
+			result[r.RawReview.User.Login] = &us
+		}
+	}
+
+	return result
+}
+
+func init() {
+	Register(&Provider{
+		Name:      "github",
+		Tag:       "reviewGithub",
+		Formats:   []string{"<owner>/<repo>#<id>", "https://<host>/<owner>/<repo>/pull/<id>"},
+		IdPattern: githubIdRegex,
+		Configured: func() bool {
+			_, _, err := repository.GetGithubConfig()
+			return err == nil
+		},
+		Incremental:   true,
+		New:           func() PullRequest { return &GithubInfo{} },
+		Fetch:         fetchGithub,
+		SameReview:    sameGithubReview,
+		FixupIdentity: fixupGithubIdentity,
+	})
+}
+
+var githubIdRegex = regexp.MustCompile(`^(?:https?://[^/]+/)?([\w.-]+)/([\w.-]+)(?:#|/pull/)(\d+)/?$`)
+
+func fetchGithub(id string, since PullRequest) (PullRequest, error) {
+	matched := githubIdRegex.FindStringSubmatch(id)
+	if matched == nil {
+		return nil, fmt.Errorf("unable to parse id: %s", id)
+	}
+	number, err := strconv.ParseInt(matched[3], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse id: %s", err)
+	}
+	var old *GithubInfo
+	if since != nil {
+		old = since.(*GithubInfo)
+	}
+	return FetchGithubReviewInfo(matched[1], matched[2], number, old)
+}
+
+// fixupGithubIdentity prompts for the GitHub login of identities which don't have one
+func fixupGithubIdentity(i *identity.Identity, prompt Prompt) (func(identity.Mutator) identity.Mutator, error) {
+	if i.MutableMetadata()[GithubLoginMetadataKey] != "" {
+		return nil, nil
+	}
+
+	fmt.Println("No GitHub login for ", i.Name(), ", please insert it, or leave empty to skip.")
+	login, err := prompt("GitHub login", "github login", i.Login())
+	if err != nil || login == "" {
+		return nil, err
+	}
+
+	client, err := newGithubClient()
+	if err != nil {
+		return nil, err
+	}
+	if err := client.Get("/users/"+url.PathEscape(login), &GithubUser{}); err != nil {
+		return nil, fmt.Errorf("no GitHub user %s: %s", login, err)
+	}
+
+	return func(mutator identity.Mutator) identity.Mutator {
+		mutator.Metadata[GithubLoginMetadataKey] = login
+		return mutator
+	}, nil
+}
+
+// newGithubClient returns a client ready to be queried. Must be called within a git repo
+// which has the GitHub API token set in the git config.
+func newGithubClient() (*rest.Client, error) {
+	githubUrl, apiToken, err := repository.GetGithubConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	return rest.NewClient(githubUrl, map[string]string{
+		"Accept":        "application/vnd.github+json",
+		"Authorization": "Bearer " + apiToken,
+	}), nil
+}
+
+// FetchGithubReviewInfo exports reviews, review comments, commits and state changes from GitHub
+// for the given pull request and returns in a PullRequest object. If since review is specified
+// only updates will be returned
+func FetchGithubReviewInfo(owner string, repo string, number int64, since *GithubInfo) (PullRequest, error) {
+	client, err := newGithubClient()
+	if err != nil {
+		return nil, err
+	}
+
+	return fetchGithubReviewInfo(client, owner, repo, number, since)
+}
+
+func fetchGithubReviewInfo(client *rest.Client, owner string, repo string, number int64, since *GithubInfo) (*GithubInfo, error) {
+	knownReviews := map[int64]struct{}{}
+	knownComments := map[int64]time.Time{}
+	knownCommits := map[string]struct{}{}
+	knownEvents := map[int64]struct{}{}
+
+	if since != nil {
+		for _, r := range since.Reviews {
+			knownReviews[r.RawReview.Id] = struct{}{}
+			for _, c := range r.Comments {
+				if c.RawComment.UpdatedAt.After(knownComments[c.RawComment.Id]) {
+					knownComments[c.RawComment.Id] = c.RawComment.UpdatedAt
+				}
+			}
+		}
+		for _, c := range since.Commits {
+			knownCommits[c.RawCommit.Sha] = struct{}{}
+		}
+		for _, e := range since.Events {
+			knownEvents[e.RawEvent.Id] = struct{}{}
+		}
+	}
+
+	pullPath := fmt.Sprintf("/repos/%s/%s/pulls/%d", url.PathEscape(owner), url.PathEscape(repo), number)
+
+	result := GithubInfo{
+		Owner:      owner,
+		Repository: repo,
+		Number:     number,
+	}
+
+	err := client.Get(pullPath, &result.RawPull)
+	if err != nil {
+		return nil, err
+	}
+
+	var reviews []GithubReviewData
+	if err := client.GetList(pullPath+"/reviews", &reviews); err != nil {
+		return nil, err
+	}
+
+	var comments []GithubCommentData
+	if err := client.GetList(pullPath+"/comments", &comments); err != nil {
+		return nil, err
+	}
+
+	reviewComments := map[int64][]GithubComment{}
+	for _, c := range comments {
+		if updated, ok := knownComments[c.Id]; ok {
+			if updated.Before(c.UpdatedAt) {
+				reviewComments[c.ReviewId] = append(reviewComments[c.ReviewId], GithubComment{RawComment: c, Update: true})
+			}
+			continue
+		}
+		reviewComments[c.ReviewId] = append(reviewComments[c.ReviewId], GithubComment{RawComment: c})
+	}
+
+	for _, r := range reviews {
+		// Pending reviews are only visible to their author until submitted
+		if r.State == GithubReviewPending {
+			continue
+		}
+		if _, ok := knownReviews[r.Id]; ok && len(reviewComments[r.Id]) == 0 {
+			continue
+		}
+		result.Reviews = append(result.Reviews, GithubReview{
+			RawReview: r,
+			Comments:  reviewComments[r.Id],
+		})
+	}
+
+	var commits []GithubCommitData
+	if err := client.GetList(pullPath+"/commits", &commits); err != nil {
+		return nil, err
+	}
+
+	for _, c := range commits {
+		if _, ok := knownCommits[c.Sha]; ok {
+			continue
+		}
+		result.Commits = append(result.Commits, GithubCommit{RawCommit: c})
+	}
+
+	var events []GithubEventData
+	issuePath := fmt.Sprintf("/repos/%s/%s/issues/%d/events", url.PathEscape(owner), url.PathEscape(repo), number)
+	if err := client.GetList(issuePath, &events); err != nil {
+		return nil, err
+	}
+
+	for _, e := range events {
+		if _, ok := knownEvents[e.Id]; ok || !githubStateEvents[e.Event] {
+			continue
+		}
+		result.Events = append(result.Events, GithubEvent{RawEvent: e})
+	}
+
+	// The files are only downloaded when they are unknown, e.g. for the reviews stored before they
+	// were recorded, or when the head of the pull request moved
+	if since == nil || since.Files == nil || since.RawPull.Head.Sha != result.RawPull.Head.Sha {
+		var files []GithubFileData
+		if err := client.GetList(pullPath+"/files", &files); err != nil {
+			return nil, err
+		}
+
+		result.Files = []FileChange{}
+		for _, f := range files {
+			result.Files = append(result.Files, FileChange{Path: f.Filename, Additions: f.Additions, Deletions: f.Deletions})
+		}
+	}
+
+	return &result, nil
+}
+
+// UnmarshalJSON fulfils the Marshaler interface so that we can handle the author identity
+func (r *GithubReview) UnmarshalJSON(data []byte) error {
+	type rawUpdate struct {
+		RawReview GithubReviewData
+		Comments  []GithubComment
+		AuthorId  json.RawMessage
+	}
+
+	var raw rawUpdate
+	err := json.Unmarshal(data, &raw)
+	if err != nil {
+		return err
+	}
+
+	r.RawReview = raw.RawReview
+	r.Comments = raw.Comments
+
+	if raw.AuthorId != nil {
+		author, err := identity.UnmarshalJSON(raw.AuthorId)
+		if err != nil {
+			return err
+		}
+		r.AuthorId = author
+	}
+
+	return nil
+}
+
+// UnmarshalJSON fulfils the Marshaler interface so that we can handle the author identity
+func (c *GithubCommit) UnmarshalJSON(data []byte) error {
+	type rawUpdate struct {
+		RawCommit GithubCommitData
+		AuthorId  json.RawMessage
+	}
+
+	var raw rawUpdate
+	err := json.Unmarshal(data, &raw)
+	if err != nil {
+		return err
+	}
+
+	c.RawCommit = raw.RawCommit
+
+	if raw.AuthorId != nil {
+		author, err := identity.UnmarshalJSON(raw.AuthorId)
+		if err != nil {
+			return err
+		}
+		c.AuthorId = author
+	}
+
+	return nil
+}
+
+// UnmarshalJSON fulfils the Marshaler interface so that we can handle the author identity
+func (e *GithubEvent) UnmarshalJSON(data []byte) error {
+	type rawUpdate struct {
+		RawEvent GithubEventData
+		AuthorId json.RawMessage
+	}
+
+	var raw rawUpdate
+	err := json.Unmarshal(data, &raw)
+	if err != nil {
+		return err
+	}
+
+	e.RawEvent = raw.RawEvent
+
+	if raw.AuthorId != nil {
+		author, err := identity.UnmarshalJSON(raw.AuthorId)
+		if err != nil {
+			return err
+		}
+		e.AuthorId = author
+	}
+
+	return nil
+}
+
+// sameGithubReview tells if the id, in any of the accepted forms, designates the pull request
+func sameGithubReview(id string, pr PullRequest) bool {
+	matched := githubIdRegex.FindStringSubmatch(id)
+	if matched == nil {
+		return false
+	}
+	number, err := strconv.ParseInt(matched[3], 10, 64)
+	info := pr.(*GithubInfo)
+	return err == nil && matched[1] == info.Owner && matched[2] == info.Repository && number == info.Number
+}
@@ -0,0 +1,157 @@
+package review
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/daedaleanai/git-ticket/identity"
+	"github.com/daedaleanai/git-ticket/util/rest"
+)
+
+type githubTestResolver map[string]identity.Interface
+
+func (r githubTestResolver) ResolveIdentityPhabID(string) (identity.Interface, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
+func (r githubTestResolver) ResolveIdentityGiteaID(int64) (identity.Interface, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
+func (r githubTestResolver) ResolveIdentityMetadata(key string, value string) (identity.Interface, error) {
+	if i, ok := r[value]; ok {
+		return i, nil
+	}
+	return nil, fmt.Errorf("identity doesn't exist")
+}
+
+func newGithubTestServer(t *testing.T, responses map[string]string) *rest.Client {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+
+		response, ok := responses[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(response))
+	}))
+	t.Cleanup(server.Close)
+
+	return rest.NewClient(server.URL, map[string]string{"Authorization": "Bearer token"})
+}
+
+func TestFetchGithubReviewInfo(t *testing.T) {
+	responses := map[string]string{
+		"/repos/octo/hello/pulls/7": `{"number": 7, "title": "Add greeting", "state": "open",
+			"html_url": "https://github.com/octo/hello/pull/7", "user": {"login": "alice"}, "head": {"sha": "abc"}}`,
+		"/repos/octo/hello/pulls/7/reviews": `[
+			{"id": 1, "user": {"login": "bob"}, "body": "", "state": "CHANGES_REQUESTED", "commit_id": "abc",
+			 "submitted_at": "2021-03-04T12:00:00Z"},
+			{"id": 2, "user": {"login": "bob"}, "state": "PENDING"}]`,
+		"/repos/octo/hello/pulls/7/comments": `[
+			{"id": 10, "pull_request_review_id": 1, "user": {"login": "bob"}, "body": "Typo", "path": "hello.go",
+			 "line": 3, "commit_id": "abc", "updated_at": "2021-03-04T12:00:00Z"}]`,
+		"/repos/octo/hello/pulls/7/commits": `[
+			{"sha": "abc", "author": {"login": "alice"},
+			 "commit": {"message": "Add greeting", "author": {"name": "Alice", "date": "2021-03-04T10:00:00Z"}}}]`,
+		"/repos/octo/hello/issues/7/events": `[
+			{"id": 100, "event": "labeled", "actor": {"login": "alice"}, "created_at": "2021-03-04T11:00:00Z"}]`,
+		"/repos/octo/hello/pulls/7/files": `[{"filename": "hello.go", "additions": 5, "deletions": 1}]`,
+	}
+	client := newGithubTestServer(t, responses)
+
+	info, err := fetchGithubReviewInfo(client, "octo", "hello", 7, nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, "octo/hello#7", info.Id())
+	assert.Equal(t, "Add greeting", info.Title())
+	assert.Equal(t, "https://github.com/octo/hello/pull/7", info.ReviewUrl())
+	require.Len(t, info.Reviews, 1)
+	require.Len(t, info.Reviews[0].Comments, 1)
+	assert.Contains(t, info.Reviews[0].Comments[0].Summary(), "[hello.go:3@abc]")
+	require.Len(t, info.Commits, 1)
+	assert.Empty(t, info.Events)
+	assert.Len(t, info.History(), 2)
+	assert.Equal(t, []FileChange{{Path: "hello.go", Additions: 5, Deletions: 1}}, info.ChangedFiles())
+
+	alice := identity.NewBare("Alice", "alice@example.com")
+	bob := identity.NewBare("Bob", "bob@example.com")
+	resolver := githubTestResolver{"alice": alice, "bob": bob}
+
+	require.NoError(t, info.FetchIdentities(resolver))
+	assert.Equal(t, bob, info.Reviews[0].Author())
+	assert.Equal(t, alice, info.Commits[0].Author())
+	assert.Contains(t, info.LatestOverallStatus(), GithubReviewChangesRequested)
+	assert.Contains(t, info.LatestUserStatuses(), "bob")
+
+	// The comment is edited, bob approves and alice merges
+	responses["/repos/octo/hello/pulls/7/reviews"] = `[
+		{"id": 1, "user": {"login": "bob"}, "body": "", "state": "CHANGES_REQUESTED", "commit_id": "abc",
+		 "submitted_at": "2021-03-04T12:00:00Z"},
+		{"id": 3, "user": {"login": "bob"}, "body": "Thanks", "state": "APPROVED", "commit_id": "abc",
+		 "submitted_at": "2021-03-05T12:00:00Z"}]`
+	responses["/repos/octo/hello/pulls/7/comments"] = `[
+		{"id": 10, "pull_request_review_id": 1, "user": {"login": "bob"}, "body": "Typo!", "path": "hello.go",
+		 "line": 3, "commit_id": "abc", "updated_at": "2021-03-05T11:00:00Z"}]`
+	responses["/repos/octo/hello/issues/7/events"] = `[
+		{"id": 100, "event": "labeled", "actor": {"login": "alice"}, "created_at": "2021-03-04T11:00:00Z"},
+		{"id": 101, "event": "merged", "actor": {"login": "alice"}, "commit_id": "abc", "created_at": "2021-03-05T13:00:00Z"}]`
+
+	update, err := fetchGithubReviewInfo(client, "octo", "hello", 7, info)
+	require.NoError(t, err)
+
+	require.Len(t, update.Reviews, 2)
+	assert.Equal(t, int64(1), update.Reviews[0].RawReview.Id)
+	require.Len(t, update.Reviews[0].Comments, 1)
+	assert.True(t, update.Reviews[0].Comments[0].Update)
+	assert.Equal(t, int64(3), update.Reviews[1].RawReview.Id)
+	assert.Empty(t, update.Commits)
+	require.Len(t, update.Events, 1)
+	assert.Equal(t, "[MERGED]", update.Events[0].Summary())
+
+	require.NoError(t, update.FetchIdentities(resolver))
+	info.Merge(update)
+	assert.Contains(t, info.LatestOverallStatus(), GithubReviewApproved)
+
+	// Nothing new
+	update, err = fetchGithubReviewInfo(client, "octo", "hello", 7, info)
+	require.NoError(t, err)
+	assert.True(t, update.IsEmpty())
+	assert.Nil(t, update.ChangedFiles())
+
+	// The head moves with only the files changed
+	responses["/repos/octo/hello/pulls/7"] = `{"number": 7, "title": "Add greeting", "state": "open",
+		"html_url": "https://github.com/octo/hello/pull/7", "user": {"login": "alice"}, "head": {"sha": "abd"}}`
+	responses["/repos/octo/hello/pulls/7/files"] = `[{"filename": "greeting.go", "additions": 5, "deletions": 0}]`
+	update, err = fetchGithubReviewInfo(client, "octo", "hello", 7, info)
+	require.NoError(t, err)
+	assert.False(t, update.IsEmpty())
+	info.Merge(update)
+	assert.Equal(t, []FileChange{{Path: "greeting.go", Additions: 5, Deletions: 0}}, info.ChangedFiles())
+
+	// Commits from emails not linked to a GitHub account can't be attributed
+	responses["/repos/octo/hello/pulls/7/commits"] = `[
+		{"sha": "def", "commit": {"message": "Fix", "author": {"name": "Eve", "email": "eve@example.com"}}}]`
+	update, err = fetchGithubReviewInfo(client, "octo", "hello", 7, info)
+	require.NoError(t, err)
+	assert.Error(t, update.FetchIdentities(resolver))
+
+	_, err = fetchGithubReviewInfo(client, "octo", "hello", 8, nil)
+	assert.Error(t, err)
+}
+
+func TestGithubProviderForId(t *testing.T) {
+	p, err := ProviderForId("https://github.com/octo/hello/pull/7")
+	require.NoError(t, err)
+	assert.Equal(t, "github", p.Name)
+
+	p, err = ProviderForId("https://git.example.com/octo/hello.js/pull/7")
+	require.NoError(t, err)
+	assert.Equal(t, "github", p.Name)
+}
@@ -0,0 +1,711 @@
+package review
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	termtext "github.com/MichaelMure/go-term-text"
+
+	"github.com/daedaleanai/git-ticket/entity"
+	"github.com/daedaleanai/git-ticket/identity"
+	"github.com/daedaleanai/git-ticket/repository"
+	"github.com/daedaleanai/git-ticket/util/colors"
+	"github.com/daedaleanai/git-ticket/util/rest"
+	"github.com/daedaleanai/git-ticket/util/timestamp"
+)
+
+// GitlabUsernameMetadataKey is the identity metadata holding the username of the user on GitLab
+const GitlabUsernameMetadataKey = "gitlab-username"
+
+// Kinds of the GitLab merge request events, recorded by GitLab as system notes
+const (
+	GitlabEventApproved   = "APPROVED"
+	GitlabEventUnapproved = "UNAPPROVED"
+	GitlabEventPushed     = "PUSHED"
+	GitlabEventMerged     = "MERGED"
+	GitlabEventClosed     = "CLOSED"
+	GitlabEventReopened   = "REOPENED"
+)
+
+var (
+	gitlabPushedRegex = regexp.MustCompile(`^added (\d+) (?:new )?commits?`)
+	gitlabCommitRegex = regexp.MustCompile(`(?m)([0-9a-f]{7,40}) - (.+?)(?:</li>|$)`)
+)
+
+// GitlabUser is a GitLab account, as returned by the GitLab API
+type GitlabUser struct {
+	Id       int64  `json:"id"`
+	Username string `json:"username"`
+	Name     string `json:"name"`
+}
+
+// GitlabMergeRequestData is a merge request, as returned by the GitLab API
+type GitlabMergeRequestData struct {
+	Iid       int64      `json:"iid"`
+	Title     string     `json:"title"`
+	State     string     `json:"state"`
+	Draft     bool       `json:"draft"`
+	WebUrl    string     `json:"web_url"`
+	Sha       string     `json:"sha"`
+	Author    GitlabUser `json:"author"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// GitlabApprovalsData is the approval state of a merge request, as returned by the GitLab API
+type GitlabApprovalsData struct {
+	Approved          bool `json:"approved"`
+	ApprovalsRequired int  `json:"approvals_required"`
+	ApprovalsLeft     int  `json:"approvals_left"`
+	ApprovedBy        []struct {
+		User GitlabUser `json:"user"`
+	} `json:"approved_by"`
+}
+
+// GitlabPipelineData is a merge request pipeline, as returned by the GitLab API
+type GitlabPipelineData struct {
+	Id        int64     `json:"id"`
+	Sha       string    `json:"sha"`
+	Status    string    `json:"status"`
+	WebUrl    string    `json:"web_url"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// GitlabPositionData is the position of an inline comment, as returned by the GitLab API
+type GitlabPositionData struct {
+	HeadSha string `json:"head_sha"`
+	OldPath string `json:"old_path"`
+	NewPath string `json:"new_path"`
+	OldLine int    `json:"old_line"`
+	NewLine int    `json:"new_line"`
+}
+
+// GitlabDiffData is the diff of a file changed by a merge request, as returned by the GitLab API
+type GitlabDiffData struct {
+	OldPath string `json:"old_path"`
+	NewPath string `json:"new_path"`
+	Diff    string `json:"diff"`
+}
+
+// GitlabNoteData is a merge request note, as returned by the GitLab API
+type GitlabNoteData struct {
+	Id         int64               `json:"id"`
+	Type       string              `json:"type"`
+	Body       string              `json:"body"`
+	Author     GitlabUser          `json:"author"`
+	System     bool                `json:"system"`
+	Resolvable bool                `json:"resolvable"`
+	Resolved   bool                `json:"resolved"`
+	Position   *GitlabPositionData `json:"position,omitempty"`
+	CreatedAt  time.Time           `json:"created_at"`
+	UpdatedAt  time.Time           `json:"updated_at"`
+}
+
+// gitlabDiscussionData is a merge request discussion, as returned by the GitLab API
+type gitlabDiscussionData struct {
+	Id    string           `json:"id"`
+	Notes []GitlabNoteData `json:"notes"`
+}
+
+// GitlabNote holds a comment of a merge request discussion
+type GitlabNote struct {
+	RawNote      GitlabNoteData
+	DiscussionId string
+	Update       bool
+	AuthorId     identity.Interface
+}
+
+// Author returns the author of the comment
+func (n *GitlabNote) Author() identity.Interface {
+	return n.AuthorId
+}
+
+// Timestamp returns the time of the comment
+func (n *GitlabNote) Timestamp() timestamp.Timestamp {
+	return timestamp.Timestamp(n.RawNote.CreatedAt.Unix())
+}
+
+// Changes returns the comment itself
+func (n *GitlabNote) Changes() []Change {
+	return []Change{n}
+}
+
+// Summary returns a string containing the comment text, and it's an inline
+// comment the file & line details, on a single line. Comments over 50 characters
+// are truncated.
+func (n *GitlabNote) Summary() string {
+	// Put the comment on one line and output the first 50 characters
+	output := termtext.LeftPadMaxLine(strings.ReplaceAll(strings.ReplaceAll(n.RawNote.Body, "\n", " "), "\r", ""), 50, 0)
+
+	// If it's an inline comment append the file and line number
+	if p := n.RawNote.Position; p != nil {
+		if p.NewPath != "" && p.NewLine != 0 {
+			output = output + fmt.Sprintf(" [%s:%d@%s]", p.NewPath, p.NewLine, p.HeadSha)
+		} else {
+			output = output + fmt.Sprintf(" [%s:%d@%s]", p.OldPath, p.OldLine, p.HeadSha)
+		}
+	}
+	if n.Update {
+		output = output + " (edited)"
+	}
+
+	return output
+}
+
+// CommentId returns the GitLab id of the note
+func (n *GitlabNote) CommentId() string {
+	return strconv.FormatInt(n.RawNote.Id, 10)
+}
+
+// Body returns the complete text of the note
+func (n *GitlabNote) Body() string {
+	return n.RawNote.Body
+}
+
+// Location returns the file & line details of an inline comment
+func (n *GitlabNote) Location() string {
+	p := n.RawNote.Position
+	if p == nil {
+		return ""
+	}
+	if p.NewPath != "" && p.NewLine != 0 {
+		return fmt.Sprintf("%s:%d@%s", p.NewPath, p.NewLine, p.HeadSha)
+	}
+	return fmt.Sprintf("%s:%d@%s", p.OldPath, p.OldLine, p.HeadSha)
+}
+
+// InReplyTo returns the discussion of the note, the notes of a discussion being its replies
+func (n *GitlabNote) InReplyTo() string {
+	if n.DiscussionId == "" {
+		return ""
+	}
+	return "discussion:" + n.DiscussionId
+}
+
+// GitlabCommitChange is a commit pushed to a merge request
+type GitlabCommitChange struct {
+	Sha     string
+	Message string
+}
+
+// Summary returns a string containing the commit message. Comments over 50 characters
+// are truncated.
+func (c *GitlabCommitChange) Summary() string {
+	message := termtext.LeftPadMaxLine(c.Message, 50, 0)
+
+	return fmt.Sprintf("%s [commit %s]", message, c.Sha)
+}
+
+// GitlabEvent holds an event of a merge request recorded by GitLab as a system note, like an
+// approval, a push or a state change
+type GitlabEvent struct {
+	RawNote  GitlabNoteData
+	Kind     string
+	AuthorId identity.Interface
+}
+
+// newGitlabEvent returns the event the system note records, if it's one of interest
+func newGitlabEvent(note GitlabNoteData) (GitlabEvent, bool) {
+	body := strings.TrimSpace(note.Body)
+
+	var kind string
+	switch {
+	case body == "approved this merge request":
+		kind = GitlabEventApproved
+	case body == "unapproved this merge request":
+		kind = GitlabEventUnapproved
+	case body == "merged":
+		kind = GitlabEventMerged
+	case body == "closed":
+		kind = GitlabEventClosed
+	case body == "reopened":
+		kind = GitlabEventReopened
+	case gitlabPushedRegex.MatchString(body):
+		kind = GitlabEventPushed
+	default:
+		return GitlabEvent{}, false
+	}
+
+	return GitlabEvent{RawNote: note, Kind: kind}, true
+}
+
+// Author returns the user who caused the event
+func (e *GitlabEvent) Author() identity.Interface {
+	return e.AuthorId
+}
+
+// Timestamp returns timestamp of the event
+func (e *GitlabEvent) Timestamp() timestamp.Timestamp {
+	return timestamp.Timestamp(e.RawNote.CreatedAt.Unix())
+}
+
+// Status returns the kind of the event
+func (e *GitlabEvent) Status() string {
+	return e.Kind
+}
+
+// Changes returns the commits pushed, if any
+func (e *GitlabEvent) Changes() []Change {
+	if e.Kind != GitlabEventPushed {
+		return nil
+	}
+
+	var result []Change
+	for _, m := range gitlabCommitRegex.FindAllStringSubmatch(e.RawNote.Body, -1) {
+		result = append(result, &GitlabCommitChange{Sha: m[1], Message: m[2]})
+	}
+	return result
+}
+
+// Summary returns a short description of the event
+func (e *GitlabEvent) Summary() string {
+	if m := gitlabPushedRegex.FindStringSubmatch(e.RawNote.Body); m != nil && e.Kind == GitlabEventPushed {
+		if m[1] == "1" {
+			return "[" + e.Kind + "] 1 commit"
+		}
+		return "[" + e.Kind + "] " + m[1] + " commits"
+	}
+	return "[" + e.Kind + "]"
+}
+
+// GitlabInfo is GitLab-specific implementation of PullRequest
+type GitlabInfo struct {
+	Project string
+	Iid     int64
+
+	RawMerge     GitlabMergeRequestData
+	RawApprovals GitlabApprovalsData
+	// Pipelines holds the latest state of the pipelines of the merge request, newest first
+	Pipelines []GitlabPipelineData
+	Notes     []GitlabNote
+	Events    []GitlabEvent
+	Files     []FileChange `json:",omitempty"`
+}
+
+// Id returns GitLab merge request id
+func (g *GitlabInfo) Id() string {
+	return fmt.Sprintf("%s!%d", g.Project, g.Iid)
+}
+
+// ReviewUrl returns GitLab merge request URL, if known. Otherwise it returns the review id
+func (g *GitlabInfo) ReviewUrl() string {
+	if g.RawMerge.WebUrl != "" {
+		return g.RawMerge.WebUrl
+	}
+
+	gitlabUrl, _, _ := repository.GetGitlabConfig()
+	if gitlabUrl != "" {
+		return fmt.Sprintf("%s/%s/-/merge_requests/%d", gitlabUrl, g.Project, g.Iid)
+	}
+
+	// Fallback to the ID if URL is unknown
+	return g.Id()
+}
+
+// Title returns GitLab merge request title
+func (g *GitlabInfo) Title() string {
+	return g.RawMerge.Title
+}
+
+// History returns all events from merge request
+func (g *GitlabInfo) History() []TimelineEvent {
+	result := []TimelineEvent{}
+
+	for _, n := range g.Notes {
+		upd := n
+		result = append(result, &upd)
+	}
+	for _, e := range g.Events {
+		upd := e
+		result = append(result, &upd)
+	}
+	return result
+}
+
+// IsEmpty check if there is any changes
+func (g *GitlabInfo) IsEmpty() bool {
+	return len(g.Notes)+len(g.Events)+len(g.Pipelines)+len(g.Files) == 0
+}
+
+// EnsureIdentities validated if all users are resolved
+func (g *GitlabInfo) EnsureIdentities(resolver identity.Resolver, found map[entity.Id]identity.Interface) error {
+	ensure := func(author *identity.Interface) error {
+		entity := (*author).Id()
+
+		if _, ok := found[entity]; !ok {
+			id, err := resolver.ResolveIdentity(entity)
+			if err != nil {
+				return err
+			}
+			found[entity] = id
+		}
+
+		*author = found[entity]
+		return nil
+	}
+
+	for i := range g.Notes {
+		if err := ensure(&g.Notes[i].AuthorId); err != nil {
+			return err
+		}
+	}
+	for i := range g.Events {
+		if err := ensure(&g.Events[i].AuthorId); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FetchIdentities resolves users from merge request to git-ticket identities
+func (g *GitlabInfo) FetchIdentities(resolver IdentityResolver) error {
+	for i, n := range g.Notes {
+		user, err := resolver.ResolveIdentityMetadata(GitlabUsernameMetadataKey, n.RawNote.Author.Username)
+		if err != nil {
+			return fmt.Errorf("%s: %s (GitLab username: %s)", err, n.RawNote.Author.Name, n.RawNote.Author.Username)
+		}
+
+		g.Notes[i].AuthorId = user
+	}
+
+	for i, e := range g.Events {
+		user, err := resolver.ResolveIdentityMetadata(GitlabUsernameMetadataKey, e.RawNote.Author.Username)
+		if err != nil {
+			return fmt.Errorf("%s: %s (GitLab username: %s)", err, e.RawNote.Author.Name, e.RawNote.Author.Username)
+		}
+
+		g.Events[i].AuthorId = user
+	}
+
+	return nil
+}
+
+// Merge updates state from new one
+func (g *GitlabInfo) Merge(update PullRequest) {
+	if update == nil {
+		return
+	}
+
+	u := update.(*GitlabInfo)
+	g.Project = u.Project
+	g.Iid = u.Iid
+	g.RawMerge = u.RawMerge
+	g.RawApprovals = u.RawApprovals
+	if u.Pipelines != nil {
+		g.Pipelines = u.Pipelines
+	}
+	g.Notes = append(g.Notes, u.Notes...)
+	g.Events = append(g.Events, u.Events...)
+	if u.Files != nil {
+		g.Files = u.Files
+	}
+}
+
+// ChangedFiles returns the files changed by the merge request
+func (g *GitlabInfo) ChangedFiles() []FileChange {
+	return g.Files
+}
+
+// LatestOverallStatus returns the latest overall status set for this review, followed by the
+// status of the latest pipeline.
+func (g *GitlabInfo) LatestOverallStatus() string {
+	var status string
+
+	switch {
+	case g.RawMerge.State == "merged":
+		status = colors.Green(GitlabEventMerged)
+	case g.RawMerge.State == "closed":
+		status = GitlabEventClosed
+	case g.RawApprovals.Approved && len(g.RawApprovals.ApprovedBy) > 0:
+		status = colors.Green(GitlabEventApproved)
+	case g.RawApprovals.ApprovalsLeft > 0:
+		status = fmt.Sprintf("PENDING (%d approvals left)", g.RawApprovals.ApprovalsLeft)
+	default:
+		status = "PENDING"
+	}
+
+	if len(g.Pipelines) > 0 {
+		pipeline := g.Pipelines[0].Status
+		if pipeline == "failed" {
+			pipeline = colors.Red(pipeline)
+		}
+		status = fmt.Sprintf("%s [pipeline %s]", status, pipeline)
+	}
+
+	return status
+}
+
+// LatestUserStatuses returns a map of users and the latest approval status they set for
+// this review.
+func (g *GitlabInfo) LatestUserStatuses() map[string]UserStatus {
+	result := map[string]UserStatus{}
+
+	for _, e := range g.Events {
+		if e.Kind != GitlabEventApproved && e.Kind != GitlabEventUnapproved {
+			continue
+		}
+		if s, ok := result[e.RawNote.Author.Username]; !ok || s.Timestamp() < e.Timestamp() {
+			us := e // Without it golang store pointer to changing loop variable
+			result[e.RawNote.Author.Username] = &us
+		}
+	}
+
+	return result
+}
+
+func init() {
+	Register(&Provider{
+		Name:      "gitlab",
+		Tag:       "reviewGitlab",
+		Formats:   []string{"<group>/<project>!<id>", "https://<host>/<group>/<project>/-/merge_requests/<id>"},
+		IdPattern: gitlabIdRegex,
+		Configured: func() bool {
+			_, _, err := repository.GetGitlabConfig()
+			return err == nil
+		},
+		Incremental:   true,
+		New:           func() PullRequest { return &GitlabInfo{} },
+		Fetch:         fetchGitlab,
+		SameReview:    sameGitlabReview,
+		FixupIdentity: fixupGitlabIdentity,
+	})
+}
+
+var gitlabIdRegex = regexp.MustCompile(`^(?:https?://[^/]+/)?([\w.-]+(?:/[\w.-]+)+?)(?:!|/-/merge_requests/)(\d+)/?$`)
+
+func fetchGitlab(id string, since PullRequest) (PullRequest, error) {
+	matched := gitlabIdRegex.FindStringSubmatch(id)
+	if matched == nil {
+		return nil, fmt.Errorf("unable to parse id: %s", id)
+	}
+	iid, err := strconv.ParseInt(matched[2], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse id: %s", err)
+	}
+	var old *GitlabInfo
+	if since != nil {
+		old = since.(*GitlabInfo)
+	}
+	return FetchGitlabReviewInfo(matched[1], iid, old)
+}
+
+// fixupGitlabIdentity prompts for the GitLab username of identities which don't have one
+func fixupGitlabIdentity(i *identity.Identity, prompt Prompt) (func(identity.Mutator) identity.Mutator, error) {
+	if i.MutableMetadata()[GitlabUsernameMetadataKey] != "" {
+		return nil, nil
+	}
+
+	fmt.Println("No GitLab username for ", i.Name(), ", please insert it, or leave empty to skip.")
+	username, err := prompt("GitLab username", "gitlab username", i.Login())
+	if err != nil || username == "" {
+		return nil, err
+	}
+
+	client, err := newGitlabClient()
+	if err != nil {
+		return nil, err
+	}
+	var users []GitlabUser
+	if err := client.Get("/users?username="+url.QueryEscape(username), &users); err != nil {
+		return nil, err
+	}
+	if len(users) != 1 {
+		return nil, fmt.Errorf("no GitLab user %s", username)
+	}
+
+	return func(mutator identity.Mutator) identity.Mutator {
+		mutator.Metadata[GitlabUsernameMetadataKey] = users[0].Username
+		return mutator
+	}, nil
+}
+
+// newGitlabClient returns a client ready to be queried. Must be called within a git repo
+// which has the GitLab URL and API token set in the git config.
+func newGitlabClient() (*rest.Client, error) {
+	gitlabUrl, apiToken, err := repository.GetGitlabConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	return rest.NewClient(gitlabUrl+"/api/v4", map[string]string{"PRIVATE-TOKEN": apiToken}), nil
+}
+
+// FetchGitlabReviewInfo exports notes, discussions, approvals, pipelines and pushed commits from
+// GitLab for the given merge request and returns in a PullRequest object. If since review is
+// specified only updates will be returned
+func FetchGitlabReviewInfo(project string, iid int64, since *GitlabInfo) (PullRequest, error) {
+	client, err := newGitlabClient()
+	if err != nil {
+		return nil, err
+	}
+
+	return fetchGitlabReviewInfo(client, project, iid, since)
+}
+
+func fetchGitlabReviewInfo(client *rest.Client, project string, iid int64, since *GitlabInfo) (*GitlabInfo, error) {
+	knownNotes := map[int64]time.Time{}
+	var knownPipelines []GitlabPipelineData
+
+	if since != nil {
+		for _, n := range since.Notes {
+			if n.RawNote.UpdatedAt.After(knownNotes[n.RawNote.Id]) {
+				knownNotes[n.RawNote.Id] = n.RawNote.UpdatedAt
+			}
+		}
+		for _, e := range since.Events {
+			knownNotes[e.RawNote.Id] = e.RawNote.UpdatedAt
+		}
+		knownPipelines = since.Pipelines
+	}
+
+	mergePath := fmt.Sprintf("/projects/%s/merge_requests/%d", url.PathEscape(project), iid)
+
+	result := GitlabInfo{
+		Project: project,
+		Iid:     iid,
+	}
+
+	if err := client.Get(mergePath, &result.RawMerge); err != nil {
+		return nil, err
+	}
+
+	if err := client.Get(mergePath+"/approvals", &result.RawApprovals); err != nil {
+		return nil, err
+	}
+
+	var pipelines []GitlabPipelineData
+	if err := client.GetList(mergePath+"/pipelines", &pipelines); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(pipelines, func(i, j int) bool {
+		return pipelines[i].Id > pipelines[j].Id
+	})
+	// Only record the pipelines when their state changed
+	if len(pipelines) > 0 && !reflect.DeepEqual(pipelines, knownPipelines) {
+		result.Pipelines = pipelines
+	}
+
+	var discussions []gitlabDiscussionData
+	if err := client.GetList(mergePath+"/discussions", &discussions); err != nil {
+		return nil, err
+	}
+
+	for _, d := range discussions {
+		for _, n := range d.Notes {
+			updated, known := knownNotes[n.Id]
+			if known && !updated.Before(n.UpdatedAt) {
+				continue
+			}
+
+			if n.System {
+				if known {
+					continue
+				}
+				if event, ok := newGitlabEvent(n); ok {
+					result.Events = append(result.Events, event)
+				}
+				continue
+			}
+
+			result.Notes = append(result.Notes, GitlabNote{
+				RawNote:      n,
+				DiscussionId: d.Id,
+				Update:       known,
+			})
+		}
+	}
+
+	// The diffs are only downloaded when the files changed are unknown, e.g. for the reviews stored
+	// before they were recorded, or when the head of the merge request moved
+	if since == nil || since.Files == nil || since.RawMerge.Sha != result.RawMerge.Sha {
+		var diffs []GitlabDiffData
+		if err := client.GetList(mergePath+"/diffs", &diffs); err != nil {
+			return nil, err
+		}
+
+		result.Files = []FileChange{}
+		for _, d := range diffs {
+			additions, deletions := countDiffLines(d.Diff)
+			result.Files = append(result.Files, FileChange{Path: d.NewPath, Additions: additions, Deletions: deletions})
+		}
+	}
+
+	return &result, nil
+}
+
+// UnmarshalJSON fulfils the Marshaler interface so that we can handle the author identity
+func (n *GitlabNote) UnmarshalJSON(data []byte) error {
+	type rawUpdate struct {
+		RawNote      GitlabNoteData
+		DiscussionId string
+		Update       bool
+		AuthorId     json.RawMessage
+	}
+
+	var raw rawUpdate
+	err := json.Unmarshal(data, &raw)
+	if err != nil {
+		return err
+	}
+
+	n.RawNote = raw.RawNote
+	n.DiscussionId = raw.DiscussionId
+	n.Update = raw.Update
+
+	if raw.AuthorId != nil {
+		author, err := identity.UnmarshalJSON(raw.AuthorId)
+		if err != nil {
+			return err
+		}
+		n.AuthorId = author
+	}
+
+	return nil
+}
+
+// UnmarshalJSON fulfils the Marshaler interface so that we can handle the author identity
+func (e *GitlabEvent) UnmarshalJSON(data []byte) error {
+	type rawUpdate struct {
+		RawNote  GitlabNoteData
+		Kind     string
+		AuthorId json.RawMessage
+	}
+
+	var raw rawUpdate
+	err := json.Unmarshal(data, &raw)
+	if err != nil {
+		return err
+	}
+
+	e.RawNote = raw.RawNote
+	e.Kind = raw.Kind
+
+	if raw.AuthorId != nil {
+		author, err := identity.UnmarshalJSON(raw.AuthorId)
+		if err != nil {
+			return err
+		}
+		e.AuthorId = author
+	}
+
+	return nil
+}
+
+// sameGitlabReview tells if the id, in any of the accepted forms, designates the merge request
+func sameGitlabReview(id string, pr PullRequest) bool {
+	matched := gitlabIdRegex.FindStringSubmatch(id)
+	if matched == nil {
+		return false
+	}
+	iid, err := strconv.ParseInt(matched[2], 10, 64)
+	info := pr.(*GitlabInfo)
+	return err == nil && matched[1] == info.Project && iid == info.Iid
+}
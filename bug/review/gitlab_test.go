@@ -0,0 +1,141 @@
+package review
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/daedaleanai/git-ticket/identity"
+	"github.com/daedaleanai/git-ticket/util/rest"
+)
+
+func newGitlabTestServer(t *testing.T, responses map[string]string) *rest.Client {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "token", r.Header.Get("PRIVATE-TOKEN"))
+
+		response, ok := responses[r.URL.EscapedPath()]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(response))
+	}))
+	t.Cleanup(server.Close)
+
+	return rest.NewClient(server.URL+"/api/v4", map[string]string{"PRIVATE-TOKEN": "token"})
+}
+
+func TestFetchGitlabReviewInfo(t *testing.T) {
+	const mr = "/api/v4/projects/group%2Fproject/merge_requests/42"
+
+	responses := map[string]string{
+		mr: `{"iid": 42, "title": "Add greeting", "state": "opened",
+			"web_url": "https://gitlab.example.com/group/project/-/merge_requests/42", "sha": "abc", "author": {"username": "alice"}}`,
+		mr + "/approvals": `{"approved": false, "approvals_required": 1, "approvals_left": 1, "approved_by": []}`,
+		mr + "/pipelines": `[{"id": 1, "sha": "abc", "status": "failed"}]`,
+		mr + "/discussions": `[
+			{"id": "d1", "notes": [
+				{"id": 1, "type": "DiffNote", "body": "Typo", "author": {"username": "bob"},
+				 "position": {"head_sha": "abc", "new_path": "hello.go", "new_line": 3},
+				 "created_at": "2021-03-04T12:00:00Z", "updated_at": "2021-03-04T12:00:00Z"}]},
+			{"id": "d2", "notes": [
+				{"id": 2, "body": "added 1 commit\n\n<ul><li>abc12345 - Add greeting</li></ul>", "system": true,
+				 "author": {"username": "alice"}, "created_at": "2021-03-04T11:00:00Z", "updated_at": "2021-03-04T11:00:00Z"}]},
+			{"id": "d3", "notes": [
+				{"id": 3, "body": "changed the description", "system": true, "author": {"username": "alice"},
+				 "created_at": "2021-03-04T11:30:00Z", "updated_at": "2021-03-04T11:30:00Z"}]}]`,
+		mr + "/diffs": `[{"old_path": "hello.go", "new_path": "hello.go", "diff": "@@ -1 +1,2 @@\n-x\n+y\n+z\n"}]`,
+	}
+	client := newGitlabTestServer(t, responses)
+
+	info, err := fetchGitlabReviewInfo(client, "group/project", 42, nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, "group/project!42", info.Id())
+	assert.Equal(t, "https://gitlab.example.com/group/project/-/merge_requests/42", info.ReviewUrl())
+	require.Len(t, info.Notes, 1)
+	assert.Equal(t, "d1", info.Notes[0].DiscussionId)
+	assert.Contains(t, info.Notes[0].Summary(), "[hello.go:3@abc]")
+	require.Len(t, info.Events, 1)
+	assert.Equal(t, "[PUSHED] 1 commit", info.Events[0].Summary())
+	require.Len(t, info.Events[0].Changes(), 1)
+	assert.Contains(t, info.Events[0].Changes()[0].Summary(), "[commit abc12345]")
+	assert.Contains(t, info.LatestOverallStatus(), "PENDING (1 approvals left) [pipeline")
+	assert.Equal(t, []FileChange{{Path: "hello.go", Additions: 2, Deletions: 1}}, info.ChangedFiles())
+
+	alice := identity.NewBare("Alice", "alice@example.com")
+	bob := identity.NewBare("Bob", "bob@example.com")
+	resolver := githubTestResolver{"alice": alice, "bob": bob}
+
+	require.NoError(t, info.FetchIdentities(resolver))
+	assert.Equal(t, bob, info.Notes[0].Author())
+	assert.Equal(t, alice, info.Events[0].Author())
+
+	// Alice replies, bob approves and the pipeline passes
+	responses[mr+"/approvals"] = `{"approved": true, "approvals_required": 1, "approvals_left": 0,
+		"approved_by": [{"user": {"username": "bob"}}]}`
+	responses[mr+"/pipelines"] = `[{"id": 1, "sha": "abc", "status": "failed"}, {"id": 2, "sha": "abc", "status": "success"}]`
+	responses[mr+"/discussions"] = `[
+		{"id": "d1", "notes": [
+			{"id": 1, "type": "DiffNote", "body": "Typo", "author": {"username": "bob"},
+			 "position": {"head_sha": "abc", "new_path": "hello.go", "new_line": 3},
+			 "created_at": "2021-03-04T12:00:00Z", "updated_at": "2021-03-04T12:00:00Z"},
+			{"id": 4, "type": "DiffNote", "body": "Fixed", "author": {"username": "alice"},
+			 "created_at": "2021-03-05T12:00:00Z", "updated_at": "2021-03-05T12:00:00Z"}]},
+		{"id": "d2", "notes": [
+			{"id": 2, "body": "added 1 commit\n\n<ul><li>abc12345 - Add greeting</li></ul>", "system": true,
+			 "author": {"username": "alice"}, "created_at": "2021-03-04T11:00:00Z", "updated_at": "2021-03-04T11:00:00Z"}]},
+		{"id": "d5", "notes": [
+			{"id": 5, "body": "approved this merge request", "system": true, "author": {"username": "bob"},
+			 "created_at": "2021-03-05T13:00:00Z", "updated_at": "2021-03-05T13:00:00Z"}]}]`
+
+	update, err := fetchGitlabReviewInfo(client, "group/project", 42, info)
+	require.NoError(t, err)
+
+	require.Len(t, update.Notes, 1)
+	assert.Equal(t, int64(4), update.Notes[0].RawNote.Id)
+	require.Len(t, update.Events, 1)
+	assert.Equal(t, GitlabEventApproved, update.Events[0].Kind)
+	require.Len(t, update.Pipelines, 2)
+	assert.Equal(t, "success", update.Pipelines[0].Status)
+
+	require.NoError(t, update.FetchIdentities(resolver))
+	info.Merge(update)
+	assert.Contains(t, info.LatestOverallStatus(), GitlabEventApproved)
+	assert.Contains(t, info.LatestOverallStatus(), "[pipeline success]")
+	require.Contains(t, info.LatestUserStatuses(), "bob")
+	assert.Equal(t, GitlabEventApproved, info.LatestUserStatuses()["bob"].Status())
+
+	// Nothing new
+	update, err = fetchGitlabReviewInfo(client, "group/project", 42, info)
+	require.NoError(t, err)
+	assert.True(t, update.IsEmpty())
+	assert.Nil(t, update.ChangedFiles())
+
+	// The head moves with only the files changed
+	responses[mr] = `{"iid": 42, "title": "Add greeting", "state": "opened",
+		"web_url": "https://gitlab.example.com/group/project/-/merge_requests/42", "sha": "abd", "author": {"username": "alice"}}`
+	responses[mr+"/diffs"] = `[{"old_path": "hello.go", "new_path": "greeting.go", "diff": ""}]`
+	update, err = fetchGitlabReviewInfo(client, "group/project", 42, info)
+	require.NoError(t, err)
+	assert.False(t, update.IsEmpty())
+	info.Merge(update)
+	assert.Equal(t, []FileChange{{Path: "greeting.go"}}, info.ChangedFiles())
+}
+
+func TestGitlabProviderForId(t *testing.T) {
+	p, err := ProviderForId("group/sub/project!42")
+	require.NoError(t, err)
+	assert.Equal(t, "gitlab", p.Name)
+
+	p, err = ProviderForId("https://gitlab.example.com/group/project/-/merge_requests/42")
+	require.NoError(t, err)
+	assert.Equal(t, "gitlab", p.Name)
+
+	matched := gitlabIdRegex.FindStringSubmatch("https://gitlab.example.com/group/sub/project/-/merge_requests/42")
+	require.NotNil(t, matched)
+	assert.Equal(t, "group/sub/project", matched[1])
+}
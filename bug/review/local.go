@@ -0,0 +1,555 @@
+package review
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	termtext "github.com/MichaelMure/go-term-text"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+
+	"github.com/daedaleanai/git-ticket/entity"
+	"github.com/daedaleanai/git-ticket/identity"
+	"github.com/daedaleanai/git-ticket/repository"
+	"github.com/daedaleanai/git-ticket/util/colors"
+	"github.com/daedaleanai/git-ticket/util/timestamp"
+)
+
+// The kinds of events of a local review
+const (
+	LocalEventUpdated        = "UPDATED"
+	LocalEventComment        = "COMMENT"
+	LocalEventApproved       = "APPROVED"
+	LocalEventRequestChanges = "REQUEST_CHANGES"
+)
+
+// localContextLines is the number of lines of code stored before and after the line of an
+// inline comment
+const localContextLines = 2
+
+// CodeContextChange is implemented by the changes anchored in code which can show the lines
+// they comment on
+type CodeContextChange interface {
+	Change
+	// CodeContext returns the number of the first line of the code shown, the lines and the
+	// number of the line commented on
+	CodeContext() (first int, lines []string, line int)
+}
+
+// LocalComment holds a comment of a local review, possibly anchored to a line of a file
+type LocalComment struct {
+	Message string
+	Path    string `json:",omitempty"`
+	Line    int    `json:",omitempty"`
+	Commit  string `json:",omitempty"`
+
+	// Context holds the code around the line commented on, starting at ContextLine, as it
+	// was when commenting, so that it can be shown even if the commit isn't available
+	Context     []string `json:",omitempty"`
+	ContextLine int      `json:",omitempty"`
+}
+
+// Summary returns a string containing the comment text and the file, line & commit details,
+// on a single line. Comments over 50 characters are truncated.
+func (c *LocalComment) Summary() string {
+	// Put the comment on one line and output the first 50 characters
+	output := termtext.LeftPadMaxLine(strings.ReplaceAll(strings.ReplaceAll(c.Message, "\n", " "), "\r", ""), 50, 0)
+
+	if c.Path == "" {
+		return output
+	}
+	return output + fmt.Sprintf(" [%s:%d@%s]", c.Path, c.Line, shortCommit(c.Commit))
+}
+
+// CodeContext returns the code stored with the comment
+func (c *LocalComment) CodeContext() (int, []string, int) {
+	return c.ContextLine, c.Context, c.Line
+}
+
+// CommentId returns a hash of the comment, local comments not having ids
+func (c *LocalComment) CommentId() string {
+	hash := sha1.Sum([]byte(fmt.Sprintf("%s\x00%s\x00%d\x00%s", c.Message, c.Path, c.Line, c.Commit)))
+	return hex.EncodeToString(hash[:])[:8]
+}
+
+// Body returns the complete text of the comment
+func (c *LocalComment) Body() string {
+	return c.Message
+}
+
+// Location returns the file, line & commit details of an anchored comment
+func (c *LocalComment) Location() string {
+	if c.Path == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s:%d@%s", c.Path, c.Line, shortCommit(c.Commit))
+}
+
+// InReplyTo returns an empty string, local comments aren't replies
+func (c *LocalComment) InReplyTo() string {
+	return ""
+}
+
+// LocalEvent holds an event of a local review: an update of the commits reviewed, comments or
+// a verdict of a reviewer
+type LocalEvent struct {
+	Kind     string
+	Time     int64
+	Message  string         `json:",omitempty"`
+	Base     string         `json:",omitempty"`
+	Head     string         `json:",omitempty"`
+	Comments []LocalComment `json:",omitempty"`
+	AuthorId identity.Interface
+}
+
+// Author returns the author of the event
+func (e *LocalEvent) Author() identity.Interface {
+	return e.AuthorId
+}
+
+// Timestamp returns the time of the event
+func (e *LocalEvent) Timestamp() timestamp.Timestamp {
+	return timestamp.Timestamp(e.Time)
+}
+
+// Changes returns the comments of the event
+func (e *LocalEvent) Changes() []Change {
+	result := []Change{}
+	for _, c := range e.Comments {
+		cc := c // Without it golang store pointer to changing loop variable
+		result = append(result, &cc)
+	}
+	return result
+}
+
+// Summary returns the kind of the event and its message or commits
+func (e *LocalEvent) Summary() string {
+	message := termtext.LeftPadMaxLine(strings.ReplaceAll(e.Message, "\n", " "), 50, 0)
+
+	switch e.Kind {
+	case LocalEventUpdated:
+		if e.Base == "" {
+			return fmt.Sprintf("[%s] %s", e.Kind, shortCommit(e.Head))
+		}
+		return fmt.Sprintf("[%s] %s..%s", e.Kind, shortCommit(e.Base), shortCommit(e.Head))
+	case LocalEventComment:
+		if len(e.Comments) == 1 {
+			return e.Comments[0].Summary()
+		}
+		return fmt.Sprintf("[%d comments]", len(e.Comments))
+	default:
+		return strings.TrimSpace(fmt.Sprintf("[%s] %s", e.Kind, message))
+	}
+}
+
+// LocalInfo is the PullRequest implementation of reviews made with git-ticket itself, of a
+// branch or range of commits of the local repository
+type LocalInfo struct {
+	// Ref is the branch or range of commits reviewed, as given by the user
+	Ref     string
+	Base    string `json:",omitempty"`
+	Head    string
+	Subject string
+	Events  []LocalEvent
+	Files   []FileChange `json:",omitempty"`
+}
+
+// Id returns the review id, local:<ref>
+func (l *LocalInfo) Id() string {
+	return "local:" + l.Ref
+}
+
+// ReviewUrl returns the review id, local reviews have no URL
+func (l *LocalInfo) ReviewUrl() string {
+	return l.Id()
+}
+
+// Title returns the subject of the last commit reviewed
+func (l *LocalInfo) Title() string {
+	return l.Subject
+}
+
+// History returns all events from the review
+func (l *LocalInfo) History() []TimelineEvent {
+	result := []TimelineEvent{}
+
+	for _, e := range l.Events {
+		upd := e
+		result = append(result, &upd)
+	}
+	return result
+}
+
+// IsEmpty check if there is any changes
+func (l *LocalInfo) IsEmpty() bool {
+	return len(l.Events) == 0
+}
+
+// EnsureIdentities validated if all users are resolved
+func (l *LocalInfo) EnsureIdentities(resolver identity.Resolver, found map[entity.Id]identity.Interface) error {
+	for i, e := range l.Events {
+		entity := e.AuthorId.Id()
+
+		if _, ok := found[entity]; !ok {
+			id, err := resolver.ResolveIdentity(entity)
+			if err != nil {
+				return err
+			}
+			found[entity] = id
+		}
+
+		l.Events[i].AuthorId = found[entity]
+	}
+	return nil
+}
+
+// FetchIdentities does nothing, the events of local reviews are made by git-ticket identities
+func (l *LocalInfo) FetchIdentities(IdentityResolver) error {
+	return nil
+}
+
+// Merge updates state from new one
+func (l *LocalInfo) Merge(update PullRequest) {
+	if update == nil {
+		return
+	}
+
+	u := update.(*LocalInfo)
+	l.Ref = u.Ref
+	l.Base = u.Base
+	l.Head = u.Head
+	l.Subject = u.Subject
+	l.Events = append(l.Events, u.Events...)
+	if u.Files != nil {
+		l.Files = u.Files
+	}
+}
+
+// ChangedFiles returns the files changed by the commits reviewed
+func (l *LocalInfo) ChangedFiles() []FileChange {
+	return l.Files
+}
+
+// latestVerdicts returns the last verdict of each reviewer, ignoring those made on
+// previous commits
+func (l *LocalInfo) latestVerdicts() map[string]*LocalEvent {
+	result := map[string]*LocalEvent{}
+
+	for i, e := range l.Events {
+		if e.Kind != LocalEventApproved && e.Kind != LocalEventRequestChanges {
+			continue
+		}
+		if e.Head != l.Head {
+			continue
+		}
+		result[e.AuthorId.Id().String()] = &l.Events[i]
+	}
+	return result
+}
+
+// LatestOverallStatus returns the overall status of the review from the verdicts of
+// the reviewers on the current commits
+func (l *LocalInfo) LatestOverallStatus() string {
+	approved := false
+	rejected := false
+
+	for _, e := range l.latestVerdicts() {
+		if e.Kind == LocalEventApproved {
+			approved = true
+		} else {
+			rejected = true
+		}
+	}
+
+	if rejected {
+		return colors.Red(LocalEventRequestChanges)
+	} else if approved {
+		return colors.Green(LocalEventApproved)
+	} else {
+		return "PENDING"
+	}
+}
+
+// LatestUserStatuses returns a map of reviewers and their verdict on the current commits
+func (l *LocalInfo) LatestUserStatuses() map[string]UserStatus {
+	result := map[string]UserStatus{}
+
+	for id, e := range l.latestVerdicts() {
+		result[id] = &localUserStatus{e}
+	}
+	return result
+}
+
+type localUserStatus struct {
+	*LocalEvent
+}
+
+func (s *localUserStatus) Status() string {
+	return s.Kind
+}
+
+func init() {
+	Register(&Provider{
+		Name:        "local",
+		Tag:         "reviewLocal",
+		Formats:     []string{"local:<branch>", "local:<base>..<head>"},
+		IdPattern:   localIdRegex,
+		Configured:  func() bool { return true },
+		Incremental: true,
+		Local:       true,
+		New:         func() PullRequest { return &LocalInfo{} },
+		Fetch:       fetchLocal,
+	})
+}
+
+var localIdRegex = regexp.MustCompile(`^local:(\S+)$`)
+
+func fetchLocal(id string, since PullRequest) (PullRequest, error) {
+	matched := localIdRegex.FindStringSubmatch(id)
+	if matched == nil {
+		return nil, fmt.Errorf("unable to parse id: %s", id)
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, fmt.Errorf("unable to get the current working directory: %q", err)
+	}
+	repo, err := repository.NewGitRepoNoInit(cwd)
+	if err != nil {
+		return nil, err
+	}
+	author, err := identity.GetUserIdentity(repo)
+	if err != nil {
+		return nil, err
+	}
+
+	var old *LocalInfo
+	if since != nil {
+		old = since.(*LocalInfo)
+	}
+	return FetchLocalReviewInfo(repo, matched[1], author, time.Now().Unix(), old)
+}
+
+// FetchLocalReviewInfo resolves the branch or range of commits of a local review and returns it
+// in a PullRequest object. If since review is specified the update of the commits reviewed,
+// if any, is returned
+func FetchLocalReviewInfo(repo repository.Repo, ref string, author identity.Interface, unixTime int64, since *LocalInfo) (*LocalInfo, error) {
+	var base string
+	head := ref
+	if i := strings.Index(ref, ".."); i >= 0 {
+		base, head = ref[:i], ref[i+2:]
+	}
+
+	result := LocalInfo{Ref: ref}
+
+	headHash, err := repo.ResolveRevision(plumbing.Revision(head))
+	if err != nil {
+		return nil, fmt.Errorf("unable to resolve %s: %s", head, err)
+	}
+	result.Head = headHash.String()
+
+	if base != "" {
+		baseHash, err := repo.ResolveRevision(plumbing.Revision(base))
+		if err != nil {
+			return nil, fmt.Errorf("unable to resolve %s: %s", base, err)
+		}
+		result.Base = baseHash.String()
+	}
+
+	commit, err := repo.CommitObject(*headHash)
+	if err != nil {
+		return nil, err
+	}
+	result.Subject = strings.SplitN(commit.Message, "\n", 2)[0]
+
+	// Without base the changes of the last commit are reviewed. Otherwise the changes since the
+	// merge base, as git diff base...head, so the commits added to base since aren't reviewed
+	var stats object.FileStats
+	if result.Base == "" {
+		stats, err = commit.Stats()
+	} else {
+		var baseCommit *object.Commit
+		baseCommit, err = repo.CommitObject(plumbing.NewHash(result.Base))
+		if err != nil {
+			return nil, err
+		}
+		var mergeBases []*object.Commit
+		mergeBases, err = baseCommit.MergeBase(commit)
+		if err != nil {
+			return nil, err
+		}
+		if len(mergeBases) == 0 {
+			return nil, fmt.Errorf("%s and %s have no common ancestor", base, head)
+		}
+		var patch *object.Patch
+		patch, err = mergeBases[0].Patch(commit)
+		if err == nil {
+			stats = patch.Stats()
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result.Files = []FileChange{}
+	for _, s := range stats {
+		result.Files = append(result.Files, FileChange{Path: s.Name, Additions: s.Addition, Deletions: s.Deletion})
+	}
+
+	if since == nil || since.Head != result.Head || since.Base != result.Base {
+		result.Events = append(result.Events, LocalEvent{
+			Kind:     LocalEventUpdated,
+			Time:     unixTime,
+			Base:     result.Base,
+			Head:     result.Head,
+			AuthorId: author,
+		})
+	}
+
+	return &result, nil
+}
+
+// NewLocalComment returns an update of the review with a comment. If location is given, as
+// file:line or file:line@commit, the comment is anchored to that line of the file in the
+// commit, by default the last commit reviewed.
+func NewLocalComment(repo repository.Repo, review *LocalInfo, location, message string, author identity.Interface, unixTime int64) (*LocalInfo, error) {
+	comment := LocalComment{Message: message}
+
+	if location != "" {
+		commit := review.Head
+		if i := strings.LastIndex(location, "@"); i >= 0 {
+			location, commit = location[:i], location[i+1:]
+		}
+
+		i := strings.LastIndex(location, ":")
+		if i < 0 {
+			return nil, fmt.Errorf("invalid location %s, expected file:line[@commit]", location)
+		}
+		line, err := strconv.Atoi(location[i+1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid line in %s: %s", location, err)
+		}
+
+		hash, err := repo.ResolveRevision(plumbing.Revision(commit))
+		if err != nil {
+			return nil, fmt.Errorf("unable to resolve %s: %s", commit, err)
+		}
+
+		comment.Path = location[:i]
+		comment.Line = line
+		comment.Commit = hash.String()
+		comment.ContextLine, comment.Context, err = readCodeContext(repo, *hash, comment.Path, line)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return review.update(LocalEvent{
+		Kind:     LocalEventComment,
+		Time:     unixTime,
+		Comments: []LocalComment{comment},
+		AuthorId: author,
+	}), nil
+}
+
+// NewLocalVerdict returns an update of the review with the approval, or the request for changes,
+// of the last commit reviewed
+func NewLocalVerdict(review *LocalInfo, approved bool, message string, author identity.Interface, unixTime int64) *LocalInfo {
+	kind := LocalEventRequestChanges
+	if approved {
+		kind = LocalEventApproved
+	}
+
+	return review.update(LocalEvent{
+		Kind:     kind,
+		Time:     unixTime,
+		Message:  message,
+		Base:     review.Base,
+		Head:     review.Head,
+		AuthorId: author,
+	})
+}
+
+// update returns an update of the review holding the given event only
+func (l *LocalInfo) update(event LocalEvent) *LocalInfo {
+	return &LocalInfo{
+		Ref:     l.Ref,
+		Base:    l.Base,
+		Head:    l.Head,
+		Subject: l.Subject,
+		Events:  []LocalEvent{event},
+		Files:   l.Files,
+	}
+}
+
+// readCodeContext returns the number of the first line and the lines of code around the given line
+// of the file in the commit
+func readCodeContext(repo repository.Repo, commit plumbing.Hash, path string, line int) (int, []string, error) {
+	c, err := repo.CommitObject(commit)
+	if err != nil {
+		return 0, nil, err
+	}
+	file, err := c.File(path)
+	if err != nil {
+		return 0, nil, fmt.Errorf("%s in commit %s: %s", path, shortCommit(commit.String()), err)
+	}
+	lines, err := file.Lines()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if line < 1 || line > len(lines) {
+		return 0, nil, fmt.Errorf("line %d out of %s, which has %d lines", line, path, len(lines))
+	}
+
+	first := line - localContextLines
+	if first < 1 {
+		first = 1
+	}
+	last := line + localContextLines
+	if last > len(lines) {
+		last = len(lines)
+	}
+
+	return first, lines[first-1 : last], nil
+}
+
+func shortCommit(commit string) string {
+	if len(commit) > 10 {
+		return commit[:10]
+	}
+	return commit
+}
+
+// UnmarshalJSON fulfils the Marshaler interface so that we can handle the author identity
+func (e *LocalEvent) UnmarshalJSON(data []byte) error {
+	type rawEvent LocalEvent
+
+	var raw struct {
+		rawEvent
+		AuthorId json.RawMessage
+	}
+	err := json.Unmarshal(data, &raw)
+	if err != nil {
+		return err
+	}
+
+	*e = LocalEvent(raw.rawEvent)
+
+	if raw.AuthorId != nil {
+		author, err := identity.UnmarshalJSON(raw.AuthorId)
+		if err != nil {
+			return err
+		}
+		e.AuthorId = author
+	}
+
+	return nil
+}
@@ -0,0 +1,105 @@
+package review
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/daedaleanai/git-ticket/identity"
+	"github.com/daedaleanai/git-ticket/repository"
+)
+
+func TestLocalReview(t *testing.T) {
+	repo := repository.CreateTestRepo(false)
+	defer repository.CleanupTestRepos(repo)
+	repository.SetupSigningKey(t, repo, "alice@example.com")
+
+	commit := func(content string, parent repository.Hash) repository.Hash {
+		blob, err := repo.StoreData([]byte(content))
+		require.NoError(t, err)
+		tree, err := repo.StoreTree([]repository.TreeEntry{{ObjectType: repository.Blob, Hash: blob, Name: "main.go"}})
+		require.NoError(t, err)
+		if parent == "" {
+			hash, err := repo.StoreCommit(tree)
+			require.NoError(t, err)
+			return hash
+		}
+		hash, err := repo.StoreCommitWithParent(tree, parent)
+		require.NoError(t, err)
+		return hash
+	}
+
+	base := commit("package main\n", "")
+	head := commit("package main\n\nimport \"fmt\"\n\nfunc main() {\n\tfmt.Println(\"hello\")\n}\n", base)
+
+	alice := identity.NewBare("Alice", "alice@example.com")
+	bob := identity.NewBare("Bob", "bob@example.com")
+
+	info, err := FetchLocalReviewInfo(repo, string(base)+".."+string(head), alice, 1000, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "local:"+string(base)+".."+string(head), info.Id())
+	assert.Equal(t, string(head), info.Head)
+	require.Len(t, info.Events, 1)
+	assert.Equal(t, LocalEventUpdated, info.Events[0].Kind)
+	assert.Equal(t, "PENDING", info.LatestOverallStatus())
+	assert.Equal(t, []FileChange{{Path: "main.go", Additions: 6, Deletions: 0}}, info.ChangedFiles())
+
+	p, err := ProviderForId(info.Id())
+	require.NoError(t, err)
+	assert.Equal(t, "local", p.Name)
+
+	// Nothing changed
+	update, err := FetchLocalReviewInfo(repo, info.Ref, alice, 1001, info)
+	require.NoError(t, err)
+	assert.True(t, update.IsEmpty())
+
+	update, err = NewLocalComment(repo, info, "main.go:6", "Say hello to the world", bob, 1002)
+	require.NoError(t, err)
+	info.Merge(update)
+
+	comment := info.Events[1].Comments[0]
+	assert.Equal(t, "main.go", comment.Path)
+	assert.Equal(t, string(head), comment.Commit)
+	assert.Contains(t, comment.Summary(), "[main.go:6@"+string(head)[:10]+"]")
+	first, lines, line := comment.CodeContext()
+	assert.Equal(t, 4, first)
+	assert.Equal(t, 6, line)
+	assert.Equal(t, []string{"", "func main() {", "\tfmt.Println(\"hello\")", "}"}, lines)
+
+	_, err = NewLocalComment(repo, info, "main.go:9", "Out of the file", bob, 1003)
+	assert.Error(t, err)
+	_, err = NewLocalComment(repo, info, "other.go:1@"+string(base), "Not in the commit", bob, 1003)
+	assert.Error(t, err)
+
+	// A comment without location isn't anchored
+	update, err = NewLocalComment(repo, info, "", "Looks fine otherwise", bob, 1004)
+	require.NoError(t, err)
+	_, ok := update.Events[0].Changes()[0].(CodeContextChange)
+	assert.True(t, ok)
+	first, lines, _ = update.Events[0].Comments[0].CodeContext()
+	assert.Empty(t, lines)
+
+	info.Merge(NewLocalVerdict(info, false, "Greet the world", bob, 1005))
+	assert.Contains(t, info.LatestOverallStatus(), LocalEventRequestChanges)
+	require.Contains(t, info.LatestUserStatuses(), bob.Id().String())
+
+	// The verdict is stale once the review is updated to new commits
+	fixed := commit("package main\n", head)
+	info.Ref = string(base) + ".." + string(fixed)
+	update, err = FetchLocalReviewInfo(repo, info.Ref, alice, 1006, info)
+	require.NoError(t, err)
+	require.Len(t, update.Events, 1)
+	info.Merge(update)
+	assert.Equal(t, "PENDING", info.LatestOverallStatus())
+
+	info.Merge(NewLocalVerdict(info, true, "", bob, 1007))
+	assert.Contains(t, info.LatestOverallStatus(), LocalEventApproved)
+	assert.Equal(t, "[APPROVED]", info.Events[len(info.Events)-1].Summary())
+
+	// The commits added to the base since the review branched off aren't reviewed
+	advanced := commit("package main\n\n// Package main says hello\n", base)
+	info, err = FetchLocalReviewInfo(repo, string(advanced)+".."+string(head), alice, 1008, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []FileChange{{Path: "main.go", Additions: 6, Deletions: 0}}, info.ChangedFiles())
+}
@@ -3,6 +3,7 @@ package review
 import (
 	"encoding/json"
 	"fmt"
+	"regexp"
 	"sort"
 	"strconv"
 	"strings"
@@ -39,6 +40,9 @@ type PhabTransaction struct {
 	Path string `json:",omitempty"` // file path, inline comments only
 	Line int    `json:",omitempty"` // line number, inline comments only
 	Text string `json:",omitempty"`
+	// CommentPhid identifies the comment, and ReplyTo the inline comment it replies to
+	CommentPhid string `json:",omitempty"`
+	ReplyTo     string `json:",omitempty"`
 	// status and userstatus specific fields
 	Status string `json:",omitempty"`
 	// diff specific fields
@@ -122,8 +126,14 @@ func (g *PhabUpdateGroup) Summary() string {
 
 // Summary returns a string containing the comment text, and it's an inline
 // comment the file & line details, on a single line. Comments over 50 characters
-// are truncated.
+// are truncated. The other transactions are summarized as the status or diff set.
 func (c *ReviewUpdate) Summary() string {
+	switch c.Type {
+	case StatusTransaction, UserStatusTransaction:
+		return "[" + c.Status() + "]"
+	case DiffTransaction:
+		return "[diff>" + strconv.Itoa(c.DiffId) + "]"
+	}
 	if c.Type != CommentTransaction {
 		return ""
 	}
@@ -139,12 +149,44 @@ func (c *ReviewUpdate) Summary() string {
 	return output
 }
 
+// CommentId returns the PHID of the comment, or the id of its transaction for the comments
+// stored without it
+func (c *ReviewUpdate) CommentId() string {
+	if c.CommentPhid != "" {
+		return c.CommentPhid
+	}
+	return c.TransId
+}
+
+// Body returns the complete text of the comment, or an empty string if it's not a comment
+func (c *ReviewUpdate) Body() string {
+	if c.Type != CommentTransaction {
+		return ""
+	}
+	return c.Text
+}
+
+// Location returns the file, line & diff details of an inline comment
+func (c *ReviewUpdate) Location() string {
+	if c.Path == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s:%d@%d", c.Path, c.Line, c.Diff)
+}
+
+// InReplyTo returns the PHID of the inline comment replied to
+func (c *ReviewUpdate) InReplyTo() string {
+	return c.ReplyTo
+}
+
 // PhabReviewInfo is Phabricator-specific implementation of PullRequest
 type PhabReviewInfo struct {
 	RevisionId      string // e.g. D1234
 	RevisionTitle   string `json:"Title"`
 	LastTransaction string
 	Updates         []ReviewUpdate
+	// Files holds the files changed by the latest diff
+	Files []FileChange `json:",omitempty"`
 }
 
 // Id returns Phabricator revision id
@@ -250,6 +292,14 @@ func (r *PhabReviewInfo) Merge(update PullRequest) {
 
 	r.LastTransaction = u.LastTransaction
 	r.Updates = append(r.Updates, u.Updates...)
+	if u.Files != nil {
+		r.Files = u.Files
+	}
+}
+
+// ChangedFiles returns the files changed by the latest diff of the revision
+func (r *PhabReviewInfo) ChangedFiles() []FileChange {
+	return r.Files
 }
 
 // LatestOverallStatus returns the latest overall status set for this review.
@@ -297,6 +347,24 @@ var statusActionToState = map[string]string{
 	"request-review":  "review requested",
 }
 
+func init() {
+	Register(&Provider{
+		Name:        "phabricator",
+		Tag:         "review",
+		Formats:     []string{"Dnnn"},
+		IdPattern:   regexp.MustCompile(`^D\d+$`),
+		Incremental: true,
+		New:         func() PullRequest { return &PhabReviewInfo{} },
+		Fetch: func(id string, since PullRequest) (PullRequest, error) {
+			lastTransaction := ""
+			if since != nil {
+				lastTransaction = since.(*PhabReviewInfo).LastTransaction
+			}
+			return FetchPhabricatorReviewInfo(id, lastTransaction)
+		},
+	})
+}
+
 // FetchPhabricatorReviewInfo exports review comments and status info from Phabricator for
 // the given differential ID and returns in a PullRequest object. If a since
 // transaction ID is specified then only updates since then are returned.
@@ -311,6 +379,7 @@ func FetchPhabricatorReviewInfo(id string, since string) (PullRequest, error) {
 	var before string
 	var after string
 	var deltaUpdate bool
+	var latestDiff int
 
 	// If since is set then only get the transactions since then, else get them all
 	if since != "" {
@@ -363,6 +432,7 @@ func FetchPhabricatorReviewInfo(id string, since string) (PullRequest, error) {
 				commentDiff := int(diff["id"].(float64))
 				commentPath := t.Fields["path"].(string)
 				commentLine := int(t.Fields["line"].(float64))
+				replyTo, _ := t.Fields["replyToCommentPHID"].(string)
 
 				transData.Type = CommentTransaction
 
@@ -371,6 +441,8 @@ func FetchPhabricatorReviewInfo(id string, since string) (PullRequest, error) {
 					transData.Path = commentPath
 					transData.Line = commentLine
 					transData.Text = c.Content["raw"].(string)
+					transData.CommentPhid = c.Phid
+					transData.ReplyTo = replyTo
 
 					result.Updates = append(result.Updates, transData)
 				}
@@ -380,6 +452,7 @@ func FetchPhabricatorReviewInfo(id string, since string) (PullRequest, error) {
 
 				for _, c := range t.Comments {
 					transData.Text = c.Content["raw"].(string)
+					transData.CommentPhid = c.Phid
 
 					result.Updates = append(result.Updates, transData)
 				}
@@ -415,6 +488,9 @@ func FetchPhabricatorReviewInfo(id string, since string) (PullRequest, error) {
 
 				transData.Type = DiffTransaction
 				transData.DiffId = response.Data[0].ID
+				if transData.DiffId > latestDiff {
+					latestDiff = transData.DiffId
+				}
 
 				result.Updates = append(result.Updates, transData)
 			}
@@ -443,6 +519,23 @@ func FetchPhabricatorReviewInfo(id string, since string) (PullRequest, error) {
 
 	}
 
+	// The files changed are only updated with the diffs
+	if latestDiff > 0 {
+		response, err := phabClient.DifferentialQueryDiffs(requests.DifferentialQueryDiffsRequest{IDs: []uint64{uint64(latestDiff)}})
+		if err != nil {
+			return nil, err
+		}
+
+		result.Files = []FileChange{}
+		if diff, ok := response[strconv.Itoa(latestDiff)]; ok {
+			for _, c := range diff.Changes {
+				additions, _ := strconv.Atoi(c.AddLines)
+				deletions, _ := strconv.Atoi(c.DelLines)
+				result.Files = append(result.Files, FileChange{Path: c.CurrentPath, Additions: additions, Deletions: deletions})
+			}
+		}
+	}
+
 	return &result, nil
 }
 
@@ -0,0 +1,174 @@
+package review
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/daedaleanai/git-ticket/identity"
+)
+
+// Prompt asks the user for a value, proposing a default one, see input.PromptDefault
+type Prompt func(prompt, name, defaultValue string) (string, error)
+
+// Provider describes a review system the review information of a ticket can be fetched from
+type Provider struct {
+	// Name identifies the provider, e.g. "gitea"
+	Name string
+	// Tag is the JSON field holding the review information in the operations
+	Tag string
+	// Formats describes the review ids accepted, for help and error messages
+	Formats []string
+	// IdPattern matches the review ids handled by the provider, nil for the providers
+	// which can't be fetched from, like the marker of removed reviews
+	IdPattern *regexp.Regexp
+	// Configured, if set, tells if the provider is configured in the repository. When several
+	// providers accept an id, the only configured one is picked.
+	Configured func() bool
+	// Incremental tells if the provider only fetches the updates since the review stored
+	Incremental bool
+	// Local tells if the reviews are read from the repository itself rather than fetched
+	// from a review server
+	Local bool
+	// New returns an empty review information to unmarshal into
+	New func() PullRequest
+	// Fetch fetches the review information with the given id. If since is not nil and the
+	// provider is incremental, only the updates since then are returned.
+	Fetch func(id string, since PullRequest) (PullRequest, error)
+	// SameReview, if set, tells if the given id designates the review information, for the
+	// providers accepting several forms of ids, e.g. URLs. The ids are compared otherwise.
+	SameReview func(id string, pr PullRequest) bool
+	// FixupIdentity, if set, attempts to find the user the identity corresponds to on the
+	// provider, prompting if needed. It returns the changes to apply to the identity, or nil
+	// if it doesn't need any.
+	FixupIdentity func(i *identity.Identity, prompt Prompt) (func(identity.Mutator) identity.Mutator, error)
+}
+
+var providers []*Provider
+
+// Register adds a provider to the registry. It panics if the name or the JSON tag of the
+// provider is already registered.
+func Register(p *Provider) {
+	for _, other := range providers {
+		if other.Name == p.Name || other.Tag == p.Tag {
+			panic(fmt.Sprintf("review provider %s registered twice", p.Name))
+		}
+	}
+	providers = append(providers, p)
+}
+
+// Providers returns the registered providers, in registration order
+func Providers() []*Provider {
+	return providers
+}
+
+// ProviderForId returns the provider handling the given review id. An id accepted by several
+// providers, like <owner>/<repo>#<id> by Gitea and GitHub, is handled by the only one configured
+// in the repository, it is rejected as ambiguous otherwise.
+func ProviderForId(id string) (*Provider, error) {
+	var matched []*Provider
+	var formats []string
+	for _, p := range providers {
+		if p.IdPattern != nil && p.IdPattern.MatchString(id) {
+			matched = append(matched, p)
+		}
+		for _, f := range p.Formats {
+			formats = append(formats, fmt.Sprintf("%s for %s", f, p.Name))
+		}
+	}
+
+	if len(matched) == 0 {
+		return nil, fmt.Errorf("review id '%s' unexpected format (%s)", id, strings.Join(formats, ", "))
+	}
+
+	if len(matched) == 1 {
+		return matched[0], nil
+	}
+
+	var configured []*Provider
+	var names []string
+	for _, p := range matched {
+		names = append(names, p.Name)
+		if p.Configured != nil && p.Configured() {
+			configured = append(configured, p)
+		}
+	}
+	if len(configured) == 1 {
+		return configured[0], nil
+	}
+
+	return nil, fmt.Errorf("review id '%s' is ambiguous between %s, use the URL of the review instead",
+		id, strings.Join(names, " and "))
+}
+
+// ProviderForTag returns the provider whose review information is stored under the given JSON tag
+func ProviderForTag(tag string) (*Provider, bool) {
+	for _, p := range providers {
+		if p.Tag == tag {
+			return p, true
+		}
+	}
+	return nil, false
+}
+
+// ProviderFor returns the provider the review information comes from
+func ProviderFor(pr PullRequest) (*Provider, bool) {
+	for _, p := range providers {
+		if reflect.TypeOf(p.New()) == reflect.TypeOf(pr) {
+			return p, true
+		}
+	}
+	return nil, false
+}
+
+// Resolve returns the provider handling the given review id, and the review it designates
+// among the stored ones, if any. An id of a stored review is handled by the provider the review
+// was fetched from, even if it is accepted by several providers.
+func Resolve(id string, reviews map[string]PullRequest) (*Provider, PullRequest, error) {
+	if stored, ok := reviews[id]; ok {
+		if p, ok := ProviderFor(stored); ok && p.IdPattern != nil && p.IdPattern.MatchString(id) {
+			return p, stored, nil
+		}
+	}
+
+	p, err := ProviderForId(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, stored := range reviews {
+		if storedProvider, ok := ProviderFor(stored); !ok || storedProvider != p {
+			continue
+		}
+		if p.SameReview != nil && p.SameReview(id, stored) || p.SameReview == nil && id == stored.Id() {
+			return p, stored, nil
+		}
+	}
+	return p, nil, nil
+}
+
+// FetchFrom fetches the review information with the given id from the provider. If since is
+// given then only updates since then are returned, for the providers supporting it.
+func FetchFrom(p *Provider, id string, since PullRequest) (PullRequest, error) {
+	if !p.Incremental {
+		since = nil
+	}
+	return p.Fetch(id, since)
+}
+
+// Fetch fetches the review information with the given id from the provider handling it.
+// If since is given then only updates since then are returned, for the providers
+// supporting it, and the review is fetched again from the provider it came from.
+func Fetch(id string, since PullRequest) (PullRequest, error) {
+	if since != nil {
+		if p, ok := ProviderFor(since); ok && p.IdPattern != nil && p.IdPattern.MatchString(id) {
+			return FetchFrom(p, id, since)
+		}
+	}
+
+	p, err := ProviderForId(id)
+	if err != nil {
+		return nil, err
+	}
+	return FetchFrom(p, id, since)
+}
@@ -0,0 +1,134 @@
+package review
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProviderForId(t *testing.T) {
+	p, err := ProviderForId("D1234")
+	require.NoError(t, err)
+	assert.Equal(t, "phabricator", p.Name)
+
+	p, err = ProviderForId("daedalean/git-ticket/pulls/9")
+	require.NoError(t, err)
+	assert.Equal(t, "gitea", p.Name)
+
+	_, err = ProviderForId("T1234")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Dnnn for phabricator")
+
+	p, ok := ProviderFor(&RemoveReview{ReviewId: "D1234"})
+	require.True(t, ok)
+	assert.Equal(t, "removeReview", p.Tag)
+
+	p, ok = ProviderForTag("reviewGitea")
+	require.True(t, ok)
+	assert.Equal(t, "gitea", p.Name)
+}
+
+func TestProviderForAmbiguousId(t *testing.T) {
+	registered := providers
+	defer func() { providers = registered }()
+
+	configured := map[string]bool{}
+	providers = nil
+	for _, name := range []string{"first", "second"} {
+		name := name
+		Register(&Provider{
+			Name:       name,
+			Tag:        name,
+			IdPattern:  regexp.MustCompile(`^\w+/\w+#\d+$`),
+			Configured: func() bool { return configured[name] },
+		})
+	}
+
+	_, err := ProviderForId("owner/repo#9")
+	assert.EqualError(t, err, "review id 'owner/repo#9' is ambiguous between first and second, use the URL of the review instead")
+
+	configured["second"] = true
+	p, err := ProviderForId("owner/repo#9")
+	require.NoError(t, err)
+	assert.Equal(t, "second", p.Name)
+
+	configured["first"] = true
+	_, err = ProviderForId("owner/repo#9")
+	assert.Error(t, err)
+}
+
+func TestFetchAmbiguousId(t *testing.T) {
+	registered := providers
+	defer func() { providers = registered }()
+
+	var fetched []string
+	providers = nil
+	for _, p := range []*Provider{
+		{Name: "gitea", Tag: "gitea", New: func() PullRequest { return &GiteaInfo{} }},
+		{Name: "github", Tag: "github", New: func() PullRequest { return &GithubInfo{} }},
+	} {
+		p := p
+		p.IdPattern = regexp.MustCompile(`^\w+/\w+#\d+$`)
+		p.Configured = func() bool { return true }
+		p.Fetch = func(id string, since PullRequest) (PullRequest, error) {
+			fetched = append(fetched, p.Name)
+			return p.New(), nil
+		}
+		Register(p)
+	}
+
+	// The stored review names its provider
+	_, err := Fetch("o/r#3", &GithubInfo{Owner: "o", Repository: "r", Number: 3})
+	require.NoError(t, err)
+	_, err = Fetch("o/r#3", &GiteaInfo{Owner: "o", Repository: "r", PullId: 3})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"github", "gitea"}, fetched)
+
+	_, err = Fetch("o/r#3", nil)
+	assert.EqualError(t, err, "review id 'o/r#3' is ambiguous between gitea and github, use the URL of the review instead")
+}
+
+func TestResolve(t *testing.T) {
+	reviews := map[string]PullRequest{
+		"octo/hello#7": &GithubInfo{Owner: "octo", Repository: "hello", Number: 7},
+		"1234":         &GerritInfo{Number: 1234, RawChange: GerritChangeData{ChangeId: "I0123456789abcdef0123456789abcdef01234567"}},
+	}
+
+	// The stored review is found whatever the form of its id
+	for _, id := range []string{"octo/hello#7", "https://github.com/octo/hello/pull/7"} {
+		p, stored, err := Resolve(id, reviews)
+		require.NoError(t, err, id)
+		assert.Equal(t, "github", p.Name)
+		assert.Same(t, reviews["octo/hello#7"], stored)
+	}
+	for _, id := range []string{"1234", "I0123456789abcdef0123456789abcdef01234567", "https://gerrit.example.com/c/project/+/1234"} {
+		p, stored, err := Resolve(id, reviews)
+		require.NoError(t, err, id)
+		assert.Equal(t, "gerrit", p.Name)
+		assert.Same(t, reviews["1234"], stored)
+	}
+
+	p, stored, err := Resolve("https://github.com/octo/hello/pull/8", reviews)
+	require.NoError(t, err)
+	assert.Equal(t, "github", p.Name)
+	assert.Nil(t, stored)
+}
+
+func TestRegister(t *testing.T) {
+	registered := providers
+	defer func() { providers = registered }()
+
+	Register(&Provider{
+		Name:  "test",
+		Tag:   "reviewTest",
+		New:   func() PullRequest { return &RemoveReview{} },
+		Fetch: func(id string, since PullRequest) (PullRequest, error) { return &RemoveReview{ReviewId: id}, nil },
+	})
+	_, ok := ProviderForTag("reviewTest")
+	assert.True(t, ok)
+
+	assert.Panics(t, func() { Register(&Provider{Name: "test", Tag: "other"}) })
+	assert.Panics(t, func() { Register(&Provider{Name: "other", Tag: "review"}) })
+}
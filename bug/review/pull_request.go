@@ -30,6 +30,9 @@ type TimelineEvent interface {
 type IdentityResolver interface {
 	ResolveIdentityPhabID(phabID string) (identity.Interface, error)
 	ResolveIdentityGiteaID(giteaId int64) (identity.Interface, error)
+	// ResolveIdentityMetadata returns the identity with the given value for the mutable metadata
+	// key, e.g. the user name recorded for a review provider
+	ResolveIdentityMetadata(key string, value string) (identity.Interface, error)
 }
 
 // PullRequest is a generic interface for pull request or phabricator revision
@@ -50,4 +53,7 @@ type PullRequest interface {
 	LatestOverallStatus() string
 
 	LatestUserStatuses() map[string]UserStatus
+
+	// ChangedFiles returns the files changed by the latest version of the pull request
+	ChangedFiles() []FileChange
 }
@@ -5,6 +5,14 @@ import (
 	"github.com/daedaleanai/git-ticket/identity"
 )
 
+func init() {
+	Register(&Provider{
+		Name: "remove",
+		Tag:  "removeReview",
+		New:  func() PullRequest { return &RemoveReview{} },
+	})
+}
+
 // Dummy marker for removing review info
 type RemoveReview struct {
 	ReviewId string
@@ -49,3 +57,7 @@ func (r *RemoveReview) LatestOverallStatus() string {
 func (r *RemoveReview) LatestUserStatuses() map[string]UserStatus {
 	return map[string]UserStatus{}
 }
+
+func (r *RemoveReview) ChangedFiles() []FileChange {
+	return nil
+}
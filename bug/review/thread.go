@@ -0,0 +1,137 @@
+package review
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/daedaleanai/git-ticket/identity"
+	"github.com/daedaleanai/git-ticket/util/timestamp"
+)
+
+// CommentChange is a Change which is a comment, which can be displayed in full rather than
+// as a summary
+type CommentChange interface {
+	Change
+	// CommentId returns the id of the comment, unique within its review
+	CommentId() string
+	// Body returns the complete text of the comment
+	Body() string
+	// Location returns the file, line and revision commented on as "file:line@revision",
+	// or an empty string if the comment isn't inline
+	Location() string
+	// InReplyTo returns the id of the comment, or discussion, the comment replies to, or an
+	// empty string if it isn't a reply
+	InReplyTo() string
+}
+
+// AsComment returns the change as a comment if it is one. Some changes implement CommentChange
+// without being comments, like the status transactions of Phabricator, which have no body.
+func AsComment(change Change) (CommentChange, bool) {
+	c, ok := change.(CommentChange)
+	if !ok || c.Body() == "" {
+		return nil, false
+	}
+	return c, true
+}
+
+// CommentThread is a comment of a review, with its replies in chronological order
+type CommentThread struct {
+	Comment   CommentChange
+	Author    identity.Interface
+	Timestamp timestamp.Timestamp
+	Replies   []*CommentThread
+}
+
+// Threads returns the comments of the review in chronological order, with the replies grouped
+// under the comment which started the discussion. Inline comments which don't say what they
+// reply to, like the ones from Gitea, are threaded with the first comment on the same line.
+func Threads(pr PullRequest) []*CommentThread {
+	var comments []*CommentThread
+
+	for _, evt := range pr.History() {
+		for _, change := range evt.Changes() {
+			c, ok := AsComment(change)
+			if !ok {
+				continue
+			}
+			comments = append(comments, &CommentThread{Comment: c, Author: evt.Author(), Timestamp: evt.Timestamp()})
+		}
+	}
+
+	sort.SliceStable(comments, func(i, j int) bool {
+		return comments[i].Timestamp < comments[j].Timestamp
+	})
+
+	// Threads started, by the ids of their comments and the discussions or lines they're on
+	var result []*CommentThread
+	started := make(map[string]*CommentThread)
+
+	for _, c := range comments {
+		key := c.Comment.InReplyTo()
+		if key == "" && c.Comment.Location() != "" {
+			key = "line:" + c.Comment.Location()
+		}
+
+		if root, ok := started[key]; ok && key != "" {
+			root.Replies = append(root.Replies, c)
+			started[c.Comment.CommentId()] = root
+			continue
+		}
+
+		result = append(result, c)
+		started[c.Comment.CommentId()] = c
+		if key != "" {
+			started[key] = c
+		}
+	}
+
+	return result
+}
+
+// FindComment returns the comment of the review with the given id
+func FindComment(pr PullRequest, id string) (*CommentThread, error) {
+	for _, t := range Threads(pr) {
+		for _, c := range append([]*CommentThread{t}, t.Replies...) {
+			if c.Comment.CommentId() == id {
+				return c, nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("review %s has no comment %s", pr.Id(), id)
+}
+
+// QuoteComment returns the text of a ticket comment quoting the comment of the review with the
+// given id, followed by a link back to the review
+func QuoteComment(pr PullRequest, id string) (string, error) {
+	c, err := FindComment(pr, id)
+	if err != nil {
+		return "", err
+	}
+
+	var output strings.Builder
+
+	for _, line := range strings.Split(strings.TrimRight(c.Comment.Body(), "\r\n"), "\n") {
+		output.WriteString(strings.TrimRight("> "+strings.TrimRight(line, "\r"), " ") + "\n")
+	}
+	output.WriteString("\n")
+
+	reviewRef := pr.Id()
+	if url := pr.ReviewUrl(); url != "" && url != pr.Id() {
+		reviewRef = fmt.Sprintf("[%s](%s)", pr.Id(), url)
+	}
+
+	author := "unknown"
+	if c.Author != nil {
+		author = c.Author.DisplayName()
+	}
+
+	output.WriteString(fmt.Sprintf("%s, comment %s on review %s", author, id, reviewRef))
+	if location := c.Comment.Location(); location != "" {
+		output.WriteString(fmt.Sprintf(" at `%s`", location))
+	}
+	output.WriteString("\n")
+
+	return output.String(), nil
+}
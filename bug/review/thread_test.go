@@ -0,0 +1,82 @@
+package review
+
+import (
+	"testing"
+	"time"
+
+	"code.gitea.io/sdk/gitea"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/daedaleanai/git-ticket/identity"
+)
+
+func TestThreadsByReply(t *testing.T) {
+	alice := identity.NewBare("Alice", "alice@example.com")
+	bob := identity.NewBare("Bob", "bob@example.com")
+
+	info := &PhabReviewInfo{
+		RevisionId: "D42",
+		Updates: []ReviewUpdate{
+			{PhabTransaction: PhabTransaction{TransId: "1", Timestamp: 100, Type: CommentTransaction,
+				Diff: 7, Path: "main.go", Line: 3, Text: "Why not a constant?\nIt never changes.", CommentPhid: "PHID-XCMT-1"},
+				AuthorId: bob},
+			{PhabTransaction: PhabTransaction{TransId: "2", Timestamp: 200, Type: CommentTransaction,
+				Diff: 7, Path: "main.go", Line: 9, Text: "Nit: typo", CommentPhid: "PHID-XCMT-2"},
+				AuthorId: bob},
+			{PhabTransaction: PhabTransaction{TransId: "3", Timestamp: 300, Type: CommentTransaction,
+				Diff: 8, Path: "main.go", Line: 4, Text: "Done", CommentPhid: "PHID-XCMT-3", ReplyTo: "PHID-XCMT-1"},
+				AuthorId: alice},
+			{PhabTransaction: PhabTransaction{TransId: "4", Timestamp: 300, Type: StatusTransaction, Status: "needs-review"},
+				AuthorId: alice},
+		},
+	}
+
+	threads := Threads(info)
+	require.Len(t, threads, 2)
+	assert.Equal(t, "PHID-XCMT-1", threads[0].Comment.CommentId())
+	assert.Equal(t, "Why not a constant?\nIt never changes.", threads[0].Comment.Body())
+	assert.Equal(t, "main.go:3@7", threads[0].Comment.Location())
+	assert.Equal(t, bob, threads[0].Author)
+	require.Len(t, threads[0].Replies, 1)
+	assert.Equal(t, "Done", threads[0].Replies[0].Comment.Body())
+	assert.Equal(t, alice, threads[0].Replies[0].Author)
+	assert.Empty(t, threads[1].Replies)
+
+	quote, err := QuoteComment(info, "PHID-XCMT-1")
+	require.NoError(t, err)
+	assert.Equal(t, "> Why not a constant?\n> It never changes.\n\nBob, comment PHID-XCMT-1 on review D42 at `main.go:3@7`\n", quote)
+
+	_, err = QuoteComment(info, "PHID-XCMT-9")
+	assert.Error(t, err)
+}
+
+func TestThreadsByLine(t *testing.T) {
+	alice := identity.NewBare("Alice", "alice@example.com")
+	bob := identity.NewBare("Bob", "bob@example.com")
+
+	comment := func(id int64, line uint64, body string) Comment {
+		return Comment{RawComment: gitea.PullReviewComment{ID: id, Body: body, Path: "main.go", LineNum: line, CommitID: "abc"}}
+	}
+
+	info := &GiteaInfo{
+		Owner: "tools", Repository: "hello", PullId: 1,
+		Reviews: []GiteaReview{
+			{RawReview: gitea.PullReview{Submitted: time.Unix(100, 0)}, AuthorId: bob,
+				Comments: []Comment{comment(1, 3, "Off by one?"), comment(2, 5, "Unused")}},
+			{RawReview: gitea.PullReview{Submitted: time.Unix(200, 0)}, AuthorId: alice,
+				Comments: []Comment{comment(3, 3, "No, it starts at 1")}},
+		},
+	}
+
+	threads := Threads(info)
+	require.Len(t, threads, 2)
+	assert.Equal(t, "1", threads[0].Comment.CommentId())
+	require.Len(t, threads[0].Replies, 1)
+	assert.Equal(t, "No, it starts at 1", threads[0].Replies[0].Comment.Body())
+	assert.Equal(t, "2", threads[1].Comment.CommentId())
+
+	found, err := FindComment(info, "3")
+	require.NoError(t, err)
+	assert.Equal(t, alice, found.Author)
+}
@@ -1,7 +1,9 @@
 package bug
 
 import (
+	"crypto/sha256"
 	"fmt"
+	"sort"
 	"time"
 
 	"github.com/daedaleanai/git-ticket/bug/review"
@@ -28,6 +30,8 @@ type Snapshot struct {
 	Participants []identity.Interface
 	Ccb          []CcbInfo
 	CreateTime   time.Time
+	// MovedTo is the repository the bug was moved to, if it was
+	MovedTo string
 
 	Timeline []TimelineItem
 
@@ -48,6 +52,20 @@ func (snap *Snapshot) EditTime() time.Time {
 	return snap.Operations[len(snap.Operations)-1].Time()
 }
 
+// Hash returns a hash identifying the history the snapshot has been compiled from
+func (snap *Snapshot) Hash() string {
+	return HashOperations(snap.Operations)
+}
+
+// HashOperations returns a hash identifying an ordered list of operations
+func HashOperations(ops []Operation) string {
+	h := sha256.New()
+	for _, op := range ops {
+		h.Write([]byte(op.Id()))
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
 // GetCreateMetadata return the creation metadata
 func (snap *Snapshot) GetCreateMetadata(key string) (string, bool) {
 	return snap.Operations[0].GetMetadata(key)
@@ -95,6 +113,16 @@ func (snap *Snapshot) addParticipant(participant identity.Interface) {
 	snap.Participants = append(snap.Participants, participant)
 }
 
+// HasLabel return true if the ticket has the label
+func (snap *Snapshot) HasLabel(label Label) bool {
+	for _, l := range snap.Labels {
+		if l == label {
+			return true
+		}
+	}
+	return false
+}
+
 // HasParticipant return true if the id is a participant
 func (snap *Snapshot) HasParticipant(id entity.Id) bool {
 	for _, p := range snap.Participants {
@@ -145,11 +173,48 @@ func (snap *Snapshot) GetCcbState(id entity.Id, status Status) CcbState {
 	return RemovedCcbState
 }
 
+// ChangedFiles returns the files changed by the reviews of the ticket, sorted by path. The lines
+// added and deleted by several reviews changing the same file are summed.
+func (snap *Snapshot) ChangedFiles() []review.FileChange {
+	files := map[string]*review.FileChange{}
+	for _, r := range snap.Reviews {
+		for _, f := range r.ChangedFiles() {
+			if file, ok := files[f.Path]; ok {
+				file.Additions += f.Additions
+				file.Deletions += f.Deletions
+				continue
+			}
+			file := f
+			files[f.Path] = &file
+		}
+	}
+
+	result := make([]review.FileChange, 0, len(files))
+	for _, f := range files {
+		result = append(result, *f)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Path < result[j].Path
+	})
+	return result
+}
+
 // Sign post method for gqlgen
 func (snap *Snapshot) IsAuthored() {}
 
+// EvaluateChecklist returns the checklist with the sections and questions which don't apply to
+// this snapshot, according to their conditions, hidden
+func (snap *Snapshot) EvaluateChecklist(cl config.Checklist) config.Checklist {
+	labels := make([]config.Label, len(snap.Labels))
+	for i, l := range snap.Labels {
+		labels[i] = config.Label(l)
+	}
+	return cl.Evaluate(labels)
+}
+
 // GetUserChecklists returns a map of checklists associated with this snapshot for the given reviewer id,
-// if the blank flag is set then always return a clean set of checklists
+// if the blank flag is set then always return a clean set of checklists. The checklist conditions
+// are evaluated against this snapshot.
 func (snap *Snapshot) GetUserChecklists(c config.ChecklistConfig, reviewer entity.Id, blank bool) (map[Label]config.Checklist, error) {
 	checklists := make(map[Label]config.Checklist)
 
@@ -157,13 +222,13 @@ func (snap *Snapshot) GetUserChecklists(c config.ChecklistConfig, reviewer entit
 	for _, l := range snap.Labels {
 		if l.IsChecklist() {
 			if snapshotChecklist, present := snap.Checklists[l][reviewer]; !blank && present {
-				checklists[l] = snapshotChecklist.Checklist
+				checklists[l] = snap.EvaluateChecklist(snapshotChecklist.Checklist)
 			} else {
-				var err error
-				checklists[l], err = c.GetChecklist(config.Label(l))
+				cl, err := c.GetChecklist(config.Label(l))
 				if err != nil {
 					return nil, err
 				}
+				checklists[l] = snap.EvaluateChecklist(cl)
 			}
 		}
 	}
@@ -185,7 +250,8 @@ func (snap *Snapshot) GetChecklistCompoundStates() map[Label]config.ChecklistSta
 				// at least one user has edited this checklist
 			ReviewsLoop:
 				for _, cl := range clMap {
-					clState := cl.CompoundState()
+					// questions which don't apply to the ticket don't count
+					clState := snap.EvaluateChecklist(cl.Checklist).CompoundState()
 					switch clState {
 					case config.Failed:
 						// someone failed it, it's failed
@@ -76,3 +76,38 @@ func TestSnapshot_GetChecklistCompoundStates(t *testing.T) {
 	snapshot.Labels = append(snapshot.Labels, "checklist:ABC")
 	assert.Equal(t, snapshot.GetChecklistCompoundStates(), map[Label]config.ChecklistState{"checklist:XYZ": config.Failed, "checklist:ABC": config.TBD})
 }
+
+func TestSnapshot_GetChecklistCompoundStatesConditional(t *testing.T) {
+	snapshot := Snapshot{
+		Labels: []Label{"checklist:XYZ", "repo:ground"},
+		Checklists: map[Label]map[entity.Id]ChecklistSnapshot{
+			"checklist:XYZ": {
+				"123": {
+					Checklist: config.Checklist{
+						Sections: []config.ChecklistSection{
+							{
+								Questions: []config.ChecklistQuestion{
+									{State: config.Passed},
+								},
+							},
+							{
+								Conditions: []config.ChecklistCondition{{Label: "repo:flight-.*"}},
+								Questions: []config.ChecklistQuestion{
+									{State: config.TBD},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	// the second section doesn't apply to the ticket, its questions are ignored
+	assert.Equal(t, map[Label]config.ChecklistState{"checklist:XYZ": config.Passed}, snapshot.GetChecklistCompoundStates())
+	assert.NoError(t, ValidateChecklistsCompleted(&snapshot, AcceptedStatus))
+
+	// once the section applies its questions must be answered
+	snapshot.Labels = append(snapshot.Labels, "repo:flight-control")
+	assert.Equal(t, map[Label]config.ChecklistState{"checklist:XYZ": config.TBD}, snapshot.GetChecklistCompoundStates())
+}
@@ -56,3 +56,9 @@ func (b *WithSnapshot) Merge(repo repository.Repo, other Interface) (bool, error
 	b.snap = nil
 	return b.Bug.Merge(repo, other)
 }
+
+// ClearSnapshot drops the snapshot so that it's compiled again when needed, e.g. after the
+// metadata of an operation already applied to it, and thereby its id, changed
+func (b *WithSnapshot) ClearSnapshot() {
+	b.snap = nil
+}
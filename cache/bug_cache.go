@@ -11,10 +11,14 @@ import (
 	"github.com/daedaleanai/git-ticket/bug"
 	"github.com/daedaleanai/git-ticket/entity"
 	"github.com/daedaleanai/git-ticket/repository"
+	"github.com/daedaleanai/git-ticket/util/lamport"
 )
 
 var ErrNoMatchingOp = fmt.Errorf("no matching operation found")
 
+// signCcbApprovalsConfigKey is the git config key enabling the signature of the CCB approvals
+const signCcbApprovalsConfigKey = "git-bug.ccb.sign-approvals"
+
 // BugCache is a wrapper around a Bug. It provide multiple functions:
 //
 // 1. Provide a higher level API to use than the raw API from Bug.
@@ -43,6 +47,34 @@ func (c *BugCache) Id() entity.Id {
 	return c.bug.Id()
 }
 
+// OperationCommit returns the hash of the git commit which stored the given operation,
+// or false if it's not committed yet
+func (c *BugCache) OperationCommit(id entity.Id) (repository.Hash, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.bug.OperationCommit(id)
+}
+
+// OperationsSince returns the committed operations stored with an edit lamport time after the
+// given one
+func (c *BugCache) OperationsSince(time lamport.Time) []bug.Operation {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.bug.OperationsSince(time)
+}
+
+// setOperationMetadata sets the metadata of an operation appended to the bug. As this changes
+// the id of the operation, the snapshot is compiled again for its comment and timeline ids to match.
+func (c *BugCache) setOperationMetadata(op bug.Operation, metadata map[string]string) {
+	if len(metadata) == 0 {
+		return
+	}
+	for key, value := range metadata {
+		op.SetMetadata(key, value)
+	}
+	c.bug.ClearSnapshot()
+}
+
 func (c *BugCache) notifyUpdated() error {
 	return c.repoCache.bugUpdated(c.bug.Id())
 }
@@ -95,9 +127,7 @@ func (c *BugCache) AddCommentRaw(author *IdentityCache, unixTime int64, message
 		return nil, err
 	}
 
-	for key, value := range metadata {
-		op.SetMetadata(key, value)
-	}
+	c.setOperationMetadata(op, metadata)
 
 	c.mu.Unlock()
 
@@ -121,9 +151,7 @@ func (c *BugCache) ChangeLabelsRaw(author *IdentityCache, unixTime int64, added
 		return changes, nil, err
 	}
 
-	for key, value := range metadata {
-		op.SetMetadata(key, value)
-	}
+	c.setOperationMetadata(op, metadata)
 
 	c.mu.Unlock()
 
@@ -152,9 +180,7 @@ func (c *BugCache) ForceChangeLabelsRaw(author *IdentityCache, unixTime int64, a
 		return nil, err
 	}
 
-	for key, value := range metadata {
-		op.SetMetadata(key, value)
-	}
+	c.setOperationMetadata(op, metadata)
 
 	c.mu.Unlock()
 	err = c.notifyUpdated()
@@ -182,9 +208,7 @@ func (c *BugCache) OpenRaw(author *IdentityCache, unixTime int64, metadata map[s
 		return nil, err
 	}
 
-	for key, value := range metadata {
-		op.SetMetadata(key, value)
-	}
+	c.setOperationMetadata(op, metadata)
 
 	c.mu.Unlock()
 	return op, c.notifyUpdated()
@@ -207,9 +231,7 @@ func (c *BugCache) CloseRaw(author *IdentityCache, unixTime int64, metadata map[
 		return nil, err
 	}
 
-	for key, value := range metadata {
-		op.SetMetadata(key, value)
-	}
+	c.setOperationMetadata(op, metadata)
 
 	c.mu.Unlock()
 	return op, c.notifyUpdated()
@@ -230,9 +252,7 @@ func (c *BugCache) SetAssigneeRaw(author *IdentityCache, unixTime int64, metadat
 		return nil, err
 	}
 
-	for key, value := range metadata {
-		op.SetMetadata(key, value)
-	}
+	c.setOperationMetadata(op, metadata)
 
 	return op, c.notifyUpdated()
 }
@@ -252,9 +272,7 @@ func (c *BugCache) SetChecklistRaw(author *IdentityCache, unixTime int64, cl con
 		return nil, err
 	}
 
-	for key, value := range metadata {
-		op.SetMetadata(key, value)
-	}
+	c.setOperationMetadata(op, metadata)
 
 	return op, c.notifyUpdated()
 }
@@ -292,9 +310,7 @@ func (c *BugCache) SetReviewRaw(author *IdentityCache, unixTime int64, metadata
 		return nil, err
 	}
 
-	for key, value := range metadata {
-		op.SetMetadata(key, value)
-	}
+	c.setOperationMetadata(op, metadata)
 
 	return op, c.notifyUpdated()
 }
@@ -314,10 +330,22 @@ func (c *BugCache) SetStatusRaw(author *IdentityCache, unixTime int64, metadata
 		return nil, err
 	}
 
-	for key, value := range metadata {
-		op.SetMetadata(key, value)
+	c.setOperationMetadata(op, metadata)
+
+	return op, c.notifyUpdated()
+}
+
+func (c *BugCache) ForceSetStatusRaw(author *IdentityCache, unixTime int64, metadata map[string]string, status bug.Status) (*bug.SetStatusOperation, error) {
+	c.mu.Lock()
+	op, err := bug.ForceSetStatus(c.bug, author.Identity, unixTime, status)
+	if err != nil {
+		c.mu.Unlock()
+		return nil, err
 	}
 
+	c.setOperationMetadata(op, metadata)
+
+	c.mu.Unlock()
 	return op, c.notifyUpdated()
 }
 
@@ -338,10 +366,32 @@ func (c *BugCache) SetTitleRaw(author *IdentityCache, unixTime int64, title stri
 		return nil, err
 	}
 
-	for key, value := range metadata {
-		op.SetMetadata(key, value)
+	c.setOperationMetadata(op, metadata)
+
+	c.mu.Unlock()
+	return op, c.notifyUpdated()
+}
+
+// Move records that the bug was copied to another repository, at the given path or url
+func (c *BugCache) Move(to string) (*bug.MoveOperation, error) {
+	author, err := c.repoCache.GetUserIdentity()
+	if err != nil {
+		return nil, err
+	}
+
+	return c.MoveRaw(author, time.Now().Unix(), to, nil)
+}
+
+func (c *BugCache) MoveRaw(author *IdentityCache, unixTime int64, to string, metadata map[string]string) (*bug.MoveOperation, error) {
+	c.mu.Lock()
+	op, err := bug.Move(c.bug, author.Identity, unixTime, to)
+	if err != nil {
+		c.mu.Unlock()
+		return nil, err
 	}
 
+	c.setOperationMetadata(op, metadata)
+
 	c.mu.Unlock()
 	return op, c.notifyUpdated()
 }
@@ -363,9 +413,7 @@ func (c *BugCache) EditCreateCommentRaw(author *IdentityCache, unixTime int64, b
 		return nil, err
 	}
 
-	for key, value := range metadata {
-		op.SetMetadata(key, value)
-	}
+	c.setOperationMetadata(op, metadata)
 
 	c.mu.Unlock()
 	return op, c.notifyUpdated()
@@ -388,9 +436,7 @@ func (c *BugCache) EditCommentRaw(author *IdentityCache, unixTime int64, target
 		return nil, err
 	}
 
-	for key, value := range metadata {
-		op.SetMetadata(key, value)
-	}
+	c.setOperationMetadata(op, metadata)
 
 	c.mu.Unlock()
 	return op, c.notifyUpdated()
@@ -417,6 +463,20 @@ func (c *BugCache) SetMetadataRaw(author *IdentityCache, unixTime int64, target
 	return op, c.notifyUpdated()
 }
 
+// NoopRaw adds an operation which doesn't change the ticket, to record metadata, e.g. that a
+// change of another bug tracker was imported
+func (c *BugCache) NoopRaw(author *IdentityCache, unixTime int64, metadata map[string]string) (*bug.NoOpOperation, error) {
+	c.mu.Lock()
+	op, err := bug.NoOp(c.bug, author.Identity, unixTime, metadata)
+	if err != nil {
+		c.mu.Unlock()
+		return nil, err
+	}
+
+	c.mu.Unlock()
+	return op, c.notifyUpdated()
+}
+
 func (c *BugCache) CcbAdd(user *IdentityCache, status bug.Status) (*bug.SetCcbOperation, error) {
 	author, err := c.repoCache.GetUserIdentity()
 	if err != nil {
@@ -426,13 +486,36 @@ func (c *BugCache) CcbAdd(user *IdentityCache, status bug.Status) (*bug.SetCcbOp
 	return c.SetCcbRaw(author, time.Now().Unix(), nil, user, status, bug.AddedCcbState)
 }
 
+// CcbApprove approves the given status. When git-bug.ccb.sign-approvals is set in the git
+// config, the approval is signed with the user signing key over the ticket id, the status and
+// the hash of the current ticket snapshot.
 func (c *BugCache) CcbApprove(status bug.Status) (*bug.SetCcbOperation, error) {
 	author, err := c.repoCache.GetUserIdentity()
 	if err != nil {
 		return nil, err
 	}
 
-	return c.SetCcbRaw(author, time.Now().Unix(), nil, author, status, bug.ApprovedCcbState)
+	sign, err := c.repoCache.LocalConfig().ReadBool(signCcbApprovalsConfigKey)
+	if err != nil && err != repository.ErrNoConfigEntry {
+		return nil, fmt.Errorf("failed to read %s: %w", signCcbApprovalsConfigKey, err)
+	}
+	if !sign {
+		return c.SetCcbRaw(author, time.Now().Unix(), nil, author, status, bug.ApprovedCcbState)
+	}
+
+	snapshotHash := c.Snapshot().Hash()
+
+	armored, err := c.repoCache.repo.SignData(bug.CcbApprovalPayload(c.Id(), status, snapshotHash))
+	if err != nil {
+		return nil, err
+	}
+
+	signature := &bug.CcbSignature{
+		SnapshotHash: snapshotHash,
+		Armored:      armored,
+	}
+
+	return c.SetCcbSignedRaw(author, time.Now().Unix(), nil, status, signature)
 }
 
 func (c *BugCache) CcbBlock(status bug.Status) (*bug.SetCcbOperation, error) {
@@ -459,32 +542,78 @@ func (c *BugCache) SetCcbRaw(author *IdentityCache, unixTime int64, metadata map
 		return nil, err
 	}
 
-	for key, value := range metadata {
-		op.SetMetadata(key, value)
+	c.setOperationMetadata(op, metadata)
+
+	return op, c.notifyUpdated()
+}
+
+func (c *BugCache) SetCcbSignedRaw(author *IdentityCache, unixTime int64, metadata map[string]string, status bug.Status, signature *bug.CcbSignature) (*bug.SetCcbOperation, error) {
+	op, err := bug.SetCcbSigned(c.bug, author.Identity, unixTime, status, signature)
+	if err != nil {
+		return nil, err
 	}
 
+	c.setOperationMetadata(op, metadata)
+
 	return op, c.notifyUpdated()
 }
 
+// Commit stores the operations not committed yet, unless the bug was moved to another repository
+// or a pre- hook vetoes them: they are then dropped. The post- hooks are run once the operations
+// are stored.
 func (c *BugCache) Commit() error {
 	c.mu.Lock()
-	err := c.bug.Commit(c.repoCache.repo)
+	ops := c.bug.StagedOperations()
+	err := checkNotMoved(c.bug.Snapshot(), ops)
+	if err == nil {
+		err = c.repoCache.runPreHooks(c.bug.Snapshot(), ops)
+	}
 	if err != nil {
+		c.bug.DiscardStaging()
+		c.bug.ClearSnapshot()
 		c.mu.Unlock()
+		if updateErr := c.notifyUpdated(); updateErr != nil {
+			return updateErr
+		}
 		return err
 	}
+	err = c.bug.Commit(c.repoCache.repo)
+	if err != nil {
+		c.mu.Unlock()
+		return err
+	}
+	snap := c.bug.Snapshot()
 	c.mu.Unlock()
+
+	c.repoCache.runPostHooks(snap, ops)
 	return c.notifyUpdated()
 }
 
+// checkNotMoved returns an error if operations not committed yet follow the move of the bug to
+// another repository, a moved bug being closed to further changes
+func checkNotMoved(snap *bug.Snapshot, staged []bug.Operation) error {
+	isStaged := make(map[entity.Id]bool, len(staged))
+	for _, op := range staged {
+		isStaged[op.Id()] = true
+	}
+
+	movedTo := ""
+	for _, op := range snap.Operations {
+		if move, ok := op.(*bug.MoveOperation); ok && movedTo == "" {
+			movedTo = move.To
+			continue
+		}
+		if movedTo != "" && isStaged[op.Id()] {
+			return fmt.Errorf("ticket %s was moved to %s and can't be changed anymore", snap.Id().Human(), movedTo)
+		}
+	}
+	return nil
+}
+
 func (c *BugCache) CommitAsNeeded() error {
-	c.mu.Lock()
-	err := c.bug.CommitAsNeeded(c.repoCache.repo)
-	if err != nil {
-		c.mu.Unlock()
-		return err
+	if c.NeedCommit() {
+		return c.Commit()
 	}
-	c.mu.Unlock()
 	return c.notifyUpdated()
 }
 
@@ -26,6 +26,9 @@ type BugExcerpt struct {
 	EditLamportTime   lamport.Time
 	CreateUnixTime    int64
 	EditUnixTime      int64
+	// LenOperations is the number of operations, which changes when operations stored with an
+	// older edit time are merged, unlike EditLamportTime
+	LenOperations int
 
 	Status       bug.Status
 	Labels       []bug.Label
@@ -36,6 +39,10 @@ type BugExcerpt struct {
 	Participants []entity.Id
 	Ccb          []CcbInfoExcerpt
 	Checklists   []ChecklistInfoExcerpt
+	// Files holds the paths of the files changed by the reviews
+	Files []string
+	// MovedTo is the repository the bug was moved to, if it was
+	MovedTo string
 
 	// If author is identity.Bare, LegacyAuthor is set
 	// If author is identity.Identity, AuthorId is set and data is deported
@@ -111,6 +118,12 @@ func NewBugExcerpt(b bug.Interface, snap *bug.Snapshot) *BugExcerpt {
 		})
 	}
 
+	changedFiles := snap.ChangedFiles()
+	files := make([]string, 0, len(changedFiles))
+	for _, f := range changedFiles {
+		files = append(files, f.Path)
+	}
+
 	e := &BugExcerpt{
 		Id:                b.Id(),
 		CreateLamportTime: b.CreateLamportTime(),
@@ -124,8 +137,11 @@ func NewBugExcerpt(b bug.Interface, snap *bug.Snapshot) *BugExcerpt {
 		Participants:      participantsIds,
 		Ccb:               ccb,
 		Checklists:        checklists,
+		Files:             files,
+		MovedTo:           snap.MovedTo,
 		Title:             snap.Title,
 		LenComments:       len(snap.Comments),
+		LenOperations:     len(snap.Operations),
 		CreateMetadata:    b.FirstOp().AllMetadata(),
 	}
 
@@ -152,6 +168,16 @@ func (b *BugExcerpt) EditTime() time.Time {
 	return time.Unix(b.EditUnixTime, 0)
 }
 
+// HasLabel return true if the ticket has the label
+func (b *BugExcerpt) HasLabel(label bug.Label) bool {
+	for _, l := range b.Labels {
+		if l == label {
+			return true
+		}
+	}
+	return false
+}
+
 /*
  * Sorting
  */
@@ -38,6 +38,8 @@ func executeFilter(filter query.FilterNode, resolver resolver, b *BugExcerpt) bo
 		return executeLabelFilter(filter, resolver, b)
 	case *query.TitleFilter:
 		return executeTitleFilter(filter, resolver, b)
+	case *query.TouchesFilter:
+		return executeTouchesFilter(filter, resolver, b)
 	case *query.ChecklistFilter:
 		return executeChecklistFilter(filter, resolver, b)
 	case *query.NotFilter:
@@ -189,6 +191,30 @@ func executeTitleFilter(filter *query.TitleFilter, resolver resolver, b *BugExce
 	}
 }
 
+func executeTouchesFilter(filter *query.TouchesFilter, resolver resolver, b *BugExcerpt) bool {
+	var runMatcher func(path string) bool
+	switch matcher := filter.Path.(type) {
+	case *query.LiteralNode:
+		// A literal matches the file or the files in the directory
+		expected := strings.TrimSuffix(matcher.Token.Literal, "/")
+		runMatcher = func(path string) bool {
+			return path == expected || strings.HasPrefix(path, expected+"/")
+		}
+	case *query.RegexNode:
+		runMatcher = matcher.Match
+	default:
+		log.Fatal("Unhandled LiteralMatcherNode type: ", reflect.TypeOf(filter.Path))
+		return false
+	}
+
+	for _, f := range b.Files {
+		if runMatcher(f) {
+			return true
+		}
+	}
+	return false
+}
+
 func executeChecklistFilter(filter *query.ChecklistFilter, resolver resolver, b *BugExcerpt) bool {
 	stateMatcher := func(actualState config.ChecklistState) bool {
 		for _, state := range filter.States {
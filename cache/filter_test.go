@@ -34,3 +34,26 @@ func TestTitleFilter(t *testing.T) {
 		})
 	}
 }
+
+func TestTouchesFilter(t *testing.T) {
+	excerpt := &BugExcerpt{Files: []string{"src/flight/control.go", "README.md"}}
+
+	var tests = []struct {
+		name    string
+		matcher query.LiteralMatcherNode
+		match   bool
+	}{
+		{name: "file", matcher: &query.LiteralNode{Token: query.Token{TokenType: query.IdentToken, Literal: "README.md"}}, match: true},
+		{name: "directory", matcher: &query.LiteralNode{Token: query.Token{TokenType: query.IdentToken, Literal: "src/flight"}}, match: true},
+		{name: "directory with slash", matcher: &query.LiteralNode{Token: query.Token{TokenType: query.IdentToken, Literal: "src/flight/"}}, match: true},
+		{name: "path prefix", matcher: &query.LiteralNode{Token: query.Token{TokenType: query.IdentToken, Literal: "src/fl"}}, match: false},
+		{name: "regex", matcher: &query.RegexNode{Token: query.Token{TokenType: query.RegexToken, Literal: "src/flight/.*"}, Regex: *regexp.MustCompile("src/flight/.*")}, match: true},
+		{name: "regex no match", matcher: &query.RegexNode{Token: query.Token{TokenType: query.RegexToken, Literal: "^docs/"}, Regex: *regexp.MustCompile("^docs/")}, match: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.match, executeTouchesFilter(&query.TouchesFilter{Path: tt.matcher}, nil, excerpt))
+		})
+	}
+}
@@ -0,0 +1,195 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/daedaleanai/git-ticket/bug"
+	"github.com/daedaleanai/git-ticket/entity"
+	"github.com/daedaleanai/git-ticket/identity"
+)
+
+// hooksDir is the directory of the git repository holding the executables run on the ticket
+// events, named after the event with a "pre-" or a "post-" prefix, e.g. post-status-change
+const hooksDir = "ticket-hooks"
+
+// HookEvent returns the event of an operation, e.g. "status-change", or an empty string for
+// the operations without hooks
+func HookEvent(op bug.Operation) string {
+	switch op.(type) {
+	case *bug.CreateOperation:
+		return "create"
+	case *bug.SetTitleOperation:
+		return "title-change"
+	case *bug.AddCommentOperation:
+		return "comment"
+	case *bug.EditCommentOperation:
+		return "comment-edit"
+	case *bug.SetStatusOperation:
+		return "status-change"
+	case *bug.LabelChangeOperation:
+		return "label-change"
+	case *bug.SetAssigneeOperation:
+		return "assignee-change"
+	case *bug.SetCcbOperation:
+		return "ccb"
+	case *bug.SetChecklistOperation:
+		return "checklist"
+	case *bug.SetReviewOperation:
+		return "review"
+	case *bug.SetMetadataOperation:
+		return "metadata"
+	case *bug.MoveOperation:
+		return "move"
+	}
+	return ""
+}
+
+// HookIdentity is an identity in the payload of a hook
+type HookIdentity struct {
+	Id    entity.Id `json:"id"`
+	Name  string    `json:"name"`
+	Email string    `json:"email"`
+}
+
+func newHookIdentity(i identity.Interface) *HookIdentity {
+	if i == nil {
+		return nil
+	}
+	return &HookIdentity{Id: i.Id(), Name: i.DisplayName(), Email: i.Email()}
+}
+
+// HookSnapshot is the state of the ticket in the payload of a hook
+type HookSnapshot struct {
+	Title      string        `json:"title"`
+	Status     string        `json:"status"`
+	Labels     []bug.Label   `json:"labels"`
+	Author     *HookIdentity `json:"author"`
+	Assignee   *HookIdentity `json:"assignee,omitempty"`
+	CreateTime time.Time     `json:"create_time"`
+	EditTime   time.Time     `json:"edit_time"`
+	MovedTo    string        `json:"moved_to,omitempty"`
+}
+
+// HookPayload is the JSON document the hooks read on their standard input
+type HookPayload struct {
+	Event string `json:"event"`
+	// Ticket is the id of the ticket, not set for the pre- hooks of a new ticket
+	Ticket    entity.Id     `json:"ticket,omitempty"`
+	Operation bug.Operation `json:"operation"`
+	// Snapshot is the state of the ticket with the operation applied
+	Snapshot HookSnapshot `json:"snapshot"`
+}
+
+// NewHookSnapshot returns the state of a ticket in the payload of a hook
+func NewHookSnapshot(snap *bug.Snapshot) HookSnapshot {
+	return HookSnapshot{
+		Title:      snap.Title,
+		Status:     snap.Status.String(),
+		Labels:     snap.Labels,
+		Author:     newHookIdentity(snap.Author),
+		Assignee:   newHookIdentity(snap.Assignee),
+		CreateTime: snap.CreateTime,
+		EditTime:   snap.EditTime(),
+		MovedTo:    snap.MovedTo,
+	}
+}
+
+func newHookPayload(event string, snap *bug.Snapshot, op bug.Operation) HookPayload {
+	return HookPayload{
+		Event:     event,
+		Ticket:    snap.Id(),
+		Operation: op,
+		Snapshot:  NewHookSnapshot(snap),
+	}
+}
+
+// HookRejectedError is returned when a pre- hook vetoes the commit of operations
+type HookRejectedError struct {
+	Hook   string
+	Output string
+}
+
+func (e *HookRejectedError) Error() string {
+	if e.Output == "" {
+		return fmt.Sprintf("the %s hook rejected the change", e.Hook)
+	}
+	return fmt.Sprintf("the %s hook rejected the change: %s", e.Hook, e.Output)
+}
+
+// hookPath returns the path of the executable of a hook, or false if there is none
+func (c *RepoCache) hookPath(hook string) (string, bool) {
+	path := filepath.Join(c.repo.GetPath(), hooksDir, hook)
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() || info.Mode()&0111 == 0 {
+		return "", false
+	}
+	return path, true
+}
+
+// runPreHooks runs the pre- hooks of the operations to be committed, the first one exiting with
+// an error vetoes the commit
+func (c *RepoCache) runPreHooks(snap *bug.Snapshot, ops []bug.Operation) error {
+	for _, op := range ops {
+		event := HookEvent(op)
+		if event == "" {
+			continue
+		}
+		path, ok := c.hookPath("pre-" + event)
+		if !ok {
+			continue
+		}
+
+		payload, err := json.Marshal(newHookPayload(event, snap, op))
+		if err != nil {
+			return err
+		}
+
+		cmd := exec.Command(path)
+		cmd.Stdin = bytes.NewReader(payload)
+		output, err := cmd.CombinedOutput()
+		if _, ok := err.(*exec.ExitError); ok {
+			return &HookRejectedError{Hook: "pre-" + event, Output: strings.TrimSpace(string(output))}
+		}
+		if err != nil {
+			return errors.Wrapf(err, "can't run the pre-%s hook", event)
+		}
+	}
+
+	return nil
+}
+
+// runPostHooks runs the post- hooks of the operations committed or merged. As the operations
+// are already stored, the failures are only reported.
+func (c *RepoCache) runPostHooks(snap *bug.Snapshot, ops []bug.Operation) {
+	for _, op := range ops {
+		event := HookEvent(op)
+		if event == "" {
+			continue
+		}
+		path, ok := c.hookPath("post-" + event)
+		if !ok {
+			continue
+		}
+
+		payload, err := json.Marshal(newHookPayload(event, snap, op))
+		if err == nil {
+			cmd := exec.Command(path)
+			cmd.Stdin = bytes.NewReader(payload)
+			cmd.Stdout = os.Stderr
+			cmd.Stderr = os.Stderr
+			err = cmd.Run()
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "the post-%s hook of ticket %s failed: %v\n", event, snap.Id().Human(), err)
+		}
+	}
+}
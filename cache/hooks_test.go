@@ -0,0 +1,96 @@
+package cache
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/daedaleanai/git-ticket/bug"
+	"github.com/daedaleanai/git-ticket/config"
+	"github.com/daedaleanai/git-ticket/repository"
+)
+
+func writeHook(t *testing.T, repo repository.ClockedRepo, name string, script string) {
+	dir := filepath.Join(repo.GetPath(), hooksDir)
+	require.NoError(t, os.MkdirAll(dir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"+script), 0755))
+}
+
+func TestHooks(t *testing.T) {
+	repo := repository.CreateTestRepo(false)
+	defer repository.CleanupTestRepos(repo)
+	repository.SetupSigningKey(t, repo, "rene@descartes.fr")
+
+	repoCache, err := NewRepoCache(repo, false)
+	require.NoError(t, err)
+
+	rene, err := repoCache.NewIdentity("René Descartes", "rene@descartes.fr", true, true, "")
+	require.NoError(t, err)
+	require.NoError(t, repoCache.SetUserIdentity(rene))
+
+	require.NoError(t, repoCache.DoWithLockedConfigCache(func(c *config.ConfigCache) error {
+		if err := c.LabelConfig.AppendLabelToConfiguration(config.Label("repo:test")); err != nil {
+			return err
+		}
+		return c.LabelConfig.Store(repo)
+	}))
+
+	payloads := filepath.Join(t.TempDir(), "payloads")
+	writeHook(t, repo, "post-comment", "cat >> "+payloads+"\necho >> "+payloads+"\n")
+	writeHook(t, repo, "pre-status-change", "echo 'status frozen'\nexit 1\n")
+
+	b, _, err := repoCache.NewBug(NewBugOpts{Title: "Engine explodes", Message: "On ignition.", Workflow: "workflow:eng", Repo: "repo:test"})
+	require.NoError(t, err)
+
+	// The vetoed operations are dropped
+	_, err = b.AddComment("Looking into it")
+	require.NoError(t, err)
+	_, err = b.SetStatus(bug.RejectedStatus)
+	require.NoError(t, err)
+	err = b.Commit()
+	assert.Equal(t, &HookRejectedError{Hook: "pre-status-change", Output: "status frozen"}, err)
+	assert.False(t, b.NeedCommit())
+	assert.Equal(t, bug.ProposedStatus, b.Snapshot().Status)
+	assert.Len(t, b.Snapshot().Comments, 1)
+	_, err = os.Stat(payloads)
+	assert.True(t, os.IsNotExist(err))
+
+	// The post- hooks read the payload of the committed operations
+	_, err = b.AddComment("Looking into it")
+	require.NoError(t, err)
+	require.NoError(t, b.Commit())
+
+	data, err := os.ReadFile(payloads)
+	require.NoError(t, err)
+	var payload struct {
+		Event     string `json:"event"`
+		Ticket    string `json:"ticket"`
+		Operation struct {
+			Message string `json:"message"`
+		} `json:"operation"`
+		Snapshot struct {
+			Title  string `json:"title"`
+			Status string `json:"status"`
+			Author struct {
+				Email string `json:"email"`
+			} `json:"author"`
+		} `json:"snapshot"`
+	}
+	require.NoError(t, json.Unmarshal(data, &payload))
+	assert.Equal(t, "comment", payload.Event)
+	assert.Equal(t, b.Id().String(), payload.Ticket)
+	assert.Equal(t, "Looking into it", payload.Operation.Message)
+	assert.Equal(t, "Engine explodes", payload.Snapshot.Title)
+	assert.Equal(t, "proposed", payload.Snapshot.Status)
+	assert.Equal(t, "rene@descartes.fr", payload.Snapshot.Author.Email)
+
+	// A new ticket can be vetoed as well
+	writeHook(t, repo, "pre-create", "exit 1\n")
+	_, _, err = repoCache.NewBug(NewBugOpts{Title: "Wings fall off", Message: "In flight.", Workflow: "workflow:eng", Repo: "repo:test"})
+	assert.EqualError(t, err, "the pre-create hook rejected the change")
+	assert.Len(t, repoCache.AllBugsIds(), 1)
+}
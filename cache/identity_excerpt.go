@@ -26,6 +26,7 @@ type IdentityExcerpt struct {
 	PhabID            string
 	GiteaID           int64
 	ImmutableMetadata map[string]string
+	MutableMetadata   map[string]string
 }
 
 func NewIdentityExcerpt(i *identity.Identity) *IdentityExcerpt {
@@ -37,6 +38,7 @@ func NewIdentityExcerpt(i *identity.Identity) *IdentityExcerpt {
 		PhabID:            i.PhabID(),
 		GiteaID:           i.GiteaID(),
 		ImmutableMetadata: i.ImmutableMetadata(),
+		MutableMetadata:   i.MutableMetadata(),
 	}
 }
 
@@ -24,7 +24,11 @@ import (
 
 // 1: original format
 // 2: added cache for identities with a reference in the bug cache
-const formatVersion = 2
+// 3: added the mutable metadata of identities
+// 4: added the files changed by the reviews of tickets
+// 5: added the repository tickets were moved to
+// 6: added the number of operations of the bugs
+const formatVersion = 6
 
 // The maximum number of bugs loaded in memory. After that, eviction will be done.
 const defaultMaxLoadedBugs = 1000
@@ -245,6 +245,39 @@ func (c *RepoCache) resolveBugMatcher(f func(*BugExcerpt) bool) (entity.Id, erro
 	return matching[0], nil
 }
 
+// ChecklistStats aggregates the answers to the checklist with the given label across the
+// tickets matching the query, or all of them if the query is nil
+func (c *RepoCache) ChecklistStats(label bug.Label, q *query.CompiledQuery, maxComments int) (bug.ChecklistStats, error) {
+	var template config.Checklist
+	err := c.DoWithLockedConfigCache(func(conf *config.ConfigCache) error {
+		var err error
+		template, err = conf.ChecklistConfig.GetChecklist(config.Label(label))
+		return err
+	})
+	if err != nil {
+		return bug.ChecklistStats{}, err
+	}
+
+	var snaps []*bug.Snapshot
+	for _, id := range c.QueryBugs(q) {
+		excerpt, err := c.ResolveBugExcerpt(id)
+		if err != nil {
+			return bug.ChecklistStats{}, err
+		}
+		if !excerpt.HasLabel(label) {
+			continue
+		}
+
+		b, err := c.ResolveBug(id)
+		if err != nil {
+			return bug.ChecklistStats{}, err
+		}
+		snaps = append(snaps, b.Snapshot())
+	}
+
+	return bug.ComputeChecklistStats(template, snaps, maxComments), nil
+}
+
 // QueryBugs return the id of all Bug matching the given Query
 func (c *RepoCache) QueryBugs(q *query.CompiledQuery) []entity.Id {
 	c.muBug.RLock()
@@ -522,6 +555,12 @@ func (c *RepoCache) NewBugRaw(author *IdentityCache, unixTime int64, opts NewBug
 		op.SetMetadata(key, value)
 	}
 
+	ops := b.StagedOperations()
+	snap := b.Compile()
+	if err := c.runPreHooks(&snap, ops); err != nil {
+		return nil, nil, err
+	}
+
 	err = b.Commit(c.repo)
 	if err != nil {
 		return nil, nil, err
@@ -546,6 +585,8 @@ func (c *RepoCache) NewBugRaw(author *IdentityCache, unixTime int64, opts NewBug
 		return nil, nil, err
 	}
 
+	c.runPostHooks(cached.Snapshot(), ops)
+
 	return cached, op, nil
 }
 
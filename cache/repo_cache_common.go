@@ -109,6 +109,12 @@ func (c *RepoCache) MergeAll(remote string) <-chan entity.MergeResult {
 				c.muBug.Lock()
 				c.bugExcerpts[result.Id] = NewBugExcerpt(b, &snap)
 				c.muBug.Unlock()
+
+				if result.Status == entity.MergeStatusNew {
+					c.runPostHooks(&snap, snap.Operations)
+				} else {
+					c.runPostHooks(&snap, b.MergedOperations())
+				}
 			}
 		}
 
@@ -0,0 +1,253 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+
+	"github.com/daedaleanai/git-ticket/bug"
+	"github.com/daedaleanai/git-ticket/entity"
+	"github.com/daedaleanai/git-ticket/identity"
+	"github.com/daedaleanai/git-ticket/repository"
+)
+
+// exportRecord is a line of an export, holding either an identity or a bug with its full history
+type exportRecord struct {
+	Identity entity.Id                  `json:"identity,omitempty"`
+	Versions []identity.ExportedVersion `json:"versions,omitempty"`
+	Bug      entity.Id                  `json:"ticket,omitempty"`
+	Packs    []bug.ExportedPack         `json:"packs,omitempty"`
+}
+
+// recordingResolver loads identities from the repository and records their ids in order
+type recordingResolver struct {
+	identity.Resolver
+	ids   []entity.Id
+	known map[entity.Id]bool
+}
+
+func (r *recordingResolver) ResolveIdentity(id entity.Id) (identity.Interface, error) {
+	if !r.known[id] {
+		r.known[id] = true
+		r.ids = append(r.ids, id)
+	}
+	return r.Resolver.ResolveIdentity(id)
+}
+
+// ExportBugs writes the bugs with their full history as JSON lines, preceded by all the
+// identities they reference. The bugs are exported as committed in git: operations not
+// committed yet aren't exported.
+func (c *RepoCache) ExportBugs(ids []entity.Id, w io.Writer) error {
+	resolver := &recordingResolver{
+		Resolver: identity.NewSimpleResolver(c.repo),
+		known:    make(map[entity.Id]bool),
+	}
+
+	for _, id := range ids {
+		if _, err := bug.ReadLocalBugWithResolver(c.repo, resolver, id); err != nil {
+			return errors.Wrapf(err, "ticket %s", id.Human())
+		}
+	}
+
+	encoder := json.NewEncoder(w)
+
+	for _, id := range resolver.ids {
+		versions, err := identity.Export(c.repo, id)
+		if err != nil {
+			return errors.Wrapf(err, "identity %s", id.Human())
+		}
+		if err := encoder.Encode(exportRecord{Identity: id, Versions: versions}); err != nil {
+			return err
+		}
+	}
+
+	for _, id := range ids {
+		packs, err := bug.Export(c.repo, id)
+		if err != nil {
+			return errors.Wrapf(err, "ticket %s", id.Human())
+		}
+		if err := encoder.Encode(exportRecord{Bug: id, Packs: packs}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ImportBugs reads an export written by ExportBugs and stores its identities and bugs with the
+// same ids and history. The identities and bugs already there are fast-forwarded if the export
+// has a later version of them.
+func (c *RepoCache) ImportBugs(r io.Reader) ([]entity.MergeResult, error) {
+	var results []entity.MergeResult
+
+	decoder := json.NewDecoder(r)
+	for {
+		var record exportRecord
+		err := decoder.Decode(&record)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return results, errors.Wrap(err, "invalid export")
+		}
+
+		var result entity.MergeResult
+		switch {
+		case record.Identity != "":
+			result, err = c.importIdentity(record.Identity, record.Versions)
+		case record.Bug != "":
+			result, err = c.importBug(record.Bug, record.Packs)
+		default:
+			return results, errors.New("invalid export: line with neither an identity nor a ticket")
+		}
+		if err != nil {
+			return results, err
+		}
+
+		results = append(results, result)
+	}
+
+	return results, c.write()
+}
+
+func (c *RepoCache) importIdentity(id entity.Id, versions []identity.ExportedVersion) (entity.MergeResult, error) {
+	if err := id.Validate(); err != nil {
+		return entity.MergeResult{}, errors.Wrap(err, "invalid identity id")
+	}
+
+	status, err := identity.Import(c.repo, id, versions)
+	if err != nil {
+		return entity.MergeResult{}, errors.Wrapf(err, "identity %s", id.Human())
+	}
+	if status == entity.MergeStatusNothing {
+		return entity.NewMergeStatus(status, id, nil), nil
+	}
+
+	i, err := identity.ReadLocal(c.repo, id)
+	if err != nil {
+		return entity.MergeResult{}, errors.Wrapf(err, "identity %s", id.Human())
+	}
+
+	c.muIdentity.Lock()
+	delete(c.identities, id)
+	c.identitiesExcerpts[id] = NewIdentityExcerpt(i)
+	c.muIdentity.Unlock()
+
+	return entity.NewMergeStatus(status, id, i), nil
+}
+
+func (c *RepoCache) importBug(id entity.Id, packs []bug.ExportedPack) (entity.MergeResult, error) {
+	if err := id.Validate(); err != nil {
+		return entity.MergeResult{}, errors.Wrap(err, "invalid ticket id")
+	}
+
+	status, err := bug.Import(c.repo, id, packs)
+	if err != nil {
+		return entity.MergeResult{}, errors.Wrapf(err, "ticket %s", id.Human())
+	}
+	if status == entity.MergeStatusNothing {
+		return entity.NewMergeStatus(status, id, nil), nil
+	}
+
+	b, err := bug.ReadLocalBug(c.repo, id)
+	if err != nil {
+		return entity.MergeResult{}, errors.Wrapf(err, "ticket %s", id.Human())
+	}
+	snap := b.Compile()
+
+	c.muBug.Lock()
+	// a loaded copy of the bug would be behind
+	if cached, ok := c.bugs[id]; ok && !cached.NeedCommit() {
+		c.loadedBugs.Remove(id)
+		delete(c.bugs, id)
+	}
+	c.bugExcerpts[id] = NewBugExcerpt(b, &snap)
+	c.muBug.Unlock()
+
+	return entity.NewMergeStatus(status, id, b), nil
+}
+
+// MoveBug copies a bug with its full history and the identities it references to another
+// repository, either the local repository at the given path or the given remote, then records
+// the move in the bug. It returns the path or the url of the repository the bug was moved to.
+func (c *RepoCache) MoveBug(b *BugCache, to string) (string, error) {
+	if b.NeedCommit() {
+		return "", errors.New("the ticket has uncommitted changes")
+	}
+	if movedTo := b.Snapshot().MovedTo; movedTo != "" {
+		return "", fmt.Errorf("the ticket was already moved to %s", movedTo)
+	}
+
+	var location string
+	var err error
+	if info, statErr := os.Stat(to); statErr == nil && info.IsDir() {
+		location, err = c.copyBugToPath(b.Id(), to)
+	} else {
+		location, err = c.copyBugToRemote(b.Id(), to)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := b.Move(location); err != nil {
+		return "", err
+	}
+	return location, b.Commit()
+}
+
+func (c *RepoCache) copyBugToPath(id entity.Id, path string) (string, error) {
+	location, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	repo, err := repository.NewGitRepo(location, []repository.ClockLoader{bug.ClockLoader, identity.ClockLoader})
+	if err != nil {
+		return "", errors.Wrapf(err, "can't open the repository %s", location)
+	}
+	if repo.GetPath() == c.repo.GetPath() {
+		return "", errors.New("the ticket is already in this repository")
+	}
+
+	target, err := NewRepoCache(repo, false)
+	if err != nil {
+		return "", err
+	}
+
+	var export bytes.Buffer
+	err = c.ExportBugs([]entity.Id{id}, &export)
+	if err == nil {
+		_, err = target.ImportBugs(&export)
+	}
+	if closeErr := target.Close(); err == nil {
+		err = closeErr
+	}
+
+	return location, err
+}
+
+func (c *RepoCache) copyBugToRemote(id entity.Id, remote string) (string, error) {
+	remotes, err := c.repo.GetRemotes()
+	if err != nil {
+		return "", err
+	}
+	location, ok := remotes[remote]
+	if !ok {
+		return "", fmt.Errorf("%s is neither a repository nor a remote", remote)
+	}
+
+	// the identities referenced by the ticket must be on the remote as well
+	if _, err := c.repo.PushRefs(remote, identity.Namespace); err != nil {
+		return "", err
+	}
+	if _, err := c.PushTicket(remote, id.String()); err != nil {
+		return "", err
+	}
+
+	return location, nil
+}
@@ -0,0 +1,177 @@
+package cache
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/daedaleanai/git-ticket/bug"
+	"github.com/daedaleanai/git-ticket/config"
+	"github.com/daedaleanai/git-ticket/entity"
+	"github.com/daedaleanai/git-ticket/repository"
+)
+
+func TestExportImportBugs(t *testing.T) {
+	repoA := repository.CreateTestRepo(false)
+	repoB := repository.CreateTestRepo(false)
+	defer repository.CleanupTestRepos(repoA, repoB)
+
+	repository.SetupSigningKey(t, repoA, "a@e.org")
+
+	cacheA, err := NewRepoCache(repoA, false)
+	require.NoError(t, err)
+
+	rene, err := cacheA.NewIdentity("René Descartes", "rene@descartes.fr", true, true, "")
+	require.NoError(t, err)
+	require.NoError(t, cacheA.SetUserIdentity(rene))
+	isaac, err := cacheA.NewIdentity("Isaac Newton", "isaac@newton.uk", true, true, "")
+	require.NoError(t, err)
+	// not referenced by the exported tickets
+	_, err = cacheA.NewIdentity("Blaise Pascal", "blaise@pascal.fr", true, true, "")
+	require.NoError(t, err)
+
+	require.NoError(t, cacheA.DoWithLockedConfigCache(func(c *config.ConfigCache) error {
+		if err := c.LabelConfig.AppendLabelToConfiguration(config.Label("repo:test")); err != nil {
+			return err
+		}
+		return c.LabelConfig.Store(cacheA.repo)
+	}))
+
+	opts := NewBugOpts{Title: "title", Message: "message", Workflow: "workflow:eng", Repo: "repo:test"}
+	bug1, _, err := cacheA.NewBug(opts)
+	require.NoError(t, err)
+	media, err := cacheA.StoreData([]byte("a picture"))
+	require.NoError(t, err)
+	_, err = bug1.AddCommentWithFiles("see the picture", []repository.Hash{media})
+	require.NoError(t, err)
+	_, err = bug1.SetAssignee(isaac)
+	require.NoError(t, err)
+	_, err = bug1.SetMetadata(bug1.Snapshot().Operations[0].Id(), map[string]string{"origin": "test"})
+	require.NoError(t, err)
+	require.NoError(t, bug1.Commit())
+	_, err = bug1.AddComment("second pack")
+	require.NoError(t, err)
+	require.NoError(t, bug1.Commit())
+
+	// not exported
+	_, _, err = cacheA.NewBug(opts)
+	require.NoError(t, err)
+
+	var export bytes.Buffer
+	require.NoError(t, cacheA.ExportBugs([]entity.Id{bug1.Id()}, &export))
+	exported := export.String()
+
+	cacheB, err := NewRepoCache(repoB, false)
+	require.NoError(t, err)
+
+	results, err := cacheB.ImportBugs(bytes.NewBufferString(exported))
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+	for _, result := range results {
+		assert.Equal(t, entity.MergeStatusNew, result.Status)
+	}
+	assert.ElementsMatch(t, []entity.Id{rene.Id(), isaac.Id()}, cacheB.AllIdentityIds())
+	require.Equal(t, []entity.Id{bug1.Id()}, cacheB.AllBugsIds())
+
+	imported, err := cacheB.ResolveBug(bug1.Id())
+	require.NoError(t, err)
+	snapA, snapB := bug1.Snapshot(), imported.Snapshot()
+	require.Len(t, snapB.Operations, len(snapA.Operations))
+	for i := range snapA.Operations {
+		assert.Equal(t, snapA.Operations[i].Id(), snapB.Operations[i].Id())
+		assert.Equal(t, snapA.Operations[i].GetAuthor().Id(), snapB.Operations[i].GetAuthor().Id())
+	}
+	assert.Equal(t, bug1.bug.CreateLamportTime(), imported.bug.CreateLamportTime())
+	assert.Equal(t, bug1.bug.EditLamportTime(), imported.bug.EditLamportTime())
+	assert.Equal(t, isaac.Id(), snapB.Assignee.Id())
+	assert.Equal(t, "test", snapB.Operations[0].AllMetadata()["origin"])
+	data, err := cacheB.ReadData(media)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("a picture"), data)
+
+	// Importing again changes nothing
+	results, err = cacheB.ImportBugs(bytes.NewBufferString(exported))
+	require.NoError(t, err)
+	for _, result := range results {
+		assert.Equal(t, entity.MergeStatusNothing, result.Status)
+	}
+
+	// A later export fast-forwards the ticket
+	_, err = bug1.AddComment("third pack")
+	require.NoError(t, err)
+	require.NoError(t, bug1.Commit())
+	export.Reset()
+	require.NoError(t, cacheA.ExportBugs([]entity.Id{bug1.Id()}, &export))
+
+	results, err = cacheB.ImportBugs(&export)
+	require.NoError(t, err)
+	assert.Equal(t, entity.MergeStatusUpdated, results[2].Status)
+	imported, err = cacheB.ResolveBug(bug1.Id())
+	require.NoError(t, err)
+	assert.Len(t, imported.Snapshot().Comments, 4)
+}
+
+func TestMoveBug(t *testing.T) {
+	repoA := repository.CreateTestRepo(false)
+	repoB := repository.CreateTestRepo(false)
+	defer repository.CleanupTestRepos(repoA, repoB)
+
+	repository.SetupSigningKey(t, repoA, "a@e.org")
+
+	cacheA, err := NewRepoCache(repoA, false)
+	require.NoError(t, err)
+
+	rene, err := cacheA.NewIdentity("René Descartes", "rene@descartes.fr", true, true, "")
+	require.NoError(t, err)
+	require.NoError(t, cacheA.SetUserIdentity(rene))
+
+	require.NoError(t, cacheA.DoWithLockedConfigCache(func(c *config.ConfigCache) error {
+		if err := c.LabelConfig.AppendLabelToConfiguration(config.Label("repo:test")); err != nil {
+			return err
+		}
+		return c.LabelConfig.Store(cacheA.repo)
+	}))
+
+	b, _, err := cacheA.NewBug(NewBugOpts{Title: "title", Message: "message", Workflow: "workflow:eng", Repo: "repo:test"})
+	require.NoError(t, err)
+
+	_, err = cacheA.MoveBug(b, "not-a-remote")
+	assert.EqualError(t, err, "not-a-remote is neither a repository nor a remote")
+	_, err = cacheA.MoveBug(b, repoA.GetPath())
+	assert.EqualError(t, err, "the ticket is already in this repository")
+
+	location, err := cacheA.MoveBug(b, repoB.GetPath())
+	require.NoError(t, err)
+	assert.Equal(t, repoB.GetPath(), location)
+
+	snap := b.Snapshot()
+	assert.Equal(t, location, snap.MovedTo)
+	require.IsType(t, &bug.MoveOperation{}, snap.Operations[len(snap.Operations)-1])
+	assert.False(t, b.NeedCommit())
+
+	_, err = cacheA.MoveBug(b, repoB.GetPath())
+	assert.EqualError(t, err, "the ticket was already moved to "+location)
+
+	// The moved ticket is closed to further changes
+	excerpt, err := cacheA.ResolveBugExcerpt(b.Id())
+	require.NoError(t, err)
+	assert.Equal(t, location, excerpt.MovedTo)
+	operations := len(b.Snapshot().Operations)
+	_, err = b.AddComment("too late")
+	require.NoError(t, err)
+	assert.EqualError(t, b.Commit(), fmt.Sprintf("ticket %s was moved to %s and can't be changed anymore", b.Id().Human(), location))
+	assert.False(t, b.NeedCommit())
+	assert.Len(t, b.Snapshot().Operations, operations)
+
+	// The copy doesn't carry the tombstone
+	cacheB, err := NewRepoCache(repoB, false)
+	require.NoError(t, err)
+	moved, err := cacheB.ResolveBug(b.Id())
+	require.NoError(t, err)
+	assert.Equal(t, "title", moved.Snapshot().Title)
+	assert.Empty(t, moved.Snapshot().MovedTo)
+	assert.Equal(t, []entity.Id{rene.Id()}, cacheB.AllIdentityIds())
+}
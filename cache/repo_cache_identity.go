@@ -174,6 +174,19 @@ func (c *RepoCache) ResolveIdentityGiteaID(giteaID int64) (identity.Interface, e
 	return user.Identity, nil
 }
 
+// ResolveIdentityMetadata retrieve an Identity with the given value, compared case-insensitively
+// like the user names of the review providers, for the mutable metadata key.
+// It fails if multiple identities match.
+func (c *RepoCache) ResolveIdentityMetadata(key string, value string) (identity.Interface, error) {
+	user, err := c.ResolveIdentityMatcher(func(excerpt *IdentityExcerpt) bool {
+		return excerpt.MutableMetadata[key] != "" && strings.EqualFold(excerpt.MutableMetadata[key], value)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user.Identity, nil
+}
+
 // ResolveIdentityPrefix retrieve an Identity matching an id prefix.
 // It fails if multiple identities match.
 func (c *RepoCache) ResolveIdentityPrefix(prefix string) (*IdentityCache, error) {
@@ -309,6 +322,20 @@ func (c *RepoCache) NewIdentityWithKeyRaw(name string, email string, login strin
 	return c.finishIdentity(i, metadata)
 }
 
+// NewIdentityWithPhabIdRaw creates a new identity with the provided phabID, e.g. of a user of an
+// imported Maniphest task, without querying Phabricator or Gitea
+func (c *RepoCache) NewIdentityWithPhabIdRaw(name string, email string, login string, avatarUrl string, metadata map[string]string, phabID string) (*IdentityCache, error) {
+	i := identity.NewIdentityFull(name, email, login, avatarUrl, phabID, -1, nil)
+	return c.finishIdentity(i, metadata)
+}
+
+// NewIdentityWithGiteaIdRaw creates a new identity with the provided giteaID, e.g. of a user of an
+// imported Gitea issue, without querying Phabricator or Gitea
+func (c *RepoCache) NewIdentityWithGiteaIdRaw(name string, email string, login string, avatarUrl string, metadata map[string]string, giteaID int64) (*IdentityCache, error) {
+	i := identity.NewIdentityFull(name, email, login, avatarUrl, "", giteaID, nil)
+	return c.finishIdentity(i, metadata)
+}
+
 // UpdateIdentityWithGiteaId updates an existing identity in the repository and cache using the provided giteaID
 func (c *RepoCache) UpdateIdentityWithGiteaId(i *IdentityCache, name string, email string, login string, avatarUrl string, skipPhabId bool, giteaID int64) error {
 
@@ -0,0 +1,30 @@
+package commands
+
+import (
+	"errors"
+
+	"github.com/spf13/cobra"
+
+	"github.com/daedaleanai/git-ticket/bridge"
+)
+
+func newBridgeCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "bridge",
+		Short: "Synchronise the tickets with other bug trackers.",
+	}
+
+	cmd.AddCommand(newBridgeConfigureCommand())
+	cmd.AddCommand(newBridgePullCommand())
+	cmd.AddCommand(newBridgePushCommand())
+
+	return cmd
+}
+
+// resolveBridgeTarget returns the target named by the arguments
+func resolveBridgeTarget(args []string) (*bridge.Target, error) {
+	if len(args) < 1 {
+		return nil, errors.New("no bridge target supplied")
+	}
+	return bridge.TargetFor(args[0])
+}
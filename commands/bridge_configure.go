@@ -0,0 +1,65 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/daedaleanai/git-ticket/bridge"
+)
+
+func newBridgeConfigureCommand() *cobra.Command {
+	env := newEnv()
+	params := make(map[string]*string)
+
+	var help strings.Builder
+	for _, t := range bridge.Targets() {
+		help.WriteString(fmt.Sprintf("\n%s:\n", t.Name))
+		for _, p := range t.Params {
+			help.WriteString(fmt.Sprintf("  --%s: %s\n", p.Name, p.Description))
+		}
+	}
+
+	cmd := &cobra.Command{
+		Use:   "configure <target>",
+		Short: "Configure the bridge with a bug tracker.",
+		Long: `configure stores the parameters of the bridge with a bug tracker in the git config of the
+repository. The parameters of the targets are:
+` + help.String(),
+		Example:  `git ticket bridge configure github --project daedaleanai/git-ticket --repo repo:git-ticket`,
+		PreRunE:  loadBackend(env),
+		PostRunE: closeBackend(env),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			target, err := resolveBridgeTarget(args)
+			if err != nil {
+				return err
+			}
+
+			values := make(map[string]string)
+			for name, value := range params {
+				values[name] = *value
+			}
+			if err := bridge.Configure(env.backend, target, values); err != nil {
+				return err
+			}
+
+			env.out.Printf("Configured the %s bridge\n", target.Name)
+			return nil
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.SortFlags = false
+
+	// The flags are the parameters of all the targets, each only taking the ones it knows
+	for _, t := range bridge.Targets() {
+		for _, p := range t.Params {
+			if _, ok := params[p.Name]; !ok {
+				params[p.Name] = flags.String(p.Name, "", p.Description)
+			}
+		}
+	}
+
+	return cmd
+}
@@ -0,0 +1,49 @@
+package commands
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/daedaleanai/git-ticket/bridge"
+)
+
+func newBridgePullCommand() *cobra.Command {
+	env := newEnv()
+
+	cmd := &cobra.Command{
+		Use:   "pull <target>",
+		Short: "Import the issues of a bug tracker as tickets.",
+		Long: `pull imports the issues of the bug tracker configured with "bridge configure", with their
+comments, labels, assignees and state changes, as tickets. Only the issues updated since the
+previous pull are fetched, and the ones already imported are updated rather than imported again.
+`,
+		Example:  `git ticket bridge pull github`,
+		PreRunE:  loadBackendEnsureUser(env),
+		PostRunE: closeBackend(env),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runBridgePull(env, args)
+		},
+	}
+
+	return cmd
+}
+
+func runBridgePull(env *Env, args []string) error {
+	target, err := resolveBridgeTarget(args)
+	if err != nil {
+		return err
+	}
+
+	conf, err := bridge.LoadConfig(env.backend, target.Name)
+	if err != nil {
+		return err
+	}
+
+	result, err := target.Pull(env.backend, conf)
+	if err != nil {
+		return err
+	}
+
+	env.out.Printf("Pulled from %s: %d new, %d updated, %d unchanged tickets\n",
+		target.Name, result.Created, result.Updated, result.Unchanged)
+	return nil
+}
@@ -0,0 +1,53 @@
+package commands
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/daedaleanai/git-ticket/bridge"
+)
+
+func newBridgePushCommand() *cobra.Command {
+	env := newEnv()
+
+	cmd := &cobra.Command{
+		Use:   "push <target>",
+		Short: "Export the changes of the tickets to a bug tracker.",
+		Long: `push exports the changes made to the tickets imported from the bug tracker configured with
+"bridge configure", and creates issues for the new tickets with the repo label of the bridge.
+The issues are pulled first, so that the changes made to them meanwhile aren't overwritten.
+`,
+		Example:  `git ticket bridge push github`,
+		PreRunE:  loadBackendEnsureUser(env),
+		PostRunE: closeBackend(env),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runBridgePush(env, args)
+		},
+	}
+
+	return cmd
+}
+
+func runBridgePush(env *Env, args []string) error {
+	if err := runBridgePull(env, args); err != nil {
+		return err
+	}
+
+	target, err := resolveBridgeTarget(args)
+	if err != nil {
+		return err
+	}
+
+	conf, err := bridge.LoadConfig(env.backend, target.Name)
+	if err != nil {
+		return err
+	}
+
+	result, err := target.Push(env.backend, conf)
+	if err != nil {
+		return err
+	}
+
+	env.out.Printf("Pushed to %s: %d new, %d updated, %d unchanged tickets\n",
+		target.Name, result.Created, result.Updated, result.Unchanged)
+	return nil
+}
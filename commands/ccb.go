@@ -19,6 +19,7 @@ CCB members, as defined in the "ccb" config, can be added as approvers to the st
 	cmd.AddCommand(newCcbBlockCommand())
 	cmd.AddCommand(newCcbRmCommand())
 	cmd.AddCommand(newCcbListCommand())
+	cmd.AddCommand(newCcbVerifyCommand())
 
 	return cmd
 }
@@ -15,8 +15,13 @@ func newCcbApproveCommand() *cobra.Command {
 	env := newEnv()
 
 	cmd := &cobra.Command{
-		Use:      "approve status [ticket_id]",
-		Short:    "Approve a ticket status.",
+		Use:   "approve status [ticket_id]",
+		Short: "Approve a ticket status.",
+		Long: `approve approves a ticket status as a CCB member.
+
+The approval is signed with your signing key when git-bug.ccb.sign-approvals is set to true in
+the git config, see "git ticket ccb verify".
+`,
 		PreRunE:  loadBackendEnsureUser(env),
 		PostRunE: closeBackend(env),
 		RunE: func(cmd *cobra.Command, args []string) error {
@@ -0,0 +1,77 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	_select "github.com/daedaleanai/git-ticket/commands/select"
+	"github.com/daedaleanai/git-ticket/util/colors"
+	"github.com/daedaleanai/git-ticket/validate"
+)
+
+func newCcbVerifyCommand() *cobra.Command {
+	env := newEnv()
+
+	cmd := &cobra.Command{
+		Use:   "verify [ticket_id]",
+		Short: "Verify the signatures of the CCB approvals of a ticket.",
+		Long: `verify checks the PGP signature carried by each CCB approval of a ticket.
+
+An approval is valid when it has been signed with a key of the approver identity, over the ticket id, the approved status and the ticket history preceding the approval. Approvals made without signature, as git-bug.ccb.sign-approvals isn't set in the git config of the approver, are reported as unsigned. A valid signature made over another ticket history, as a merge may reorder the operations preceding the approval, is reported as stale.
+`,
+		PreRunE:  loadBackend(env),
+		PostRunE: closeBackend(env),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runCcbVerify(env, args)
+		},
+	}
+
+	return cmd
+}
+
+func runCcbVerify(env *Env, args []string) error {
+	b, args, err := _select.ResolveBug(env.backend, args)
+	if err != nil {
+		return err
+	}
+
+	validator, err := validate.NewValidator(env.repo, env.backend)
+	if err != nil {
+		return err
+	}
+
+	results := validator.VerifyCcbApprovals(b.Snapshot())
+	if len(results) == 0 {
+		env.out.Println("No CCB approvals on this ticket")
+		return nil
+	}
+
+	var invalid int
+	for _, r := range results {
+		op := r.Operation
+		prefix := fmt.Sprintf("(%s) %s approved %s:",
+			op.Time().Format("2006-01-02 15:04:05"),
+			op.Ccb.User.DisplayName(),
+			op.Ccb.Status)
+
+		switch r.Status {
+		case validate.CcbApprovalValid:
+			env.out.Printf("%s %s (key %s)\n", prefix, colors.Green(r.Status.String()), r.Fingerprint)
+		case validate.CcbApprovalInvalid:
+			invalid++
+			env.out.Printf("%s %s: %s\n", prefix, colors.Red(r.Status.String()), r.Err)
+		case validate.CcbApprovalStale:
+			env.out.Printf("%s %s: %s (key %s)\n", prefix, colors.Yellow(r.Status.String()), r.Err, r.Fingerprint)
+		default:
+			env.out.Printf("%s %s\n", prefix, colors.Yellow(r.Status.String()))
+		}
+	}
+
+	if invalid > 0 {
+		return errors.Errorf("%d invalid CCB approval(s)", invalid)
+	}
+
+	return nil
+}
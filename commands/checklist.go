@@ -8,8 +8,11 @@ func newChecklistCommand() *cobra.Command {
 		Short: "List available checklists and their contents.",
 	}
 
+	cmd.AddCommand(newChecklistDiffCommand())
 	cmd.AddCommand(newChecklistListCommand())
+	cmd.AddCommand(newChecklistReportCommand())
 	cmd.AddCommand(newChecklistShowCommand())
+	cmd.AddCommand(newChecklistStatsCommand())
 
 	return cmd
 }
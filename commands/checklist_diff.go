@@ -0,0 +1,96 @@
+package commands
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/spf13/cobra"
+
+	"github.com/daedaleanai/git-ticket/config"
+	"github.com/daedaleanai/git-ticket/util/colors"
+)
+
+func newChecklistDiffCommand() *cobra.Command {
+	env := newEnv()
+	cmd := &cobra.Command{
+		Use:   "diff <label> [from_version [to_version]]",
+		Short: "Shows the changes of a checklist between two versions.",
+		Long: `diff shows the questions added to and removed from a checklist between two versions.
+
+By default the current version is compared to the version preceding it. Questions
+are identified by their section and text, a reworded question is shown as removed
+and added.
+`,
+		Args:     cobra.RangeArgs(1, 3),
+		PreRunE:  loadBackend(env),
+		PostRunE: closeBackend(env),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runChecklistDiff(env, args)
+		},
+	}
+
+	return cmd
+}
+
+func runChecklistDiff(env *Env, args []string) error {
+	label := config.Label(args[0])
+
+	history, err := config.LoadChecklistHistory(env.repo)
+	if err != nil {
+		return err
+	}
+
+	versions := history.Versions(label)
+	if len(versions) == 0 {
+		return fmt.Errorf("invalid checklist %s", label)
+	}
+
+	to := versions[len(versions)-1]
+	from := to
+	if len(versions) > 1 {
+		from = versions[len(versions)-2]
+	}
+
+	if len(args) > 1 {
+		if from, err = strconv.Atoi(args[1]); err != nil {
+			return fmt.Errorf("invalid version %s", args[1])
+		}
+	}
+	if len(args) > 2 {
+		if to, err = strconv.Atoi(args[2]); err != nil {
+			return fmt.Errorf("invalid version %s", args[2])
+		}
+	}
+
+	fromChecklist, err := history.GetChecklistVersion(label, from)
+	if err != nil {
+		return err
+	}
+	toChecklist, err := history.GetChecklistVersion(label, to)
+	if err != nil {
+		return err
+	}
+
+	env.out.Printf("%s: version %d -> %d\n", colors.Cyan(string(label)), from, to)
+
+	diff := config.DiffChecklists(fromChecklist, toChecklist)
+	if len(diff) == 0 {
+		env.out.Println("No changes")
+		return nil
+	}
+
+	var section string
+	for i, d := range diff {
+		if i == 0 || d.Section != section {
+			section = d.Section
+			env.out.Printf(colors.Green(fmt.Sprintf("#### %s ####\n", section)))
+		}
+		if d.Added {
+			env.out.Printf("%s\n", colors.Green("+ "+d.Question))
+		} else {
+			env.out.Printf("%s\n", colors.Red("- "+d.Question))
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,377 @@
+package commands
+
+import (
+	"encoding/csv"
+	"fmt"
+	"html/template"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/daedaleanai/git-ticket/bug"
+	"github.com/daedaleanai/git-ticket/config"
+	"github.com/daedaleanai/git-ticket/entity"
+	"github.com/daedaleanai/git-ticket/query"
+)
+
+type checklistReportOptions struct {
+	format string
+}
+
+// checklistReportTicket holds the checklists evidence of a ticket
+type checklistReportTicket struct {
+	Id         string
+	HumanId    string
+	Title      string
+	Status     string
+	Checklists []checklistReportChecklist
+	// Issues lists the required checklists which are missing or not passed
+	Issues []string
+}
+
+type checklistReportChecklist struct {
+	Label    string
+	Title    string
+	State    string
+	Required bool
+	Reviews  []checklistReportReview
+}
+
+type checklistReportReview struct {
+	Reviewer string
+	Time     time.Time
+	Commit   string
+	Version  int
+	State    string
+	Answers  []checklistReportAnswer
+}
+
+type checklistReportAnswer struct {
+	Number   string
+	Section  string
+	Question string
+	State    string
+	Value    string
+	Comment  string
+}
+
+func newChecklistReportCommand() *cobra.Command {
+	env := newEnv()
+	options := checklistReportOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "report <query>",
+		Short: "Reports the checklists of the tickets matching a query.",
+		Long: `report lists for every ticket matching the query each of its checklists, the answers
+and comments of every reviewer, when they were given and the commit which recorded them.
+
+Tickets whose required checklists, as configured in the label mapping, are missing or
+not passed are flagged.
+`,
+		Example:  `git ticket checklist report 'status(accepted)' --format html > report.html`,
+		Args:     cobra.MinimumNArgs(1),
+		PreRunE:  loadBackend(env),
+		PostRunE: closeBackend(env),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runChecklistReport(env, options, args)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.SortFlags = false
+
+	flags.StringVarP(&options.format, "format", "f", "markdown",
+		"Select the output format. Valid values are [markdown,html,csv]")
+
+	return cmd
+}
+
+func runChecklistReport(env *Env, opts checklistReportOptions, args []string) error {
+	parser, err := query.NewParser(strings.Join(args, " "))
+	if err != nil {
+		return err
+	}
+	q, err := parser.Parse()
+	if err != nil {
+		return err
+	}
+
+	var labelMapping config.LabelMapping
+	var templates config.ChecklistConfig
+	err = env.backend.DoWithLockedConfigCache(func(c *config.ConfigCache) error {
+		labelMapping = c.LabelMapping()
+		templates = c.ChecklistConfig
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	var tickets []checklistReportTicket
+	for _, id := range env.backend.QueryBugs(q) {
+		ticket, err := buildChecklistReportTicket(env, labelMapping, templates, id)
+		if err != nil {
+			return err
+		}
+		tickets = append(tickets, ticket)
+	}
+
+	switch opts.format {
+	case "markdown":
+		return checklistReportMarkdownFormatter(env, tickets)
+	case "html":
+		return checklistReportHtmlFormatter(env, tickets)
+	case "csv":
+		return checklistReportCsvFormatter(env, tickets)
+	default:
+		return fmt.Errorf("unknown format %s", opts.format)
+	}
+}
+
+func buildChecklistReportTicket(env *Env, labelMapping config.LabelMapping, templates config.ChecklistConfig, id entity.Id) (checklistReportTicket, error) {
+	b, err := env.backend.ResolveBug(id)
+	if err != nil {
+		return checklistReportTicket{}, err
+	}
+	snap := b.Snapshot()
+
+	ticket := checklistReportTicket{
+		Id:      snap.Id().String(),
+		HumanId: snap.Id().Human(),
+		Title:   snap.Title,
+		Status:  snap.Status.String(),
+	}
+
+	required := make(map[bug.Label]bool)
+	for _, l := range snap.Labels {
+		for _, cl := range labelMapping[config.Label(l)].RequiredChecklists {
+			required[bug.Label(cl)] = true
+		}
+	}
+
+	states := snap.GetChecklistCompoundStates()
+
+	for _, l := range snap.Labels {
+		if !l.IsChecklist() {
+			continue
+		}
+
+		checklist := checklistReportChecklist{
+			Label:    l.String(),
+			State:    states[l].String(),
+			Required: required[l],
+		}
+		// the title of a checklist whose template was removed is the one of its last review
+		template, err := templates.GetChecklist(config.Label(l))
+		hasTemplate := err == nil
+		checklist.Title = template.Title
+		var lastEdit time.Time
+
+		for reviewerId, cl := range snap.Checklists[l] {
+			reviewer, err := env.backend.ResolveIdentityExcerpt(reviewerId)
+			if err != nil {
+				return ticket, err
+			}
+
+			commit, ok := b.OperationCommit(cl.OperationId)
+			if !ok {
+				return ticket, fmt.Errorf("no commit recorded the %s checklist of %s in ticket %s",
+					l, reviewer.DisplayName(), b.Id().Human())
+			}
+			evaluated := snap.EvaluateChecklist(cl.Checklist)
+			if !hasTemplate && !cl.LastEdit.Before(lastEdit) {
+				checklist.Title = evaluated.Title
+				lastEdit = cl.LastEdit
+			}
+
+			review := checklistReportReview{
+				Reviewer: reviewer.DisplayName(),
+				Time:     cl.LastEdit,
+				Commit:   string(commit),
+				Version:  evaluated.Version,
+				State:    evaluated.CompoundState().String(),
+			}
+
+			for sn, s := range evaluated.Sections {
+				for qn, q := range s.Questions {
+					if s.Hidden || q.Hidden {
+						continue
+					}
+					review.Answers = append(review.Answers, checklistReportAnswer{
+						Number:   fmt.Sprintf("%d.%d", sn+1, qn+1),
+						Section:  s.Title,
+						Question: q.Question,
+						State:    q.State.String(),
+						Value:    q.Value,
+						Comment:  q.Comment,
+					})
+				}
+			}
+
+			checklist.Reviews = append(checklist.Reviews, review)
+		}
+
+		// Sort them so that they appear in consistent order
+		sort.Slice(checklist.Reviews, func(i, j int) bool {
+			return checklist.Reviews[i].Time.Before(checklist.Reviews[j].Time)
+		})
+
+		ticket.Checklists = append(ticket.Checklists, checklist)
+	}
+
+	requiredLabels := make([]string, 0, len(required))
+	for l := range required {
+		requiredLabels = append(requiredLabels, l.String())
+	}
+	sort.Strings(requiredLabels)
+
+	for _, l := range requiredLabels {
+		state, present := states[bug.Label(l)]
+		switch {
+		case !present:
+			ticket.Issues = append(ticket.Issues, fmt.Sprintf("required checklist %s is missing", l))
+		case len(snap.Checklists[bug.Label(l)]) == 0:
+			ticket.Issues = append(ticket.Issues, fmt.Sprintf("required checklist %s has not been reviewed", l))
+		case state != config.Passed:
+			ticket.Issues = append(ticket.Issues, fmt.Sprintf("required checklist %s is %s", l, state))
+		}
+	}
+
+	return ticket, nil
+}
+
+func checklistReportMarkdownFormatter(env *Env, tickets []checklistReportTicket) error {
+	cell := func(s string) string {
+		return strings.ReplaceAll(strings.ReplaceAll(s, "|", "\\|"), "\n", "<br>")
+	}
+
+	env.out.Printf("# Checklists report\n\n")
+
+	for _, t := range tickets {
+		env.out.Printf("## %s %s (%s)\n\n", t.HumanId, t.Title, t.Status)
+
+		for _, issue := range t.Issues {
+			env.out.Printf("- **%s**\n", issue)
+		}
+		if len(t.Issues) > 0 {
+			env.out.Println()
+		}
+
+		for _, cl := range t.Checklists {
+			required := ""
+			if cl.Required {
+				required = ", required"
+			}
+			env.out.Printf("### %s: %s (%s%s)\n\n", cl.Label, cl.Title, cl.State, required)
+
+			for _, r := range cl.Reviews {
+				env.out.Printf("#### %s, %s, commit %s, version %d: %s\n\n",
+					r.Reviewer, r.Time.Format(time.RFC3339), r.Commit, r.Version, r.State)
+				env.out.Printf("| # | Section | Question | State | Value | Comment |\n")
+				env.out.Printf("|---|---|---|---|---|---|\n")
+				for _, a := range r.Answers {
+					env.out.Printf("| %s | %s | %s | %s | %s | %s |\n",
+						a.Number, cell(a.Section), cell(a.Question), a.State, cell(a.Value), cell(a.Comment))
+				}
+				env.out.Println()
+			}
+		}
+	}
+
+	return nil
+}
+
+var checklistReportHtmlTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"formatTime": func(t time.Time) string {
+		return t.Format(time.RFC3339)
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Checklists report</title>
+<style>
+body { font-family: sans-serif; }
+table { border-collapse: collapse; margin-bottom: 1em; }
+th, td { border: 1px solid #999; padding: 0.2em 0.5em; text-align: left; vertical-align: top; }
+.issue { color: #b00; font-weight: bold; }
+.comment { white-space: pre-wrap; }
+</style>
+</head>
+<body>
+<h1>Checklists report</h1>
+{{- range . }}
+<h2>{{ .HumanId }} {{ .Title }} ({{ .Status }})</h2>
+{{- if .Issues }}
+<ul>
+{{- range .Issues }}
+<li class="issue">{{ . }}</li>
+{{- end }}
+</ul>
+{{- end }}
+{{- range .Checklists }}
+<h3>{{ .Label }}: {{ .Title }} ({{ .State }}{{ if .Required }}, required{{ end }})</h3>
+{{- range .Reviews }}
+<h4>{{ .Reviewer }}, {{ formatTime .Time }}, commit {{ .Commit }}, version {{ .Version }}: {{ .State }}</h4>
+<table>
+<tr><th>#</th><th>Section</th><th>Question</th><th>State</th><th>Value</th><th>Comment</th></tr>
+{{- range .Answers }}
+<tr><td>{{ .Number }}</td><td>{{ .Section }}</td><td>{{ .Question }}</td><td>{{ .State }}</td><td>{{ .Value }}</td><td class="comment">{{ .Comment }}</td></tr>
+{{- end }}
+</table>
+{{- end }}
+{{- end }}
+{{- end }}
+</body>
+</html>
+`))
+
+func checklistReportHtmlFormatter(env *Env, tickets []checklistReportTicket) error {
+	return checklistReportHtmlTemplate.Execute(env.out, tickets)
+}
+
+func checklistReportCsvFormatter(env *Env, tickets []checklistReportTicket) error {
+	w := csv.NewWriter(env.out)
+
+	err := w.Write([]string{"ticket", "title", "status", "issues", "checklist", "checklist state", "required",
+		"reviewer", "time", "commit", "version", "number", "section", "question", "state", "value", "comment"})
+	if err != nil {
+		return err
+	}
+
+	for _, t := range tickets {
+		ticketColumns := []string{t.Id, t.Title, t.Status, strings.Join(t.Issues, "; ")}
+
+		if len(t.Checklists) == 0 {
+			if err := w.Write(append(ticketColumns, make([]string, 13)...)); err != nil {
+				return err
+			}
+		}
+
+		for _, cl := range t.Checklists {
+			checklistColumns := append(ticketColumns[:4:4], cl.Label, cl.State, fmt.Sprint(cl.Required))
+
+			if len(cl.Reviews) == 0 {
+				if err := w.Write(append(checklistColumns, make([]string, 10)...)); err != nil {
+					return err
+				}
+			}
+
+			for _, r := range cl.Reviews {
+				reviewColumns := append(checklistColumns[:7:7], r.Reviewer, r.Time.Format(time.RFC3339), r.Commit, fmt.Sprint(r.Version))
+
+				for _, a := range r.Answers {
+					row := append(reviewColumns[:11:11], a.Number, a.Section, a.Question, a.State, a.Value, a.Comment)
+					if err := w.Write(row); err != nil {
+						return err
+					}
+				}
+			}
+		}
+	}
+
+	w.Flush()
+	return w.Error()
+}
@@ -0,0 +1,59 @@
+package commands
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type nopWriteCloser struct {
+	*bytes.Buffer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+func TestChecklistReportCsvFormatter(t *testing.T) {
+	buf := &bytes.Buffer{}
+	env := &Env{out: out{WriteCloser: nopWriteCloser{buf}}}
+
+	tickets := []checklistReportTicket{
+		{
+			Id:     "1234567",
+			Title:  "Reviewed ticket",
+			Status: "accepted",
+			Checklists: []checklistReportChecklist{
+				{
+					Label:    "checklist:sw",
+					State:    "PASSED",
+					Required: true,
+					Reviews: []checklistReportReview{
+						{
+							Reviewer: "René Descartes",
+							Time:     time.Date(2021, 3, 4, 12, 0, 0, 0, time.UTC),
+							Commit:   "abcdef",
+							Version:  2,
+							State:    "PASSED",
+							Answers: []checklistReportAnswer{
+								{Number: "1.1", Section: "Design", Question: "Documented?", State: "PASSED", Comment: "see, the wiki"},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			Id:     "89abcde",
+			Title:  "Unreviewed ticket",
+			Status: "accepted",
+			Issues: []string{"required checklist checklist:sw is missing"},
+		},
+	}
+
+	assert.NoError(t, checklistReportCsvFormatter(env, tickets))
+	assert.Equal(t, `ticket,title,status,issues,checklist,checklist state,required,reviewer,time,commit,version,number,section,question,state,value,comment
+1234567,Reviewed ticket,accepted,,checklist:sw,PASSED,true,René Descartes,2021-03-04T12:00:00Z,abcdef,2,1.1,Design,Documented?,PASSED,,"see, the wiki"
+89abcde,Unreviewed ticket,accepted,required checklist checklist:sw is missing,,,,,,,,,,,,,
+`, buf.String())
+}
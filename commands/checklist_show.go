@@ -36,7 +36,7 @@ func runChecklistShow(env *Env, args []string) error {
 		return err
 	}
 
-	env.out.Printf("%s\n", colors.Cyan(checklist.Title))
+	env.out.Printf("%s (version %d)\n", colors.Cyan(checklist.Title), checklist.Version)
 
 	for i, section := range checklist.Sections {
 		env.out.Printf(colors.Green(fmt.Sprintf("#### %d. %s ####\n", i, section.Title)))
@@ -0,0 +1,151 @@
+package commands
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/daedaleanai/git-ticket/bug"
+	"github.com/daedaleanai/git-ticket/query"
+	"github.com/daedaleanai/git-ticket/util/colors"
+)
+
+type checklistStatsOptions struct {
+	format   string
+	comments int
+}
+
+func newChecklistStatsCommand() *cobra.Command {
+	env := newEnv()
+	options := checklistStatsOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "stats <label> [query]",
+		Short: "Shows statistics about the answers to a checklist.",
+		Long: `stats aggregates the answers to a checklist across all the tickets carrying it, or
+only those matching the query: the number of questions passed, failed, not applicable or
+still TBD, their trend per month and the most common comments of the reviewers.
+`,
+		Example:  `git ticket checklist stats checklist:sw-review 'status(merged)' --format csv`,
+		Args:     cobra.MinimumNArgs(1),
+		PreRunE:  loadBackend(env),
+		PostRunE: closeBackend(env),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runChecklistStats(env, options, args)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.SortFlags = false
+
+	flags.StringVarP(&options.format, "format", "f", "table",
+		"Select the output format. Valid values are [table,csv,json]")
+	flags.IntVarP(&options.comments, "comments", "c", 3,
+		"Number of most common comments to show per question")
+
+	return cmd
+}
+
+func runChecklistStats(env *Env, opts checklistStatsOptions, args []string) error {
+	var q *query.CompiledQuery
+
+	if len(args) > 1 {
+		parser, err := query.NewParser(strings.Join(args[1:], " "))
+		if err != nil {
+			return err
+		}
+		q, err = parser.Parse()
+		if err != nil {
+			return err
+		}
+	}
+
+	stats, err := env.backend.ChecklistStats(bug.Label(args[0]), q, opts.comments)
+	if err != nil {
+		return err
+	}
+
+	switch opts.format {
+	case "table":
+		return checklistStatsTableFormatter(env, stats)
+	case "csv":
+		return checklistStatsCsvFormatter(env, stats)
+	case "json":
+		jsonObject, _ := json.MarshalIndent(stats, "", "    ")
+		env.out.Printf("%s\n", jsonObject)
+		return nil
+	default:
+		return fmt.Errorf("unknown format %s", opts.format)
+	}
+}
+
+func checklistStatsTableFormatter(env *Env, stats bug.ChecklistStats) error {
+	env.out.Printf("%s: %d tickets, %d reviews\n", colors.Cyan(stats.Label.String()), stats.Tickets, stats.Reviews)
+
+	var section string
+	for i, q := range stats.Questions {
+		if i == 0 || q.Section != section {
+			section = q.Section
+			env.out.Printf(colors.Green(fmt.Sprintf("#### %s ####\n", section)))
+		}
+
+		env.out.Printf("%s\n", q.Question)
+		env.out.Printf("  %-8s %6s %6s %6s %6s\n", "", "PASSED", "FAILED", "NA", "TBD")
+		env.out.Printf("  %-8s %6d %6s %6d %6d\n", "total", q.Passed, checklistStatsFailed(q.Failed), q.NotApplicable, q.TBD)
+		for _, m := range q.Months {
+			env.out.Printf("  %-8s %6d %6s %6d %6d\n", m.Month, m.Passed, checklistStatsFailed(m.Failed), m.NotApplicable, m.TBD)
+		}
+		for _, c := range q.Comments {
+			env.out.Printf("  # (%d) %s\n", c.Count, strings.Replace(c.Comment, "\n", "\n  # ", -1))
+		}
+	}
+
+	return nil
+}
+
+// checklistStatsFailed highlights the failure counts
+func checklistStatsFailed(count int) string {
+	if count == 0 {
+		return "0"
+	}
+	return colors.Red(fmt.Sprintf("%6d", count))
+}
+
+func checklistStatsCsvFormatter(env *Env, stats bug.ChecklistStats) error {
+	w := csv.NewWriter(env.out)
+
+	err := w.Write([]string{"section", "question", "month", "passed", "failed", "na", "tbd", "comments"})
+	if err != nil {
+		return err
+	}
+
+	counts := func(c bug.ChecklistStateCounts) []string {
+		return []string{strconv.Itoa(c.Passed), strconv.Itoa(c.Failed), strconv.Itoa(c.NotApplicable), strconv.Itoa(c.TBD)}
+	}
+
+	for _, q := range stats.Questions {
+		var comments []string
+		for _, c := range q.Comments {
+			comments = append(comments, fmt.Sprintf("%s (%d)", c.Comment, c.Count))
+		}
+
+		row := append([]string{q.Section, q.Question, "total"}, counts(q.ChecklistStateCounts)...)
+		if err := w.Write(append(row, strings.Join(comments, "; "))); err != nil {
+			return err
+		}
+
+		for _, m := range q.Months {
+			row := append([]string{q.Section, q.Question, m.Month}, counts(m.ChecklistStateCounts)...)
+			if err := w.Write(append(row, "")); err != nil {
+				return err
+			}
+		}
+	}
+
+	w.Flush()
+	return w.Error()
+}
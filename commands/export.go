@@ -0,0 +1,83 @@
+package commands
+
+import (
+	"errors"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/daedaleanai/git-ticket/query"
+)
+
+type exportOptions struct {
+	output string
+}
+
+func newExportCommand() *cobra.Command {
+	env := newEnv()
+	options := exportOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "export [<query>]",
+		Short: "Export tickets with their full history.",
+		Long: `export writes the tickets matching the query, or all the tickets, to a JSON lines file with
+their full history: every operation with its author, lamport times and metadata, the files of
+the comments and the git commits storing them. The identities referenced by the tickets are
+exported as well.
+
+"git ticket import" recreates the tickets of the file in another repository with the same ids
+and operations.
+`,
+		Example:  `git ticket export 'label(repo:rocket)' -o tickets.jsonl`,
+		PreRunE:  loadBackend(env),
+		PostRunE: closeBackend(env),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runExport(env, options, args)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.SortFlags = false
+
+	flags.StringVarP(&options.output, "output", "o", "",
+		"The file the tickets are written to")
+
+	return cmd
+}
+
+func runExport(env *Env, opts exportOptions, args []string) error {
+	if opts.output == "" {
+		return errors.New("no output file supplied")
+	}
+
+	var q *query.CompiledQuery
+	if len(args) > 0 {
+		parser, err := query.NewParser(strings.Join(args, " "))
+		if err != nil {
+			return err
+		}
+		q, err = parser.Parse()
+		if err != nil {
+			return err
+		}
+	}
+
+	ids := env.backend.QueryBugs(q)
+
+	f, err := os.Create(opts.output)
+	if err != nil {
+		return err
+	}
+
+	err = env.backend.ExportBugs(ids, f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	env.out.Printf("Exported %d tickets to %s\n", len(ids), opts.output)
+	return nil
+}
@@ -0,0 +1,57 @@
+package commands
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/daedaleanai/git-ticket/entity"
+)
+
+func newImportCommand() *cobra.Command {
+	env := newEnv()
+
+	cmd := &cobra.Command{
+		Use:   "import <export-file>",
+		Short: "Import tickets from an export or from other bug trackers.",
+		Long: `import recreates the tickets of a file written by "git ticket export", with the same ids and
+full history, along with the identities they reference. Tickets and identities already in the
+repository are updated if the file has a later version of them.
+
+The subcommands import the tickets of other bug trackers.
+`,
+		Example:  `git ticket import tickets.jsonl`,
+		Args:     cobra.ExactArgs(1),
+		PreRunE:  loadBackend(env),
+		PostRunE: closeBackend(env),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runImport(env, args)
+		},
+	}
+
+	cmd.AddCommand(newImportJiraCommand())
+	cmd.AddCommand(newImportManiphestCommand())
+
+	return cmd
+}
+
+func runImport(env *Env, args []string) error {
+	f, err := os.Open(args[0])
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	results, err := env.backend.ImportBugs(f)
+	for _, result := range results {
+		if result.Status != entity.MergeStatusNothing {
+			env.out.Printf("%s: %s\n", result.Id.Human(), result)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	env.out.Printf("Imported %d tickets and identities\n", len(results))
+	return nil
+}
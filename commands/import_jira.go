@@ -0,0 +1,76 @@
+package commands
+
+import (
+	"errors"
+
+	"github.com/spf13/cobra"
+
+	"github.com/daedaleanai/git-ticket/importer"
+)
+
+type importJiraOptions struct {
+	mappingFile string
+}
+
+func newImportJiraCommand() *cobra.Command {
+	env := newEnv()
+	options := importJiraOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "jira <export-file>",
+		Short: "Import the issues of a Jira JSON or CSV export.",
+		Long: `jira imports the issues of a Jira export, with their original authors and timestamps, as
+tickets. JSON exports are search results of the REST API with the comments and the change log
+expanded, CSV exports have the current status of the issues only.
+
+The mapping file is a JSON object with the workflow and repo labels of the tickets, the statuses
+of the workflow the Jira statuses map to and optionally the emails of the identities the Jira
+users map to, by account id, username or display name:
+
+  {
+    "workflow": "workflow:qa",
+    "repo": "repo:rocket",
+    "statuses": {"To Do": "proposed", "In Progress": "inprogress", "Done": "done"},
+    "users": {"jdoe": "john.doe@example.com"}
+  }
+
+The other users are mapped to the identities with their email, or to new identities. Running
+the import again on a later export only imports the changes the tickets don't have.
+`,
+		Example:  `git ticket import jira ROCK.json --mapping jira-mapping.json`,
+		Args:     cobra.ExactArgs(1),
+		PreRunE:  loadBackendEnsureUser(env),
+		PostRunE: closeBackend(env),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runImportJira(env, options, args)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.SortFlags = false
+
+	flags.StringVarP(&options.mappingFile, "mapping", "m", "",
+		"The JSON file mapping the Jira statuses and users")
+
+	return cmd
+}
+
+func runImportJira(env *Env, opts importJiraOptions, args []string) error {
+	if opts.mappingFile == "" {
+		return errors.New("no mapping file supplied")
+	}
+
+	mapping, err := importer.LoadJiraMapping(opts.mappingFile)
+	if err != nil {
+		return err
+	}
+
+	result, err := importer.ImportJira(env.backend, args[0], mapping)
+	if err != nil {
+		return err
+	}
+
+	env.out.Printf("Imported from Jira: %d new, %d updated, %d unchanged tickets\n",
+		result.Created, result.Updated, result.Unchanged)
+	return nil
+}
@@ -0,0 +1,66 @@
+package commands
+
+import (
+	"errors"
+
+	"github.com/spf13/cobra"
+
+	"github.com/daedaleanai/git-ticket/importer"
+	"github.com/daedaleanai/git-ticket/repository"
+)
+
+func newImportManiphestCommand() *cobra.Command {
+	env := newEnv()
+	options := importer.ManiphestOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "maniphest <query-key | T-id...>",
+		Short: "Import Maniphest tasks from Phabricator.",
+		Long: `maniphest imports the tasks of a saved Maniphest query, e.g. "open" or "all", or the
+tasks with the given ids as tickets, with their comments, status changes, priority, subscribers
+and projects. The authors are mapped to the identities with their Phabricator id, or to new
+identities. The open statuses, including the custom ones, are imported as proposed, resolved as
+the final status of the workflow and the other closed statuses as rejected.
+
+The T-number of a task is recorded in its ticket: importing the task again only imports the
+changes the ticket doesn't have.
+`,
+		Example:  `git ticket import maniphest T12 T13 --workflow workflow:eng --repo repo:rocket`,
+		Args:     cobra.MinimumNArgs(1),
+		PreRunE:  loadBackendEnsureUser(env),
+		PostRunE: closeBackend(env),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runImportManiphest(env, options, args)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.SortFlags = false
+
+	flags.StringVarP(&options.Workflow, "workflow", "w", "",
+		"The workflow label of the tickets")
+	flags.StringVarP(&options.Repo, "repo", "r", "",
+		"The repo label of the tickets")
+
+	return cmd
+}
+
+func runImportManiphest(env *Env, opts importer.ManiphestOptions, args []string) error {
+	if opts.Workflow == "" || opts.Repo == "" {
+		return errors.New("the workflow and repo labels of the tickets must be supplied")
+	}
+
+	client, err := repository.GetPhabClient()
+	if err != nil {
+		return err
+	}
+
+	result, err := importer.ImportManiphest(env.backend, client, args, opts)
+	if err != nil {
+		return err
+	}
+
+	env.out.Printf("Imported from Maniphest: %d new, %d updated, %d unchanged tickets\n",
+		result.Created, result.Updated, result.Unchanged)
+	return nil
+}
@@ -21,6 +21,7 @@ import (
 
 type lsOptions struct {
 	outputFormat string
+	moved        bool
 }
 
 func newLsCommand() *cobra.Command {
@@ -31,6 +32,7 @@ func newLsCommand() *cobra.Command {
 		Use:   "ls [query]",
 		Short: "List tickets.",
 		Long: `Display a summary of each ticket. By default shows only "active" tickets, i.e. In Progress, In Review, Reviewed and Accepted.
+The tickets moved to another repository are only listed with --moved.
 
 You can pass an additional query to filter and order the list. This query can be expressed with a simple query language.
 See https://github.com/daedaleanai/git-ticket/blob/master/doc/queries.md`,
@@ -49,6 +51,8 @@ git ticket ls all(status(vetted), label(r"repo:.*")) sort(edit-desc)
 
 	flags.StringVarP(&options.outputFormat, "format", "f", "default",
 		"Select the output formatting style. Valid values are [default,plain,json,org-mode]")
+	flags.BoolVar(&options.moved, "moved", false,
+		"List the tickets moved to another repository as well")
 
 	return cmd
 }
@@ -74,13 +78,16 @@ func runLs(env *Env, opts lsOptions, args []string) error {
 
 	allIds := env.backend.QueryBugs(q)
 
-	bugExcerpt := make([]*cache.BugExcerpt, len(allIds))
-	for i, id := range allIds {
+	bugExcerpt := make([]*cache.BugExcerpt, 0, len(allIds))
+	for _, id := range allIds {
 		b, err := env.backend.ResolveBugExcerpt(id)
 		if err != nil {
 			return err
 		}
-		bugExcerpt[i] = b
+		if b.MovedTo != "" && !opts.moved {
+			continue
+		}
+		bugExcerpt = append(bugExcerpt, b)
 	}
 
 	switch opts.outputFormat {
@@ -114,6 +121,7 @@ type JSONBugExcerpt struct {
 
 	Comments int               `json:"comments"`
 	Metadata map[string]string `json:"metadata"`
+	MovedTo  string            `json:"moved_to,omitempty"`
 }
 
 func lsJsonFormatter(env *Env, bugExcerpts []*cache.BugExcerpt) error {
@@ -129,6 +137,7 @@ func lsJsonFormatter(env *Env, bugExcerpts []*cache.BugExcerpt) error {
 			Title:      b.Title,
 			Comments:   b.LenComments,
 			Metadata:   b.CreateMetadata,
+			MovedTo:    b.MovedTo,
 		}
 
 		if b.AuthorId != "" {
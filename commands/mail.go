@@ -0,0 +1,15 @@
+package commands
+
+import "github.com/spf13/cobra"
+
+func newMailCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "mail",
+		Short: "Create tickets and comments from mail.",
+	}
+
+	cmd.AddCommand(newMailIngestCommand())
+	cmd.AddCommand(newMailQuarantineCommand())
+
+	return cmd
+}
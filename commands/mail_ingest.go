@@ -0,0 +1,86 @@
+package commands
+
+import (
+	"errors"
+
+	"github.com/spf13/cobra"
+
+	"github.com/daedaleanai/git-ticket/importer"
+)
+
+type mailIngestOptions struct {
+	importer.MailOptions
+	quarantine bool
+}
+
+func newMailIngestCommand() *cobra.Command {
+	env := newEnv()
+	options := mailIngestOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "ingest <mbox | maildir>",
+		Short: "Create tickets and comments from the messages of a mailbox.",
+		Long: `ingest reads the messages of a mbox file or a Maildir. A message starting a thread creates a
+ticket, with the subject as title, and a reply adds a comment to the ticket of its thread. The
+attachments are stored as files of the ticket or the comment.
+
+The senders are mapped to the identities with their email. The messages of unknown senders are
+put in the quarantine, see "git ticket mail quarantine": once the senders have an identity, the
+quarantine is ingested with --quarantine.
+
+The messages already ingested are skipped, so a mailbox can be ingested again as it grows. The
+messages which can't be parsed, e.g. with an invalid sender or date, are skipped and reported.
+`,
+		Example: `git ticket mail ingest ~/Mail/support --workflow workflow:qa --repo repo:rocket
+git ticket mail ingest --quarantine --workflow workflow:qa --repo repo:rocket`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if options.quarantine {
+				return cobra.NoArgs(cmd, args)
+			}
+			return cobra.ExactArgs(1)(cmd, args)
+		},
+		PreRunE:  loadBackendEnsureUser(env),
+		PostRunE: closeBackend(env),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runMailIngest(env, options, args)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.SortFlags = false
+
+	flags.StringVarP(&options.Workflow, "workflow", "w", "",
+		"The workflow label of the tickets created")
+	flags.StringVarP(&options.Repo, "repo", "r", "",
+		"The repo label of the tickets created")
+	flags.BoolVarP(&options.quarantine, "quarantine", "q", false,
+		"Ingest the messages of the quarantine")
+
+	return cmd
+}
+
+func runMailIngest(env *Env, opts mailIngestOptions, args []string) error {
+	if opts.Workflow == "" || opts.Repo == "" {
+		return errors.New("the workflow and repo labels of the tickets must be supplied")
+	}
+
+	path := importer.MailQuarantinePath(env.backend)
+	if !opts.quarantine {
+		path = args[0]
+	}
+
+	result, err := importer.IngestMail(env.backend, path, opts.MailOptions)
+	if err != nil {
+		return err
+	}
+
+	env.out.Printf("Ingested: %d new tickets, %d comments, %d messages already ingested\n",
+		result.Tickets, result.Comments, result.Unchanged)
+	if result.Quarantined > 0 {
+		env.out.Printf("%d messages of unknown senders are in the quarantine\n", result.Quarantined)
+	}
+	for _, invalid := range result.Invalid {
+		env.err.Printf("Skipped invalid mail %s\n", invalid)
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package commands
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/daedaleanai/git-ticket/importer"
+)
+
+func newMailQuarantineCommand() *cobra.Command {
+	env := newEnv()
+
+	cmd := &cobra.Command{
+		Use:   "quarantine",
+		Short: "List the messages of unknown senders.",
+		Long: `quarantine lists the messages "git ticket mail ingest" didn't ingest because their sender has
+no identity. The quarantine is a Maildir: the messages can be read with a mail client, or
+deleted. Once the senders have an identity, "git ticket mail ingest --quarantine" ingests them.
+`,
+		Args:     cobra.NoArgs,
+		PreRunE:  loadBackend(env),
+		PostRunE: closeBackend(env),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runMailQuarantine(env)
+		},
+	}
+
+	return cmd
+}
+
+func runMailQuarantine(env *Env) error {
+	messages, err := importer.ListQuarantinedMail(env.backend)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range messages {
+		env.out.Printf("%s %s %s\n", m.Date.Format("2006-01-02 15:04:05"), m.From, m.Subject)
+	}
+	if len(messages) > 0 {
+		env.out.Printf("\n%d messages in %s\n", len(messages), importer.MailQuarantinePath(env.backend))
+	}
+	return nil
+}
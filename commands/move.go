@@ -0,0 +1,63 @@
+package commands
+
+import (
+	"errors"
+
+	"github.com/spf13/cobra"
+
+	_select "github.com/daedaleanai/git-ticket/commands/select"
+)
+
+type moveOptions struct {
+	to string
+}
+
+func newMoveCommand() *cobra.Command {
+	env := newEnv()
+	options := moveOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "move [<id>]",
+		Short: "Move a ticket to another repository.",
+		Long: `move copies a ticket with its full history, its files and the identities it references to
+another repository, given either as the path of a local repository or as the name of a remote.
+The ticket keeps its id in the other repository.
+
+The ticket stays in this repository, closed to further use: "git ticket show" points to the
+repository it was moved to.
+`,
+		Example:  `git ticket move 4a6e2b1 --to ../rocket`,
+		PreRunE:  loadBackendEnsureUser(env),
+		PostRunE: closeBackend(env),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runMove(env, options, args)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.SortFlags = false
+
+	flags.StringVar(&options.to, "to", "",
+		"The path of the repository or the remote the ticket is moved to")
+
+	return cmd
+}
+
+func runMove(env *Env, opts moveOptions, args []string) error {
+	if opts.to == "" {
+		return errors.New("no destination supplied")
+	}
+
+	b, _, err := _select.ResolveBug(env.backend, args)
+	if err != nil {
+		return err
+	}
+
+	location, err := env.backend.MoveBug(b, opts.to)
+	if err != nil {
+		return err
+	}
+
+	env.out.Printf("Moved ticket %s to %s\n", b.Id().Human(), location)
+	return nil
+}
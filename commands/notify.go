@@ -0,0 +1,27 @@
+package commands
+
+import "github.com/spf13/cobra"
+
+func newNotifyCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "notify",
+		Short: "Send email digests of the ticket changes.",
+		Long: `notify sends to the assignees, the CCB members and the participants of the tickets a digest of
+the changes other people made since the last notification, through the SMTP server configured
+in the git config:
+
+  git-bug.notify.smtp-server    host:port of the SMTP server
+  git-bug.notify.from           sender address of the emails
+  git-bug.notify.smtp-username  optional, with git-bug.notify.smtp-password
+  git-bug.notify.template       optional file defining the "subject" and "body" Go templates
+
+Once configured, "git ticket pull" sends the digests as well.
+`,
+	}
+
+	cmd.AddCommand(newNotifyOptInCommand())
+	cmd.AddCommand(newNotifyOptOutCommand())
+	cmd.AddCommand(newNotifySendCommand())
+
+	return cmd
+}
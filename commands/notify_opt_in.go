@@ -0,0 +1,40 @@
+package commands
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/daedaleanai/git-ticket/notify"
+)
+
+func newNotifyOptInCommand() *cobra.Command {
+	env := newEnv()
+
+	cmd := &cobra.Command{
+		Use:   "opt-in [{user_name | user_id}]",
+		Short: "Receive the notification digests again.",
+		Long: `opt-in records in the identity, by default yours, that it wants notification digests again.
+`,
+		Args:     cobra.MaximumNArgs(1),
+		PreRunE:  loadBackend(env),
+		PostRunE: closeBackend(env),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runNotifyOptIn(env, args)
+		},
+	}
+
+	return cmd
+}
+
+func runNotifyOptIn(env *Env, args []string) error {
+	id, _, err := ResolveUser(env.backend, args)
+	if err != nil {
+		return err
+	}
+
+	if err := notify.SetOptOut(id, false); err != nil {
+		return err
+	}
+
+	env.out.Printf("%s opted in to the notifications\n", id.DisplayName())
+	return nil
+}
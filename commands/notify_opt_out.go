@@ -0,0 +1,40 @@
+package commands
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/daedaleanai/git-ticket/notify"
+)
+
+func newNotifyOptOutCommand() *cobra.Command {
+	env := newEnv()
+
+	cmd := &cobra.Command{
+		Use:   "opt-out [{user_name | user_id}]",
+		Short: "Stop receiving the notification digests.",
+		Long: `opt-out records in the identity, by default yours, that it doesn't want notification digests.
+`,
+		Args:     cobra.MaximumNArgs(1),
+		PreRunE:  loadBackend(env),
+		PostRunE: closeBackend(env),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runNotifyOptOut(env, args)
+		},
+	}
+
+	return cmd
+}
+
+func runNotifyOptOut(env *Env, args []string) error {
+	id, _, err := ResolveUser(env.backend, args)
+	if err != nil {
+		return err
+	}
+
+	if err := notify.SetOptOut(id, true); err != nil {
+		return err
+	}
+
+	env.out.Printf("%s opted out of the notifications\n", id.DisplayName())
+	return nil
+}
@@ -0,0 +1,70 @@
+package commands
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/daedaleanai/git-ticket/notify"
+)
+
+type notifySendOptions struct {
+	dryRun bool
+}
+
+func newNotifySendCommand() *cobra.Command {
+	env := newEnv()
+	options := notifySendOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "send",
+		Short: "Send the digests of the changes made since the last notification.",
+		Long: `send computes for each recipient the digest of the changes made to their tickets by other
+people since the last notification, and sends it. The first time, nothing is sent: only the
+later changes are notified.
+`,
+		Args:     cobra.NoArgs,
+		PreRunE:  loadBackend(env),
+		PostRunE: closeBackend(env),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runNotifySend(env, options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.SortFlags = false
+
+	flags.BoolVar(&options.dryRun, "dry-run", false,
+		"Print the emails instead of sending them")
+
+	return cmd
+}
+
+func runNotifySend(env *Env, opts notifySendOptions) error {
+	conf, err := notify.LoadConfig(env.backend)
+	if err != nil {
+		return err
+	}
+
+	n, err := notify.NewNotifier(env.backend, conf)
+	if err != nil {
+		return err
+	}
+	digests, err := n.Digests()
+	if err != nil {
+		return err
+	}
+
+	if opts.dryRun {
+		for _, digest := range digests {
+			msg, err := n.Render(digest)
+			if err != nil {
+				return err
+			}
+			env.out.Printf("%s\n", msg)
+		}
+		return nil
+	}
+
+	sent, err := n.Send(digests)
+	env.out.Printf("Sent %d notification digests\n", sent)
+	return err
+}
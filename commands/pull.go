@@ -4,6 +4,8 @@ import (
 	"errors"
 
 	"github.com/spf13/cobra"
+
+	"github.com/daedaleanai/git-ticket/notify"
 )
 
 func newPullCommand() *cobra.Command {
@@ -37,5 +39,13 @@ func runPull(env *Env, args []string) error {
 		return err
 	}
 
+	// the tickets were pulled, failing to notify them doesn't fail the pull: the digests which
+	// weren't sent are sent with the next ones
+	if notify.Configured(env.backend) {
+		if err := runNotifySend(env, notifySendOptions{}); err != nil {
+			env.err.Printf("Failed to send the notification digests: %s\n", err)
+		}
+	}
+
 	return nil
 }
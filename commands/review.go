@@ -10,9 +10,13 @@ func newReviewCommand() *cobra.Command {
 		Short: "Review actions of a ticket.",
 	}
 
+	cmd.AddCommand(newReviewApproveCommand())
 	cmd.AddCommand(newReviewChecklistCommand())
 	cmd.AddCommand(newReviewClearCommand())
+	cmd.AddCommand(newReviewCommentCommand())
 	cmd.AddCommand(newReviewFetchCommand())
+	cmd.AddCommand(newReviewQuoteCommand())
+	cmd.AddCommand(newReviewSyncCommand())
 
 	return cmd
 }
@@ -0,0 +1,66 @@
+package commands
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/daedaleanai/git-ticket/bug/review"
+)
+
+type reviewApproveOptions struct {
+	requestChanges bool
+	message        string
+}
+
+func newReviewApproveCommand() *cobra.Command {
+	env := newEnv()
+	options := reviewApproveOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "approve local:<ref> [ticket_id]",
+		Short: "Approve, or request changes to, a local review stored in a ticket.",
+		Long: `approve records the verdict of the user on the last commit of a local review, a review
+of a branch or range of commits made with git-ticket itself and attached to the ticket with
+"review fetch local:<ref>". Verdicts on previous commits are ignored once the review is
+updated to new commits.
+`,
+		PreRunE:  loadBackendEnsureUser(env),
+		PostRunE: closeBackend(env),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runReviewApprove(env, options, args)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.SortFlags = false
+
+	flags.BoolVarP(&options.requestChanges, "request-changes", "r", false,
+		"Request changes instead of approving")
+	flags.StringVarP(&options.message, "message", "m", "",
+		"Provide a message explaining the verdict")
+
+	return cmd
+}
+
+func runReviewApprove(env *Env, opts reviewApproveOptions, args []string) error {
+	b, localReview, err := resolveLocalReview(env, args)
+	if err != nil {
+		return err
+	}
+
+	author, err := env.backend.GetUserIdentity()
+	if err != nil {
+		return err
+	}
+
+	update := review.NewLocalVerdict(localReview, !opts.requestChanges, opts.message, author.Identity, time.Now().Unix())
+
+	_, err = b.SetReview(update)
+	if err != nil {
+		return fmt.Errorf("failed to store review info: %s", err)
+	}
+
+	return b.Commit()
+}
@@ -1,20 +1,33 @@
 package commands
 
 import (
+	"encoding/json"
 	"fmt"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
 
 	"github.com/manifoldco/promptui"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
 
 	"github.com/daedaleanai/git-ticket/bug"
+	"github.com/daedaleanai/git-ticket/cache"
 	_select "github.com/daedaleanai/git-ticket/commands/select"
 	"github.com/daedaleanai/git-ticket/config"
 	"github.com/daedaleanai/git-ticket/input"
 )
 
 type reviewChecklistOptions struct {
-	blank bool
+	blank     bool
+	upgrade   bool
+	fromFile  string
+	set       []string
+	checklist string
+	export    bool
+	format    string
 }
 
 func newReviewChecklistCommand() *cobra.Command {
@@ -22,8 +35,29 @@ func newReviewChecklistCommand() *cobra.Command {
 	options := reviewChecklistOptions{}
 
 	cmd := &cobra.Command{
-		Use:      "checklist [ticket_id]",
-		Short:    "Complete a checklist associated with a ticket.",
+		Use:   "checklist [ticket_id]",
+		Short: "Complete a checklist associated with a ticket.",
+		Long: `checklist completes a checklist associated with a ticket, in the editor by default.
+
+Answers can also be given without interaction, either with a file holding the answers to
+every question, by checklist label, section title and question text:
+
+  checklist:sw-review:
+    Design:
+      Is the design documented?:
+        state: passed
+        comment: see the wiki
+
+or by answering single questions with "section/question=state:comment". Sections and
+questions can be referenced by their number, and numeric, text or link questions take
+their value in braces after the state, as in "2/1=passed{85}:measured by CI".
+
+The sections and questions which only apply depending on the answers to previous questions
+are updated once the checklist is saved in the editor, which is opened again when they changed.
+`,
+		Example: `git ticket review checklist --from-file answers.yaml
+git ticket review checklist --checklist checklist:sw-review --set "Design/Is the design documented?=passed:see the wiki"
+git ticket review checklist --export --format yaml > answers.yaml`,
 		PreRunE:  loadBackendEnsureUser(env),
 		PostRunE: closeBackend(env),
 		RunE: func(cmd *cobra.Command, args []string) error {
@@ -37,6 +71,24 @@ func newReviewChecklistCommand() *cobra.Command {
 	flags.BoolVarP(&options.blank, "blank", "b", false,
 		"Discard any previously edited checklist and start again with a blank one",
 	)
+	flags.BoolVarP(&options.upgrade, "upgrade", "u", false,
+		"Upgrade the previously edited checklists to the latest template version, keeping the answers of unchanged questions",
+	)
+	flags.StringVarP(&options.fromFile, "from-file", "F", "",
+		"Take the answers to every question from the given JSON or YAML file. Use - to read them from the standard input",
+	)
+	flags.StringArrayVarP(&options.set, "set", "s", nil,
+		"Answer a single question, as \"section/question=state:comment\"",
+	)
+	flags.StringVarP(&options.checklist, "checklist", "c", "",
+		"Label of the checklist answered with --set or exported, required if the ticket has several checklists",
+	)
+	flags.BoolVarP(&options.export, "export", "e", false,
+		"Print the current answers in the format accepted by --from-file",
+	)
+	flags.StringVarP(&options.format, "format", "f", "yaml",
+		"Format of the exported answers and of the answers read from the standard input [yaml,json]",
+	)
 
 	return cmd
 
@@ -54,9 +106,11 @@ func runReviewChecklist(env *Env, opts reviewChecklistOptions, args []string) er
 	}
 
 	var ticketChecklists map[bug.Label]config.Checklist
-	env.backend.DoWithLockedConfigCache(func(c *config.ConfigCache) error {
+	var templates config.ChecklistConfig
+	err = env.backend.DoWithLockedConfigCache(func(c *config.ConfigCache) error {
 		inner, err := b.Snapshot().GetUserChecklists(c.ChecklistConfig, id.Id(), opts.blank)
 		ticketChecklists = inner
+		templates = c.ChecklistConfig
 		return err
 	})
 	if err != nil {
@@ -68,6 +122,25 @@ func runReviewChecklist(env *Env, opts reviewChecklistOptions, args []string) er
 		return nil
 	}
 
+	if opts.upgrade {
+		return upgradeReviewChecklists(b, ticketChecklists, templates)
+	}
+
+	if opts.export {
+		return exportReviewChecklists(env, ticketChecklists, opts)
+	}
+
+	if opts.fromFile != "" || len(opts.set) > 0 {
+		return answerReviewChecklists(b, ticketChecklists, opts)
+	}
+
+	for label, cl := range ticketChecklists {
+		if template, err := templates.GetChecklist(config.Label(label)); err == nil && cl.Version < template.Version {
+			fmt.Printf("Checklist %s was completed on version %d, the latest version is %d: use --upgrade to update it\n",
+				label, cl.Version, template.Version)
+		}
+	}
+
 	// Collect checklist labels
 	ticketChecklistLabels := make([]string, 0, len(ticketChecklists))
 
@@ -97,7 +170,7 @@ func runReviewChecklist(env *Env, opts reviewChecklistOptions, args []string) er
 
 	// Use the editor to edit the checklist, if it changed then create an update
 	// operation and commit
-	clChange, err := input.ChecklistEditorInput(env.repo, ticketChecklists[bug.Label(selectedChecklistLabel)], opts.blank)
+	clChange, err := input.ChecklistEditorInput(env.repo, ticketChecklists[bug.Label(selectedChecklistLabel)], b.Snapshot().EvaluateChecklist, opts.blank)
 	if err != nil {
 		return errors.Wrap(err, "checklist not saved, re-run command to continue editing or use -b flag to start again")
 	}
@@ -114,3 +187,187 @@ func runReviewChecklist(env *Env, opts reviewChecklistOptions, args []string) er
 	fmt.Println("Checklists unchanged")
 	return nil
 }
+
+// upgradeReviewChecklists replaces the checklists completed against an older template
+// version by the latest one, migrating the answers of the unchanged questions
+func upgradeReviewChecklists(b *cache.BugCache, ticketChecklists map[bug.Label]config.Checklist, templates config.ChecklistConfig) error {
+	upgradedCount := 0
+
+	for label, cl := range ticketChecklists {
+		template, err := templates.GetChecklist(config.Label(label))
+		if err != nil {
+			return err
+		}
+
+		if cl.Version >= template.Version {
+			continue
+		}
+
+		upgraded, reset := cl.Upgrade(template)
+
+		_, err = b.SetChecklist(upgraded)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Upgraded checklist %s from version %d to %d, %d question(s) reset\n", label, cl.Version, template.Version, reset)
+		upgradedCount++
+	}
+
+	if upgradedCount == 0 {
+		fmt.Println("Checklists already up to date")
+		return nil
+	}
+
+	return b.Commit()
+}
+
+// selectReviewChecklist returns the label of the checklist given with the --checklist flag,
+// or the only checklist of the ticket if there is no flag
+func selectReviewChecklist(ticketChecklists map[bug.Label]config.Checklist, opts reviewChecklistOptions) (bug.Label, error) {
+	if opts.checklist != "" {
+		label := bug.Label(opts.checklist)
+		if _, present := ticketChecklists[label]; !present {
+			return "", fmt.Errorf("checklist %s is not associated with the ticket", label)
+		}
+		return label, nil
+	}
+
+	if len(ticketChecklists) > 1 {
+		return "", fmt.Errorf("the ticket has several checklists, select one with --checklist")
+	}
+
+	for label := range ticketChecklists {
+		return label, nil
+	}
+	return "", fmt.Errorf("no checklists associated with ticket")
+}
+
+// exportReviewChecklists prints the user answers to the ticket checklists
+func exportReviewChecklists(env *Env, ticketChecklists map[bug.Label]config.Checklist, opts reviewChecklistOptions) error {
+	if opts.checklist != "" {
+		label, err := selectReviewChecklist(ticketChecklists, opts)
+		if err != nil {
+			return err
+		}
+		ticketChecklists = map[bug.Label]config.Checklist{label: ticketChecklists[label]}
+	}
+
+	answers := bug.ExportChecklistAnswers(ticketChecklists)
+
+	var data []byte
+	var err error
+	switch opts.format {
+	case "yaml":
+		data, err = yaml.Marshal(answers)
+	case "json":
+		data, err = json.MarshalIndent(answers, "", "    ")
+		data = append(data, '\n')
+	default:
+		return fmt.Errorf("unknown format %s", opts.format)
+	}
+	if err != nil {
+		return err
+	}
+
+	env.out.Print(string(data))
+	return nil
+}
+
+// answerReviewChecklists applies the answers given with --from-file and --set to the
+// ticket checklists, they are only saved if they are all valid
+func answerReviewChecklists(b *cache.BugCache, ticketChecklists map[bug.Label]config.Checklist, opts reviewChecklistOptions) error {
+	answers := make(bug.ChecklistAnswers)
+
+	if opts.fromFile != "" {
+		raw, err := input.TextFileInput(opts.fromFile)
+		if err != nil {
+			return err
+		}
+
+		format := opts.format
+		switch strings.ToLower(filepath.Ext(opts.fromFile)) {
+		case ".json":
+			format = "json"
+		case ".yaml", ".yml":
+			format = "yaml"
+		}
+
+		switch format {
+		case "json":
+			err = json.Unmarshal([]byte(raw), &answers)
+		case "yaml":
+			err = yaml.Unmarshal([]byte(raw), &answers)
+		default:
+			return fmt.Errorf("unknown format %s", format)
+		}
+		if err != nil {
+			return fmt.Errorf("unable to read answers from %s: %s", opts.fromFile, err)
+		}
+	}
+
+	// Checklists answered with a file must be complete, single answers can be given on top of them
+	complete := make(map[bug.Label]bool)
+	for label := range answers {
+		complete[label] = true
+	}
+
+	if len(opts.set) > 0 {
+		label, err := selectReviewChecklist(ticketChecklists, opts)
+		if err != nil {
+			return err
+		}
+
+		for _, spec := range opts.set {
+			section, question, answer, err := bug.ParseChecklistAnswer(spec)
+			if err != nil {
+				return err
+			}
+			if answers[label] == nil {
+				answers[label] = make(map[string]map[string]bug.ChecklistAnswer)
+			}
+			if answers[label][section] == nil {
+				answers[label][section] = make(map[string]bug.ChecklistAnswer)
+			}
+			answers[label][section][question] = answer
+		}
+	}
+
+	var errs []string
+	var answered []config.Checklist
+
+	for label, clAnswers := range answers {
+		cl, present := ticketChecklists[label]
+		if !present {
+			errs = append(errs, fmt.Sprintf("checklist %s is not associated with the ticket", label))
+			continue
+		}
+
+		answeredCl := cl.Copy()
+		if err := bug.ApplyChecklistAnswers(&answeredCl, clAnswers, complete[label]); err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		if !reflect.DeepEqual(answeredCl, cl) {
+			answered = append(answered, answeredCl)
+		}
+	}
+
+	if len(errs) > 0 {
+		sort.Strings(errs)
+		return fmt.Errorf("checklist not saved:\n%s", strings.Join(errs, "\n"))
+	}
+
+	if len(answered) == 0 {
+		fmt.Println("Checklists unchanged")
+		return nil
+	}
+
+	for _, cl := range answered {
+		if _, err := b.SetChecklist(cl); err != nil {
+			return err
+		}
+	}
+
+	return b.Commit()
+}
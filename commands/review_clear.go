@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 
+	"github.com/daedaleanai/git-ticket/bug/review"
 	_select "github.com/daedaleanai/git-ticket/commands/select"
 	"github.com/spf13/cobra"
 )
@@ -13,7 +14,7 @@ func newReviewClearCommand() *cobra.Command {
 
 	cmd := &cobra.Command{
 		Use:      "clear {revision_id | pull_request_ref} [ticket_id]",
-		Short:    "Clear the Differential Revision or Gitea pull request data associated with a ticket.",
+		Short:    "Clear the review data, e.g. a Differential Revision or Gitea pull request, associated with a ticket.",
 		PreRunE:  loadBackendEnsureUser(env),
 		PostRunE: closeBackend(env),
 		RunE: func(cmd *cobra.Command, args []string) error {
@@ -37,6 +38,10 @@ func runReviewClear(env *Env, args []string) error {
 		return err
 	}
 
+	if _, err := review.ProviderForId(diffId); err != nil {
+		return err
+	}
+
 	if _, ok := b.Snapshot().Reviews[diffId]; !ok {
 		return fmt.Errorf("ticket %s does not have a review %s", b.Id().Human(), diffId)
 	}
@@ -0,0 +1,125 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/daedaleanai/git-ticket/bug/review"
+	"github.com/daedaleanai/git-ticket/cache"
+	_select "github.com/daedaleanai/git-ticket/commands/select"
+	"github.com/daedaleanai/git-ticket/input"
+)
+
+type reviewCommentOptions struct {
+	location    string
+	messageFile string
+	message     string
+}
+
+func newReviewCommentCommand() *cobra.Command {
+	env := newEnv()
+	options := reviewCommentOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "comment local:<ref> [ticket_id]",
+		Short: "Comment on a local review stored in a ticket.",
+		Long: `comment adds a comment to a local review, a review of a branch or range of commits
+made with git-ticket itself and attached to the ticket with "review fetch local:<ref>".
+
+The comment can be anchored to a line of a file with --at file:line, in the last commit reviewed,
+or --at file:line@commit in another commit. The lines around it are stored with the comment.
+`,
+		Example:  `git ticket review comment local:feature/parser --at parser/lexer.go:42 -m "Off by one?"`,
+		PreRunE:  loadBackendEnsureUser(env),
+		PostRunE: closeBackend(env),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runReviewComment(env, options, args)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.SortFlags = false
+
+	flags.StringVarP(&options.location, "at", "a", "",
+		"Anchor the comment to a line of a file, as file:line[@commit]")
+	flags.StringVarP(&options.messageFile, "file", "F", "",
+		"Take the message from the given file. Use - to read the message from the standard input")
+	flags.StringVarP(&options.message, "message", "m", "",
+		"Provide the new message from the command line")
+
+	return cmd
+}
+
+func runReviewComment(env *Env, opts reviewCommentOptions, args []string) error {
+	b, localReview, err := resolveLocalReview(env, args)
+	if err != nil {
+		return err
+	}
+
+	if opts.messageFile != "" && opts.message == "" {
+		opts.message, err = input.BugCommentFileInput(opts.messageFile)
+		if err != nil {
+			return err
+		}
+	}
+
+	if opts.messageFile == "" && opts.message == "" {
+		opts.message, err = input.BugCommentEditorInput(env.backend, "")
+		if err == input.ErrEmptyMessage {
+			env.err.Println("Empty message, aborting.")
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	author, err := env.backend.GetUserIdentity()
+	if err != nil {
+		return err
+	}
+
+	update, err := review.NewLocalComment(env.repo, localReview, opts.location, opts.message, author.Identity, time.Now().Unix())
+	if err != nil {
+		return err
+	}
+
+	_, err = b.SetReview(update)
+	if err != nil {
+		return fmt.Errorf("failed to store review info: %s", err)
+	}
+
+	return b.Commit()
+}
+
+// resolveLocalReview returns the ticket selected by the arguments following the review id, and
+// its local review with that id
+func resolveLocalReview(env *Env, args []string) (*cache.BugCache, *review.LocalInfo, error) {
+	if len(args) < 1 {
+		return nil, nil, errors.New("no review id supplied")
+	}
+
+	reviewId := args[0]
+	args = args[1:]
+
+	b, args, err := _select.ResolveBug(env.backend, args)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	existing, ok := b.Snapshot().Reviews[reviewId]
+	if !ok {
+		return nil, nil, fmt.Errorf("ticket %s does not have a review %s, attach it with \"review fetch %s\"",
+			b.Id().Human(), reviewId, reviewId)
+	}
+
+	localReview, ok := existing.(*review.LocalInfo)
+	if !ok {
+		return nil, nil, fmt.Errorf("%s is not a local review, comment it on its review server", reviewId)
+	}
+
+	return b, localReview, nil
+}
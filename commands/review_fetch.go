@@ -3,11 +3,11 @@ package commands
 import (
 	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/daedaleanai/git-ticket/bug/review"
 	"github.com/spf13/cobra"
 
-	"github.com/daedaleanai/git-ticket/bug"
 	_select "github.com/daedaleanai/git-ticket/commands/select"
 )
 
@@ -16,16 +16,21 @@ func newReviewFetchCommand() *cobra.Command {
 
 	cmd := &cobra.Command{
 		Use:   "fetch {revision_id | pull_request_ref} [ticket_id]",
-		Short: "Get Differential Revision data from Phabricator or Gitea and store in a ticket.",
-		Long: `fetch stores Phabricator Differential Revision data in a ticket.
+		Short: "Get review data from Phabricator, Gitea or another review provider and store in a ticket.",
+		Long: `fetch stores review data, e.g. a Phabricator Differential Revision, in a ticket.
 
-The command takes a Phabricator Differential Revision ID (e.g. D1234) or Gitea Pull Request (e.g. daedalean-github/git-ticket#9) and queries the 
-server for any associated comments or status changes, any resulting data
-is stored with the selected ticket. Subsequent calls with the same ID will fetch and
-store any updates since the previous call. Multiple Revisions can be stored with a
-ticket by running the command with different IDs.
+The command takes a review id, e.g. a Phabricator Differential Revision ID (D1234) or a Gitea
+Pull Request (daedalean-github/git-ticket#9), and queries the server for any associated comments
+or status changes, any resulting data is stored with the selected ticket. Subsequent calls with
+the same ID will fetch and store any updates since the previous call. Multiple Revisions can be
+stored with a ticket by running the command with different IDs.
 
-`,
+The review ids accepted are listed below. An id accepted by several providers is fetched
+from the provider of the review stored with the same id, or else from the only one configured
+in the repository, e.g. the Gitea URL or the GitHub API token is set in the git config. It is
+rejected as ambiguous otherwise, and the URL of the review has to be given instead.
+
+` + reviewIdFormats(),
 		PreRunE:  loadBackendEnsureUser(env),
 		PostRunE: closeBackend(env),
 		RunE: func(cmd *cobra.Command, args []string) error {
@@ -50,13 +55,13 @@ func runReviewFetch(env *Env, args []string) error {
 	}
 
 	// If we already have review data for this Differential then just get any updates
-	// since then
-	var lastUpdate review.PullRequest
-	if existingReview, ok := b.Snapshot().Reviews[diffId]; ok {
-		lastUpdate = existingReview
+	// since then, whatever the form of the id given, e.g. an URL
+	provider, lastUpdate, err := review.Resolve(diffId, b.Snapshot().Reviews)
+	if err != nil {
+		return err
 	}
 
-	review, err := bug.FetchReviewInfo(diffId, lastUpdate)
+	review, err := review.FetchFrom(provider, diffId, lastUpdate)
 	if err != nil {
 		return fmt.Errorf("failed to fetch review info: %s", err)
 	}
@@ -73,3 +78,14 @@ func runReviewFetch(env *Env, args []string) error {
 
 	return b.Commit()
 }
+
+// reviewIdFormats lists the formats of the review ids accepted by the review providers
+func reviewIdFormats() string {
+	var formats strings.Builder
+	for _, p := range review.Providers() {
+		for _, f := range p.Formats {
+			fmt.Fprintf(&formats, "  %-40s %s\n", f, p.Name)
+		}
+	}
+	return formats.String()
+}
@@ -0,0 +1,76 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/daedaleanai/git-ticket/bug/review"
+	_select "github.com/daedaleanai/git-ticket/commands/select"
+)
+
+type reviewQuoteOptions struct {
+	message string
+}
+
+func newReviewQuoteCommand() *cobra.Command {
+	env := newEnv()
+	options := reviewQuoteOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "quote <review_id> <comment_id> [ticket_id]",
+		Short: "Quote a comment of a review in a comment of the ticket.",
+		Long: `quote adds a comment to the ticket quoting the complete text of a comment of one of its
+reviews, followed by a link back to the review. The ids of the comments are shown by
+"show --timeline --full-reviews".
+`,
+		Example:  `git ticket review quote D1234 PHID-XCMT-abcd -m "Follow-up needed before the release."`,
+		PreRunE:  loadBackendEnsureUser(env),
+		PostRunE: closeBackend(env),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runReviewQuote(env, options, args)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.SortFlags = false
+
+	flags.StringVarP(&options.message, "message", "m", "",
+		"Add a message after the quote")
+
+	return cmd
+}
+
+func runReviewQuote(env *Env, opts reviewQuoteOptions, args []string) error {
+	if len(args) < 2 {
+		return errors.New("a review id and a comment id must be supplied")
+	}
+
+	reviewId, commentId := args[0], args[1]
+
+	b, _, err := _select.ResolveBug(env.backend, args[2:])
+	if err != nil {
+		return err
+	}
+
+	r, ok := b.Snapshot().Reviews[reviewId]
+	if !ok {
+		return fmt.Errorf("ticket %s does not have a review %s", b.Id().Human(), reviewId)
+	}
+
+	message, err := review.QuoteComment(r, commentId)
+	if err != nil {
+		return err
+	}
+	if opts.message != "" {
+		message = message + "\n" + opts.message
+	}
+
+	_, err = b.AddComment(message)
+	if err != nil {
+		return err
+	}
+
+	return b.Commit()
+}
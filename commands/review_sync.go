@@ -0,0 +1,190 @@
+package commands
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/daedaleanai/git-ticket/bug"
+	"github.com/daedaleanai/git-ticket/bug/review"
+	"github.com/daedaleanai/git-ticket/cache"
+	"github.com/daedaleanai/git-ticket/query"
+)
+
+type reviewSyncOptions struct {
+	jobs int
+	rate float64
+}
+
+// reviewSyncJob is the refresh of one review of a ticket
+type reviewSyncJob struct {
+	ticket *cache.BugCache
+	id     string
+	since  review.PullRequest
+
+	update review.PullRequest
+	err    error
+}
+
+func newReviewSyncCommand() *cobra.Command {
+	env := newEnv()
+	options := reviewSyncOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "sync [query]",
+		Short: "Fetch the updates of all the reviews stored in tickets.",
+		Long: `sync fetches the updates of all the reviews stored in the tickets, or only in those
+matching the query, as "review fetch" does for a single review. The reviews are fetched in
+parallel, at a limited rate to spare the review servers, and the tickets are only updated
+for the reviews which changed since they were last fetched.
+
+Only the reviews fetched from a review server are synced, the local reviews of the branches
+of the repository are updated with "review fetch".
+`,
+		Example:  `git ticket review sync 'status(inprogress,inreview)' --jobs 8`,
+		PreRunE:  loadBackendEnsureUser(env),
+		PostRunE: closeBackend(env),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runReviewSync(env, options, args)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.SortFlags = false
+
+	flags.IntVarP(&options.jobs, "jobs", "j", 4,
+		"Maximum number of reviews fetched at the same time")
+	flags.Float64VarP(&options.rate, "rate", "r", 5,
+		"Maximum number of reviews fetched per second, 0 for no limit")
+
+	return cmd
+}
+
+func runReviewSync(env *Env, opts reviewSyncOptions, args []string) error {
+	var q *query.CompiledQuery
+
+	if len(args) > 0 {
+		parser, err := query.NewParser(strings.Join(args, " "))
+		if err != nil {
+			return err
+		}
+		q, err = parser.Parse()
+		if err != nil {
+			return err
+		}
+	}
+
+	var jobs []*reviewSyncJob
+	tickets := 0
+
+	for _, id := range env.backend.QueryBugs(q) {
+		b, err := env.backend.ResolveBug(id)
+		if err != nil {
+			return err
+		}
+
+		reviews := b.Snapshot().Reviews
+		ids := make([]string, 0, len(reviews))
+		for reviewId, r := range reviews {
+			if isRemoteReview(r) {
+				ids = append(ids, reviewId)
+			}
+		}
+		if len(ids) == 0 {
+			continue
+		}
+		tickets++
+		sort.Strings(ids)
+
+		for _, reviewId := range ids {
+			jobs = append(jobs, &reviewSyncJob{ticket: b, id: reviewId, since: reviews[reviewId]})
+		}
+	}
+
+	var interval time.Duration
+	if opts.rate > 0 {
+		interval = time.Duration(float64(time.Second) / opts.rate)
+	}
+
+	fetchReviews(jobs, opts.jobs, interval, bug.FetchReviewInfo)
+
+	// The tickets are only updated once all the reviews are fetched, the updates being merged
+	// into the reviews fetched from
+	var updated, failed int
+	for i, job := range jobs {
+		if job.err == nil && !job.update.IsEmpty() {
+			_, job.err = job.ticket.SetReview(job.update)
+			if job.err == nil {
+				updated++
+				env.out.Printf("Updated %s in %s\n", job.id, job.ticket.Id().Human())
+			}
+		}
+		if job.err != nil {
+			failed++
+			env.err.Printf("Failed to sync %s in %s: %s\n", job.id, job.ticket.Id().Human(), job.err)
+		}
+
+		// Commit once all the reviews of the ticket are handled
+		if i+1 == len(jobs) || jobs[i+1].ticket != job.ticket {
+			if err := job.ticket.CommitAsNeeded(); err != nil {
+				return err
+			}
+		}
+	}
+
+	env.out.Printf("Synced %d reviews in %d tickets: %d updated, %d unchanged, %d failed\n",
+		len(jobs), tickets, updated, len(jobs)-updated-failed, failed)
+
+	if failed > 0 {
+		return fmt.Errorf("failed to sync %d reviews", failed)
+	}
+	return nil
+}
+
+// isRemoteReview tells if the review is fetched from a review server, and so is synced
+func isRemoteReview(r review.PullRequest) bool {
+	p, ok := review.ProviderFor(r)
+	return ok && p.Fetch != nil && !p.Local
+}
+
+// fetchReviews fetches the updates of the reviews of the jobs with at most concurrency fetches
+// at the same time, and at most one started per interval if it isn't zero
+func fetchReviews(jobs []*reviewSyncJob, concurrency int, interval time.Duration, fetch func(string, review.PullRequest) (review.PullRequest, error)) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	var throttle <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		throttle = ticker.C
+	}
+
+	queue := make(chan *reviewSyncJob)
+	var wg sync.WaitGroup
+
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range queue {
+				job.update, job.err = fetch(job.id, job.since)
+			}
+		}()
+	}
+
+	for i, job := range jobs {
+		if throttle != nil && i > 0 {
+			<-throttle
+		}
+		queue <- job
+	}
+	close(queue)
+
+	wg.Wait()
+}
@@ -0,0 +1,60 @@
+package commands
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/daedaleanai/git-ticket/bug/review"
+)
+
+func TestFetchReviews(t *testing.T) {
+	var jobs []*reviewSyncJob
+	for i := 0; i < 10; i++ {
+		jobs = append(jobs, &reviewSyncJob{id: fmt.Sprintf("D%d", i)})
+	}
+
+	var running, maxRunning int32
+	fetch := func(id string, since review.PullRequest) (review.PullRequest, error) {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			max := atomic.LoadInt32(&maxRunning)
+			if n <= max || atomic.CompareAndSwapInt32(&maxRunning, max, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+
+		if id == "D3" {
+			return nil, fmt.Errorf("not found")
+		}
+		return &review.RemoveReview{ReviewId: id}, nil
+	}
+
+	start := time.Now()
+	fetchReviews(jobs, 3, 5*time.Millisecond, fetch)
+
+	assert.GreaterOrEqual(t, time.Since(start), 45*time.Millisecond)
+	assert.LessOrEqual(t, maxRunning, int32(3))
+
+	for _, job := range jobs {
+		if job.id == "D3" {
+			assert.Error(t, job.err)
+			continue
+		}
+		require.NoError(t, job.err)
+		assert.Equal(t, job.id, job.update.Id())
+	}
+}
+
+func TestIsRemoteReview(t *testing.T) {
+	assert.True(t, isRemoteReview(&review.PhabReviewInfo{RevisionId: "D1"}))
+	assert.True(t, isRemoteReview(&review.GiteaInfo{Owner: "daedalean", Repository: "git-ticket", PullId: 9}))
+	assert.False(t, isRemoteReview(&review.LocalInfo{Ref: "feature"}))
+	assert.False(t, isRemoteReview(&review.RemoveReview{ReviewId: "D1"}))
+}
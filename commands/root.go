@@ -74,16 +74,22 @@ _git_bug() {
 
 	cmd.AddCommand(newAddCommand())
 	cmd.AddCommand(newAssignCommand())
+	cmd.AddCommand(newBridgeCommand())
 	cmd.AddCommand(newCcbCommand())
 	cmd.AddCommand(newChecklistCommand())
 	cmd.AddCommand(newCommandsCommand())
 	cmd.AddCommand(newCommentCommand())
 	cmd.AddCommand(newConfigCommand())
 	cmd.AddCommand(newDeselectCommand())
+	cmd.AddCommand(newExportCommand())
+	cmd.AddCommand(newImportCommand())
 	cmd.AddCommand(newLabelCommand())
 	cmd.AddCommand(newLsCommand())
 	cmd.AddCommand(newLsIdCommand())
 	cmd.AddCommand(newLsLabelCommand())
+	cmd.AddCommand(newMailCommand())
+	cmd.AddCommand(newMoveCommand())
+	cmd.AddCommand(newNotifyCommand())
 	cmd.AddCommand(newPullCommand())
 	cmd.AddCommand(newPushCommand())
 	cmd.AddCommand(newResetCommand())
@@ -98,6 +104,7 @@ _git_bug() {
 	cmd.AddCommand(newUserCommand())
 	cmd.AddCommand(newValidateCommand())
 	cmd.AddCommand(newVersionCommand())
+	cmd.AddCommand(newWebhookCommand())
 	cmd.AddCommand(newWebUICommand())
 	cmd.AddCommand(newMigrateCommand())
 
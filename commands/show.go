@@ -13,6 +13,7 @@ import (
 
 	"github.com/charmbracelet/glamour"
 	"github.com/daedaleanai/git-ticket/bug"
+	"github.com/daedaleanai/git-ticket/bug/review"
 	_select "github.com/daedaleanai/git-ticket/commands/select"
 	"github.com/daedaleanai/git-ticket/config"
 	"github.com/daedaleanai/git-ticket/util/colors"
@@ -23,6 +24,7 @@ type showOptions struct {
 	fields      string
 	format      string
 	timeline    bool
+	fullReviews bool
 	since       string
 	rawComments bool
 }
@@ -46,8 +48,10 @@ func newShowCommand() *cobra.Command {
 
 	flags.BoolVarP(&options.timeline, "timeline", "t", false,
 		"Output the timeline of the ticket")
+	flags.BoolVarP(&options.fullReviews, "full-reviews", "", false,
+		"Output the complete review comments in the timeline, with their replies")
 	flags.StringVarP(&options.fields, "field", "", "",
-		"Select field to display. Valid values are [assignee,author,authorEmail,ccb,checklists,createTime,lastEdit,humanId,id,labels,reviews,shortId,status,nextStatuses,title,workflow,actors,participants]")
+		"Select field to display. Valid values are [assignee,author,authorEmail,ccb,checklists,createTime,files,lastEdit,humanId,id,labels,reviews,shortId,status,nextStatuses,title,workflow,actors,participants]")
 	flags.StringVarP(&options.format, "format", "f", "default",
 		"Select the output formatting style. Valid values are [default,json,org-mode]")
 	flags.StringVarP(&options.since, "since", "s", "",
@@ -58,6 +62,41 @@ func newShowCommand() *cobra.Command {
 	return cmd
 }
 
+// showFullReviewComments outputs the complete comments of a review event, indented, with the
+// code commented on if known and the comment each reply is to
+func showFullReviewComments(env *Env, evt review.TimelineEvent, replyTo map[string]string) {
+	for _, change := range evt.Changes() {
+		c, ok := review.AsComment(change)
+		if !ok {
+			continue
+		}
+
+		header := "#" + c.CommentId()
+		if location := c.Location(); location != "" {
+			header = header + " " + location
+		}
+		if parent, ok := replyTo[c.CommentId()]; ok {
+			header = header + " in reply to #" + parent
+		}
+		env.out.Printf("    %s\n", colors.Cyan(header))
+
+		if cc, ok := change.(review.CodeContextChange); ok {
+			first, lines, line := cc.CodeContext()
+			for i, l := range lines {
+				marker := " "
+				if first+i == line {
+					marker = ">"
+				}
+				env.out.Printf("    %s %5d | %s\n", marker, first+i, l)
+			}
+		}
+
+		for _, line := range strings.Split(strings.TrimRight(c.Body(), "\r\n"), "\n") {
+			env.out.Printf("    %s\n", strings.TrimRight(line, "\r"))
+		}
+	}
+}
+
 func runShow(env *Env, opts showOptions, args []string) error {
 	b, args, err := _select.ResolveBug(env.backend, args)
 	if err != nil {
@@ -74,9 +113,26 @@ func runShow(env *Env, opts showOptions, args []string) error {
 				return err
 			}
 		}
+		// The discussions of the reviews, to show which comment each reply is to
+		threads := make(map[string]map[string]string)
+		if opts.fullReviews {
+			for id, r := range snap.Reviews {
+				threads[id] = make(map[string]string)
+				for _, t := range review.Threads(r) {
+					for _, reply := range t.Replies {
+						threads[id][reply.Comment.CommentId()] = t.Comment.CommentId()
+					}
+				}
+			}
+		}
+
 		for _, op := range snap.Timeline {
 			if op.When().Time().After(since) {
 				env.out.Println(op)
+
+				if rtl, ok := op.(*bug.SetReviewTimelineItem); ok && opts.fullReviews {
+					showFullReviewComments(env, rtl.Event, threads[rtl.Review.Id()])
+				}
 			}
 		}
 
@@ -103,6 +159,10 @@ func runShow(env *Env, opts showOptions, args []string) error {
 			env.out.Printf("%s\n", snap.Author.Email())
 		case "createTime":
 			env.out.Printf("%s\n", snap.CreateTime.String())
+		case "files":
+			for _, f := range snap.ChangedFiles() {
+				env.out.Printf("%s %s %s\n", f.Path, colors.Green(fmt.Sprintf("+%d", f.Additions)), colors.Red(fmt.Sprintf("-%d", f.Deletions)))
+			}
 		case "lastEdit":
 			env.out.Printf("%s\n", snap.EditTime().String())
 		case "humanId":
@@ -121,7 +181,7 @@ func runShow(env *Env, opts showOptions, args []string) error {
 							if err != nil {
 								return err
 							}
-							env.out.Printf("%s reviewed %s: %s\n", reviewer.DisplayName(), cl.LastEdit, cl)
+							env.out.Printf("%s reviewed %s: %s\n", reviewer.DisplayName(), cl.LastEdit, snap.EvaluateChecklist(cl.Checklist))
 						}
 					}
 				}
@@ -146,6 +206,16 @@ func runShow(env *Env, opts showOptions, args []string) error {
 						if summary != "" {
 							env.out.Printf("(%s) %s: %s\n", time.Unix(c.Timestamp().Time().Unix(), 0).Format("2006-01-02 15:04:05"), termtext.LeftPadMaxLine(c.Author().DisplayName(), 15, 0), summary)
 						}
+						if cc, ok := evt.(review.CodeContextChange); ok {
+							first, lines, line := cc.CodeContext()
+							for i, l := range lines {
+								marker := " "
+								if first+i == line {
+									marker = ">"
+								}
+								env.out.Printf("    %s %5d | %s\n", marker, first+i, l)
+							}
+						}
 					}
 				}
 			}
@@ -262,6 +332,10 @@ func showDefaultFormatter(env *Env, snapshot *bug.Snapshot, opts showOptions) er
 		snapshot.EditTime().Format("2006-01-02 15:04:05"),
 	)
 
+	if snapshot.MovedTo != "" {
+		env.out.Printf("%s\n\n", colors.Red("This ticket was moved to "+snapshot.MovedTo))
+	}
+
 	// Workflow
 	workflow, labels := workflowAndLabels(snapshot)
 	env.out.Printf("workflow: %s\n", workflow)
@@ -399,6 +473,7 @@ type JSONBugSnapshot struct {
 	Actors       []JSONIdentity `json:"actors"`
 	Participants []JSONIdentity `json:"participants"`
 	Comments     []JSONComment  `json:"comments"`
+	MovedTo      string         `json:"moved_to,omitempty"`
 }
 
 type JSONComment struct {
@@ -428,6 +503,7 @@ func showJsonFormatter(env *Env, snapshot *bug.Snapshot) error {
 		Title:      snapshot.Title,
 		Author:     NewJSONIdentity(snapshot.Author),
 		Assignee:   NewJSONIdentity(snapshot.Assignee),
+		MovedTo:    snapshot.MovedTo,
 	}
 
 	jsonBug.Actors = make([]JSONIdentity, len(snapshot.Actors))
@@ -11,6 +11,7 @@ import (
 	"golang.org/x/text/transform"
 	"golang.org/x/text/unicode/norm"
 
+	"github.com/daedaleanai/git-ticket/bug/review"
 	"github.com/daedaleanai/git-ticket/cache"
 )
 
@@ -80,7 +81,7 @@ func newUserCommand() *cobra.Command {
 	flags.SortFlags = false
 
 	flags.StringVarP(&options.fields, "field", "f", "",
-		"Select field to display. Valid values are [email,humanId,id,keys,lastModification,lastModificationLamport,login,metadata,name,phabId,giteaId]")
+		"Select field to display. Valid values are [email,humanId,id,keys,lastModification,lastModificationLamport,login,metadata,name,phabId,giteaId,githubLogin,gitlabUsername,gerritUsername]")
 
 	return cmd
 }
@@ -125,6 +126,12 @@ func runUser(env *Env, opts userOptions, args []string) error {
 			env.out.Printf("%s\n", id.PhabID())
 		case "giteaId":
 			env.out.Printf("%d\n", id.GiteaID())
+		case "githubLogin":
+			env.out.Printf("%s\n", id.MutableMetadata()[review.GithubLoginMetadataKey])
+		case "gitlabUsername":
+			env.out.Printf("%s\n", id.MutableMetadata()[review.GitlabUsernameMetadataKey])
+		case "gerritUsername":
+			env.out.Printf("%s\n", id.MutableMetadata()[review.GerritUsernameMetadataKey])
 
 		default:
 			return fmt.Errorf("\nUnsupported field: %s\n", opts.fields)
@@ -139,6 +146,9 @@ func runUser(env *Env, opts userOptions, args []string) error {
 	env.out.Printf("Login: %s\n", id.Login())
 	env.out.Printf("PhabID: %s\n", id.PhabID())
 	env.out.Printf("GiteaID: %v\n", id.GiteaID())
+	env.out.Printf("GitHub login: %s\n", id.MutableMetadata()[review.GithubLoginMetadataKey])
+	env.out.Printf("GitLab username: %s\n", id.MutableMetadata()[review.GitlabUsernameMetadataKey])
+	env.out.Printf("Gerrit username: %s\n", id.MutableMetadata()[review.GerritUsernameMetadataKey])
 	env.out.Printf("Last modification: %s (lamport %d)\n",
 		id.LastModification().Time().Format("2006-01-02 15:04:05"),
 		id.LastModificationLamport())
@@ -5,6 +5,7 @@ import (
 
 	"github.com/spf13/cobra"
 
+	"github.com/daedaleanai/git-ticket/bug/review"
 	"github.com/daedaleanai/git-ticket/identity"
 	"github.com/daedaleanai/git-ticket/input"
 )
@@ -14,6 +15,9 @@ type userCreateOptions struct {
 	skipPhabId     bool
 	skipGiteaId    bool
 	giteaUserName  string
+	githubLogin    string
+	gitlabUsername string
+	gerritUsername string
 }
 
 func newUserCreateCommand() *cobra.Command {
@@ -43,6 +47,15 @@ func newUserCreateCommand() *cobra.Command {
 	flags.StringVar(&options.giteaUserName, "gitea-username", "",
 		"The username of this user in the Gitea server. Must match exactly one user",
 	)
+	flags.StringVar(&options.githubLogin, "github-login", "",
+		"The login of this user on GitHub, used to fetch GitHub pull requests",
+	)
+	flags.StringVar(&options.gitlabUsername, "gitlab-username", "",
+		"The username of this user on GitLab, used to fetch GitLab merge requests",
+	)
+	flags.StringVar(&options.gerritUsername, "gerrit-username", "",
+		"The username of this user on Gerrit, used to fetch Gerrit changes",
+	)
 
 	return cmd
 }
@@ -99,7 +112,18 @@ func runUserCreate(env *Env, opts userCreateOptions) error {
 		opts.giteaUserName = userName
 	}
 
-	id, err := env.backend.NewIdentityWithKeyRaw(name, email, "", avatarURL, nil, key, opts.skipPhabId, opts.skipGiteaId, opts.giteaUserName)
+	metadata := make(map[string]string)
+	if opts.githubLogin != "" {
+		metadata[review.GithubLoginMetadataKey] = opts.githubLogin
+	}
+	if opts.gitlabUsername != "" {
+		metadata[review.GitlabUsernameMetadataKey] = opts.gitlabUsername
+	}
+	if opts.gerritUsername != "" {
+		metadata[review.GerritUsernameMetadataKey] = opts.gerritUsername
+	}
+
+	id, err := env.backend.NewIdentityWithKeyRaw(name, email, "", avatarURL, metadata, key, opts.skipPhabId, opts.skipGiteaId, opts.giteaUserName)
 	if err != nil {
 		return err
 	}
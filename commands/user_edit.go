@@ -5,13 +5,18 @@ import (
 
 	"github.com/spf13/cobra"
 
+	"github.com/daedaleanai/git-ticket/bug/review"
+	"github.com/daedaleanai/git-ticket/identity"
 	"github.com/daedaleanai/git-ticket/input"
 )
 
 type userEditOptions struct {
-	skipPhabId    bool
-	skipGiteaId   bool
-	giteaUserName string
+	skipPhabId     bool
+	skipGiteaId    bool
+	giteaUserName  string
+	githubLogin    string
+	gitlabUsername string
+	gerritUsername string
 }
 
 func newUserEditCommand() *cobra.Command {
@@ -39,6 +44,15 @@ func newUserEditCommand() *cobra.Command {
 	flags.StringVar(&options.giteaUserName, "gitea-username", "",
 		"The username of this user in the Gitea server. Must match exactly one user",
 	)
+	flags.StringVar(&options.githubLogin, "github-login", "",
+		"The login of this user on GitHub, used to fetch GitHub pull requests",
+	)
+	flags.StringVar(&options.gitlabUsername, "gitlab-username", "",
+		"The username of this user on GitLab, used to fetch GitLab merge requests",
+	)
+	flags.StringVar(&options.gerritUsername, "gerrit-username", "",
+		"The username of this user on Gerrit, used to fetch Gerrit changes",
+	)
 
 	return cmd
 }
@@ -77,5 +91,26 @@ func runUserEdit(env *Env, opts userEditOptions, args []string) error {
 		opts.giteaUserName = userName
 	}
 
-	return env.backend.UpdateIdentity(id, name, email, "", avatarURL, opts.skipPhabId, opts.skipGiteaId, opts.giteaUserName)
+	err = env.backend.UpdateIdentity(id, name, email, "", avatarURL, opts.skipPhabId, opts.skipGiteaId, opts.giteaUserName)
+	if err != nil {
+		return err
+	}
+
+	err = id.Mutate(func(mutator identity.Mutator) identity.Mutator {
+		if opts.githubLogin != "" {
+			mutator.Metadata[review.GithubLoginMetadataKey] = opts.githubLogin
+		}
+		if opts.gitlabUsername != "" {
+			mutator.Metadata[review.GitlabUsernameMetadataKey] = opts.gitlabUsername
+		}
+		if opts.gerritUsername != "" {
+			mutator.Metadata[review.GerritUsernameMetadataKey] = opts.gerritUsername
+		}
+		return mutator
+	})
+	if err != nil {
+		return err
+	}
+
+	return id.CommitAsNeeded()
 }
@@ -2,10 +2,10 @@ package commands
 
 import (
 	"fmt"
-	"strconv"
 
 	"github.com/spf13/cobra"
 
+	"github.com/daedaleanai/git-ticket/bug/review"
 	"github.com/daedaleanai/git-ticket/cache"
 	"github.com/daedaleanai/git-ticket/input"
 )
@@ -14,7 +14,7 @@ func newUserFixupCommand() *cobra.Command {
 	env := newEnv()
 	cmd := &cobra.Command{
 		Use:      "fixup",
-		Short:    "Walks through all users and attempts to fetch their gitea identities",
+		Short:    "Walks through all users and attempts to fetch their identities on the review providers, e.g. Gitea",
 		PreRunE:  loadBackend(env),
 		PostRunE: closeBackend(env),
 		Args:     cobra.NoArgs,
@@ -27,54 +27,37 @@ func newUserFixupCommand() *cobra.Command {
 	return cmd
 }
 
-func isGiteaIdError(err error) bool {
-	_, ok := err.(cache.GiteaIdError)
-	return ok
-}
-
-func fixupIdentity(backend *cache.RepoCache, id *cache.IdentityCache) error {
-	if id.GiteaID() != -1 {
-		fmt.Println("Skipping identity ", id.Name())
-		return nil
-	}
+func fixupIdentity(id *cache.IdentityCache) error {
+	updated := false
 
-	// Attempt to update the identity using the user name, without prompting the user.
-	// This will often be sufficient.
-	fmt.Println("Updating identity ", id.Name())
-	err := backend.UpdateIdentity(id, id.Name(), id.Email(), "", id.AvatarUrl(), true, false, id.Name())
-	if err == nil || !isGiteaIdError(err) {
-		return err
-	}
-
-	// If we didn't manage to find the gitea id for the user, let's try this time prompting for the user name
-	fmt.Println("Could not get Gitea ID for ", id.Name(), ", please insert the Gitea user name manually.")
-	userName, err := input.PromptDefault("Gitea Username", "gitea username", id.Name())
-	if err != nil {
-		return err
-	}
-
-	err = backend.UpdateIdentity(id, id.Name(), id.Email(), "", id.AvatarUrl(), true, false, userName)
-	if err == nil || !isGiteaIdError(err) {
-		return err
-	}
+	for _, p := range review.Providers() {
+		if p.FixupIdentity == nil || (p.Configured != nil && !p.Configured()) {
+			continue
+		}
 
-	fmt.Println("I still did not find the user. If you know the Gitea user ID can provide it directly")
-	giteaIdStr, err := input.PromptDefault("Gitea ID", "gitea id", "-1")
-	if err != nil {
-		return err
-	}
+		mutation, err := p.FixupIdentity(id.Identity, func(prompt, name, defaultValue string) (string, error) {
+			return input.PromptDefault(prompt, name, defaultValue)
+		})
+		if err != nil {
+			return fmt.Errorf("Error updating identity %s: %v", id.Name(), err)
+		}
+		if mutation == nil {
+			continue
+		}
 
-	giteaId, err := strconv.ParseInt(giteaIdStr, 0, 64)
-	if err != nil {
-		return fmt.Errorf("The Gitea user ID must be a signed integral number")
+		fmt.Printf("Updating identity %s for %s\n", id.Name(), p.Name)
+		if err := id.Mutate(mutation); err != nil {
+			return err
+		}
+		updated = true
 	}
 
-	err = backend.UpdateIdentityWithGiteaId(id, id.Name(), id.Email(), "", id.AvatarUrl(), true, giteaId)
-	if err != nil {
-		return fmt.Errorf("Error updating identity %s: %v", id.Name(), err)
+	if !updated {
+		fmt.Println("Skipping identity ", id.Name())
+		return nil
 	}
 
-	return nil
+	return id.CommitAsNeeded()
 }
 
 func runUserFixup(env *Env, args []string) error {
@@ -89,7 +72,7 @@ func runUserFixup(env *Env, args []string) error {
 	}
 
 	for _, id := range users {
-		if err := fixupIdentity(env.backend, id); err != nil {
+		if err := fixupIdentity(id); err != nil {
 			return err
 		}
 	}
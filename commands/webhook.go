@@ -0,0 +1,24 @@
+package commands
+
+import "github.com/spf13/cobra"
+
+func newWebhookCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "webhook",
+		Short: "Test the webhooks of the web UI.",
+		Long: `The web UI posts signed JSON payloads of the ticket changes to the webhooks configured in the
+git config:
+
+  git-bug.webhook.<name>.url     URL receiving the payloads
+  git-bug.webhook.<name>.secret  key of the HMAC-SHA256 signature of the payloads, sent in the
+                                 X-Git-Ticket-Signature header
+  git-bug.webhook.<name>.events  optional, among create, update, status, ccb and comment
+
+The deliveries are listed at /admin/webhooks.
+`,
+	}
+
+	cmd.AddCommand(newWebhookReceiveCommand())
+
+	return cmd
+}
@@ -0,0 +1,56 @@
+package commands
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/spf13/cobra"
+
+	"github.com/daedaleanai/git-ticket/webhook"
+)
+
+type webhookReceiveOptions struct {
+	host   string
+	port   int
+	secret string
+}
+
+func newWebhookReceiveCommand() *cobra.Command {
+	env := newEnv()
+	options := webhookReceiveOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "receive",
+		Short: "Receive webhooks locally and print their payloads.",
+		Long: `receive runs a webhook receiver checking the signature of the payloads and printing them, to test
+the webhooks locally.
+`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runWebhookReceive(env, options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.SortFlags = false
+
+	flags.StringVar(&options.host, "host", "localhost", "Host to serve the receiver on")
+	flags.IntVarP(&options.port, "port", "p", 4444, "Port to serve the receiver on")
+	flags.StringVarP(&options.secret, "secret", "s", "", "Secret of the webhook")
+	_ = cmd.MarkFlagRequired("secret")
+
+	return cmd
+}
+
+func runWebhookReceive(env *Env, opts webhookReceiveOptions) error {
+	receiver := &webhook.Receiver{
+		Secret: opts.secret,
+		Handle: func(received webhook.Received) {
+			env.out.Printf("%s %s\n%s\n", received.Event, received.Delivery, received.Payload)
+		},
+	}
+
+	addr := fmt.Sprintf("%s:%d", opts.host, opts.port)
+	env.out.Printf("Receiving webhooks at http://%s/\n", addr)
+	return http.ListenAndServe(addr, receiver)
+}
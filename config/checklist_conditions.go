@@ -0,0 +1,200 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ChecklistCondition restricts a checklist section or question to the tickets it applies to.
+// All the fields which are set must hold for the condition to hold.
+type ChecklistCondition struct {
+	// Label is a regular expression which at least one of the ticket labels must match
+	Label string `json:",omitempty"`
+	// Question references a previous question as "section/question", by section title and
+	// question text or by their numbers
+	Question string `json:",omitempty"`
+	// Answer is the state or the value the referenced question must have, case insensitive
+	Answer string `json:",omitempty"`
+}
+
+// SectionIndex returns the index of the section referenced by its title or its number,
+// or -1 if there is none
+func (c Checklist) SectionIndex(ref string) int {
+	for i, s := range c.Sections {
+		if s.Title == ref {
+			return i
+		}
+	}
+
+	if n, err := strconv.Atoi(ref); err == nil && n >= 1 && n <= len(c.Sections) {
+		return n - 1
+	}
+
+	return -1
+}
+
+// QuestionIndex returns the index of the question referenced by its text or its number,
+// or -1 if there is none
+func (s ChecklistSection) QuestionIndex(ref string) int {
+	for i, q := range s.Questions {
+		if q.Question == ref {
+			return i
+		}
+	}
+
+	if n, err := strconv.Atoi(ref); err == nil && n >= 1 && n <= len(s.Questions) {
+		return n - 1
+	}
+
+	return -1
+}
+
+// lookupQuestion resolves a "section/question" reference into section and question indexes
+func (c Checklist) lookupQuestion(ref string) (int, int, error) {
+	sep := strings.Index(ref, "/")
+	if sep < 0 {
+		return -1, -1, fmt.Errorf("invalid question reference %q, expected section/question", ref)
+	}
+
+	sn := c.SectionIndex(ref[:sep])
+	if sn < 0 {
+		return -1, -1, fmt.Errorf("unknown section in question reference %q", ref)
+	}
+	qn := c.Sections[sn].QuestionIndex(ref[sep+1:])
+	if qn < 0 {
+		return -1, -1, fmt.Errorf("unknown question in question reference %q", ref)
+	}
+
+	return sn, qn, nil
+}
+
+// validateConditions checks that the label patterns compile and that the questions referenced
+// by conditions exist and precede the item holding the condition
+func (c Checklist) validateConditions() error {
+	check := func(conditions []ChecklistCondition, sn, qn int) error {
+		where := fmt.Sprintf("section %d", sn+1)
+		if qn >= 0 {
+			where = fmt.Sprintf("question %d.%d", sn+1, qn+1)
+		}
+
+		for _, cond := range conditions {
+			if cond.Label == "" && cond.Question == "" {
+				return fmt.Errorf("%s: empty condition", where)
+			}
+			if _, err := regexp.Compile(cond.Label); err != nil {
+				return fmt.Errorf("%s: invalid label pattern: %s", where, err)
+			}
+			if cond.Question == "" {
+				continue
+			}
+			refS, refQ, err := c.lookupQuestion(cond.Question)
+			if err != nil {
+				return fmt.Errorf("%s: %s", where, err)
+			}
+			if refS > sn || (refS == sn && (qn < 0 || refQ >= qn)) {
+				return fmt.Errorf("%s: condition on question %q which doesn't precede it", where, cond.Question)
+			}
+		}
+		return nil
+	}
+
+	for sn, s := range c.Sections {
+		if err := check(s.Conditions, sn, -1); err != nil {
+			return err
+		}
+		for qn, q := range s.Questions {
+			if err := check(q.Conditions, sn, qn); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// holds returns true if the condition holds for a ticket with the given labels, the questions
+// referenced being looked up in the checklist evaluated so far
+func (cond ChecklistCondition) holds(labels []Label, c Checklist) bool {
+	if cond.Label != "" {
+		pattern, err := regexp.Compile("^(?:" + cond.Label + ")$")
+		if err != nil {
+			return false
+		}
+
+		matched := false
+		for _, l := range labels {
+			if pattern.MatchString(string(l)) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	if cond.Question != "" {
+		sn, qn, err := c.lookupQuestion(cond.Question)
+		if err != nil {
+			return false
+		}
+
+		// A question which doesn't apply has no answer
+		q := c.Sections[sn].Questions[qn]
+		if c.Sections[sn].Hidden || q.Hidden {
+			return false
+		}
+
+		answer := strings.TrimSpace(cond.Answer)
+		if !strings.EqualFold(answer, q.State.String()) &&
+			!strings.EqualFold(answer, q.State.ShortString()) &&
+			!strings.EqualFold(answer, strings.TrimSpace(q.Value)) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Evaluate returns a copy of the checklist where the sections and questions whose conditions
+// don't hold for a ticket with the given labels, and its current answers, are hidden
+func (c Checklist) Evaluate(labels []Label) Checklist {
+	evaluated := c.Copy()
+
+	holds := func(conditions []ChecklistCondition) bool {
+		for _, cond := range conditions {
+			if !cond.holds(labels, evaluated) {
+				return false
+			}
+		}
+		return true
+	}
+
+	for sn := range evaluated.Sections {
+		s := &evaluated.Sections[sn]
+		s.Hidden = !holds(s.Conditions)
+		for qn := range s.Questions {
+			s.Questions[qn].Hidden = s.Hidden || !holds(s.Questions[qn].Conditions)
+		}
+	}
+
+	return evaluated
+}
+
+// SameVisibility returns true if the same sections and questions are hidden in both checklists,
+// which must have the same structure
+func (c Checklist) SameVisibility(other Checklist) bool {
+	for sn, s := range c.Sections {
+		if s.Hidden != other.Sections[sn].Hidden {
+			return false
+		}
+		for qn, q := range s.Questions {
+			if q.Hidden != other.Sections[sn].Questions[qn].Hidden {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,89 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var conditionalChecklist = Checklist{
+	Label: "checklist:conditional",
+	Title: "Conditional Checklist",
+	Sections: []ChecklistSection{
+		{Title: "General", Questions: []ChecklistQuestion{
+			{Question: "Does the change touch safety critical code?"},
+			{Question: "Was the safety analysis updated?", Conditions: []ChecklistCondition{
+				{Question: "General/Does the change touch safety critical code?", Answer: "passed"},
+			}},
+			{Question: "Was the analysis reviewed?", Conditions: []ChecklistCondition{
+				{Question: "1/2", Answer: "P"},
+			}},
+		}},
+		{Title: "Flight software", Conditions: []ChecklistCondition{{Label: "repo:flight-.*"}}, Questions: []ChecklistQuestion{
+			{Question: "Were the simulations run?"},
+		}},
+	},
+}
+
+func TestChecklistEvaluate(t *testing.T) {
+	hidden := func(cl Checklist) []bool {
+		return []bool{
+			cl.Sections[0].Questions[1].Hidden,
+			cl.Sections[0].Questions[2].Hidden,
+			cl.Sections[1].Hidden,
+			cl.Sections[1].Questions[0].Hidden,
+		}
+	}
+
+	cl := conditionalChecklist.Evaluate([]Label{"repo:ground"})
+	assert.Equal(t, []bool{true, true, true, true}, hidden(cl))
+	assert.False(t, conditionalChecklist.Sections[1].Hidden)
+
+	cl = conditionalChecklist.Evaluate([]Label{"repo:flight-control"})
+	assert.Equal(t, []bool{true, true, false, false}, hidden(cl))
+
+	answered := conditionalChecklist.Copy()
+	answered.Sections[0].Questions[0].State = Passed
+	cl = answered.Evaluate([]Label{"repo:ground"})
+	assert.Equal(t, []bool{false, true, true, true}, hidden(cl))
+	assert.Equal(t, TBD, cl.CompoundState())
+
+	answered.Sections[0].Questions[1].State = Passed
+	answered.Sections[0].Questions[2].State = Passed
+	cl = answered.Evaluate([]Label{"repo:ground"})
+	assert.Equal(t, []bool{false, false, true, true}, hidden(cl))
+	assert.Equal(t, Passed, cl.CompoundState())
+
+	// answers to questions which no longer apply are ignored
+	answered.Sections[0].Questions[0].State = NotApplicable
+	answered.Sections[0].Questions[1].State = Failed
+	cl = answered.Evaluate([]Label{"repo:ground"})
+	assert.Equal(t, []bool{true, true, true, true}, hidden(cl))
+	assert.Equal(t, Passed, cl.CompoundState())
+}
+
+func TestChecklistSameVisibility(t *testing.T) {
+	cl := conditionalChecklist.Evaluate([]Label{"repo:ground"})
+	assert.True(t, cl.SameVisibility(cl.Evaluate([]Label{"repo:ground"})))
+	assert.False(t, cl.SameVisibility(cl.Evaluate([]Label{"repo:flight-control"})))
+
+	// answering a question shows the questions conditioned on it
+	cl.Sections[0].Questions[0].State = Passed
+	assert.False(t, cl.SameVisibility(cl.Evaluate([]Label{"repo:ground"})))
+}
+
+func TestChecklistValidateConditions(t *testing.T) {
+	assert.NoError(t, conditionalChecklist.validateConditions())
+
+	invalid := conditionalChecklist.Copy()
+	invalid.Sections[0].Questions[0].Conditions = []ChecklistCondition{{Question: "1/2", Answer: "passed"}}
+	assert.EqualError(t, invalid.validateConditions(), `question 1.1: condition on question "1/2" which doesn't precede it`)
+
+	invalid = conditionalChecklist.Copy()
+	invalid.Sections[1].Conditions = []ChecklistCondition{{Label: "repo:("}}
+	assert.Error(t, invalid.validateConditions())
+
+	invalid = conditionalChecklist.Copy()
+	invalid.Sections[1].Conditions = []ChecklistCondition{{Question: "General/Unknown?"}}
+	assert.EqualError(t, invalid.validateConditions(), `section 2: unknown question in question reference "General/Unknown?"`)
+}
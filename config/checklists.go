@@ -3,6 +3,9 @@ package config
 import (
 	"encoding/json"
 	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/daedaleanai/git-ticket/repository"
@@ -18,18 +21,52 @@ const (
 	NotApplicable
 )
 
+// ChecklistQuestionType defines the kind of answer expected for a question, in
+// addition to its state
+type ChecklistQuestionType string
+
+const (
+	// StateQuestion only expects a state and an optional comment
+	StateQuestion ChecklistQuestionType = ""
+	// NumericQuestion expects a number, optionally within thresholds
+	NumericQuestion ChecklistQuestionType = "numeric"
+	// TextQuestion expects a free-text evidence
+	TextQuestion ChecklistQuestionType = "text"
+	// LinkQuestion expects one or more links to commits or documents
+	LinkQuestion ChecklistQuestionType = "link"
+)
+
 type ChecklistQuestion struct {
 	Question string
-	Comment  string
-	State    ChecklistState
+	// Type of the answer, a question only expects a state if it's empty
+	Type ChecklistQuestionType `json:",omitempty"`
+	// Min and Max are the thresholds a numeric answer must respect to pass
+	Min *float64 `json:",omitempty"`
+	Max *float64 `json:",omitempty"`
+	// CommentOnFailure requires a comment explaining why the question failed
+	CommentOnFailure bool `json:",omitempty"`
+	Comment          string
+	State            ChecklistState
+	// Value holds the answer of numeric, text and link questions
+	Value string `json:",omitempty"`
+	// Conditions which must all hold for the question to apply to a ticket
+	Conditions []ChecklistCondition `json:",omitempty"`
+	// Hidden is set by Evaluate when the question doesn't apply to the ticket
+	Hidden bool `json:"-"`
 }
 type ChecklistSection struct {
 	Title     string
 	Questions []ChecklistQuestion
+	// Conditions which must all hold for the section to apply to a ticket
+	Conditions []ChecklistCondition `json:",omitempty"`
+	// Hidden is set by Evaluate when the section doesn't apply to the ticket
+	Hidden bool `json:"-"`
 }
 type Checklist struct {
-	Label      Label
-	Title      string
+	Label Label
+	Title string
+	// Version of the checklist template, to be increased every time the questions change
+	Version    int `json:",omitempty"`
 	Deprecated string
 	Sections   []ChecklistSection
 }
@@ -54,9 +91,74 @@ func LoadChecklistConfig(repo repository.ClockedRepo) (ChecklistConfig, error) {
 		return nil, fmt.Errorf("unable to load checklists: %q", err)
 	}
 
+	for label, cl := range checklistStore {
+		if err := cl.validateConditions(); err != nil {
+			return nil, fmt.Errorf("invalid checklist %s: %s", label, err)
+		}
+	}
+
 	return checklistStore, nil
 }
 
+// ChecklistHistory holds every version of the checklist templates ever stored in the
+// configuration, by label and version
+type ChecklistHistory map[Label]map[int]Checklist
+
+// LoadChecklistHistory reads all the revisions of the checklists configuration
+// in the current repository and collects the checklist templates by version.
+// If a version has been edited without increasing its number, the latest revision
+// of that version is kept.
+func LoadChecklistHistory(repo repository.ClockedRepo) (ChecklistHistory, error) {
+	history := make(ChecklistHistory)
+
+	revisions, err := GetConfigHistory(repo, "checklists")
+	if err != nil {
+		if _, ok := err.(*NotFoundError); ok {
+			return history, nil
+		}
+		return nil, fmt.Errorf("unable to read checklists config history: %q", err)
+	}
+
+	for _, data := range revisions {
+		checklistStore := make(map[Label]Checklist)
+
+		err = json.Unmarshal(data, &checklistStore)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load checklists: %q", err)
+		}
+
+		for label, cl := range checklistStore {
+			if history[label] == nil {
+				history[label] = make(map[int]Checklist)
+			}
+			history[label][cl.Version] = cl
+		}
+	}
+
+	return history, nil
+}
+
+// GetChecklistVersion returns the given version of a Checklist template
+func (h ChecklistHistory) GetChecklistVersion(label Label, version int) (Checklist, error) {
+	cl, present := h[label][version]
+
+	if !present {
+		return cl, fmt.Errorf("no version %d of checklist %s", version, label)
+	}
+
+	return cl, nil
+}
+
+// Versions returns the known versions of a Checklist template, in increasing order
+func (h ChecklistHistory) Versions(label Label) []int {
+	var versions []int
+	for v := range h[label] {
+		versions = append(versions, v)
+	}
+	sort.Ints(versions)
+	return versions
+}
+
 // GetChecklist returns a Checklist template out of the store
 func (c ChecklistConfig) GetChecklist(label Label) (Checklist, error) {
 	cl, present := c[label]
@@ -138,13 +240,128 @@ func (s ChecklistState) Validate() error {
 	return nil
 }
 
+var (
+	commitLinkRegex = regexp.MustCompile(`^[0-9a-fA-F]{7,40}$`)
+	urlLinkRegex    = regexp.MustCompile(`^https?://\S+$`)
+)
+
+// Validate returns an error if the type of the question is unknown
+func (t ChecklistQuestionType) Validate() error {
+	switch t {
+	case StateQuestion, NumericQuestion, TextQuestion, LinkQuestion:
+		return nil
+	default:
+		return fmt.Errorf("unknown question type %s", t)
+	}
+}
+
+// Thresholds returns a human readable description of the range a numeric answer must
+// be in, or an empty string if there is none
+func (q ChecklistQuestion) Thresholds() string {
+	switch {
+	case q.Min != nil && q.Max != nil:
+		return fmt.Sprintf("%g to %g", *q.Min, *q.Max)
+	case q.Min != nil:
+		return fmt.Sprintf(">= %g", *q.Min)
+	case q.Max != nil:
+		return fmt.Sprintf("<= %g", *q.Max)
+	default:
+		return ""
+	}
+}
+
+// ValidateAnswer checks that the answer given to the question complies with its type:
+// numeric answers must be numbers within the thresholds to pass, text and link questions
+// need a value to pass, links must be URLs or commit hashes, and questions requiring it
+// must have a comment when failed. Questions still TBD are not checked.
+func (q ChecklistQuestion) ValidateAnswer() error {
+	if err := q.Type.Validate(); err != nil {
+		return err
+	}
+	if err := q.State.Validate(); err != nil {
+		return fmt.Errorf("invalid state")
+	}
+
+	if q.State == TBD {
+		return nil
+	}
+
+	if q.State == Failed && q.CommentOnFailure && strings.TrimSpace(q.Comment) == "" {
+		return fmt.Errorf("a comment is required when failed")
+	}
+
+	value := strings.TrimSpace(q.Value)
+
+	switch q.Type {
+	case NumericQuestion:
+		if value == "" {
+			if q.State == Passed {
+				return fmt.Errorf("a numeric value is required")
+			}
+			return nil
+		}
+		number, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("value %s is not a number", value)
+		}
+		if q.State == Passed && ((q.Min != nil && number < *q.Min) || (q.Max != nil && number > *q.Max)) {
+			return fmt.Errorf("value %s is out of the %s thresholds, it can't pass", value, q.Thresholds())
+		}
+	case TextQuestion:
+		if value == "" && q.State == Passed {
+			return fmt.Errorf("an evidence is required")
+		}
+	case LinkQuestion:
+		if value == "" && q.State == Passed {
+			return fmt.Errorf("a link is required")
+		}
+		for _, link := range strings.Fields(value) {
+			if !commitLinkRegex.MatchString(link) && !urlLinkRegex.MatchString(link) {
+				return fmt.Errorf("%s is neither a URL nor a commit hash", link)
+			}
+		}
+	default:
+		if value != "" {
+			return fmt.Errorf("this question does not expect a value")
+		}
+	}
+
+	return nil
+}
+
+// ValidateAnswers checks the answer of every visible question of the checklist and
+// returns the errors found, prefixed with the section and question numbers
+func (c Checklist) ValidateAnswers() []error {
+	var errs []error
+	for sn, s := range c.Sections {
+		if s.Hidden {
+			continue
+		}
+		for qn, q := range s.Questions {
+			if q.Hidden {
+				continue
+			}
+			if err := q.ValidateAnswer(); err != nil {
+				errs = append(errs, fmt.Errorf("question %d.%d: %s", sn+1, qn+1, err))
+			}
+		}
+	}
+	return errs
+}
+
 // CompoundState returns an overall state for the checklist given the state of
 // each of the questions. If any of the questions are Failed then the checklist
-// Failed, else if any are TBD it's TBD, else it's Passed
+// Failed, else if any are TBD it's TBD, else it's Passed. Hidden questions are ignored.
 func (c Checklist) CompoundState() ChecklistState {
 	var tbdCount, failedCount int
 	for _, s := range c.Sections {
+		if s.Hidden {
+			continue
+		}
 		for _, q := range s.Questions {
+			if q.Hidden {
+				continue
+			}
 			switch q.State {
 			case TBD:
 				tbdCount++
@@ -165,13 +382,132 @@ func (c Checklist) CompoundState() ChecklistState {
 	return Passed
 }
 
+// Copy returns a deep copy of the checklist, which can be answered without altering the original
+func (c Checklist) Copy() Checklist {
+	cp := c
+	cp.Sections = make([]ChecklistSection, len(c.Sections))
+	for sn, s := range c.Sections {
+		cp.Sections[sn] = s
+		cp.Sections[sn].Questions = append([]ChecklistQuestion(nil), s.Questions...)
+	}
+	return cp
+}
+
+// ChecklistQuestionDiff is a question added to or removed from a checklist template
+type ChecklistQuestionDiff struct {
+	Section  string
+	Question string
+	// Added is set if the question is new, else it has been removed
+	Added bool
+}
+
+// findQuestion looks up a question by its text in the section with the given title
+func (c Checklist) findQuestion(section, question string) (ChecklistQuestion, bool) {
+	for _, s := range c.Sections {
+		if s.Title != section {
+			continue
+		}
+		for _, q := range s.Questions {
+			if q.Question == question {
+				return q, true
+			}
+		}
+	}
+	return ChecklistQuestion{}, false
+}
+
+// DiffChecklists returns the questions removed from and added to the from checklist
+// to obtain the to checklist. Questions are identified by their section title and text,
+// so a reworded question shows up as removed and added.
+func DiffChecklists(from, to Checklist) []ChecklistQuestionDiff {
+	var diff []ChecklistQuestionDiff
+
+	// Sections are listed in the order of the newer checklist, followed by the removed ones
+	var sections []string
+	seen := make(map[string]bool)
+	for _, cl := range []Checklist{to, from} {
+		for _, s := range cl.Sections {
+			if !seen[s.Title] {
+				seen[s.Title] = true
+				sections = append(sections, s.Title)
+			}
+		}
+	}
+
+	for _, section := range sections {
+		for _, s := range from.Sections {
+			if s.Title != section {
+				continue
+			}
+			for _, q := range s.Questions {
+				if _, present := to.findQuestion(s.Title, q.Question); !present {
+					diff = append(diff, ChecklistQuestionDiff{Section: s.Title, Question: q.Question})
+				}
+			}
+		}
+		for _, s := range to.Sections {
+			if s.Title != section {
+				continue
+			}
+			for _, q := range s.Questions {
+				if _, present := from.findQuestion(s.Title, q.Question); !present {
+					diff = append(diff, ChecklistQuestionDiff{Section: s.Title, Question: q.Question, Added: true})
+				}
+			}
+		}
+	}
+
+	return diff
+}
+
+// Upgrade returns a copy of the template where the answers of the questions left
+// unchanged since this checklist version are migrated, all the new or changed
+// questions, including those which changed type, are TBD. It also returns the number of questions which have been reset.
+func (c Checklist) Upgrade(template Checklist) (Checklist, int) {
+	var reset int
+
+	upgraded := template
+	upgraded.Sections = make([]ChecklistSection, len(template.Sections))
+
+	for sn, s := range template.Sections {
+		upgraded.Sections[sn] = s
+		upgraded.Sections[sn].Questions = make([]ChecklistQuestion, len(s.Questions))
+		for qn, q := range s.Questions {
+			if answer, present := c.findQuestion(s.Title, q.Question); present && answer.Type == q.Type {
+				q.Comment = answer.Comment
+				q.State = answer.State
+				q.Value = answer.Value
+			} else {
+				q.State = TBD
+				reset++
+			}
+			upgraded.Sections[sn].Questions[qn] = q
+		}
+	}
+
+	return upgraded, reset
+}
+
 func (c Checklist) String() string {
-	result := fmt.Sprintf("%s [%s]\n", c.Title, c.CompoundState().ColorString())
+	title := c.Title
+	if c.Version != 0 {
+		title = fmt.Sprintf("%s (version %d)", c.Title, c.Version)
+	}
+	result := fmt.Sprintf("%s [%s]\n", title, c.CompoundState().ColorString())
 
 	for sn, s := range c.Sections {
+		if s.Hidden {
+			continue
+		}
 		result = result + fmt.Sprintf("#### %s ####\n", s.Title)
 		for qn, q := range s.Questions {
+			if q.Hidden {
+				continue
+			}
 			result = result + fmt.Sprintf("(%d.%d) %s [%s]\n", sn+1, qn+1, q.Question, q.State.ColorString())
+			if answer := q.answerString(); answer != "" {
+				result = result + fmt.Sprintf("= %s\n", answer)
+			}
 			if q.Comment != "" {
 				result = result + fmt.Sprintf("# %s\n", strings.Replace(q.Comment, "\n", "\n# ", -1))
 			}
@@ -179,3 +515,24 @@ func (c Checklist) String() string {
 	}
 	return result
 }
+
+// answerString returns the value of a typed question formatted for display
+func (q ChecklistQuestion) answerString() string {
+	if q.Value == "" {
+		return ""
+	}
+
+	switch q.Type {
+	case NumericQuestion:
+		if thresholds := q.Thresholds(); thresholds != "" {
+			return fmt.Sprintf("%s (expected %s)", q.Value, thresholds)
+		}
+		return q.Value
+	case TextQuestion:
+		return strings.Replace(q.Value, "\n", "\n  ", -1)
+	case LinkQuestion:
+		return colors.Cyan(strings.Join(strings.Fields(q.Value), " "))
+	default:
+		return q.Value
+	}
+}
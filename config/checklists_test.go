@@ -0,0 +1,112 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var checklistV1 = Checklist{
+	Label:   "checklist:test",
+	Title:   "Test Checklist",
+	Version: 1,
+	Sections: []ChecklistSection{
+		{Title: "Design", Questions: []ChecklistQuestion{
+			{Question: "Is the design documented?"},
+			{Question: "Was the design reviewed?"},
+		}},
+		{Title: "Code", Questions: []ChecklistQuestion{
+			{Question: "Are there unit tests?"},
+		}},
+	},
+}
+
+var checklistV2 = Checklist{
+	Label:   "checklist:test",
+	Title:   "Test Checklist",
+	Version: 2,
+	Sections: []ChecklistSection{
+		{Title: "Design", Questions: []ChecklistQuestion{
+			{Question: "Is the design documented?"},
+			{Question: "Was the design reviewed by a second engineer?"},
+		}},
+		{Title: "Code", Questions: []ChecklistQuestion{
+			{Question: "Are there unit tests?"},
+			{Question: "Is the coverage above 80%?"},
+		}},
+	},
+}
+
+func TestDiffChecklists(t *testing.T) {
+	diff := DiffChecklists(checklistV1, checklistV2)
+
+	assert.Equal(t, []ChecklistQuestionDiff{
+		{Section: "Design", Question: "Was the design reviewed?"},
+		{Section: "Design", Question: "Was the design reviewed by a second engineer?", Added: true},
+		{Section: "Code", Question: "Is the coverage above 80%?", Added: true},
+	}, diff)
+
+	assert.Empty(t, DiffChecklists(checklistV2, checklistV2))
+}
+
+func TestChecklistUpgrade(t *testing.T) {
+	answered := checklistV1
+	answered.Sections = []ChecklistSection{
+		{Title: "Design", Questions: []ChecklistQuestion{
+			{Question: "Is the design documented?", State: Passed, Comment: "see doc"},
+			{Question: "Was the design reviewed?", State: Passed},
+		}},
+		{Title: "Code", Questions: []ChecklistQuestion{
+			{Question: "Are there unit tests?", State: Failed, Comment: "missing"},
+		}},
+	}
+
+	upgraded, reset := answered.Upgrade(checklistV2)
+
+	assert.Equal(t, 2, reset)
+	assert.Equal(t, 2, upgraded.Version)
+	assert.Equal(t, ChecklistQuestion{Question: "Is the design documented?", State: Passed, Comment: "see doc"}, upgraded.Sections[0].Questions[0])
+	assert.Equal(t, TBD, upgraded.Sections[0].Questions[1].State)
+	assert.Equal(t, ChecklistQuestion{Question: "Are there unit tests?", State: Failed, Comment: "missing"}, upgraded.Sections[1].Questions[0])
+	assert.Equal(t, TBD, upgraded.Sections[1].Questions[1].State)
+
+	// the template must not be modified
+	assert.Equal(t, TBD, checklistV2.Sections[0].Questions[0].State)
+}
+
+func TestChecklistQuestionValidateAnswer(t *testing.T) {
+	min, max := 80.0, 100.0
+
+	tests := []struct {
+		name     string
+		question ChecklistQuestion
+		valid    bool
+	}{
+		{"tbd is not checked", ChecklistQuestion{Type: NumericQuestion, State: TBD, Value: "abc"}, true},
+		{"numeric in range", ChecklistQuestion{Type: NumericQuestion, Min: &min, Max: &max, State: Passed, Value: "85.5"}, true},
+		{"numeric out of range passed", ChecklistQuestion{Type: NumericQuestion, Min: &min, Max: &max, State: Passed, Value: "42"}, false},
+		{"numeric out of range failed", ChecklistQuestion{Type: NumericQuestion, Min: &min, Max: &max, State: Failed, Value: "42"}, true},
+		{"numeric missing", ChecklistQuestion{Type: NumericQuestion, State: Passed}, false},
+		{"numeric not a number", ChecklistQuestion{Type: NumericQuestion, State: Failed, Value: "many"}, false},
+		{"text evidence", ChecklistQuestion{Type: TextQuestion, State: Passed, Value: "reviewed by QA"}, true},
+		{"text evidence missing", ChecklistQuestion{Type: TextQuestion, State: Passed}, false},
+		{"text evidence not applicable", ChecklistQuestion{Type: TextQuestion, State: NotApplicable}, true},
+		{"links", ChecklistQuestion{Type: LinkQuestion, State: Passed, Value: "https://example.com/doc 1a2b3c4d"}, true},
+		{"invalid link", ChecklistQuestion{Type: LinkQuestion, State: Passed, Value: "somewhere"}, false},
+		{"comment on failure", ChecklistQuestion{CommentOnFailure: true, State: Failed, Comment: "broken"}, true},
+		{"missing comment on failure", ChecklistQuestion{CommentOnFailure: true, State: Failed}, false},
+		{"unexpected value", ChecklistQuestion{State: Passed, Value: "42"}, false},
+		{"unknown type", ChecklistQuestion{Type: "date", State: Passed}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.question.ValidateAnswer()
+			if test.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
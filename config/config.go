@@ -121,6 +121,35 @@ func GetConfig(repo repository.ClockedRepo, name string) ([]byte, error) {
 		return nil, &NotFoundError{fmt.Sprintf("cache: failed to resolve ref %s: %s", refName, err)}
 	}
 
+	return readConfigCommit(repo, refName, commitHash)
+}
+
+// GetConfigHistory returns every revision of the named configuration data, oldest first
+func GetConfigHistory(repo repository.ClockedRepo, name string) ([][]byte, error) {
+	refName := configRefPattern + name
+	if _, err := repo.ResolveRef(refName); err != nil {
+		return nil, &NotFoundError{fmt.Sprintf("cache: failed to resolve ref %s: %s", refName, err)}
+	}
+
+	commits, err := repo.ListCommits(refName)
+	if err != nil {
+		return nil, fmt.Errorf("cache: failed to list commits of ref %s: %s", refName, err)
+	}
+
+	history := make([][]byte, 0, len(commits))
+	for _, commitHash := range commits {
+		data, err := readConfigCommit(repo, refName, commitHash)
+		if err != nil {
+			return nil, err
+		}
+		history = append(history, data)
+	}
+
+	return history, nil
+}
+
+// readConfigCommit returns the configuration data stored in the given commit
+func readConfigCommit(repo repository.ClockedRepo, refName string, commitHash repository.Hash) ([]byte, error) {
 	treeHash, err := repo.GetTreeHash(commitHash)
 	if err != nil {
 		return nil, fmt.Errorf("cache: failed to get the tree for commit %s (ref: %s): %s", commitHash, refName, err)
@@ -145,8 +174,8 @@ func GetConfig(repo repository.ClockedRepo, name string) ([]byte, error) {
 		}
 	}
 	return nil, fmt.Errorf(
-		`cache: failed to find "config.json" blob in the tree corresponding to the HEAD of %s`,
-		refName)
+		`cache: failed to find "config.json" blob in the tree of commit %s (ref: %s)`,
+		commitHash, refName)
 }
 
 // UpdateConfigs fetches config data from the remote and updates the local references.
@@ -2,6 +2,8 @@ package entity
 
 import (
 	"fmt"
+
+	"github.com/daedaleanai/git-ticket/repository"
 )
 
 // MergeStatus represent the result of a merge operation of an entity
@@ -72,3 +74,38 @@ func NewMergeInvalidStatus(id Id, reason string) MergeResult {
 		Reason: reason,
 	}
 }
+
+// FastForwardRef points the ref of an entity to the given commit if the ref doesn't exist yet,
+// or if the commit descends from the one it points to. Diverging histories are refused.
+func FastForwardRef(repo repository.Repo, ref string, commit repository.Hash) (MergeStatus, error) {
+	exist, err := repo.RefExist(ref)
+	if err != nil {
+		return MergeStatusError, err
+	}
+	if !exist {
+		return MergeStatusNew, repo.UpdateRef(ref, commit)
+	}
+
+	current, err := repo.ResolveRef(ref)
+	if err != nil {
+		return MergeStatusError, err
+	}
+	if current == commit {
+		return MergeStatusNothing, nil
+	}
+
+	ancestor, err := repo.FindCommonAncestor(current, commit)
+	if err != nil {
+		return MergeStatusError, err
+	}
+
+	switch ancestor {
+	case current:
+		return MergeStatusUpdated, repo.UpdateRef(ref, commit)
+	case commit:
+		// the local history already contains the commit
+		return MergeStatusNothing, nil
+	default:
+		return MergeStatusInvalid, fmt.Errorf("%s has diverged from the local history", ref)
+	}
+}
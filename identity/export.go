@@ -0,0 +1,106 @@
+package identity
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	"github.com/daedaleanai/git-ticket/entity"
+	"github.com/daedaleanai/git-ticket/repository"
+)
+
+// ExportedVersion holds a Version of an identity with everything needed to store the exact same
+// git commit again in another repository, so that the identity keeps its id and its history.
+type ExportedVersion struct {
+	// Commit is the hash of the git commit of the version
+	Commit repository.Hash `json:"commit"`
+	// CommitData is the raw git commit, with its author, date and signature
+	CommitData []byte          `json:"commit_data"`
+	Version    json.RawMessage `json:"version"`
+}
+
+// Export reads all the versions of a local identity as stored in git
+func Export(repo repository.Repo, id entity.Id) ([]ExportedVersion, error) {
+	hashes, err := repo.ListCommits(identityRefPattern + id.String())
+	if err != nil {
+		return nil, ErrIdentityNotExist
+	}
+
+	var versions []ExportedVersion
+
+	for _, hash := range hashes {
+		version := ExportedVersion{Commit: hash}
+
+		version.CommitData, err = repo.ReadCommitData(hash)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read git commit")
+		}
+
+		entries, err := repo.ReadTree(hash)
+		if err != nil {
+			return nil, errors.Wrap(err, "can't list git tree entries")
+		}
+		if len(entries) != 1 || entries[0].Name != versionEntryName {
+			return nil, fmt.Errorf("invalid identity data at hash %s", hash)
+		}
+
+		version.Version, err = repo.ReadData(entries[0].Hash)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read git blob data")
+		}
+
+		versions = append(versions, version)
+	}
+
+	return versions, nil
+}
+
+// Import stores the exported versions of an identity, and creates its ref or fast-forwards it if
+// the local identity is behind. The git commits are stored exactly as they were exported, or not
+// at all.
+func Import(repo repository.ClockedRepo, id entity.Id, versions []ExportedVersion) (entity.MergeStatus, error) {
+	if len(versions) == 0 {
+		return entity.MergeStatusInvalid, errors.New("identity has no version")
+	}
+	if versions[0].Commit.String() != id.String() {
+		return entity.MergeStatusInvalid, fmt.Errorf("identity id should be the first commit hash")
+	}
+
+	for _, v := range versions {
+		var version Version
+		if err := json.Unmarshal(v.Version, &version); err != nil {
+			return entity.MergeStatusInvalid, errors.Wrapf(err, "failed to decode Identity version json %s", v.Commit)
+		}
+
+		blobHash, err := repo.StoreData(v.Version)
+		if err != nil {
+			return entity.MergeStatusError, err
+		}
+
+		treeHash, err := repo.StoreTree([]repository.TreeEntry{
+			{ObjectType: repository.Blob, Hash: blobHash, Name: versionEntryName},
+		})
+		if err != nil {
+			return entity.MergeStatusError, err
+		}
+
+		commit, err := repo.StoreCommitData(v.CommitData)
+		if err != nil {
+			return entity.MergeStatusError, err
+		}
+		if commit != v.Commit {
+			return entity.MergeStatusInvalid, fmt.Errorf("commit %s doesn't match its content", v.Commit)
+		}
+
+		commitTree, err := repo.GetTreeHash(commit)
+		if err != nil {
+			return entity.MergeStatusError, err
+		}
+		if commitTree != treeHash {
+			return entity.MergeStatusInvalid, fmt.Errorf("commit %s doesn't match its version", v.Commit)
+		}
+	}
+
+	return entity.FastForwardRef(repo, identityRefPattern+id.String(), versions[len(versions)-1].Commit)
+}
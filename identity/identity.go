@@ -288,6 +288,9 @@ type Mutator struct {
 	PhabID    string
 	GiteaID   int64
 	Keys      []*Key
+	// Metadata holds the mutable metadata of the identity, only the changed values are
+	// stored in the new version
+	Metadata map[string]string
 }
 
 // Mutate allow to create a new version of the Identity in one go
@@ -300,11 +303,25 @@ func (i *Identity) Mutate(f func(orig Mutator) Mutator) {
 		PhabID:    i.PhabID(),
 		GiteaID:   i.GiteaID(),
 		Keys:      i.Keys(),
+		Metadata:  i.MutableMetadata(),
 	}
-	mutated := f(orig)
+	arg := orig
+	arg.Metadata = i.MutableMetadata()
+	mutated := f(arg)
 	if reflect.DeepEqual(orig, mutated) {
 		return
 	}
+
+	var metadata map[string]string
+	for key, value := range mutated.Metadata {
+		if old, has := orig.Metadata[key]; !has || old != value {
+			if metadata == nil {
+				metadata = make(map[string]string)
+			}
+			metadata[key] = value
+		}
+	}
+
 	i.versions = append(i.versions, &Version{
 		name:      mutated.Name,
 		email:     mutated.Email,
@@ -313,6 +330,7 @@ func (i *Identity) Mutate(f func(orig Mutator) Mutator) {
 		phabID:    mutated.PhabID,
 		giteaID:   mutated.GiteaID,
 		keys:      mutated.Keys,
+		metadata:  metadata,
 	})
 }
 
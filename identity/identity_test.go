@@ -162,6 +162,23 @@ func TestIdentityMutate(t *testing.T) {
 	assert.Equal(t, identity.Email(), "rene@descartes.fr")
 	assert.Equal(t, identity.Name(), "René")
 	assert.Equal(t, identity.Login(), "rene")
+
+	identity.SetMetadata("key1", "value1")
+	identity.Mutate(func(orig Mutator) Mutator {
+		orig.Metadata["key2"] = "value2"
+		return orig
+	})
+
+	assert.Len(t, identity.versions, 3)
+	assert.Equal(t, map[string]string{"key2": "value2"}, identity.versions[2].metadata)
+	assert.Equal(t, map[string]string{"key1": "value1", "key2": "value2"}, identity.MutableMetadata())
+
+	// unchanged metadata doesn't create a new version
+	identity.Mutate(func(orig Mutator) Mutator {
+		orig.Metadata["key2"] = "value2"
+		return orig
+	})
+	assert.Len(t, identity.versions, 3)
 }
 
 func commitsAreSet(t *testing.T, identity *Identity) {
@@ -72,8 +72,10 @@ func (v *Version) Clone() *Version {
 	clone := &Version{
 		name:      v.name,
 		email:     v.email,
+		login:     v.login,
 		avatarURL: v.avatarURL,
 		phabID:    v.phabID,
+		giteaID:   v.giteaID,
 		keys:      make([]*Key, len(v.keys)),
 	}
 
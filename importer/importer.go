@@ -0,0 +1,60 @@
+// Package importer implements the one-off imports of tickets from other bug trackers
+package importer
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/daedaleanai/git-ticket/bug"
+	"github.com/daedaleanai/git-ticket/cache"
+)
+
+// setStatus sets the status of the ticket if it's not already the one. The workflows of the other
+// bug trackers don't follow the ones of git-ticket, so a change the workflow rejects, e.g. for a
+// lack of CCB approval, is forced as it already happened. The metadata identifying the change
+// imported is recorded by a no-op if the status doesn't change, so that it isn't applied again by
+// the next import.
+func setStatus(b *cache.BugCache, author *cache.IdentityCache, unixTime int64, status bug.Status, metadata map[string]string) error {
+	if b.Snapshot().Status == status {
+		if len(metadata) == 0 {
+			return nil
+		}
+		_, err := b.NoopRaw(author, unixTime, metadata)
+		return err
+	}
+	if _, err := b.SetStatusRaw(author, unixTime, metadata, status); err == nil {
+		return nil
+	}
+	_, err := b.ForceSetStatusRaw(author, unixTime, metadata, status)
+	return err
+}
+
+// setLabels adds the labels, prefixed, the ticket doesn't have and removes its other labels with
+// the prefix
+func setLabels(b *cache.BugCache, author *cache.IdentityCache, unixTime int64, prefix string, labels []string) error {
+	snap := b.Snapshot()
+
+	wanted := make(map[string]bool)
+	for _, l := range labels {
+		wanted[prefix+l] = true
+	}
+
+	var added, removed []string
+	for l := range wanted {
+		if !snap.HasLabel(bug.Label(l)) {
+			added = append(added, l)
+		}
+	}
+	for _, l := range snap.Labels {
+		if strings.HasPrefix(string(l), prefix) && !wanted[string(l)] {
+			removed = append(removed, string(l))
+		}
+	}
+	if len(added) == 0 && len(removed) == 0 {
+		return nil
+	}
+
+	sort.Strings(added)
+	_, err := b.ForceChangeLabelsRaw(author, unixTime, added, removed, nil)
+	return err
+}
@@ -0,0 +1,674 @@
+package importer
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/daedaleanai/git-ticket/bridge"
+	"github.com/daedaleanai/git-ticket/bug"
+	"github.com/daedaleanai/git-ticket/cache"
+	"github.com/daedaleanai/git-ticket/identity"
+)
+
+// The metadata of the operations imported from Jira
+const (
+	// jiraKeyMetadataKey is the key of the issue, e.g. ROCK-12, on the create operations
+	jiraKeyMetadataKey = "jira-key"
+	// jiraCommentMetadataKey is the id of a comment, on the comment operations
+	jiraCommentMetadataKey = "jira-comment-id"
+	// jiraHistoryMetadataKey is the id of the change log entry a status change was imported from
+	jiraHistoryMetadataKey = "jira-history-id"
+	// jiraUserMetadataKey is the key of the Jira user an identity was created for
+	jiraUserMetadataKey = "jira-user"
+)
+
+// jiraLabelPrefix prefixes the Jira labels of the issues in the labels of the tickets
+const jiraLabelPrefix = "jira:"
+
+// JiraMapping maps the statuses of the issues of a Jira project to the ones of a workflow, and
+// optionally its users to the emails of identities
+type JiraMapping struct {
+	// Workflow is the workflow label of the imported tickets
+	Workflow string `json:"workflow"`
+	// Repo is the repo label of the imported tickets
+	Repo string `json:"repo"`
+	// Statuses maps the names of the Jira statuses to the ones of the workflow
+	Statuses map[string]string `json:"statuses"`
+	// Users maps the Jira users, by account id, username or display name, to the emails of
+	// existing identities
+	Users map[string]string `json:"users"`
+
+	statuses map[string]bug.Status
+}
+
+// LoadJiraMapping reads a mapping file and checks that the statuses belong to the workflow
+func LoadJiraMapping(path string) (*JiraMapping, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var mapping JiraMapping
+	if err := json.Unmarshal(data, &mapping); err != nil {
+		return nil, errors.Wrapf(err, "invalid mapping file %s", path)
+	}
+
+	if mapping.Repo == "" {
+		return nil, fmt.Errorf("the mapping file %s doesn't set the repo label", path)
+	}
+	workflow := bug.FindWorkflow([]bug.Label{bug.Label(mapping.Workflow)})
+	if workflow == nil {
+		return nil, fmt.Errorf("the mapping file %s doesn't set a valid workflow, valid workflows are %v", path, bug.GetWorkflowLabels())
+	}
+
+	mapping.statuses = make(map[string]bug.Status)
+	for name, value := range mapping.Statuses {
+		status, err := bug.StatusFromString(value)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Jira status %s", name)
+		}
+		valid := false
+		for _, s := range workflow.AllStatuses() {
+			valid = valid || s == status
+		}
+		if !valid {
+			return nil, fmt.Errorf("Jira status %s: %s isn't a status of %s", name, status, mapping.Workflow)
+		}
+		mapping.statuses[name] = status
+	}
+
+	return &mapping, nil
+}
+
+// status returns the status of the workflow the Jira status is mapped to
+func (m *JiraMapping) status(name string) (bug.Status, error) {
+	if status, ok := m.statuses[name]; ok {
+		return status, nil
+	}
+	return 0, fmt.Errorf("the Jira status %q isn't mapped", name)
+}
+
+// jiraUser is a user of a Jira export
+type jiraUser struct {
+	// Key is the account id, or the username of the servers, identifying the user
+	Key   string
+	Login string
+	Name  string
+	Email string
+}
+
+type jiraComment struct {
+	Id      string
+	Author  jiraUser
+	Body    string
+	Created time.Time
+	Updated time.Time
+}
+
+type jiraStatusChange struct {
+	Id      string
+	Author  jiraUser
+	Created time.Time
+	Status  string
+}
+
+// jiraIssue is an issue read from a Jira export
+type jiraIssue struct {
+	Key           string
+	Summary       string
+	Description   string
+	Status        string
+	Reporter      jiraUser
+	Assignee      *jiraUser
+	Created       time.Time
+	Updated       time.Time
+	Labels        []string
+	Comments      []jiraComment
+	StatusChanges []jiraStatusChange
+}
+
+// ImportJira imports the issues of a Jira export, read as JSON or CSV according to the extension
+// of the file. The issues already imported are updated with the comments and status changes they
+// didn't have, so the import can be run again on a later export.
+func ImportJira(repo *cache.RepoCache, path string, mapping *JiraMapping) (bridge.Result, error) {
+	var result bridge.Result
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return result, err
+	}
+
+	var issues []jiraIssue
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		issues, err = parseJiraJSON(data)
+	case ".csv":
+		issues, err = parseJiraCSV(data)
+	default:
+		return result, fmt.Errorf("unknown format of the Jira export %s, expected a .json or .csv file", path)
+	}
+	if err != nil {
+		return result, errors.Wrapf(err, "invalid Jira export %s", path)
+	}
+
+	user, err := repo.GetUserIdentity()
+	if err != nil {
+		return result, err
+	}
+
+	ji := &jiraImporter{repo: repo, mapping: mapping, user: user, emails: jiraEmails(issues)}
+
+	for _, issue := range issues {
+		created, updated, err := ji.importIssue(issue)
+		if err != nil {
+			return result, errors.Wrapf(err, "issue %s", issue.Key)
+		}
+
+		switch {
+		case created:
+			result.Created++
+		case updated:
+			result.Updated++
+		default:
+			result.Unchanged++
+		}
+	}
+
+	return result, nil
+}
+
+// jiraTime is a timestamp of the JSON exports, e.g. 2021-03-04T10:00:00.000+0000
+type jiraTime struct {
+	time.Time
+}
+
+func (t *jiraTime) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	if value == "" {
+		return nil
+	}
+
+	for _, layout := range []string{"2006-01-02T15:04:05.000-0700", time.RFC3339} {
+		if parsed, err := time.Parse(layout, value); err == nil {
+			t.Time = parsed
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid time %q", value)
+}
+
+type jiraJSONUser struct {
+	AccountId    string `json:"accountId"`
+	Name         string `json:"name"`
+	EmailAddress string `json:"emailAddress"`
+	DisplayName  string `json:"displayName"`
+}
+
+func (u *jiraJSONUser) user() jiraUser {
+	if u == nil {
+		return jiraUser{}
+	}
+	key := u.AccountId
+	if key == "" {
+		key = u.Name
+	}
+	return jiraUser{Key: key, Login: u.Name, Name: u.DisplayName, Email: u.EmailAddress}
+}
+
+// jiraJSONIssue is an issue of the search API of Jira, as exported with the comments and the
+// change log expanded
+type jiraJSONIssue struct {
+	Key    string `json:"key"`
+	Fields struct {
+		Summary     string `json:"summary"`
+		Description string `json:"description"`
+		Status      struct {
+			Name string `json:"name"`
+		} `json:"status"`
+		Reporter *jiraJSONUser `json:"reporter"`
+		Assignee *jiraJSONUser `json:"assignee"`
+		Created  jiraTime      `json:"created"`
+		Updated  jiraTime      `json:"updated"`
+		Labels   []string      `json:"labels"`
+		Comment  struct {
+			Comments []struct {
+				Id      string        `json:"id"`
+				Author  *jiraJSONUser `json:"author"`
+				Body    string        `json:"body"`
+				Created jiraTime      `json:"created"`
+				Updated jiraTime      `json:"updated"`
+			} `json:"comments"`
+		} `json:"comment"`
+	} `json:"fields"`
+	Changelog struct {
+		Histories []struct {
+			Id      string        `json:"id"`
+			Author  *jiraJSONUser `json:"author"`
+			Created jiraTime      `json:"created"`
+			Items   []struct {
+				Field      string `json:"field"`
+				FromString string `json:"fromString"`
+				ToString   string `json:"toString"`
+			} `json:"items"`
+		} `json:"histories"`
+	} `json:"changelog"`
+}
+
+// parseJiraJSON reads the issues of a JSON export, either a search result or a list of issues
+func parseJiraJSON(data []byte) ([]jiraIssue, error) {
+	var raw []jiraJSONIssue
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return nil, err
+		}
+	} else {
+		var search struct {
+			Issues []jiraJSONIssue `json:"issues"`
+		}
+		if err := json.Unmarshal(data, &search); err != nil {
+			return nil, err
+		}
+		raw = search.Issues
+	}
+
+	issues := make([]jiraIssue, 0, len(raw))
+	for _, r := range raw {
+		issue := jiraIssue{
+			Key:         r.Key,
+			Summary:     r.Fields.Summary,
+			Description: r.Fields.Description,
+			Status:      r.Fields.Status.Name,
+			Reporter:    r.Fields.Reporter.user(),
+			Created:     r.Fields.Created.Time,
+			Updated:     r.Fields.Updated.Time,
+			Labels:      r.Fields.Labels,
+		}
+		if r.Fields.Assignee != nil {
+			assignee := r.Fields.Assignee.user()
+			issue.Assignee = &assignee
+		}
+
+		for _, c := range r.Fields.Comment.Comments {
+			issue.Comments = append(issue.Comments, jiraComment{
+				Id:      c.Id,
+				Author:  c.Author.user(),
+				Body:    c.Body,
+				Created: c.Created.Time,
+				Updated: c.Updated.Time,
+			})
+		}
+
+		for _, h := range r.Changelog.Histories {
+			for _, item := range h.Items {
+				if item.Field != "status" {
+					continue
+				}
+				issue.StatusChanges = append(issue.StatusChanges, jiraStatusChange{
+					Id:      h.Id,
+					Author:  h.Author.user(),
+					Created: h.Created.Time,
+					Status:  item.ToString,
+				})
+			}
+		}
+
+		issues = append(issues, issue)
+	}
+
+	return issues, nil
+}
+
+// parseJiraCSVTime parses a timestamp of the CSV exports, which use the date format of the
+// Jira instance, e.g. 04/Mar/21 10:00 AM
+func parseJiraCSVTime(value string) (time.Time, error) {
+	for _, layout := range []string{"02/Jan/06 3:04 PM", "2006-01-02 15:04", "2006-01-02T15:04:05.000-0700"} {
+		if parsed, err := time.Parse(layout, value); err == nil {
+			return parsed, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q", value)
+}
+
+// parseJiraCSV reads the issues of a CSV export. The multi-valued fields, such as the labels and
+// the comments, are repeated columns, and the CSV exports don't have the change log.
+func parseJiraCSV(data []byte) ([]jiraIssue, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	columns := make(map[string][]int)
+	for i, name := range header {
+		name = strings.TrimSpace(name)
+		columns[name] = append(columns[name], i)
+	}
+	for _, required := range []string{"Issue key", "Summary", "Status", "Created"} {
+		if len(columns[required]) == 0 {
+			return nil, fmt.Errorf("missing column %q", required)
+		}
+	}
+
+	var issues []jiraIssue
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return issues, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		values := func(name string) []string {
+			var result []string
+			for _, i := range columns[name] {
+				if i < len(record) && strings.TrimSpace(record[i]) != "" {
+					result = append(result, record[i])
+				}
+			}
+			return result
+		}
+		value := func(name string) string {
+			if v := values(name); len(v) > 0 {
+				return v[0]
+			}
+			return ""
+		}
+		user := func(name string) jiraUser {
+			key := value(name + " Id")
+			if key == "" {
+				key = value(name)
+			}
+			return jiraUser{Key: key, Name: value(name)}
+		}
+
+		issue := jiraIssue{
+			Key:         value("Issue key"),
+			Summary:     value("Summary"),
+			Description: value("Description"),
+			Status:      value("Status"),
+			Reporter:    user("Reporter"),
+			Labels:      values("Labels"),
+		}
+		if issue.Created, err = parseJiraCSVTime(value("Created")); err != nil {
+			return nil, errors.Wrapf(err, "issue %s", issue.Key)
+		}
+		issue.Updated = issue.Created
+		if updated := value("Updated"); updated != "" {
+			if issue.Updated, err = parseJiraCSVTime(updated); err != nil {
+				return nil, errors.Wrapf(err, "issue %s", issue.Key)
+			}
+		}
+		if assignee := user("Assignee"); assignee.Key != "" {
+			issue.Assignee = &assignee
+		}
+
+		// The comments are exported as "<time>;<author>;<body>"
+		for _, c := range values("Comment") {
+			fields := strings.SplitN(c, ";", 3)
+			if len(fields) != 3 {
+				return nil, fmt.Errorf("issue %s: invalid comment %q", issue.Key, c)
+			}
+			created, err := parseJiraCSVTime(fields[0])
+			if err != nil {
+				return nil, errors.Wrapf(err, "issue %s", issue.Key)
+			}
+			issue.Comments = append(issue.Comments, jiraComment{
+				// The CSV exports don't have the ids of the comments
+				Id:      fields[0] + ";" + fields[1],
+				Author:  jiraUser{Key: fields[1], Name: fields[1]},
+				Body:    fields[2],
+				Created: created,
+				Updated: created,
+			})
+		}
+
+		issues = append(issues, issue)
+	}
+}
+
+// jiraImporter imports the issues of a Jira export
+type jiraImporter struct {
+	repo    *cache.RepoCache
+	mapping *JiraMapping
+	// user runs the import, the changes the exports don't tell the author of are attributed to them
+	user *cache.IdentityCache
+	// emails are the emails of the users by key, as the exports only have them in some places
+	emails map[string]string
+}
+
+// jiraEmails returns the emails of the users of the issues, by key
+func jiraEmails(issues []jiraIssue) map[string]string {
+	emails := make(map[string]string)
+	add := func(u jiraUser) {
+		if u.Key != "" && u.Email != "" {
+			emails[u.Key] = u.Email
+		}
+	}
+
+	for _, issue := range issues {
+		add(issue.Reporter)
+		if issue.Assignee != nil {
+			add(*issue.Assignee)
+		}
+		for _, c := range issue.Comments {
+			add(c.Author)
+		}
+		for _, s := range issue.StatusChanges {
+			add(s.Author)
+		}
+	}
+
+	return emails
+}
+
+// identity returns the identity of a Jira user: the one with the email it's mapped to or has,
+// or the one created for it by a previous import, or else a new identity with its name and email
+func (ji *jiraImporter) identity(u jiraUser) (*cache.IdentityCache, error) {
+	if u.Key == "" {
+		u = jiraUser{Key: "anonymous", Name: "Anonymous"}
+	}
+
+	email, ok := ji.mapping.Users[u.Key]
+	if !ok {
+		email, ok = ji.mapping.Users[u.Name]
+	}
+	if !ok {
+		email = ji.emails[u.Key]
+	}
+
+	if email != "" {
+		i, err := ji.repo.ResolveIdentityMatcher(func(excerpt *cache.IdentityExcerpt) bool {
+			return strings.EqualFold(excerpt.Email, email)
+		})
+		if err != identity.ErrIdentityNotExist {
+			return i, err
+		}
+	}
+
+	i, err := ji.repo.ResolveIdentityMatcher(func(excerpt *cache.IdentityExcerpt) bool {
+		return excerpt.ImmutableMetadata[jiraUserMetadataKey] == u.Key
+	})
+	if err != identity.ErrIdentityNotExist {
+		return i, err
+	}
+
+	name := u.Name
+	if name == "" {
+		name = u.Key
+	}
+	return ji.repo.NewIdentityRaw(name, email, u.Login, "", map[string]string{jiraUserMetadataKey: u.Key}, true, true, "")
+}
+
+// importIssue imports an issue as a ticket, or the comments and the changes the ticket doesn't have
+func (ji *jiraImporter) importIssue(issue jiraIssue) (created bool, updated bool, err error) {
+	if issue.Key == "" {
+		return false, false, errors.New("missing issue key")
+	}
+
+	b, err := ji.repo.ResolveBugCreateMetadata(jiraKeyMetadataKey, issue.Key)
+	switch {
+	case err == bug.ErrBugNotExist:
+		author, err := ji.identity(issue.Reporter)
+		if err != nil {
+			return false, false, err
+		}
+
+		b, _, err = ji.repo.NewBugRaw(author, issue.Created.Unix(), cache.NewBugOpts{
+			Title:    issue.Summary,
+			Message:  issue.Description,
+			Workflow: ji.mapping.Workflow,
+			Repo:     ji.mapping.Repo,
+		}, nil, map[string]string{
+			bridge.OriginMetadataKey: "jira",
+			jiraKeyMetadataKey:       issue.Key,
+		})
+		if err != nil {
+			return false, false, err
+		}
+		created = true
+	case err != nil:
+		return false, false, err
+	}
+
+	if err := ji.importHistory(b, issue); err != nil {
+		return false, false, err
+	}
+	if err := ji.importFields(b, issue); err != nil {
+		return false, false, err
+	}
+
+	updated = b.NeedCommit()
+	return created, updated && !created, b.CommitAsNeeded()
+}
+
+// importHistory imports the comments and the status changes of the issue in chronological order
+func (ji *jiraImporter) importHistory(b *cache.BugCache, issue jiraIssue) error {
+	type event struct {
+		time   time.Time
+		apply  func() error
+		source string
+	}
+	var events []event
+
+	for _, c := range issue.Comments {
+		c := c
+		events = append(events, event{c.Created, func() error { return ji.importComment(b, c) }, "comment " + c.Id})
+	}
+	for _, s := range issue.StatusChanges {
+		s := s
+		events = append(events, event{s.Created, func() error { return ji.importStatusChange(b, s) }, "change " + s.Id})
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].time.Before(events[j].time)
+	})
+
+	for _, e := range events {
+		if err := e.apply(); err != nil {
+			return errors.Wrap(err, e.source)
+		}
+	}
+	return nil
+}
+
+// importComment adds a comment to the ticket, or the edit of an imported comment
+func (ji *jiraImporter) importComment(b *cache.BugCache, c jiraComment) error {
+	author, err := ji.identity(c.Author)
+	if err != nil {
+		return err
+	}
+
+	target, err := b.ResolveOperationWithMetadata(jiraCommentMetadataKey, c.Id)
+	if err == cache.ErrNoMatchingOp {
+		_, err = b.AddCommentRaw(author, c.Created.Unix(), c.Body, nil, map[string]string{jiraCommentMetadataKey: c.Id})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, comment := range b.Snapshot().Comments {
+		if comment.Id() == target && comment.Message != c.Body {
+			_, err = b.EditCommentRaw(author, c.Updated.Unix(), target, c.Body, nil)
+			return err
+		}
+	}
+	return nil
+}
+
+// importStatusChange applies a status change of the change log, unless it was already imported
+func (ji *jiraImporter) importStatusChange(b *cache.BugCache, s jiraStatusChange) error {
+	_, err := b.ResolveOperationWithMetadata(jiraHistoryMetadataKey, s.Id)
+	if err == nil {
+		return nil
+	}
+	if err != cache.ErrNoMatchingOp {
+		return err
+	}
+
+	status, err := ji.mapping.status(s.Status)
+	if err != nil {
+		return err
+	}
+	author, err := ji.identity(s.Author)
+	if err != nil {
+		return err
+	}
+
+	return setStatus(b, author, s.Created.Unix(), status, map[string]string{jiraHistoryMetadataKey: s.Id})
+}
+
+// importFields applies the summary, description, labels, assignee and status of the issue to the
+// ticket where they differ
+func (ji *jiraImporter) importFields(b *cache.BugCache, issue jiraIssue) error {
+	snap := b.Snapshot()
+	unixTime := issue.Updated.Unix()
+
+	if issue.Summary != snap.Title {
+		if _, err := b.SetTitleRaw(ji.user, unixTime, issue.Summary, nil); err != nil {
+			return err
+		}
+	}
+	if issue.Description != snap.Comments[0].Message {
+		if _, err := b.EditCreateCommentRaw(ji.user, unixTime, issue.Description, nil); err != nil {
+			return err
+		}
+	}
+
+	if err := setLabels(b, ji.user, unixTime, jiraLabelPrefix, issue.Labels); err != nil {
+		return err
+	}
+
+	if issue.Assignee != nil {
+		assignee, err := ji.identity(*issue.Assignee)
+		if err != nil {
+			return err
+		}
+		if snap.Assignee == nil || snap.Assignee.Id() != assignee.Id() {
+			if _, err := b.SetAssigneeRaw(ji.user, unixTime, nil, assignee); err != nil {
+				return err
+			}
+		}
+	}
+
+	// The current status, for the exports without the change log
+	status, err := ji.mapping.status(issue.Status)
+	if err != nil {
+		return err
+	}
+	return setStatus(b, ji.user, unixTime, status, nil)
+}
@@ -0,0 +1,228 @@
+package importer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/daedaleanai/git-ticket/bridge"
+	"github.com/daedaleanai/git-ticket/bug"
+	"github.com/daedaleanai/git-ticket/cache"
+	"github.com/daedaleanai/git-ticket/config"
+	"github.com/daedaleanai/git-ticket/repository"
+)
+
+func newImportTestCache(t *testing.T) *cache.RepoCache {
+	repo := repository.CreateTestRepo(false)
+	t.Cleanup(func() { repository.CleanupTestRepos(repo) })
+
+	repository.SetupSigningKey(t, repo, "carol@example.com")
+
+	repoCache, err := cache.NewRepoCache(repo, false)
+	require.NoError(t, err)
+
+	carol, err := repoCache.NewIdentityRaw("Carol", "carol@example.com", "", "", nil, true, true, "")
+	require.NoError(t, err)
+	require.NoError(t, repoCache.SetUserIdentity(carol))
+
+	require.NoError(t, repoCache.DoWithLockedConfigCache(func(c *config.ConfigCache) error {
+		if err := c.LabelConfig.AppendLabelToConfiguration(config.Label("repo:rocket")); err != nil {
+			return err
+		}
+		return c.LabelConfig.Store(repo)
+	}))
+
+	return repoCache
+}
+
+func writeTestFile(t *testing.T, name string, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+const jiraTestMapping = `{
+	"workflow": "workflow:qa",
+	"repo": "repo:rocket",
+	"statuses": {"To Do": "proposed", "In Progress": "inprogress", "Done": "done"},
+	"users": {"d4": "carol@example.com"}
+}`
+
+const jiraTestExport = `{"issues": [{
+	"key": "ROCK-1",
+	"fields": {
+		"summary": "Engine explodes",
+		"description": "On ignition.",
+		"status": {"name": "Done"},
+		"reporter": {"accountId": "a1", "displayName": "Alice", "emailAddress": "alice@example.com"},
+		"assignee": {"accountId": "b2", "displayName": "Bob"},
+		"created": "2021-03-04T10:00:00.000+0000",
+		"updated": "2021-03-05T10:00:00.000+0000",
+		"labels": ["engine"],
+		"comment": {"comments": [
+			{"id": "100", "author": {"accountId": "b2", "displayName": "Bob"}, "body": "Can't reproduce",
+			 "created": "2021-03-04T11:00:00.000+0000", "updated": "2021-03-04T11:00:00.000+0000"}
+			%s
+		]}
+	},
+	"changelog": {"histories": [
+		{"id": "200", "author": {"accountId": "d4", "displayName": "Dave"}, "created": "2021-03-04T12:00:00.000+0000",
+		 "items": [{"field": "status", "fromString": "To Do", "toString": "In Progress"}]},
+		{"id": "201", "author": {"accountId": "b2", "displayName": "Bob"}, "created": "2021-03-05T10:00:00.000+0000",
+		 "items": [{"field": "resolution", "toString": "Fixed"}, {"field": "status", "fromString": "In Progress", "toString": "Done"}]}
+	]}
+}]}`
+
+func TestImportJiraJSON(t *testing.T) {
+	repoCache := newImportTestCache(t)
+	alice, err := repoCache.NewIdentityRaw("Alice", "alice@example.com", "", "", nil, true, true, "")
+	require.NoError(t, err)
+
+	mapping, err := LoadJiraMapping(writeTestFile(t, "mapping.json", jiraTestMapping))
+	require.NoError(t, err)
+	export := writeTestFile(t, "ROCK.json", jsonExport(""))
+
+	result, err := ImportJira(repoCache, export, mapping)
+	require.NoError(t, err)
+	assert.Equal(t, bridge.Result{Created: 1}, result)
+
+	b, err := repoCache.ResolveBugCreateMetadata(jiraKeyMetadataKey, "ROCK-1")
+	require.NoError(t, err)
+	snap := b.Snapshot()
+	assert.Equal(t, "Engine explodes", snap.Title)
+	assert.Equal(t, "On ignition.", snap.Comments[0].Message)
+	assert.Equal(t, alice.Id(), snap.Author.Id())
+	assert.Equal(t, int64(1614852000), snap.CreateTime.Unix())
+	require.Len(t, snap.Comments, 2)
+	assert.Equal(t, "Can't reproduce", snap.Comments[1].Message)
+	assert.Equal(t, "Bob", snap.Comments[1].Author.Name())
+	assert.True(t, snap.HasLabel("jira:engine"))
+	assert.True(t, snap.HasLabel("workflow:qa"))
+	require.NotNil(t, snap.Assignee)
+	assert.Equal(t, snap.Comments[1].Author.Id(), snap.Assignee.Id())
+	assert.Equal(t, bug.DoneStatus, snap.Status)
+	assert.Equal(t, "jira", snap.Operations[0].AllMetadata()[bridge.OriginMetadataKey])
+
+	// The status changes of the change log are imported with their author
+	inProgress, err := b.ResolveOperationWithMetadata(jiraHistoryMetadataKey, "200")
+	require.NoError(t, err)
+	for _, op := range snap.Operations {
+		if op.Id() == inProgress {
+			assert.Equal(t, "Carol", op.GetAuthor().Name())
+			assert.Equal(t, bug.InProgressStatus, op.(*bug.SetStatusOperation).Status)
+		}
+	}
+
+	// Importing the same export again changes nothing
+	operations := len(snap.Operations)
+	identities := len(repoCache.AllIdentityIds())
+	result, err = ImportJira(repoCache, export, mapping)
+	require.NoError(t, err)
+	assert.Equal(t, bridge.Result{Unchanged: 1}, result)
+	assert.Len(t, b.Snapshot().Operations, operations)
+	assert.Len(t, repoCache.AllIdentityIds(), identities)
+
+	// A later export has a new comment
+	export = writeTestFile(t, "ROCK.json", jsonExport(`, {"id": "101", "author": {"accountId": "a1", "displayName": "Alice"},
+		"body": "Thanks", "created": "2021-03-06T10:00:00.000+0000", "updated": "2021-03-06T10:00:00.000+0000"}`))
+	result, err = ImportJira(repoCache, export, mapping)
+	require.NoError(t, err)
+	assert.Equal(t, bridge.Result{Updated: 1}, result)
+	snap = b.Snapshot()
+	require.Len(t, snap.Comments, 3)
+	assert.Equal(t, alice.Id(), snap.Comments[2].Author.Id())
+	assert.Len(t, repoCache.AllIdentityIds(), identities)
+}
+
+func jsonExport(extraComment string) string {
+	return fmt.Sprintf(jiraTestExport, extraComment)
+}
+
+func TestImportJiraTwice(t *testing.T) {
+	repoCache := newImportTestCache(t)
+
+	mapping, err := LoadJiraMapping(writeTestFile(t, "mapping.json", jiraTestMapping))
+	require.NoError(t, err)
+
+	// The first change log entry doesn't change the status of the ticket created
+	export := writeTestFile(t, "ROCK.json", `{"issues": [{
+		"key": "ROCK-2",
+		"fields": {
+			"summary": "Fuel leaks",
+			"description": "",
+			"status": {"name": "In Progress"},
+			"reporter": {"accountId": "d4", "displayName": "Dave"},
+			"created": "2021-03-04T10:00:00.000+0000",
+			"updated": "2021-03-05T10:00:00.000+0000"
+		},
+		"changelog": {"histories": [
+			{"id": "300", "author": {"accountId": "d4", "displayName": "Dave"}, "created": "2021-03-04T11:00:00.000+0000",
+			 "items": [{"field": "status", "fromString": "Open", "toString": "To Do"}]},
+			{"id": "301", "author": {"accountId": "d4", "displayName": "Dave"}, "created": "2021-03-04T12:00:00.000+0000",
+			 "items": [{"field": "status", "fromString": "To Do", "toString": "In Progress"}]}
+		]}
+	}]}`)
+
+	result, err := ImportJira(repoCache, export, mapping)
+	require.NoError(t, err)
+	assert.Equal(t, bridge.Result{Created: 1}, result)
+
+	b, err := repoCache.ResolveBugCreateMetadata(jiraKeyMetadataKey, "ROCK-2")
+	require.NoError(t, err)
+	assert.Equal(t, bug.InProgressStatus, b.Snapshot().Status)
+	for _, id := range []string{"300", "301"} {
+		_, err := b.ResolveOperationWithMetadata(jiraHistoryMetadataKey, id)
+		assert.NoError(t, err, id)
+	}
+
+	operations := len(b.Snapshot().Operations)
+	result, err = ImportJira(repoCache, export, mapping)
+	require.NoError(t, err)
+	assert.Equal(t, bridge.Result{Unchanged: 1}, result)
+	assert.Len(t, b.Snapshot().Operations, operations)
+	assert.Equal(t, bug.InProgressStatus, b.Snapshot().Status)
+}
+
+func TestImportJiraCSV(t *testing.T) {
+	repoCache := newImportTestCache(t)
+
+	mapping, err := LoadJiraMapping(writeTestFile(t, "mapping.json", jiraTestMapping))
+	require.NoError(t, err)
+	export := writeTestFile(t, "ROCK.csv", `Summary,Issue key,Status,Reporter,Reporter Id,Created,Description,Labels,Labels,Comment,Comment
+Engine explodes,ROCK-1,In Progress,Alice,a1,04/Mar/21 10:00 AM,On ignition.,engine,urgent,04/Mar/21 11:00 AM;b2;Can't reproduce; on master,
+Paint the fins,ROCK-2,To Do,Dave,d4,05/Mar/21 9:30 AM,Red.,,,,
+`)
+
+	result, err := ImportJira(repoCache, export, mapping)
+	require.NoError(t, err)
+	assert.Equal(t, bridge.Result{Created: 2}, result)
+
+	b, err := repoCache.ResolveBugCreateMetadata(jiraKeyMetadataKey, "ROCK-1")
+	require.NoError(t, err)
+	snap := b.Snapshot()
+	assert.Equal(t, "Alice", snap.Author.Name())
+	assert.True(t, snap.HasLabel("jira:engine"))
+	assert.True(t, snap.HasLabel("jira:urgent"))
+	assert.Equal(t, bug.InProgressStatus, snap.Status)
+	require.Len(t, snap.Comments, 2)
+	assert.Equal(t, "Can't reproduce; on master", snap.Comments[1].Message)
+
+	fins, err := repoCache.ResolveBugCreateMetadata(jiraKeyMetadataKey, "ROCK-2")
+	require.NoError(t, err)
+	assert.Equal(t, "Carol", fins.Snapshot().Author.Name())
+	assert.Equal(t, bug.ProposedStatus, fins.Snapshot().Status)
+
+	result, err = ImportJira(repoCache, export, mapping)
+	require.NoError(t, err)
+	assert.Equal(t, bridge.Result{Unchanged: 2}, result)
+}
+
+func TestLoadJiraMappingInvalidStatus(t *testing.T) {
+	_, err := LoadJiraMapping(writeTestFile(t, "mapping.json",
+		`{"workflow": "workflow:qa", "repo": "repo:rocket", "statuses": {"Done": "merged"}}`))
+	assert.EqualError(t, err, "Jira status Done: merged isn't a status of workflow:qa")
+}
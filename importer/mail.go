@@ -0,0 +1,533 @@
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/daedaleanai/git-ticket/bridge"
+	"github.com/daedaleanai/git-ticket/cache"
+	"github.com/daedaleanai/git-ticket/entity"
+	"github.com/daedaleanai/git-ticket/identity"
+	"github.com/daedaleanai/git-ticket/repository"
+)
+
+const (
+	mailMessageIdMetadataKey = "mail-message-id"
+	mailInReplyToMetadataKey = "mail-in-reply-to"
+
+	mailQuarantineDir = "mail-quarantine"
+)
+
+// MailOptions are the labels of the tickets created from new mail threads
+type MailOptions struct {
+	Workflow string
+	Repo     string
+}
+
+// MailResult counts the messages of an ingest
+type MailResult struct {
+	// Tickets is the number of tickets created from new threads
+	Tickets int
+	// Comments is the number of comments added from replies
+	Comments int
+	// Unchanged is the number of messages already ingested
+	Unchanged int
+	// Quarantined is the number of messages of unknown senders put in the quarantine
+	Quarantined int
+	// Invalid are the messages which couldn't be parsed, with the reason, which were skipped
+	Invalid []string
+}
+
+// MailQuarantinePath returns the Maildir holding the messages of unknown senders
+func MailQuarantinePath(repo *cache.RepoCache) string {
+	return filepath.Join(repo.GetPath(), "git-bug", mailQuarantineDir)
+}
+
+// rawMail is a message of a mailbox, with the file holding it for a Maildir
+type rawMail struct {
+	path string
+	data []byte
+}
+
+// mailMessage is a parsed message
+type mailMessage struct {
+	raw         rawMail
+	id          string
+	references  []string
+	from        string
+	date        time.Time
+	subject     string
+	body        string
+	attachments [][]byte
+}
+
+// IngestMail creates tickets from the new threads of a mbox file or a Maildir, and comments from
+// the replies, threaded with the Message-ID, In-Reply-To and References headers. The senders are
+// mapped to the identities with their email: the messages of unknown senders are put in the
+// quarantine, to be ingested again once the senders have an identity. The messages already
+// ingested are skipped, so a mailbox can be ingested again as it grows. The messages which can't
+// be parsed are skipped too, and reported in the result.
+func IngestMail(repo *cache.RepoCache, path string, opts MailOptions) (MailResult, error) {
+	var result MailResult
+
+	raws, err := readMailbox(path)
+	if err != nil {
+		return result, err
+	}
+
+	var messages []*mailMessage
+	for i, raw := range raws {
+		m, err := parseMail(raw)
+		if err != nil {
+			source := raw.path
+			if source == "" {
+				source = fmt.Sprintf("message %d of %s", i+1, path)
+			}
+			result.Invalid = append(result.Invalid, fmt.Sprintf("%s: %s", source, err))
+			continue
+		}
+		messages = append(messages, m)
+	}
+	// a reply must come after the message it replies to
+	sort.SliceStable(messages, func(i, j int) bool {
+		return messages[i].date.Before(messages[j].date)
+	})
+
+	quarantine := MailQuarantinePath(repo)
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return result, err
+	}
+	fromQuarantine := abs == quarantine
+
+	mi := &mailIngester{repo: repo, opts: opts, quarantine: quarantine}
+	if err := mi.loadThreads(); err != nil {
+		return result, err
+	}
+
+	for _, m := range messages {
+		outcome, err := mi.ingest(m)
+		if err != nil {
+			return result, errors.Wrapf(err, "mail %s", m.id)
+		}
+
+		switch outcome {
+		case mailTicket:
+			result.Tickets++
+		case mailComment:
+			result.Comments++
+		case mailUnchanged:
+			result.Unchanged++
+		case mailQuarantined:
+			result.Quarantined++
+			continue
+		}
+
+		if fromQuarantine {
+			if err := os.Remove(m.raw.path); err != nil {
+				return result, err
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// QuarantinedMail is a message of the quarantine
+type QuarantinedMail struct {
+	Path    string
+	From    string
+	Date    time.Time
+	Subject string
+}
+
+// ListQuarantinedMail returns the messages of the quarantine, oldest first
+func ListQuarantinedMail(repo *cache.RepoCache) ([]QuarantinedMail, error) {
+	quarantine := MailQuarantinePath(repo)
+	if _, err := os.Stat(quarantine); os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	raws, err := readMailbox(quarantine)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []QuarantinedMail
+	for _, raw := range raws {
+		m, err := parseMail(raw)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid mail %s", raw.path)
+		}
+		result = append(result, QuarantinedMail{Path: raw.path, From: m.from, Date: m.date, Subject: m.subject})
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Date.Before(result[j].Date)
+	})
+
+	return result, nil
+}
+
+// readMailbox reads the messages of a Maildir, or else of a mbox file
+func readMailbox(path string) ([]rawMail, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return readMaildir(path)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return splitMbox(data), nil
+}
+
+func readMaildir(path string) ([]rawMail, error) {
+	var raws []rawMail
+
+	for _, sub := range []string{"cur", "new"} {
+		entries, err := os.ReadDir(filepath.Join(path, sub))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		for _, entry := range entries {
+			if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+				continue
+			}
+			file := filepath.Join(path, sub, entry.Name())
+			data, err := os.ReadFile(file)
+			if err != nil {
+				return nil, err
+			}
+			raws = append(raws, rawMail{path: file, data: data})
+		}
+	}
+
+	if raws == nil {
+		if _, err := os.Stat(filepath.Join(path, "cur")); os.IsNotExist(err) {
+			if _, err := os.Stat(filepath.Join(path, "new")); os.IsNotExist(err) {
+				return nil, fmt.Errorf("%s is not a Maildir, it has neither a cur nor a new directory", path)
+			}
+		}
+	}
+
+	return raws, nil
+}
+
+// splitMbox splits a mbox file on the "From " lines, and unquotes the ">From " lines of the
+// messages
+func splitMbox(data []byte) []rawMail {
+	var raws []rawMail
+	var current *bytes.Buffer
+	blank := true
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+
+		if blank && bytes.HasPrefix(line, []byte("From ")) {
+			if current != nil {
+				raws = append(raws, rawMail{data: current.Bytes()})
+			}
+			current = &bytes.Buffer{}
+			blank = false
+			continue
+		}
+		blank = len(bytes.TrimRight(line, "\r")) == 0
+
+		if current == nil {
+			continue
+		}
+		if unquoted := bytes.TrimLeft(line, ">"); len(unquoted) < len(line) && bytes.HasPrefix(unquoted, []byte("From ")) {
+			line = line[1:]
+		}
+		current.Write(line)
+		current.WriteByte('\n')
+	}
+	if current != nil {
+		raws = append(raws, rawMail{data: current.Bytes()})
+	}
+
+	return raws
+}
+
+func parseMail(raw rawMail) (*mailMessage, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(raw.data))
+	if err != nil {
+		return nil, err
+	}
+
+	m := &mailMessage{raw: raw}
+
+	from, err := mail.ParseAddress(msg.Header.Get("From"))
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid sender")
+	}
+	m.from = from.Address
+
+	m.date, err = msg.Header.Date()
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid date")
+	}
+
+	decoder := new(mime.WordDecoder)
+	m.subject, err = decoder.DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid subject")
+	}
+	m.subject = strings.Join(strings.Fields(m.subject), " ")
+
+	ids := messageIds(msg.Header.Get("Message-ID"))
+	if len(ids) > 0 {
+		m.id = ids[0]
+	} else {
+		// the same message gets the same id when it's ingested again
+		m.id = fmt.Sprintf("%x@git-ticket", sha256.Sum256(raw.data))
+	}
+
+	// the message replied to comes last
+	m.references = messageIds(msg.Header.Get("References"))
+	if inReplyTo := messageIds(msg.Header.Get("In-Reply-To")); len(inReplyTo) > 0 {
+		m.references = append(m.references, inReplyTo[0])
+	}
+
+	if err := m.readPart(msg.Header, msg.Body); err != nil {
+		return nil, err
+	}
+	m.body = strings.TrimSpace(strings.ReplaceAll(m.body, "\r\n", "\n"))
+
+	return m, nil
+}
+
+// readPart reads the text of the message from the first text part, and its attachments
+func (m *mailMessage) readPart(header map[string][]string, body io.Reader) error {
+	get := func(key string) string {
+		if values := header[key]; len(values) > 0 {
+			return values[0]
+		}
+		return ""
+	}
+
+	mediaType, params, err := mime.ParseMediaType(get("Content-Type"))
+	if err != nil {
+		mediaType = "text/plain"
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		reader := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := reader.NextRawPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return errors.Wrap(err, "invalid multipart message")
+			}
+			if err := m.readPart(part.Header, part); err != nil {
+				return err
+			}
+		}
+	}
+
+	switch strings.ToLower(get("Content-Transfer-Encoding")) {
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, &newlineSkipper{body})
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return errors.Wrap(err, "invalid message part")
+	}
+
+	disposition, _, _ := mime.ParseMediaType(get("Content-Disposition"))
+	if disposition != "attachment" && strings.HasPrefix(mediaType, "text/") && m.body == "" {
+		m.body = string(data)
+		return nil
+	}
+	if disposition == "attachment" || !strings.HasPrefix(mediaType, "text/") {
+		m.attachments = append(m.attachments, data)
+	}
+	return nil
+}
+
+// newlineSkipper drops the line breaks of base64 content
+type newlineSkipper struct {
+	r io.Reader
+}
+
+func (n *newlineSkipper) Read(p []byte) (int, error) {
+	count, err := n.r.Read(p)
+	kept := 0
+	for _, b := range p[:count] {
+		if b != '\r' && b != '\n' {
+			p[kept] = b
+			kept++
+		}
+	}
+	return kept, err
+}
+
+// messageIds returns the message ids of a header, without their angle brackets
+func messageIds(value string) []string {
+	var ids []string
+	for _, field := range strings.Fields(value) {
+		id := strings.TrimSuffix(strings.TrimPrefix(field, "<"), ">")
+		if id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+type mailOutcome int
+
+const (
+	mailUnchanged mailOutcome = iota
+	mailTicket
+	mailComment
+	mailQuarantined
+)
+
+type mailIngester struct {
+	repo       *cache.RepoCache
+	opts       MailOptions
+	quarantine string
+	// threads maps the ids of the messages ingested to their ticket
+	threads map[string]entity.Id
+}
+
+// loadThreads indexes the messages of the tickets created from mail
+func (mi *mailIngester) loadThreads() error {
+	mi.threads = make(map[string]entity.Id)
+
+	for _, id := range mi.repo.AllBugsIds() {
+		excerpt, err := mi.repo.ResolveBugExcerpt(id)
+		if err != nil {
+			return err
+		}
+		if excerpt.CreateMetadata[bridge.OriginMetadataKey] != "mail" {
+			continue
+		}
+
+		b, err := mi.repo.ResolveBug(id)
+		if err != nil {
+			return err
+		}
+		for _, op := range b.Snapshot().Operations {
+			if messageId, ok := op.GetMetadata(mailMessageIdMetadataKey); ok {
+				mi.threads[messageId] = id
+			}
+		}
+	}
+
+	return nil
+}
+
+func (mi *mailIngester) ingest(m *mailMessage) (mailOutcome, error) {
+	if _, ok := mi.threads[m.id]; ok {
+		return mailUnchanged, nil
+	}
+
+	author, err := mi.repo.ResolveIdentityMatcher(func(excerpt *cache.IdentityExcerpt) bool {
+		return strings.EqualFold(excerpt.Email, m.from)
+	})
+	if err == identity.ErrIdentityNotExist {
+		return mailQuarantined, mi.quarantineMail(m)
+	}
+	if err != nil {
+		return mailUnchanged, errors.Wrapf(err, "sender %s", m.from)
+	}
+
+	var files []repository.Hash
+	for _, attachment := range m.attachments {
+		hash, err := mi.repo.StoreData(attachment)
+		if err != nil {
+			return mailUnchanged, err
+		}
+		files = append(files, hash)
+	}
+
+	metadata := map[string]string{mailMessageIdMetadataKey: m.id}
+	if len(m.references) > 0 {
+		metadata[mailInReplyToMetadataKey] = m.references[len(m.references)-1]
+	}
+
+	for i := len(m.references) - 1; i >= 0; i-- {
+		id, ok := mi.threads[m.references[i]]
+		if !ok {
+			continue
+		}
+
+		b, err := mi.repo.ResolveBug(id)
+		if err != nil {
+			return mailUnchanged, err
+		}
+		if _, err := b.AddCommentRaw(author, m.date.Unix(), m.body, files, metadata); err != nil {
+			return mailUnchanged, err
+		}
+		mi.threads[m.id] = id
+		return mailComment, b.CommitAsNeeded()
+	}
+
+	if mi.opts.Workflow == "" || mi.opts.Repo == "" {
+		return mailUnchanged, errors.New("the workflow and repo labels of the tickets must be supplied")
+	}
+
+	title := m.subject
+	if title == "" {
+		title = "(no subject)"
+	}
+	metadata[bridge.OriginMetadataKey] = "mail"
+
+	b, _, err := mi.repo.NewBugRaw(author, m.date.Unix(), cache.NewBugOpts{
+		Title:    title,
+		Message:  m.body,
+		Workflow: mi.opts.Workflow,
+		Repo:     mi.opts.Repo,
+	}, files, metadata)
+	if err != nil {
+		return mailUnchanged, err
+	}
+	mi.threads[m.id] = b.Id()
+	return mailTicket, b.CommitAsNeeded()
+}
+
+// quarantineMail stores the message as it was received in the quarantine Maildir
+func (mi *mailIngester) quarantineMail(m *mailMessage) error {
+	for _, sub := range []string{"cur", "new", "tmp"} {
+		if err := os.MkdirAll(filepath.Join(mi.quarantine, sub), 0755); err != nil {
+			return err
+		}
+	}
+
+	name := fmt.Sprintf("%x", sha256.Sum256(m.raw.data))
+	if m.raw.path != "" && filepath.Dir(filepath.Dir(m.raw.path)) == mi.quarantine {
+		// still in the quarantine
+		return nil
+	}
+	return os.WriteFile(filepath.Join(mi.quarantine, "new", name), m.raw.data, 0644)
+}
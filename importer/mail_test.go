@@ -0,0 +1,149 @@
+package importer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/daedaleanai/git-ticket/bridge"
+)
+
+const mailTestMbox = `From carol@example.com Thu Mar  4 10:00:00 2021
+From: Carol <carol@example.com>
+To: tickets@example.com
+Subject: =?utf-8?q?Engine_explodes?=
+Date: Thu, 04 Mar 2021 10:00:00 +0000
+Message-ID: <1@rig.example.com>
+MIME-Version: 1.0
+Content-Type: multipart/mixed; boundary="b1"
+
+--b1
+Content-Type: text/plain; charset=utf-8
+
+On ignition.
+>From the log it's the valve.
+
+--b1
+Content-Type: application/octet-stream
+Content-Disposition: attachment; filename="log.bin"
+Content-Transfer-Encoding: base64
+
+bG9nIGRh
+dGE=
+--b1--
+
+From bob@example.com Thu Mar  4 11:00:00 2021
+From: Bob <bob@example.com>
+Subject: Re: Engine explodes
+Date: Thu, 04 Mar 2021 11:00:00 +0000
+Message-ID: <2@example.com>
+In-Reply-To: <1@rig.example.com>
+
+Can't reproduce.
+
+From carol@example.com Thu Mar  4 12:00:00 2021
+From: carol@EXAMPLE.com
+Subject: Re: Engine explodes
+Date: Thu, 04 Mar 2021 12:00:00 +0000
+Message-ID: <3@rig.example.com>
+References: <1@rig.example.com> <2@example.com>
+Content-Transfer-Encoding: quoted-printable
+
+Try a cold start=3D=
+ it fails.
+`
+
+func TestIngestMail(t *testing.T) {
+	repoCache := newImportTestCache(t)
+	mbox := writeTestFile(t, "tickets.mbox", mailTestMbox)
+	opts := MailOptions{Workflow: "workflow:qa", Repo: "repo:rocket"}
+
+	result, err := IngestMail(repoCache, mbox, opts)
+	require.NoError(t, err)
+	assert.Equal(t, MailResult{Tickets: 1, Comments: 1, Quarantined: 1}, result)
+
+	b, err := repoCache.ResolveBugCreateMetadata(mailMessageIdMetadataKey, "1@rig.example.com")
+	require.NoError(t, err)
+	snap := b.Snapshot()
+	assert.Equal(t, "Engine explodes", snap.Title)
+	assert.Equal(t, "Carol", snap.Author.Name())
+	assert.Equal(t, "mail", snap.Operations[0].AllMetadata()[bridge.OriginMetadataKey])
+	require.Len(t, snap.Comments, 2)
+	assert.Equal(t, "On ignition.\nFrom the log it's the valve.", snap.Comments[0].Message)
+	require.Len(t, snap.Comments[0].Files, 1)
+	data, err := repoCache.ReadData(snap.Comments[0].Files[0])
+	require.NoError(t, err)
+	assert.Equal(t, "log data", string(data))
+	// Threaded through the References, the message replied to being quarantined
+	assert.Equal(t, "Try a cold start= it fails.", snap.Comments[1].Message)
+	assert.Equal(t, "2@example.com", snap.Operations[len(snap.Operations)-1].AllMetadata()[mailInReplyToMetadataKey])
+
+	quarantined, err := ListQuarantinedMail(repoCache)
+	require.NoError(t, err)
+	require.Len(t, quarantined, 1)
+	assert.Equal(t, "bob@example.com", quarantined[0].From)
+	assert.Equal(t, "Re: Engine explodes", quarantined[0].Subject)
+
+	// Ingesting the mailbox again changes nothing
+	result, err = IngestMail(repoCache, mbox, opts)
+	require.NoError(t, err)
+	assert.Equal(t, MailResult{Unchanged: 2, Quarantined: 1}, result)
+	quarantined, err = ListQuarantinedMail(repoCache)
+	require.NoError(t, err)
+	assert.Len(t, quarantined, 1)
+
+	// Once the sender has an identity, the quarantine is ingested
+	_, err = repoCache.NewIdentityRaw("Bob", "bob@example.com", "", "", nil, true, true, "")
+	require.NoError(t, err)
+
+	result, err = IngestMail(repoCache, MailQuarantinePath(repoCache), opts)
+	require.NoError(t, err)
+	assert.Equal(t, MailResult{Comments: 1}, result)
+	snap = b.Snapshot()
+	require.Len(t, snap.Comments, 3)
+	assert.Equal(t, "Can't reproduce.", snap.Comments[2].Message)
+	assert.Equal(t, "Bob", snap.Comments[2].Author.Name())
+	assert.Len(t, repoCache.AllBugsIds(), 1)
+
+	quarantined, err = ListQuarantinedMail(repoCache)
+	require.NoError(t, err)
+	assert.Empty(t, quarantined)
+}
+
+func TestIngestInvalidMail(t *testing.T) {
+	repoCache := newImportTestCache(t)
+	mbox := writeTestFile(t, "tickets.mbox", `From carol@example.com Thu Mar  4 09:00:00 2021
+From: not an address
+Subject: Broken
+Date: Thu, 04 Mar 2021 09:00:00 +0000
+Message-ID: <0@rig.example.com>
+
+Lost.
+
+From carol@example.com Thu Mar  4 09:30:00 2021
+From: Carol <carol@example.com>
+Subject: Undated
+Date: yesterday
+Message-ID: <00@rig.example.com>
+
+Lost too.
+
+`+mailTestMbox)
+
+	// The invalid messages are skipped, the other ones ingested
+	result, err := IngestMail(repoCache, mbox, MailOptions{Workflow: "workflow:qa", Repo: "repo:rocket"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Tickets)
+	assert.Equal(t, 1, result.Comments)
+	require.Len(t, result.Invalid, 2)
+	assert.Contains(t, result.Invalid[0], "message 1 of "+mbox+": invalid sender")
+	assert.Contains(t, result.Invalid[1], "message 2 of "+mbox+": invalid date")
+}
+
+func TestSplitMbox(t *testing.T) {
+	raws := splitMbox([]byte("From a\nSubject: 1\n\nbody\nFrom the start\n>From quoted\n\nFrom b\nSubject: 2\n"))
+	require.Len(t, raws, 2)
+	assert.Equal(t, "Subject: 1\n\nbody\nFrom the start\nFrom quoted\n\n", string(raws[0].data))
+	assert.Equal(t, "Subject: 2\n", string(raws[1].data))
+}
@@ -0,0 +1,548 @@
+package importer
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/thought-machine/gonduit"
+	"github.com/thought-machine/gonduit/requests"
+	"github.com/thought-machine/gonduit/responses"
+
+	"github.com/daedaleanai/git-ticket/bridge"
+	"github.com/daedaleanai/git-ticket/bug"
+	"github.com/daedaleanai/git-ticket/cache"
+	"github.com/daedaleanai/git-ticket/identity"
+)
+
+// The metadata of the operations imported from Maniphest
+const (
+	// maniphestIdMetadataKey is the T-number of the task, e.g. T12, on the create operations
+	maniphestIdMetadataKey = "maniphest-id"
+	// maniphestTransactionMetadataKey is the PHID of the transaction a comment or a status change
+	// was imported from
+	maniphestTransactionMetadataKey = "maniphest-transaction"
+	// maniphestSubscribersMetadataKey is set by the metadata operations recording the subscribers
+	// of the task, as a comma separated list of identity ids, the latest one is the current list
+	maniphestSubscribersMetadataKey = "maniphest-subscribers"
+)
+
+// Labels of the imported tickets
+const (
+	// maniphestProjectLabelPrefix prefixes the slugs of the projects of the tasks
+	maniphestProjectLabelPrefix = "phab:"
+	// maniphestPriorityLabelPrefix prefixes the priority of the tasks, e.g. priority:high
+	maniphestPriorityLabelPrefix = "priority:"
+)
+
+// maniphestPageSize is the number of items of the responses of the Conduit API
+const maniphestPageSize = 100
+
+var maniphestTaskId = regexp.MustCompile(`^T(\d+)$`)
+
+// ManiphestOptions are the labels of the tickets imported from Maniphest
+type ManiphestOptions struct {
+	Workflow string
+	Repo     string
+}
+
+// ImportManiphest imports the Maniphest tasks with the given T-numbers, or else the ones of the
+// saved query with the given key, e.g. "open". The tasks already imported are updated with the
+// comments and changes they didn't have.
+func ImportManiphest(repo *cache.RepoCache, client *gonduit.Conn, args []string, opts ManiphestOptions) (bridge.Result, error) {
+	var result bridge.Result
+
+	workflow := bug.FindWorkflow([]bug.Label{bug.Label(opts.Workflow)})
+	if workflow == nil {
+		return result, fmt.Errorf("invalid workflow %s, valid workflows are %v", opts.Workflow, bug.GetWorkflowLabels())
+	}
+
+	request := requests.SearchRequest{
+		Attachments: map[string]bool{"projects": true, "subscribers": true},
+		Limit:       maniphestPageSize,
+	}
+	ids, err := maniphestTaskIds(args)
+	if err != nil {
+		return result, err
+	}
+	if ids != nil {
+		request.Constraints = map[string]interface{}{"ids": ids}
+	} else {
+		request.QueryKey = args[0]
+	}
+
+	user, err := repo.GetUserIdentity()
+	if err != nil {
+		return result, err
+	}
+
+	// The statuses can be customized, the open ones have to be known to map them
+	var statuses maniphestStatuses
+	if err := client.Call("maniphest.querystatuses", map[string]interface{}{}, &statuses); err != nil {
+		return result, errors.Wrap(err, "unable to query the Maniphest statuses")
+	}
+
+	mi := &maniphestImporter{
+		repo:       repo,
+		client:     client,
+		opts:       opts,
+		workflow:   workflow,
+		user:       user,
+		statuses:   statuses,
+		identities: make(map[string]*cache.IdentityCache),
+		projects:   make(map[string]string),
+	}
+
+	for {
+		response, err := client.ManiphestSearch(request)
+		if err != nil {
+			return result, err
+		}
+
+		for _, task := range response.Data {
+			created, updated, err := mi.importTask(task)
+			if err != nil {
+				return result, errors.Wrapf(err, "task T%d", task.ID)
+			}
+
+			switch {
+			case created:
+				result.Created++
+			case updated:
+				result.Updated++
+			default:
+				result.Unchanged++
+			}
+		}
+
+		if response.Cursor.After == "" {
+			return result, nil
+		}
+		request.After = response.Cursor.After
+	}
+}
+
+// maniphestTaskIds returns the ids of the tasks if the arguments are T-numbers, or nil if the
+// argument is a query key
+func maniphestTaskIds(args []string) ([]int, error) {
+	if len(args) == 0 {
+		return nil, errors.New("no query or task supplied")
+	}
+	if len(args) == 1 && !maniphestTaskId.MatchString(args[0]) {
+		return nil, nil
+	}
+
+	ids := make([]int, 0, len(args))
+	for _, arg := range args {
+		match := maniphestTaskId.FindStringSubmatch(arg)
+		if match == nil {
+			return nil, fmt.Errorf("invalid task %q, expected a T-number or a single query key", arg)
+		}
+		id, _ := strconv.Atoi(match[1])
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// maniphestStatuses are the statuses of the tasks configured in Phabricator, as returned by
+// maniphest.querystatuses
+type maniphestStatuses struct {
+	DefaultClosedStatus string   `json:"defaultClosedStatus"`
+	OpenStatuses        []string `json:"openStatuses"`
+}
+
+// maniphestImporter imports Maniphest tasks
+type maniphestImporter struct {
+	repo     *cache.RepoCache
+	client   *gonduit.Conn
+	opts     ManiphestOptions
+	workflow *bug.Workflow
+	// user runs the import, the changes the tasks don't tell the author of are attributed to them
+	user     *cache.IdentityCache
+	statuses maniphestStatuses
+	// identities are the identities of the Phabricator users by PHID
+	identities map[string]*cache.IdentityCache
+	// projects are the slugs of the projects by PHID
+	projects map[string]string
+}
+
+// identity returns the identity with the Phabricator id, creating it with the name of the
+// Phabricator user if there isn't any
+func (mi *maniphestImporter) identity(phid string) (*cache.IdentityCache, error) {
+	if !strings.HasPrefix(phid, "PHID-USER-") {
+		// Changes made by applications, e.g. Herald, are attributed to the user running the import
+		return mi.user, nil
+	}
+	if i, ok := mi.identities[phid]; ok {
+		return i, nil
+	}
+
+	i, err := mi.repo.ResolveIdentityPhabID(phid)
+	var result *cache.IdentityCache
+	switch {
+	case err == nil:
+		result, err = mi.repo.ResolveIdentity(i.Id())
+	case err == identity.ErrIdentityNotExist:
+		result, err = mi.newIdentity(phid)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	mi.identities[phid] = result
+	return result, nil
+}
+
+// newIdentity creates the identity of a Phabricator user
+func (mi *maniphestImporter) newIdentity(phid string) (*cache.IdentityCache, error) {
+	response, err := mi.client.UserSearch(requests.SearchRequest{
+		Constraints: map[string]interface{}{"phids": []string{phid}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(response.Data) == 0 {
+		return nil, fmt.Errorf("unknown Phabricator user %s", phid)
+	}
+
+	username, _ := response.Data[0].Fields["username"].(string)
+	name, _ := response.Data[0].Fields["realName"].(string)
+	if name == "" {
+		name = username
+	}
+	return mi.repo.NewIdentityWithPhabIdRaw(name, "", username, "", nil, phid)
+}
+
+// projectSlugs returns the slugs of the projects
+func (mi *maniphestImporter) projectSlugs(phids []string) ([]string, error) {
+	var unknown []string
+	for _, phid := range phids {
+		if _, ok := mi.projects[phid]; !ok {
+			unknown = append(unknown, phid)
+		}
+	}
+
+	if len(unknown) > 0 {
+		response, err := mi.client.ProjectSearch(requests.SearchRequest{
+			Constraints: map[string]interface{}{"phids": unknown},
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range response.Data {
+			slug, _ := p.Fields["slug"].(string)
+			if slug == "" {
+				slug, _ = p.Fields["name"].(string)
+			}
+			mi.projects[p.PHID] = strings.ReplaceAll(strings.ToLower(slug), " ", "_")
+		}
+	}
+
+	var slugs []string
+	for _, phid := range phids {
+		if slug := mi.projects[phid]; slug != "" {
+			slugs = append(slugs, slug)
+		}
+	}
+	return slugs, nil
+}
+
+// status returns the status of the workflow a Maniphest status maps to: the open ones, including
+// the custom ones, to proposed, resolved or the default closed status to the final status of the
+// workflow and the other closed ones to rejected
+func (mi *maniphestImporter) status(value string) bug.Status {
+	if value == "" {
+		return bug.ProposedStatus
+	}
+	for _, open := range mi.statuses.OpenStatuses {
+		if value == open {
+			return bug.ProposedStatus
+		}
+	}
+
+	if value == "resolved" || value == mi.statuses.DefaultClosedStatus {
+		for _, s := range mi.workflow.AllStatuses() {
+			if s == bug.DoneStatus {
+				return bug.DoneStatus
+			}
+		}
+		return bug.MergedStatus
+	}
+	return bug.RejectedStatus
+}
+
+// maniphestTask holds the fields of a task of a search response
+type maniphestTask struct {
+	title       string
+	description string
+	author      string
+	owner       string
+	status      string
+	priority    string
+	created     time.Time
+	modified    time.Time
+	projects    []string
+	subscribers []string
+}
+
+func newManiphestTask(data responses.SearchData) maniphestTask {
+	field := func(name string, key string) string {
+		if m, ok := data.Fields[name].(map[string]interface{}); ok {
+			value, _ := m[key].(string)
+			return value
+		}
+		value, _ := data.Fields[name].(string)
+		return value
+	}
+	unixTime := func(name string) time.Time {
+		value, _ := data.Fields[name].(float64)
+		return time.Unix(int64(value), 0)
+	}
+	phids := func(attachment string, key string) []string {
+		var result []string
+		values, _ := data.Attachments[attachment][key].([]interface{})
+		for _, v := range values {
+			if phid, ok := v.(string); ok {
+				result = append(result, phid)
+			}
+		}
+		return result
+	}
+
+	return maniphestTask{
+		title:       field("name", ""),
+		description: field("description", "raw"),
+		author:      field("authorPHID", ""),
+		owner:       field("ownerPHID", ""),
+		status:      field("status", "value"),
+		priority:    field("priority", "name"),
+		created:     unixTime("dateCreated"),
+		modified:    unixTime("dateModified"),
+		projects:    phids("projects", "projectPHIDs"),
+		subscribers: phids("subscribers", "subscriberPHIDs"),
+	}
+}
+
+// importTask imports a task as a ticket, or the comments and the changes the ticket doesn't have
+func (mi *maniphestImporter) importTask(data responses.SearchData) (created bool, updated bool, err error) {
+	taskId := fmt.Sprintf("T%d", data.ID)
+	task := newManiphestTask(data)
+
+	b, err := mi.repo.ResolveBugCreateMetadata(maniphestIdMetadataKey, taskId)
+	switch {
+	case err == bug.ErrBugNotExist:
+		author, err := mi.identity(task.author)
+		if err != nil {
+			return false, false, err
+		}
+
+		b, _, err = mi.repo.NewBugRaw(author, task.created.Unix(), cache.NewBugOpts{
+			Title:    task.title,
+			Message:  task.description,
+			Workflow: mi.opts.Workflow,
+			Repo:     mi.opts.Repo,
+		}, nil, map[string]string{
+			bridge.OriginMetadataKey: "maniphest",
+			maniphestIdMetadataKey:   taskId,
+		})
+		if err != nil {
+			return false, false, err
+		}
+		created = true
+	case err != nil:
+		return false, false, err
+	}
+
+	if err := mi.importTransactions(b, taskId); err != nil {
+		return false, false, err
+	}
+	if err := mi.importFields(b, task); err != nil {
+		return false, false, err
+	}
+
+	updated = b.NeedCommit()
+	return created, updated && !created, b.CommitAsNeeded()
+}
+
+// importTransactions imports the comments and the status changes of the task in chronological order
+func (mi *maniphestImporter) importTransactions(b *cache.BugCache, taskId string) error {
+	var transactions []responses.Data
+
+	request := requests.TransactionSearchRequest{ObjectID: taskId, Limit: maniphestPageSize}
+	for {
+		response, err := mi.client.TransactionSearch(request)
+		if err != nil {
+			return err
+		}
+		transactions = append(transactions, response.Data...)
+
+		after, _ := response.Cursor.After.(string)
+		if after == "" {
+			break
+		}
+		request.After = after
+	}
+
+	// The transactions are returned newest first
+	sort.SliceStable(transactions, func(i, j int) bool {
+		return transactions[i].ID < transactions[j].ID
+	})
+
+	for _, t := range transactions {
+		if err := mi.importTransaction(b, t); err != nil {
+			return errors.Wrapf(err, "transaction %s", t.Phid)
+		}
+	}
+	return nil
+}
+
+// importTransaction adds a comment to the ticket, or its edit, or applies a status change,
+// unless it was already imported
+func (mi *maniphestImporter) importTransaction(b *cache.BugCache, t responses.Data) error {
+	if t.Type != "comment" && t.Type != "status" {
+		return nil
+	}
+
+	author, err := mi.identity(t.AuthorPHID)
+	if err != nil {
+		return err
+	}
+	metadata := map[string]string{maniphestTransactionMetadataKey: t.Phid}
+
+	target, err := b.ResolveOperationWithMetadata(maniphestTransactionMetadataKey, t.Phid)
+	if err != nil && err != cache.ErrNoMatchingOp {
+		return err
+	}
+	imported := err == nil
+
+	switch t.Type {
+	case "comment":
+		// The versions of the comment are returned newest first
+		if len(t.Comments) == 0 || t.Comments[0].Removed {
+			return nil
+		}
+		message, _ := t.Comments[0].Content["raw"].(string)
+
+		if !imported {
+			_, err = b.AddCommentRaw(author, time.Time(t.DateCreated).Unix(), message, nil, metadata)
+			return err
+		}
+		for _, comment := range b.Snapshot().Comments {
+			if comment.Id() == target && comment.Message != message {
+				_, err = b.EditCommentRaw(author, time.Time(t.Comments[0].DateModified).Unix(), target, message, nil)
+				return err
+			}
+		}
+
+	case "status":
+		if imported {
+			return nil
+		}
+		value, _ := t.Fields["new"].(string)
+		return setStatus(b, author, time.Time(t.DateCreated).Unix(), mi.status(value), metadata)
+	}
+
+	return nil
+}
+
+// importFields applies the title, description, projects, priority, owner, subscribers and status
+// of the task to the ticket where they differ
+func (mi *maniphestImporter) importFields(b *cache.BugCache, task maniphestTask) error {
+	snap := b.Snapshot()
+	unixTime := task.modified.Unix()
+
+	if task.title != snap.Title {
+		if _, err := b.SetTitleRaw(mi.user, unixTime, task.title, nil); err != nil {
+			return err
+		}
+	}
+	if task.description != snap.Comments[0].Message {
+		if _, err := b.EditCreateCommentRaw(mi.user, unixTime, task.description, nil); err != nil {
+			return err
+		}
+	}
+
+	projects, err := mi.projectSlugs(task.projects)
+	if err != nil {
+		return err
+	}
+	if err := setLabels(b, mi.user, unixTime, maniphestProjectLabelPrefix, projects); err != nil {
+		return err
+	}
+
+	var priority []string
+	if task.priority != "" {
+		priority = []string{strings.ReplaceAll(strings.ToLower(task.priority), " ", "-")}
+	}
+	if err := setLabels(b, mi.user, unixTime, maniphestPriorityLabelPrefix, priority); err != nil {
+		return err
+	}
+
+	if task.owner != "" {
+		owner, err := mi.identity(task.owner)
+		if err != nil {
+			return err
+		}
+		if snap.Assignee == nil || snap.Assignee.Id() != owner.Id() {
+			if _, err := b.SetAssigneeRaw(mi.user, unixTime, nil, owner); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := mi.importSubscribers(b, task, unixTime); err != nil {
+		return err
+	}
+
+	// The current status, in case the transactions didn't reach it
+	return setStatus(b, mi.user, unixTime, mi.status(task.status), nil)
+}
+
+// ManiphestSubscribers returns the ids of the identities subscribed to the task the ticket was
+// imported from
+func ManiphestSubscribers(snap *bug.Snapshot) []string {
+	for i := len(snap.Operations) - 1; i >= 0; i-- {
+		op, ok := snap.Operations[i].(*bug.SetMetadataOperation)
+		if !ok {
+			continue
+		}
+		if value, ok := op.NewMetadata[maniphestSubscribersMetadataKey]; ok {
+			if value == "" {
+				return nil
+			}
+			return strings.Split(value, ",")
+		}
+	}
+	return nil
+}
+
+// importSubscribers records the subscribers of the task if they changed
+func (mi *maniphestImporter) importSubscribers(b *cache.BugCache, task maniphestTask, unixTime int64) error {
+	var subscribers []string
+	for _, phid := range task.subscribers {
+		// The subscribers can be projects too
+		if !strings.HasPrefix(phid, "PHID-USER-") {
+			continue
+		}
+		i, err := mi.identity(phid)
+		if err != nil {
+			return err
+		}
+		subscribers = append(subscribers, i.Id().String())
+	}
+	sort.Strings(subscribers)
+
+	snap := b.Snapshot()
+	if strings.Join(subscribers, ",") == strings.Join(ManiphestSubscribers(snap), ",") {
+		return nil
+	}
+
+	_, err := b.SetMetadataRaw(mi.user, unixTime, snap.Operations[0].Id(), map[string]string{
+		maniphestSubscribersMetadataKey: strings.Join(subscribers, ","),
+	})
+	return err
+}
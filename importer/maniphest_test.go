@@ -0,0 +1,278 @@
+package importer
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thought-machine/gonduit"
+	"github.com/thought-machine/gonduit/core"
+
+	"github.com/daedaleanai/git-ticket/bridge"
+	"github.com/daedaleanai/git-ticket/bug"
+)
+
+// fakeConduit serves the search methods of the Conduit API used by the Maniphest import
+type fakeConduit struct {
+	mu           sync.Mutex
+	tasks        []map[string]interface{}
+	transactions map[string][]map[string]interface{}
+	users        map[string]map[string]interface{}
+	projects     map[string]map[string]interface{}
+}
+
+func newFakeConduit(t *testing.T) (*fakeConduit, *gonduit.Conn) {
+	fake := &fakeConduit{
+		transactions: make(map[string][]map[string]interface{}),
+		users: map[string]map[string]interface{}{
+			"PHID-USER-alice": {"username": "alice", "realName": "Alice"},
+			"PHID-USER-bob":   {"username": "bob", "realName": "Bob"},
+		},
+		projects: map[string]map[string]interface{}{
+			"PHID-PROJ-engine": {"name": "Engine", "slug": "engine"},
+		},
+	}
+	server := httptest.NewServer(http.HandlerFunc(fake.serve))
+	t.Cleanup(server.Close)
+
+	client, err := gonduit.Dial(server.URL, &core.ClientOptions{APIToken: "secret"})
+	require.NoError(t, err)
+	return fake, client
+}
+
+func (f *fakeConduit) serve(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var params struct {
+		QueryKey    string                 `json:"queryKey"`
+		ObjectID    string                 `json:"objectIdentifier"`
+		Constraints map[string]interface{} `json:"constraints"`
+	}
+	_ = json.Unmarshal([]byte(r.FormValue("params")), &params)
+
+	withPhids := func(all map[string]map[string]interface{}) []interface{} {
+		var data []interface{}
+		phids, _ := params.Constraints["phids"].([]interface{})
+		for _, phid := range phids {
+			if fields, ok := all[phid.(string)]; ok {
+				data = append(data, map[string]interface{}{"phid": phid, "fields": fields})
+			}
+		}
+		return data
+	}
+
+	var data []interface{}
+	switch strings.TrimPrefix(r.URL.Path, "/api/") {
+	case "conduit.getcapabilities":
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"result": map[string]interface{}{
+			"authentication": []string{"token"}, "input": []string{"urlencoded"}, "output": []string{"json"},
+		}})
+		return
+	case "maniphest.querystatuses":
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"result": map[string]interface{}{
+			"defaultClosedStatus": "resolved",
+			"openStatuses":        []string{"open", "triaged"},
+			"closedStatuses":      map[string]string{"2": "resolved", "3": "wontfix", "4": "invalid"},
+		}})
+		return
+	case "maniphest.search":
+		ids, _ := params.Constraints["ids"].([]interface{})
+		for _, task := range f.tasks {
+			for _, id := range ids {
+				if id.(float64) == float64(task["id"].(int)) {
+					data = append(data, task)
+				}
+			}
+			if params.QueryKey == "all" {
+				data = append(data, task)
+			}
+		}
+	case "transaction.search":
+		// Newest first
+		transactions := f.transactions[params.ObjectID]
+		for i := len(transactions) - 1; i >= 0; i-- {
+			data = append(data, transactions[i])
+		}
+	case "user.search":
+		data = withPhids(f.users)
+	case "project.search":
+		data = withPhids(f.projects)
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"result":     map[string]interface{}{"data": data, "cursor": map[string]interface{}{"after": nil}},
+		"error_code": nil,
+	})
+}
+
+func (f *fakeConduit) addTask(id int, fields map[string]interface{}, subscribers []string, projects []string) {
+	f.tasks = append(f.tasks, map[string]interface{}{
+		"id": id, "phid": "PHID-TASK-" + fields["name"].(string), "fields": fields,
+		"attachments": map[string]interface{}{
+			"subscribers": map[string]interface{}{"subscriberPHIDs": subscribers},
+			"projects":    map[string]interface{}{"projectPHIDs": projects},
+		},
+	})
+}
+
+func (f *fakeConduit) addTransaction(task string, transaction map[string]interface{}) {
+	transaction["id"] = len(f.transactions[task]) + 1
+	f.transactions[task] = append(f.transactions[task], transaction)
+}
+
+func comment(phid string, author string, date int, content string) map[string]interface{} {
+	return map[string]interface{}{
+		"phid": phid, "type": "comment", "authorPHID": author, "dateCreated": date,
+		"comments": []interface{}{map[string]interface{}{
+			"phid": phid + "-1", "dateModified": date, "content": map[string]interface{}{"raw": content},
+		}},
+	}
+}
+
+func TestImportManiphest(t *testing.T) {
+	repoCache := newImportTestCache(t)
+	fake, client := newFakeConduit(t)
+	opts := ManiphestOptions{Workflow: "workflow:qa", Repo: "repo:rocket"}
+
+	bob, err := repoCache.NewIdentityWithPhabIdRaw("Bob", "bob@example.com", "", "", nil, "PHID-USER-bob")
+	require.NoError(t, err)
+
+	fake.addTask(12, map[string]interface{}{
+		"name": "Engine explodes", "description": map[string]interface{}{"raw": "On ignition."},
+		"authorPHID": "PHID-USER-alice", "ownerPHID": "PHID-USER-bob",
+		"status":      map[string]interface{}{"value": "resolved"},
+		"priority":    map[string]interface{}{"name": "Unbreak Now!"},
+		"dateCreated": 1614852000, "dateModified": 1614938400,
+	}, []string{"PHID-USER-alice", "PHID-USER-bob", "PHID-PROJ-engine"}, []string{"PHID-PROJ-engine"})
+	fake.addTransaction("T12", map[string]interface{}{"phid": "PHID-XACT-1", "type": "create", "authorPHID": "PHID-USER-alice", "dateCreated": 1614852000})
+	fake.addTransaction("T12", comment("PHID-XACT-2", "PHID-USER-bob", 1614855600, "Can't reproduce"))
+	fake.addTransaction("T12", map[string]interface{}{
+		"phid": "PHID-XACT-3", "type": "status", "authorPHID": "PHID-USER-bob", "dateCreated": 1614938400,
+		"fields": map[string]interface{}{"old": "open", "new": "resolved"},
+	})
+
+	result, err := ImportManiphest(repoCache, client, []string{"T12"}, opts)
+	require.NoError(t, err)
+	assert.Equal(t, bridge.Result{Created: 1}, result)
+
+	b, err := repoCache.ResolveBugCreateMetadata(maniphestIdMetadataKey, "T12")
+	require.NoError(t, err)
+	snap := b.Snapshot()
+	assert.Equal(t, "Engine explodes", snap.Title)
+	assert.Equal(t, "On ignition.", snap.Comments[0].Message)
+	assert.Equal(t, "Alice", snap.Author.Name())
+	alice, err := repoCache.ResolveIdentityPhabID("PHID-USER-alice")
+	require.NoError(t, err)
+	assert.Equal(t, alice.Id(), snap.Author.Id())
+	require.Len(t, snap.Comments, 2)
+	assert.Equal(t, bob.Id(), snap.Comments[1].Author.Id())
+	assert.True(t, snap.HasLabel("phab:engine"))
+	assert.True(t, snap.HasLabel("priority:unbreak-now!"))
+	require.NotNil(t, snap.Assignee)
+	assert.Equal(t, bob.Id(), snap.Assignee.Id())
+	assert.Equal(t, bug.DoneStatus, snap.Status)
+	assert.ElementsMatch(t, []string{alice.Id().String(), bob.Id().String()}, ManiphestSubscribers(snap))
+	assert.Equal(t, "maniphest", snap.Operations[0].AllMetadata()[bridge.OriginMetadataKey])
+
+	// Importing the task again changes nothing
+	operations := len(snap.Operations)
+	identities := len(repoCache.AllIdentityIds())
+	result, err = ImportManiphest(repoCache, client, []string{"all"}, opts)
+	require.NoError(t, err)
+	assert.Equal(t, bridge.Result{Unchanged: 1}, result)
+	assert.Len(t, b.Snapshot().Operations, operations)
+	assert.Len(t, repoCache.AllIdentityIds(), identities)
+
+	// The comment is edited, a subscriber leaves and the priority changes
+	fake.transactions["T12"][1]["comments"] = []interface{}{map[string]interface{}{
+		"phid": "PHID-XACT-2-2", "dateModified": 1615024800, "content": map[string]interface{}{"raw": "Reproduced"},
+	}}
+	task := fake.tasks[0]
+	task["fields"].(map[string]interface{})["priority"] = map[string]interface{}{"name": "Low"}
+	task["fields"].(map[string]interface{})["dateModified"] = 1615024800
+	task["attachments"].(map[string]interface{})["subscribers"] = map[string]interface{}{"subscriberPHIDs": []string{"PHID-USER-bob"}}
+
+	result, err = ImportManiphest(repoCache, client, []string{"T12"}, opts)
+	require.NoError(t, err)
+	assert.Equal(t, bridge.Result{Updated: 1}, result)
+	snap = b.Snapshot()
+	require.Len(t, snap.Comments, 2)
+	assert.Equal(t, "Reproduced", snap.Comments[1].Message)
+	assert.True(t, snap.HasLabel("priority:low"))
+	assert.False(t, snap.HasLabel("priority:unbreak-now!"))
+	assert.Equal(t, []string{bob.Id().String()}, ManiphestSubscribers(snap))
+	assert.Len(t, repoCache.AllBugsIds(), 1)
+}
+
+func TestImportManiphestStatuses(t *testing.T) {
+	repoCache := newImportTestCache(t)
+	fake, client := newFakeConduit(t)
+	opts := ManiphestOptions{Workflow: "workflow:qa", Repo: "repo:rocket"}
+
+	// The task is created open then moved to a custom open status
+	fake.addTask(13, map[string]interface{}{
+		"name": "Fuel leaks", "description": map[string]interface{}{"raw": ""},
+		"authorPHID":  "PHID-USER-alice",
+		"status":      map[string]interface{}{"value": "triaged"},
+		"priority":    map[string]interface{}{"name": "Normal"},
+		"dateCreated": 1614852000, "dateModified": 1614855600,
+	}, nil, nil)
+	fake.addTransaction("T13", map[string]interface{}{"phid": "PHID-XACT-10", "type": "create", "authorPHID": "PHID-USER-alice", "dateCreated": 1614852000})
+	fake.addTransaction("T13", map[string]interface{}{
+		"phid": "PHID-XACT-11", "type": "status", "authorPHID": "PHID-USER-alice", "dateCreated": 1614852000,
+		"fields": map[string]interface{}{"old": nil, "new": "open"},
+	})
+	fake.addTransaction("T13", map[string]interface{}{
+		"phid": "PHID-XACT-12", "type": "status", "authorPHID": "PHID-USER-bob", "dateCreated": 1614855600,
+		"fields": map[string]interface{}{"old": "open", "new": "triaged"},
+	})
+
+	result, err := ImportManiphest(repoCache, client, []string{"T13"}, opts)
+	require.NoError(t, err)
+	assert.Equal(t, bridge.Result{Created: 1}, result)
+
+	b, err := repoCache.ResolveBugCreateMetadata(maniphestIdMetadataKey, "T13")
+	require.NoError(t, err)
+	assert.Equal(t, bug.ProposedStatus, b.Snapshot().Status)
+	for _, phid := range []string{"PHID-XACT-11", "PHID-XACT-12"} {
+		_, err := b.ResolveOperationWithMetadata(maniphestTransactionMetadataKey, phid)
+		assert.NoError(t, err, phid)
+	}
+
+	// Importing the task again changes nothing
+	operations := len(b.Snapshot().Operations)
+	result, err = ImportManiphest(repoCache, client, []string{"T13"}, opts)
+	require.NoError(t, err)
+	assert.Equal(t, bridge.Result{Unchanged: 1}, result)
+	assert.Len(t, b.Snapshot().Operations, operations)
+
+	// The closed statuses other than resolved reject the ticket
+	fake.tasks[0]["fields"].(map[string]interface{})["status"] = map[string]interface{}{"value": "wontfix"}
+	fake.tasks[0]["fields"].(map[string]interface{})["dateModified"] = 1614938400
+	result, err = ImportManiphest(repoCache, client, []string{"T13"}, opts)
+	require.NoError(t, err)
+	assert.Equal(t, bridge.Result{Updated: 1}, result)
+	assert.Equal(t, bug.RejectedStatus, b.Snapshot().Status)
+}
+
+func TestManiphestTaskIds(t *testing.T) {
+	ids, err := maniphestTaskIds([]string{"T1", "T23"})
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 23}, ids)
+
+	ids, err = maniphestTaskIds([]string{"open"})
+	require.NoError(t, err)
+	assert.Nil(t, ids)
+
+	_, err = maniphestTaskIds([]string{"T1", "open"})
+	assert.EqualError(t, err, `invalid task "open", expected a T-number or a single query key`)
+}
@@ -250,21 +250,77 @@ const checklistPreamble = `# %s
 
 # Leave lines starting with '#' unchanged.
 # States in [] can be: TBD, PASSED, FAILED or NA. Abbreviations accepted.
-# Any text entered between the question and state will be saved as a comment.
+# Numeric, text and link questions expect their answer on a single line in {}.
+# Any other text entered between the question and state will be saved as a comment.
 # Saving an empty (or invalid format) aborts the operation.
 
 `
 
+// checklistQuestionHint describes the answer expected for a question in the checklist editor
+func checklistQuestionHint(q config.ChecklistQuestion) string {
+	var hints []string
+
+	switch q.Type {
+	case config.NumericQuestion:
+		if thresholds := q.Thresholds(); thresholds != "" {
+			hints = append(hints, "numeric, "+thresholds)
+		} else {
+			hints = append(hints, "numeric")
+		}
+	case config.TextQuestion:
+		hints = append(hints, "text evidence")
+	case config.LinkQuestion:
+		hints = append(hints, "URLs or commit hashes")
+	}
+	if q.CommentOnFailure {
+		hints = append(hints, "comment required if failed")
+	}
+
+	if len(hints) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(hints, "; ") + ")"
+}
+
 // ChecklistEditorInput will open the default editor in the terminal with a
 // checklist for the user to fill. The file is then processed to extract the
 // comment and status for each question, results are added to checklist.
+// The conditions of the checklist are evaluated again with the new answers,
+// and the editor is opened again if they changed the questions which apply.
 // Returns bool indicating if anything changed and any error value.
-func ChecklistEditorInput(repo repository.RepoCommon, checklist config.Checklist, ignoreBackup bool) (bool, error) {
+func ChecklistEditorInput(repo repository.RepoCommon, checklist config.Checklist, evaluate func(config.Checklist) config.Checklist, ignoreBackup bool) (bool, error) {
 	if checklist.Deprecated != "" {
 		return false, fmt.Errorf("%s is deprecated: %s",
 			checklist.Label, checklist.Deprecated)
 	}
 
+	var checklistChanged bool
+	for {
+		changed, err := editChecklist(repo, checklist, ignoreBackup)
+		checklistChanged = checklistChanged || changed
+		if err != nil {
+			return checklistChanged, err
+		}
+
+		evaluated := evaluate(checklist)
+		if checklist.SameVisibility(evaluated) {
+			return checklistChanged, nil
+		}
+
+		// The sections are shared with the caller, which gets the questions which apply too
+		for sn := range checklist.Sections {
+			checklist.Sections[sn].Hidden = evaluated.Sections[sn].Hidden
+			for qn := range checklist.Sections[sn].Questions {
+				checklist.Sections[sn].Questions[qn].Hidden = evaluated.Sections[sn].Questions[qn].Hidden
+			}
+		}
+		ignoreBackup = true
+	}
+}
+
+// editChecklist opens the editor once with the questions of the checklist which apply to the
+// ticket and saves the answers in the checklist
+func editChecklist(repo repository.RepoCommon, checklist config.Checklist, ignoreBackup bool) (bool, error) {
 	checklistBackupFile := ".git-ticket." + bug.Label(checklist.Label).String() + ".backup"
 
 	var template string
@@ -276,9 +332,18 @@ func ChecklistEditorInput(repo repository.RepoCommon, checklist config.Checklist
 	} else {
 		template = fmt.Sprintf(checklistPreamble, checklist.Title)
 		for sn, s := range checklist.Sections {
+			if s.Hidden {
+				continue
+			}
 			template = template + fmt.Sprintf("#\n#### %s ####\n#\n", s.Title)
 			for qn, q := range s.Questions {
-				template = template + fmt.Sprintf("# %d.%d : %s\n", sn+1, qn+1, q.Question)
+				if q.Hidden {
+					continue
+				}
+				template = template + fmt.Sprintf("# %d.%d : %s%s\n", sn+1, qn+1, q.Question, checklistQuestionHint(q))
+				if q.Type != config.StateQuestion {
+					template = template + fmt.Sprintf("{%s}\n", q.Value)
+				}
 				if len(strings.TrimSpace(q.Comment)) != 0 {
 					template = template + fmt.Sprintf("%s\n", q.Comment)
 				}
@@ -300,13 +365,33 @@ func ChecklistEditorInput(repo repository.RepoCommon, checklist config.Checklist
 	lines := strings.Split(raw, "\n")
 
 	var commentText string
+	var valueText string
 	var inComment bool
 	var checklistChanged bool
+	var answerErrors []string
 	nextS := 1
 	nextQ := 1
+	lastS := 0
+
+	// skipHidden moves to the next question which applies to the ticket, if the current one doesn't
+	skipHidden := func() {
+		for nextS <= len(checklist.Sections) {
+			section := checklist.Sections[nextS-1]
+			if nextQ > len(section.Questions) {
+				nextS++
+				nextQ = 1
+			} else if section.Hidden || section.Questions[nextQ-1].Hidden {
+				nextQ++
+			} else {
+				return
+			}
+		}
+	}
+	skipHidden()
 
 	questionSearch, _ := regexp.Compile(`^# (\d+)\.(\d+) : (\w+)`)
 	stateSearch, _ := regexp.Compile(`^\[(.+)\]$`)
+	valueSearch, _ := regexp.Compile(`^\{(.*)\}$`)
 
 	for l, line := range lines {
 		if !inComment {
@@ -327,7 +412,8 @@ func ChecklistEditorInput(repo repository.RepoCommon, checklist config.Checklist
 				}
 				inComment = true
 				commentText = ""
-			} else if nextQ != 1 {
+				valueText = ""
+			} else if nextS == lastS {
 				// next question line missing
 				return checklistChanged, fmt.Errorf("checklist parse error (question line), line %d", l)
 			}
@@ -344,17 +430,27 @@ func ChecklistEditorInput(repo repository.RepoCommon, checklist config.Checklist
 					checklist.Sections[nextS-1].Questions[nextQ-1].Comment = strippedCommentText
 					checklistChanged = true
 				}
+				// check and save value
+				strippedValueText := strings.TrimSpace(valueText)
+				if checklist.Sections[nextS-1].Questions[nextQ-1].Value != strippedValueText {
+					checklist.Sections[nextS-1].Questions[nextQ-1].Value = strippedValueText
+					checklistChanged = true
+				}
 				// check and save state
 				if checklist.Sections[nextS-1].Questions[nextQ-1].State != newState {
 					checklist.Sections[nextS-1].Questions[nextQ-1].State = newState
 					checklistChanged = true
 				}
-				nextQ++
-				if nextQ > len(checklist.Sections[nextS-1].Questions) {
-					nextS++
-					nextQ = 1
+				// check the answer complies with the question type
+				if err := checklist.Sections[nextS-1].Questions[nextQ-1].ValidateAnswer(); err != nil {
+					answerErrors = append(answerErrors, fmt.Sprintf("question %d.%d: %s", nextS, nextQ, err))
 				}
+				lastS = nextS
+				nextQ++
+				skipHidden()
 				inComment = false
+			} else if valueSearch.MatchString(line) {
+				valueText = valueSearch.FindStringSubmatch(line)[1]
 			} else {
 				// we're still in the comment section
 				commentText = commentText + line + "\n"
@@ -366,6 +462,10 @@ func ChecklistEditorInput(repo repository.RepoCommon, checklist config.Checklist
 		return checklistChanged, fmt.Errorf("checklist parse error, section/question count")
 	}
 
+	if len(answerErrors) > 0 {
+		return checklistChanged, fmt.Errorf("invalid checklist answers:\n%s", strings.Join(answerErrors, "\n"))
+	}
+
 	os.Remove(checklistBackupFile)
 
 	return checklistChanged, nil
@@ -0,0 +1,486 @@
+// Package notify sends to the people involved in tickets a digest of the changes by email
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/daedaleanai/git-ticket/bug"
+	"github.com/daedaleanai/git-ticket/cache"
+	"github.com/daedaleanai/git-ticket/entity"
+	"github.com/daedaleanai/git-ticket/identity"
+	"github.com/daedaleanai/git-ticket/util/lamport"
+)
+
+// configKeyPrefix is the prefix of the keys of the notification configuration in the git config
+const configKeyPrefix = "git-bug.notify."
+
+const (
+	serverConfigKey   = "smtp-server"
+	usernameConfigKey = "smtp-username"
+	passwordConfigKey = "smtp-password"
+	fromConfigKey     = "from"
+	templateConfigKey = "template"
+)
+
+// stateFile is the file of the git-bug directory of the repository recording the notified
+// operations
+const stateFile = "notify-state"
+
+// notifiedTail is the number of ids of the last notified operations of each ticket kept in the
+// state, to recognize them once the operations of other clones are merged before them
+const notifiedTail = 100
+
+// OptOutMetadataKey is the metadata key of the identities not wanting notifications
+const OptOutMetadataKey = "notify-opt-out"
+
+// defaultTemplate renders a digest, the "subject" and the "body" templates are both required
+const defaultTemplate = `{{define "subject"}}[git-ticket] {{len .Tickets}} updated ticket(s){{end}}
+{{- define "body"}}Hello {{.Name}},
+
+{{range .Tickets}}{{.Id.Human}} [{{.Status}}] {{.Title}}
+{{range .Changes}}    {{.}}
+{{end}}
+{{end}}--
+You receive this digest because you are involved in these tickets.
+Run "git ticket notify opt-out" to stop receiving it.
+{{end}}`
+
+// Config is the configuration of the notifications, read from the git config of the repository
+type Config struct {
+	// Server is the host:port of the SMTP server
+	Server   string
+	Username string
+	Password string
+	// From is the sender address of the emails
+	From string
+	// Template is the path of a file with the "subject" and "body" templates of the emails
+	Template string
+}
+
+// Configured tells if the repository has a notification configuration
+func Configured(repo *cache.RepoCache) bool {
+	server, err := repo.LocalConfig().ReadString(configKeyPrefix + serverConfigKey)
+	return err == nil && server != ""
+}
+
+// LoadConfig reads the notification configuration from the git config of the repository
+func LoadConfig(repo *cache.RepoCache) (Config, error) {
+	values, err := repo.LocalConfig().ReadAll(configKeyPrefix)
+	if err != nil {
+		return Config{}, err
+	}
+
+	conf := Config{
+		Server:   values[configKeyPrefix+serverConfigKey],
+		Username: values[configKeyPrefix+usernameConfigKey],
+		Password: values[configKeyPrefix+passwordConfigKey],
+		From:     values[configKeyPrefix+fromConfigKey],
+		Template: values[configKeyPrefix+templateConfigKey],
+	}
+	if conf.Server == "" || conf.From == "" {
+		return conf, fmt.Errorf("notifications aren't configured, set %s%s and %s%s in the git config",
+			configKeyPrefix, serverConfigKey, configKeyPrefix, fromConfigKey)
+	}
+	return conf, nil
+}
+
+// TicketChanges are the changes of a ticket in a digest
+type TicketChanges struct {
+	Id     entity.Id
+	Title  string
+	Status bug.Status
+	// Changes are the timeline entries of the changes, oldest first
+	Changes []string
+}
+
+// Digest is the email sent to a recipient, with the changes made by other people to the tickets
+// the recipient is involved in
+type Digest struct {
+	Name    string
+	Email   string
+	Tickets []TicketChanges
+
+	recipient entity.Id
+	// ops are the ids of the operations of each ticket the recipient was notified of
+	ops map[entity.Id][]entity.Id
+}
+
+// ticketState is the cursor of the notified operations of a ticket. Operations are recognized by
+// their ids rather than their edit lamport time, as the operations made in other clones may have
+// been stored with an older time than the ones notified already. They are merged before the
+// local operations not pushed yet, which are among the last ones notified.
+type ticketState struct {
+	// EditTime is the edit lamport time of the ticket when it was notified
+	EditTime lamport.Time
+	// Operations is the number of operations of the ticket when it was notified
+	Operations int
+	// Tail are the ids of the last notified operations, at most notifiedTail of them
+	Tail []entity.Id
+}
+
+// state records which operations were notified
+type state struct {
+	Tickets map[entity.Id]ticketState
+	// Pending are the ids of the operations of each ticket the recipients who missed a digest
+	// weren't notified of
+	Pending map[entity.Id]map[entity.Id][]entity.Id
+}
+
+// Notifier computes and sends the digests of the changes made since the last notification
+type Notifier struct {
+	repo     *cache.RepoCache
+	conf     Config
+	template *template.Template
+
+	// notified are the cursors of the tickets, unless the notifications were never sent
+	notified    map[entity.Id]ticketState
+	initialized bool
+	// pending are the ids of the operations the recipients who missed a digest weren't
+	// notified of, and pendingTickets the tickets of these operations
+	pending        map[entity.Id]map[entity.Id]bool
+	pendingTickets map[entity.Id]bool
+	// current are the cursors of the tickets, recorded once the digests are sent
+	current map[entity.Id]ticketState
+}
+
+// NewNotifier reads the state of the notifications and the template of the digests
+func NewNotifier(repo *cache.RepoCache, conf Config) (*Notifier, error) {
+	n := &Notifier{
+		repo:           repo,
+		conf:           conf,
+		notified:       make(map[entity.Id]ticketState),
+		pending:        make(map[entity.Id]map[entity.Id]bool),
+		pendingTickets: make(map[entity.Id]bool),
+		current:        make(map[entity.Id]ticketState),
+	}
+
+	text := defaultTemplate
+	if conf.Template != "" {
+		data, err := os.ReadFile(conf.Template)
+		if err != nil {
+			return nil, errors.Wrap(err, "can't read the notification template")
+		}
+		text = string(data)
+	}
+	var err error
+	n.template, err = template.New("digest").Parse(text)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid notification template")
+	}
+	for _, name := range []string{"subject", "body"} {
+		if n.template.Lookup(name) == nil {
+			return nil, fmt.Errorf("invalid notification template: no %q template", name)
+		}
+	}
+
+	data, err := os.ReadFile(stateFilePath(repo))
+	if os.IsNotExist(err) {
+		return n, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "can't read the notification state")
+	}
+	var st state
+	if err := json.Unmarshal(data, &st); err != nil {
+		return nil, errors.Wrap(err, "invalid notification state")
+	}
+
+	n.initialized = true
+	for id, ts := range st.Tickets {
+		n.notified[id] = ts
+		n.current[id] = ts
+	}
+	for recipient, tickets := range st.Pending {
+		n.pending[recipient] = make(map[entity.Id]bool)
+		for id, ops := range tickets {
+			n.pendingTickets[id] = true
+			for _, op := range ops {
+				n.pending[recipient][op] = true
+			}
+		}
+	}
+
+	return n, nil
+}
+
+func stateFilePath(repo *cache.RepoCache) string {
+	return path.Join(repo.GetPath(), "git-bug", stateFile)
+}
+
+// newTicketState returns the cursor of a ticket whose operations are all notified
+func newTicketState(excerpt *cache.BugExcerpt, ops []bug.Operation) ticketState {
+	ts := ticketState{EditTime: excerpt.EditLamportTime, Operations: len(ops)}
+	start := len(ops) - notifiedTail
+	if start < 0 {
+		start = 0
+	}
+	for _, op := range ops[start:] {
+		ts.Tail = append(ts.Tail, op.Id())
+	}
+	return ts
+}
+
+// unnotified returns the ids of the operations of a ticket which weren't notified
+func (n *Notifier) unnotified(id entity.Id, ops []bug.Operation) map[entity.Id]bool {
+	ts := n.notified[id]
+
+	// the operations before the tail didn't move
+	start := ts.Operations - len(ts.Tail)
+	if start > len(ops) {
+		start = len(ops)
+	}
+	tail := make(map[entity.Id]bool)
+	for _, op := range ts.Tail {
+		tail[op] = true
+	}
+
+	result := make(map[entity.Id]bool)
+	for _, op := range ops[start:] {
+		if !tail[op.Id()] {
+			result[op.Id()] = true
+		}
+	}
+	return result
+}
+
+// Digests computes the digests of the changes made since the last notification. The recipients
+// are the assignees, the CCB members and the participants of the tickets, except the ones who
+// opted out. The first time, there is nothing to notify: only the later changes are.
+func (n *Notifier) Digests() ([]Digest, error) {
+	digests := make(map[entity.Id]*Digest)
+	optedOut := make(map[entity.Id]bool)
+
+	for _, id := range n.repo.AllBugsIds() {
+		excerpt, err := n.repo.ResolveBugExcerpt(id)
+		if err != nil {
+			return nil, err
+		}
+		ts, ok := n.notified[id]
+		if ok && ts.EditTime == excerpt.EditLamportTime && ts.Operations == excerpt.LenOperations && !n.pendingTickets[id] {
+			continue
+		}
+
+		b, err := n.repo.ResolveBug(id)
+		if err != nil {
+			return nil, err
+		}
+		snap := b.Snapshot()
+
+		n.current[id] = newTicketState(excerpt, snap.Operations)
+		if !n.initialized {
+			continue
+		}
+		unnotified := n.unnotified(id, snap.Operations)
+
+		for _, recipient := range recipients(snap) {
+			if recipient.Email() == "" {
+				continue
+			}
+			out, ok := optedOut[recipient.Id()]
+			if !ok {
+				out, err = n.optedOut(recipient.Id())
+				if err != nil {
+					return nil, err
+				}
+				optedOut[recipient.Id()] = out
+			}
+			if out {
+				continue
+			}
+
+			var ops []bug.Operation
+			for _, op := range snap.Operations {
+				if unnotified[op.Id()] || n.pending[recipient.Id()][op.Id()] {
+					ops = append(ops, op)
+				}
+			}
+			changes := changesFor(snap, ops, recipient.Id())
+			if len(changes) == 0 {
+				continue
+			}
+
+			digest, ok := digests[recipient.Id()]
+			if !ok {
+				digest = &Digest{
+					Name:      recipient.DisplayName(),
+					Email:     recipient.Email(),
+					recipient: recipient.Id(),
+					ops:       make(map[entity.Id][]entity.Id),
+				}
+				digests[recipient.Id()] = digest
+			}
+			for _, op := range ops {
+				digest.ops[id] = append(digest.ops[id], op.Id())
+			}
+			digest.Tickets = append(digest.Tickets, TicketChanges{
+				Id:      snap.Id(),
+				Title:   snap.Title,
+				Status:  snap.Status,
+				Changes: changes,
+			})
+		}
+	}
+
+	result := make([]Digest, 0, len(digests))
+	for _, digest := range digests {
+		sort.Slice(digest.Tickets, func(i, j int) bool {
+			return digest.Tickets[i].Id < digest.Tickets[j].Id
+		})
+		result = append(result, *digest)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Email < result[j].Email
+	})
+
+	return result, nil
+}
+
+func (n *Notifier) optedOut(id entity.Id) (bool, error) {
+	i, err := n.repo.ResolveIdentity(id)
+	if err == identity.ErrIdentityNotExist {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return i.MutableMetadata()[OptOutMetadataKey] == "true", nil
+}
+
+// recipients returns the assignee, the CCB members and the participants of a ticket
+func recipients(snap *bug.Snapshot) []identity.Interface {
+	var result []identity.Interface
+	seen := make(map[entity.Id]bool)
+	add := func(i identity.Interface) {
+		if i != nil && !seen[i.Id()] {
+			seen[i.Id()] = true
+			result = append(result, i)
+		}
+	}
+
+	add(snap.Assignee)
+	for _, ccb := range snap.Ccb {
+		add(ccb.User)
+	}
+	for _, p := range snap.Participants {
+		add(p)
+	}
+
+	return result
+}
+
+// changesFor returns the timeline entries of the operations not made by the recipient
+func changesFor(snap *bug.Snapshot, ops []bug.Operation, recipient entity.Id) []string {
+	ids := make(map[entity.Id]bool)
+	for _, op := range ops {
+		if op.GetAuthor().Id() != recipient {
+			ids[op.Id()] = true
+		}
+	}
+
+	var changes []string
+	for _, item := range snap.Timeline {
+		if ids[item.Id()] {
+			changes = append(changes, strings.TrimSpace(item.String()))
+		}
+	}
+	return changes
+}
+
+// Render returns the email of a digest
+func (n *Notifier) Render(digest Digest) ([]byte, error) {
+	var subject, body bytes.Buffer
+	if err := n.template.ExecuteTemplate(&subject, "subject", digest); err != nil {
+		return nil, errors.Wrap(err, "can't render the subject")
+	}
+	if err := n.template.ExecuteTemplate(&body, "body", digest); err != nil {
+		return nil, errors.Wrap(err, "can't render the body")
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", n.conf.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", (&mail.Address{Name: digest.Name, Address: digest.Email}).String())
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", strings.TrimSpace(subject.String())))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	msg.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	msg.Write(bytes.ReplaceAll(body.Bytes(), []byte("\n"), []byte("\r\n")))
+
+	return msg.Bytes(), nil
+}
+
+// Send sends the digests computed by Digests, then records the operations which were notified.
+// The recipients of the digests that failed to be sent get them with the next ones.
+func (n *Notifier) Send(digests []Digest) (int, error) {
+	var auth smtp.Auth
+	if n.conf.Username != "" {
+		host, _, err := net.SplitHostPort(n.conf.Server)
+		if err != nil {
+			return 0, errors.Wrap(err, "invalid SMTP server")
+		}
+		auth = smtp.PlainAuth("", n.conf.Username, n.conf.Password, host)
+	}
+
+	from, err := mail.ParseAddress(n.conf.From)
+	if err != nil {
+		return 0, errors.Wrap(err, "invalid sender address")
+	}
+
+	// the recipients who missed a digest and have nothing to receive now are up to date
+	st := state{Tickets: n.current, Pending: make(map[entity.Id]map[entity.Id][]entity.Id)}
+	sent := 0
+	var errs []string
+
+	for _, digest := range digests {
+		msg, err := n.Render(digest)
+		if err == nil {
+			err = smtp.SendMail(n.conf.Server, auth, from.Address, []string{digest.Email}, msg)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %s", digest.Email, err))
+			st.Pending[digest.recipient] = digest.ops
+			continue
+		}
+		sent++
+	}
+
+	data, err := json.Marshal(st)
+	if err != nil {
+		return sent, err
+	}
+	if err := os.WriteFile(stateFilePath(n.repo), data, 0644); err != nil {
+		return sent, errors.Wrap(err, "can't write the notification state")
+	}
+
+	if len(errs) > 0 {
+		return sent, fmt.Errorf("failed to send %d digests:\n%s", len(errs), strings.Join(errs, "\n"))
+	}
+	return sent, nil
+}
+
+// SetOptOut records in the metadata of an identity whether it receives notifications
+func SetOptOut(i *cache.IdentityCache, optOut bool) error {
+	err := i.Mutate(func(mutator identity.Mutator) identity.Mutator {
+		mutator.Metadata[OptOutMetadataKey] = strconv.FormatBool(optOut)
+		return mutator
+	})
+	if err != nil {
+		return err
+	}
+	return i.CommitAsNeeded()
+}
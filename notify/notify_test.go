@@ -0,0 +1,354 @@
+package notify
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/daedaleanai/git-ticket/bug"
+	"github.com/daedaleanai/git-ticket/cache"
+	"github.com/daedaleanai/git-ticket/config"
+	"github.com/daedaleanai/git-ticket/entity"
+	"github.com/daedaleanai/git-ticket/identity"
+	"github.com/daedaleanai/git-ticket/repository"
+)
+
+// fakeSMTP is a local SMTP server stand-in keeping the messages it receives
+type fakeSMTP struct {
+	listener net.Listener
+	mu       sync.Mutex
+	messages []fakeMessage
+}
+
+type fakeMessage struct {
+	from string
+	to   []string
+	data string
+}
+
+func newFakeSMTP(t *testing.T) *fakeSMTP {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	f := &fakeSMTP{listener: listener}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	return f
+}
+
+func (f *fakeSMTP) addr() string {
+	return f.listener.Addr().String()
+}
+
+func (f *fakeSMTP) serve(conn net.Conn) {
+	defer conn.Close()
+	text := textproto.NewConn(conn)
+	_ = text.PrintfLine("220 localhost ready")
+
+	var msg fakeMessage
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		command := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		switch command {
+		case "EHLO", "HELO":
+			_ = text.PrintfLine("250 localhost")
+		case "MAIL":
+			msg = fakeMessage{from: strings.TrimPrefix(line, "MAIL FROM:")}
+			_ = text.PrintfLine("250 OK")
+		case "RCPT":
+			msg.to = append(msg.to, strings.TrimPrefix(line, "RCPT TO:"))
+			_ = text.PrintfLine("250 OK")
+		case "DATA":
+			_ = text.PrintfLine("354 go ahead")
+			data, err := text.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			msg.data = string(data)
+			f.mu.Lock()
+			f.messages = append(f.messages, msg)
+			f.mu.Unlock()
+			_ = text.PrintfLine("250 OK")
+		case "QUIT":
+			_ = text.PrintfLine("221 bye")
+			return
+		default:
+			_ = text.PrintfLine("250 OK")
+		}
+	}
+}
+
+func (f *fakeSMTP) received() []fakeMessage {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	result := f.messages
+	f.messages = nil
+	return result
+}
+
+func notify(t *testing.T, repoCache *cache.RepoCache) []Digest {
+	conf, err := LoadConfig(repoCache)
+	require.NoError(t, err)
+	n, err := NewNotifier(repoCache, conf)
+	require.NoError(t, err)
+	digests, err := n.Digests()
+	require.NoError(t, err)
+	sent, err := n.Send(digests)
+	require.NoError(t, err)
+	assert.Equal(t, len(digests), sent)
+	return digests
+}
+
+func TestNotify(t *testing.T) {
+	repo := repository.CreateTestRepo(false)
+	defer repository.CleanupTestRepos(repo)
+	repository.SetupSigningKey(t, repo, "rene@descartes.fr")
+
+	repoCache, err := cache.NewRepoCache(repo, false)
+	require.NoError(t, err)
+
+	rene, err := repoCache.NewIdentity("René Descartes", "rene@descartes.fr", true, true, "")
+	require.NoError(t, err)
+	require.NoError(t, repoCache.SetUserIdentity(rene))
+	isaac, err := repoCache.NewIdentity("Isaac Newton", "isaac@newton.uk", true, true, "")
+	require.NoError(t, err)
+
+	require.NoError(t, repoCache.DoWithLockedConfigCache(func(c *config.ConfigCache) error {
+		if err := c.LabelConfig.AppendLabelToConfiguration(config.Label("repo:test")); err != nil {
+			return err
+		}
+		return c.LabelConfig.Store(repo)
+	}))
+
+	_, err = LoadConfig(repoCache)
+	assert.Error(t, err)
+	assert.False(t, Configured(repoCache))
+
+	server := newFakeSMTP(t)
+	require.NoError(t, repo.LocalConfig().StoreString("git-bug.notify.smtp-server", server.addr()))
+	require.NoError(t, repo.LocalConfig().StoreString("git-bug.notify.from", "git-ticket <tickets@example.com>"))
+	assert.True(t, Configured(repoCache))
+
+	b, _, err := repoCache.NewBug(cache.NewBugOpts{Title: "Engine explodes", Message: "On ignition.", Workflow: "workflow:eng", Repo: "repo:test"})
+	require.NoError(t, err)
+
+	// The first time, only the state is recorded
+	assert.Empty(t, notify(t, repoCache))
+	assert.Empty(t, server.received())
+
+	_, err = b.SetAssignee(isaac)
+	require.NoError(t, err)
+	_, err = b.AddComment("Please have a look")
+	require.NoError(t, err)
+	require.NoError(t, b.Commit())
+
+	// Only Isaac is notified, the changes are René's
+	digests := notify(t, repoCache)
+	require.Len(t, digests, 1)
+	assert.Equal(t, "isaac@newton.uk", digests[0].Email)
+	require.Len(t, digests[0].Tickets, 1)
+	assert.Equal(t, b.Id(), digests[0].Tickets[0].Id)
+	assert.Len(t, digests[0].Tickets[0].Changes, 2)
+
+	messages := server.received()
+	require.Len(t, messages, 1)
+	assert.Equal(t, []string{"<isaac@newton.uk>"}, messages[0].to)
+	assert.Contains(t, messages[0].data, "Subject: [git-ticket] 1 updated ticket(s)\n")
+	assert.Contains(t, messages[0].data, b.Id().Human()+" [proposed] Engine explodes\n")
+	assert.Contains(t, messages[0].data, "Please have a look")
+
+	// Nothing new
+	assert.Empty(t, notify(t, repoCache))
+
+	// Isaac's comment is notified to René, the participant
+	_, err = b.AddCommentRaw(isaac, time.Now().Unix(), "On it", nil, nil)
+	require.NoError(t, err)
+	require.NoError(t, b.Commit())
+
+	digests = notify(t, repoCache)
+	require.Len(t, digests, 1)
+	assert.Equal(t, "rene@descartes.fr", digests[0].Email)
+	assert.Len(t, server.received(), 1)
+
+	// A digest that fails to be sent is sent with the next one
+	_, err = b.AddComment("Any news?")
+	require.NoError(t, err)
+	require.NoError(t, b.Commit())
+
+	require.NoError(t, repo.LocalConfig().StoreString("git-bug.notify.smtp-server", "127.0.0.1:1"))
+	conf, err := LoadConfig(repoCache)
+	require.NoError(t, err)
+	n, err := NewNotifier(repoCache, conf)
+	require.NoError(t, err)
+	digests, err = n.Digests()
+	require.NoError(t, err)
+	sent, err := n.Send(digests)
+	assert.Error(t, err)
+	assert.Equal(t, 0, sent)
+
+	require.NoError(t, repo.LocalConfig().StoreString("git-bug.notify.smtp-server", server.addr()))
+	digests = notify(t, repoCache)
+	require.Len(t, digests, 1)
+	assert.Equal(t, "isaac@newton.uk", digests[0].Email)
+	assert.Len(t, digests[0].Tickets[0].Changes, 1)
+	assert.Len(t, server.received(), 1)
+	assert.Empty(t, notify(t, repoCache))
+
+	// Isaac opts out
+	isaacCache, err := repoCache.ResolveIdentity(isaac.Id())
+	require.NoError(t, err)
+	require.NoError(t, SetOptOut(isaacCache, true))
+	_, err = b.AddComment("Hello?")
+	require.NoError(t, err)
+	require.NoError(t, b.Commit())
+
+	assert.Empty(t, notify(t, repoCache))
+	assert.Empty(t, server.received())
+}
+
+func TestCustomTemplate(t *testing.T) {
+	repo := repository.CreateTestRepo(false)
+	defer repository.CleanupTestRepos(repo)
+
+	repoCache, err := cache.NewRepoCache(repo, false)
+	require.NoError(t, err)
+
+	_, err = NewNotifier(repoCache, Config{Template: writeTemplate(t, `{{define "body"}}{{end}}`)})
+	assert.EqualError(t, err, `invalid notification template: no "subject" template`)
+
+	n, err := NewNotifier(repoCache, Config{
+		From:     "tickets@example.com",
+		Template: writeTemplate(t, `{{define "subject"}}Änderungen{{end}}{{define "body"}}{{len .Tickets}} tickets for {{.Name}}{{end}}`),
+	})
+	require.NoError(t, err)
+
+	msg, err := n.Render(Digest{Name: "Isaac", Email: "isaac@newton.uk"})
+	require.NoError(t, err)
+
+	scanner := bufio.NewScanner(strings.NewReader(string(msg)))
+	var lines []string
+	for scanner.Scan() {
+		lines = append(lines, strings.TrimSuffix(scanner.Text(), "\r"))
+	}
+	assert.Contains(t, lines, `To: "Isaac" <isaac@newton.uk>`)
+	assert.Contains(t, lines, "Subject: =?utf-8?q?=C3=84nderungen?=")
+	assert.Equal(t, "0 tickets for Isaac", lines[len(lines)-1])
+}
+
+func writeTemplate(t *testing.T, content string) string {
+	path := t.TempDir() + "/digest.tmpl"
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+func TestNotifyOtherClone(t *testing.T) {
+	repoA, repoB, remote := repository.SetupReposAndRemote()
+	defer repository.CleanupTestRepos(repoA, repoB, remote)
+	repository.SetupSigningKey(t, repoA, "rene@descartes.fr")
+	repository.SetupSigningKey(t, repoB, "rene@descartes.fr")
+
+	cacheA, err := cache.NewRepoCache(repoA, false)
+	require.NoError(t, err)
+	cacheB, err := cache.NewRepoCache(repoB, false)
+	require.NoError(t, err)
+
+	rene, err := cacheA.NewIdentity("René Descartes", "rene@descartes.fr", true, true, "")
+	require.NoError(t, err)
+	require.NoError(t, cacheA.SetUserIdentity(rene))
+	isaac, err := cacheA.NewIdentity("Isaac Newton", "isaac@newton.uk", true, true, "")
+	require.NoError(t, err)
+	require.NoError(t, cacheA.DoWithLockedConfigCache(func(c *config.ConfigCache) error {
+		if err := c.LabelConfig.AppendLabelToConfiguration(config.Label("repo:test")); err != nil {
+			return err
+		}
+		return c.LabelConfig.Store(repoA)
+	}))
+
+	server := newFakeSMTP(t)
+	require.NoError(t, repoA.LocalConfig().StoreString("git-bug.notify.smtp-server", server.addr()))
+	require.NoError(t, repoA.LocalConfig().StoreString("git-bug.notify.from", "tickets@example.com"))
+
+	b, _, err := cacheA.NewBug(cache.NewBugOpts{Title: "Engine explodes", Message: "On ignition.", Workflow: "workflow:eng", Repo: "repo:test"})
+	require.NoError(t, err)
+	_, err = cacheA.Push("origin")
+	require.NoError(t, err)
+	require.NoError(t, cacheB.Pull("origin", io.Discard))
+	isaacB, err := cacheB.ResolveIdentity(isaac.Id())
+	require.NoError(t, err)
+	require.NoError(t, cacheB.SetUserIdentity(isaacB))
+
+	assert.Empty(t, notify(t, cacheA))
+
+	// René's changes in A are notified to nobody but move the clock of A ahead of the one of B
+	for _, message := range []string{"First", "Second", "Third"} {
+		_, err = b.AddComment(message)
+		require.NoError(t, err)
+		require.NoError(t, b.Commit())
+	}
+	assert.Empty(t, notify(t, cacheA))
+
+	// Isaac's comment in B is stored with an older edit time
+	bB, err := cacheB.ResolveBug(b.Id())
+	require.NoError(t, err)
+	_, err = bB.AddComment("On it")
+	require.NoError(t, err)
+	require.NoError(t, bB.Commit())
+	_, err = cacheB.Push("origin")
+	require.NoError(t, err)
+
+	require.NoError(t, cacheA.Pull("origin", io.Discard))
+	require.NoError(t, cacheA.Close())
+	cacheA, err = cache.NewRepoCache(repoA, false)
+	require.NoError(t, err)
+
+	digests := notify(t, cacheA)
+	require.Len(t, digests, 1)
+	assert.Equal(t, "rene@descartes.fr", digests[0].Email)
+	require.Len(t, digests[0].Tickets[0].Changes, 1)
+	assert.Contains(t, digests[0].Tickets[0].Changes[0], "On it")
+	assert.Empty(t, notify(t, cacheA))
+}
+
+func TestUnnotified(t *testing.T) {
+	author := identity.NewBare("René Descartes", "rene@descartes.fr")
+	var ops []bug.Operation
+	for i := 0; i < notifiedTail+20; i++ {
+		ops = append(ops, bug.NewAddCommentOp(author, int64(i), fmt.Sprintf("comment %d", i), nil))
+	}
+
+	// Only the last operations are kept
+	n := &Notifier{notified: map[entity.Id]ticketState{
+		"ticket": newTicketState(&cache.BugExcerpt{EditLamportTime: 5}, ops[:notifiedTail+10]),
+	}}
+	assert.Len(t, n.notified["ticket"].Tail, notifiedTail)
+	assert.Equal(t, notifiedTail+10, n.notified["ticket"].Operations)
+
+	// The operations of another clone are merged before the last local ones
+	merged := append(append(append([]bug.Operation{}, ops[:notifiedTail]...), ops[notifiedTail+10:]...), ops[notifiedTail:notifiedTail+10]...)
+	unnotified := n.unnotified("ticket", merged)
+	assert.Len(t, unnotified, 10)
+	for _, op := range ops[notifiedTail+10:] {
+		assert.True(t, unnotified[op.Id()])
+	}
+}
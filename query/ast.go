@@ -185,6 +185,21 @@ func (f *TitleFilter) Span() Span {
 func (*TitleFilter) astNode()    {}
 func (*TitleFilter) filterNode() {}
 
+// Filters a ticket by the files changed by its reviews
+type TouchesFilter struct {
+	Path LiteralMatcherNode
+	span Span
+}
+
+func (f *TouchesFilter) String() string {
+	return fmt.Sprintf("touches(%s)", f.Path)
+}
+func (f *TouchesFilter) Span() Span {
+	return f.span
+}
+func (*TouchesFilter) astNode()    {}
+func (*TouchesFilter) filterNode() {}
+
 // Filters a ticket label by checklist
 type ChecklistFilter struct {
 	Checklist LiteralMatcherNode
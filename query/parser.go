@@ -27,6 +27,7 @@ func init() {
 		"participant": parseParticipantExpression,
 		"label":       parseLabelExpression,
 		"title":       parseTitleExpression,
+		"touches":     parseTouchesExpression,
 		"checklist":   parseChecklistExpression,
 		"not":         parseNotExpression,
 		"create-before": func(parser *Parser) (AstNode, *ParseError) {
@@ -507,6 +508,21 @@ func parseTitleExpression(parser *Parser) (AstNode, *ParseError) {
 	return &TitleFilter{Title: matcher, span: firstToken.Span.Extend(span)}, err
 }
 
+func parseTouchesExpression(parser *Parser) (AstNode, *ParseError) {
+	ctx := &parser.context
+	ctx.push("While parsing Touches expression")
+	defer ctx.pop()
+
+	firstToken := parser.curToken
+	err := parser.advance()
+	if err != nil {
+		return nil, err
+	}
+
+	matcher, span, err := parser.parseDelimitedLiteralMatcher()
+	return &TouchesFilter{Path: matcher, span: firstToken.Span.Extend(span)}, err
+}
+
 func parseChecklistExpression(parser *Parser) (AstNode, *ParseError) {
 	ctx := &parser.context
 	ctx.push("While parsing Checklist expression")
@@ -120,6 +120,18 @@ func TestParseFilters(t *testing.T) {
 			nil,
 			nil,
 		},
+		{
+			`touches(src/flight)`,
+			&TouchesFilter{Path: &LiteralNode{Token{IdentToken, "src/flight", Span{8, 18}}}, span: Span{0, 19}},
+			nil,
+			nil,
+		},
+		{
+			`touches(r"src/flight/.*")`,
+			&TouchesFilter{Path: &RegexNode{Token{RegexToken, "src/flight/.*", Span{8, 24}}, *regexp.MustCompile("src/flight/.*")}, span: Span{0, 25}},
+			nil,
+			nil,
+		},
 		{
 			`checklist(r"checklist:sw-.*", passed, F)`,
 			&ChecklistFilter{Checklist: &RegexNode{Token{RegexToken, "checklist:sw-.*", Span{10, 28}}, *regexp.MustCompile("checklist:sw-.*")}, States: []config.ChecklistState{config.Passed, config.Failed}, span: Span{0, 40}},
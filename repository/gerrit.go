@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// GetGerritConfig returns the Gerrit URL, user name and HTTP password from the repository config
+func GetGerritConfig() (string, string, string, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return "", "", "", fmt.Errorf("unable to get the current working directory: %q", err)
+	}
+
+	repo, err := NewGitRepoNoInit(cwd)
+	if err == ErrNotARepo {
+		return "", "", "", fmt.Errorf("must be run from within a git repo")
+	}
+
+	var gerritUrl string
+	if gerritUrl, err = repo.LocalConfig().ReadString("gerrit.url"); err != nil {
+		if gerritUrl, err = repo.GlobalConfig().ReadString("gerrit.url"); err != nil {
+			return "", "", "", fmt.Errorf("No Gerrit URL set. Set it with:\ngit config --global --replace-all gerrit.url <URL of gerrit server>")
+		}
+	}
+	gerritUrl = strings.TrimSuffix(gerritUrl, "/")
+
+	var username string
+	if username, err = repo.LocalConfig().ReadString("gerrit.username"); err != nil {
+		if username, err = repo.GlobalConfig().ReadString("gerrit.username"); err != nil {
+			return gerritUrl, "", "", fmt.Errorf("No Gerrit user name set. Set it with:\ngit config --global --replace-all gerrit.username <your gerrit user name>")
+		}
+	}
+
+	var password string
+	if password, err = repo.LocalConfig().ReadString("gerrit.password"); err != nil {
+		if password, err = repo.GlobalConfig().ReadString("gerrit.password"); err != nil {
+			msg := `No Gerrit HTTP password set. Please go to
+	%s/settings/#HTTPCredentials click on <Generate new password> and then paste
+the password into this command
+	git config --global --replace-all gerrit.password <PASTE_PASSWORD_HERE>`
+			return gerritUrl, username, "", fmt.Errorf(msg, gerritUrl)
+		}
+	}
+
+	return gerritUrl, username, password, nil
+}
@@ -496,6 +496,77 @@ func (repo *GitRepo) storeCommitRaw(treeHash Hash, extraArgs ...string) (Hash, e
 	return Hash(stdout), nil
 }
 
+// ReadCommitData returns the raw content of a Git commit, with its signature if any
+func (repo *GitRepo) ReadCommitData(commit Hash) ([]byte, error) {
+	obj, err := repo.repo.Storer.EncodedObject(plumbing.CommitObject, plumbing.NewHash(commit.String()))
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := obj.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	return io.ReadAll(reader)
+}
+
+// StoreCommitData will store a Git commit from its raw content and return its hash
+func (repo *GitRepo) StoreCommitData(data []byte) (Hash, error) {
+	obj := repo.repo.Storer.NewEncodedObject()
+	obj.SetType(plumbing.CommitObject)
+
+	w, err := obj.Writer()
+	if err != nil {
+		return "", err
+	}
+
+	_, err = w.Write(data)
+	if err != nil {
+		return "", err
+	}
+
+	h, err := repo.repo.Storer.SetEncodedObject(obj)
+	if err != nil {
+		return "", err
+	}
+
+	return Hash(h.String()), nil
+}
+
+// SignData returns an armored detached PGP signature of the data, made with
+// the same key and gpg program `git commit-tree -S` would use.
+func (repo *GitRepo) SignData(data []byte) (string, error) {
+	program, err := repo.runGitCommand("config", "gpg.program")
+	if err != nil || program == "" {
+		program = "gpg"
+	}
+
+	signingKey, err := repo.runGitCommand("config", "user.signingkey")
+	if err != nil || signingKey == "" {
+		// Same fallback as git: the committer email selects the key
+		signingKey, err = repo.GetUserEmail()
+		if err != nil {
+			return "", errors.Wrap(err, "no signing key configured")
+		}
+	}
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+
+	cmd := exec.Command(program, "--detach-sign", "--armor", "--local-user", signingKey)
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("failed to sign data: %s", strings.TrimSpace(stderr.String()))
+	}
+
+	return stdout.String(), nil
+}
+
 // UpdateRef will create or update a Git reference
 func (repo *GitRepo) UpdateRef(ref string, hash Hash) error {
 	_, err := repo.runGitCommand("update-ref", ref, string(hash))
@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// DefaultGithubApiUrl is the API URL of github.com, used when github.url isn't set
+const DefaultGithubApiUrl = "https://api.github.com"
+
+// GetGithubConfig returns the GitHub API URL and API token from the repository config.
+// The API URL of GitHub Enterprise servers is usually https://<host>/api/v3.
+func GetGithubConfig() (string, string, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return "", "", fmt.Errorf("unable to get the current working directory: %q", err)
+	}
+
+	repo, err := NewGitRepoNoInit(cwd)
+	if err == ErrNotARepo {
+		return "", "", fmt.Errorf("must be run from within a git repo")
+	}
+
+	var githubUrl string
+	if githubUrl, err = repo.LocalConfig().ReadString("github.url"); err != nil {
+		if githubUrl, err = repo.GlobalConfig().ReadString("github.url"); err != nil {
+			githubUrl = DefaultGithubApiUrl
+		}
+	}
+	githubUrl = strings.TrimSuffix(githubUrl, "/")
+
+	var apiToken string
+	if apiToken, err = repo.LocalConfig().ReadString("github.api-token"); err != nil {
+		if apiToken, err = repo.GlobalConfig().ReadString("github.api-token"); err != nil {
+			msg := `No GitHub API token set. Please go to
+	%s/settings/tokens generate a token with the repo scope
+and then paste the token into this command
+	git config --global --replace-all github.api-token <PASTE_TOKEN_HERE>`
+			return githubUrl, "", fmt.Errorf(msg, GithubWebUrl(githubUrl))
+		}
+	}
+
+	return githubUrl, apiToken, nil
+}
+
+// GithubWebUrl returns the URL of the web interface of the GitHub server with the given API URL
+func GithubWebUrl(apiUrl string) string {
+	if apiUrl == DefaultGithubApiUrl {
+		return "https://github.com"
+	}
+	return strings.TrimSuffix(apiUrl, "/api/v3")
+}
@@ -0,0 +1,41 @@
+package repository
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// GetGitlabConfig returns the GitLab URL and API token from the repository config
+func GetGitlabConfig() (string, string, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return "", "", fmt.Errorf("unable to get the current working directory: %q", err)
+	}
+
+	repo, err := NewGitRepoNoInit(cwd)
+	if err == ErrNotARepo {
+		return "", "", fmt.Errorf("must be run from within a git repo")
+	}
+
+	var gitlabUrl string
+	if gitlabUrl, err = repo.LocalConfig().ReadString("gitlab.url"); err != nil {
+		if gitlabUrl, err = repo.GlobalConfig().ReadString("gitlab.url"); err != nil {
+			return "", "", fmt.Errorf("No GitLab URL set. Set it with:\ngit config --global --replace-all gitlab.url <URL of gitlab server>")
+		}
+	}
+	gitlabUrl = strings.TrimSuffix(gitlabUrl, "/")
+
+	var apiToken string
+	if apiToken, err = repo.LocalConfig().ReadString("gitlab.api-token"); err != nil {
+		if apiToken, err = repo.GlobalConfig().ReadString("gitlab.api-token"); err != nil {
+			msg := `No GitLab API token set. Please go to
+	%s/-/user_settings/personal_access_tokens create a token with the read_api scope
+and then paste the token into this command
+	git config --global --replace-all gitlab.api-token <PASTE_TOKEN_HERE>`
+			return gitlabUrl, "", fmt.Errorf(msg, gitlabUrl)
+		}
+	}
+
+	return gitlabUrl, apiToken, nil
+}
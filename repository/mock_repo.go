@@ -147,6 +147,36 @@ func (r *mockRepoForTest) StoreCommitWithParent(treeHash Hash, parent Hash) (Has
 	return hash, nil
 }
 
+func (r *mockRepoForTest) ReadCommitData(hash Hash) ([]byte, error) {
+	c, ok := r.commits[hash]
+	if !ok {
+		return nil, fmt.Errorf("unknown commit")
+	}
+
+	data := fmt.Sprintf("tree %s\n", c.treeHash)
+	if c.parent != "" {
+		data += fmt.Sprintf("parent %s\n", c.parent)
+	}
+	return []byte(data), nil
+}
+
+func (r *mockRepoForTest) StoreCommitData(data []byte) (Hash, error) {
+	var treeHash, parent Hash
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(line, "tree ") {
+			treeHash = Hash(strings.TrimPrefix(line, "tree "))
+		}
+		if strings.HasPrefix(line, "parent ") {
+			parent = Hash(strings.TrimPrefix(line, "parent "))
+		}
+	}
+
+	if parent == "" {
+		return r.StoreCommit(treeHash)
+	}
+	return r.StoreCommitWithParent(treeHash, parent)
+}
+
 func (r *mockRepoForTest) UpdateRef(ref string, hash Hash) error {
 	r.refs[ref] = hash
 	return nil
@@ -282,6 +312,10 @@ func (r *mockRepoForTest) ResolveRevision(rev plumbing.Revision) (*plumbing.Hash
 	panic("implement me")
 }
 
+func (r *mockRepoForTest) SignData(data []byte) (string, error) {
+	return "", fmt.Errorf("signing is not supported by the mock repository")
+}
+
 func (r *mockRepoForTest) GetTreeHash(commit Hash) (Hash, error) {
 	c, ok := r.commits[commit]
 	if !ok {
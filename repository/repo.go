@@ -89,6 +89,17 @@ type Repo interface {
 	// StoreCommit will store a Git commit with the given Git tree
 	StoreCommitWithParent(treeHash Hash, parent Hash) (Hash, error)
 
+	// SignData returns an armored detached PGP signature of the data, made with
+	// the user signing key
+	SignData(data []byte) (string, error)
+
+	// ReadCommitData returns the raw content of a Git commit, with its signature if any
+	ReadCommitData(commit Hash) ([]byte, error)
+
+	// StoreCommitData will store a Git commit from its raw content, as returned by
+	// ReadCommitData, and return its hash
+	StoreCommitData(data []byte) (Hash, error)
+
 	// GetTreeHash return the git tree hash referenced in a commit
 	GetTreeHash(commit Hash) (Hash, error)
 
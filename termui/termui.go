@@ -672,7 +672,7 @@ func reviewWithEditor(bug *cache.BugCache, checklist config.Checklist) error {
 	ui.g.Close()
 	ui.g = nil
 
-	clChange, err := input.ChecklistEditorInput(ui.cache, checklist, false)
+	clChange, err := input.ChecklistEditorInput(ui.cache, checklist, bug.Snapshot().EvaluateChecklist, false)
 	if err != nil {
 		ui.msgPopup.Activate("", fmt.Sprintf("checklist not saved, re-execute command to continue editing: %s", err))
 	} else if !clChange {
@@ -0,0 +1,108 @@
+package rest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// PageSize is the number of items queried per page by GetList
+const PageSize = 100
+
+// Client is a minimal client of the REST APIs of the review servers and bug trackers
+type Client struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+	// Prefix is stripped from the responses, e.g. the one Gerrit adds against XSSI
+	Prefix string
+}
+
+// NewClient returns a client of the API at the given URL, sending the headers, e.g. the
+// authentication token, with every request
+func NewClient(url string, headers map[string]string) *Client {
+	return &Client{
+		url:     strings.TrimSuffix(url, "/"),
+		headers: headers,
+		client:  &http.Client{Timeout: time.Minute},
+	}
+}
+
+// Do sends a request to the API path with in encoded as JSON if it isn't nil, and decodes the
+// response into out if it isn't nil
+func (c *Client) Do(method string, path string, in interface{}, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, c.url+path, body)
+	if err != nil {
+		return err
+	}
+	for key, value := range c.headers {
+		req.Header.Set(key, value)
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s %s: %s %s", method, path, resp.Status, strings.TrimSpace(string(message)))
+	}
+
+	if out == nil {
+		return nil
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(bytes.TrimPrefix(data, []byte(c.Prefix)), out)
+}
+
+// Get queries the API path and decodes the response into out
+func (c *Client) Get(path string, out interface{}) error {
+	return c.Do(http.MethodGet, path, nil, out)
+}
+
+// GetList queries all the pages of the API path, appending the items to the slice out points to
+func (c *Client) GetList(path string, out interface{}) error {
+	list := reflect.ValueOf(out).Elem()
+
+	separator := "?"
+	if strings.Contains(path, "?") {
+		separator = "&"
+	}
+
+	for page := 1; ; page++ {
+		items := reflect.New(list.Type())
+		err := c.Get(fmt.Sprintf("%s%sper_page=%d&page=%d", path, separator, PageSize, page), items.Interface())
+		if err != nil {
+			return err
+		}
+
+		list.Set(reflect.AppendSlice(list, items.Elem()))
+
+		if items.Elem().Len() < PageSize {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetList(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			http.Error(w, "bad credentials", http.StatusUnauthorized)
+			return
+		}
+		assert.Equal(t, "open", r.URL.Query().Get("state"))
+
+		// Two full pages followed by a partial one
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		count := PageSize
+		if page == 3 {
+			count = 3
+		}
+		items := make([]int, count)
+		for i := range items {
+			items[i] = (page-1)*PageSize + i
+		}
+		json.NewEncoder(w).Encode(items)
+	}))
+	defer server.Close()
+
+	var items []int
+	require.NoError(t, NewClient(server.URL+"/", map[string]string{"Authorization": "Bearer token"}).GetList("/items?state=open", &items))
+	require.Len(t, items, 2*PageSize+3)
+	assert.Equal(t, 2*PageSize+2, items[len(items)-1])
+
+	err := NewClient(server.URL, nil).GetList("/items?state=open", &items)
+	assert.EqualError(t, err, "GET /items?state=open&per_page=100&page=1: 401 Unauthorized bad credentials")
+}
+
+func TestPrefix(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(")]}'\n{\"name\":\"change\"}"))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, nil)
+	client.Prefix = ")]}'"
+
+	var out struct{ Name string }
+	require.NoError(t, client.Get("/changes/1", &out))
+	assert.Equal(t, "change", out.Name)
+}
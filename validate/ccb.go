@@ -0,0 +1,132 @@
+package validate
+
+// CCB approvals signatures validation.
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/daedaleanai/git-ticket/bug"
+)
+
+// CcbSignatureStatus is the outcome of the verification of a CCB approval
+type CcbSignatureStatus int
+
+const (
+	_ CcbSignatureStatus = iota
+	CcbApprovalUnsigned
+	CcbApprovalValid
+	CcbApprovalInvalid
+	// CcbApprovalStale is a valid signature over another history of the ticket, e.g. after a
+	// merge reordered the operations preceding the approval
+	CcbApprovalStale
+)
+
+func (s CcbSignatureStatus) String() string {
+	switch s {
+	case CcbApprovalUnsigned:
+		return "unsigned"
+	case CcbApprovalValid:
+		return "valid"
+	case CcbApprovalInvalid:
+		return "invalid"
+	case CcbApprovalStale:
+		return "stale"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// CcbApprovalResult holds the verification details of a single CCB approval
+type CcbApprovalResult struct {
+	Operation *bug.SetCcbOperation
+	Status    CcbSignatureStatus
+	// Fingerprint of the key which made a valid signature
+	Fingerprint string
+	// Err holds the reason an approval is invalid or stale
+	Err error
+}
+
+// VerifyCcbApprovals checks the signatures of all the CCB approvals in the ticket
+// history. A signature is valid if it has been made over the ticket id, the approved
+// status and the hash of the history preceding the approval, with a key belonging
+// to the approver which was valid at the time of signing. A signature correctly made
+// over another history, as the operations may be reordered by a merge, is stale.
+func (v *Validator) VerifyCcbApprovals(snap *bug.Snapshot) []CcbApprovalResult {
+	var results []CcbApprovalResult
+
+	for i, op := range snap.Operations {
+		ccbOp, ok := op.(*bug.SetCcbOperation)
+		if !ok || ccbOp.Ccb.State != bug.ApprovedCcbState {
+			continue
+		}
+
+		result := CcbApprovalResult{Operation: ccbOp}
+
+		if ccbOp.Signature == nil {
+			result.Status = CcbApprovalUnsigned
+			results = append(results, result)
+			continue
+		}
+
+		fingerprint, err := v.verifyCcbApproval(snap, ccbOp)
+		switch {
+		case err != nil:
+			result.Status = CcbApprovalInvalid
+			result.Err = err
+		case ccbOp.Signature.SnapshotHash != bug.HashOperations(snap.Operations[:i]):
+			result.Status = CcbApprovalStale
+			result.Fingerprint = fingerprint
+			result.Err = errors.New("signed snapshot doesn't match the ticket history")
+		default:
+			result.Status = CcbApprovalValid
+			result.Fingerprint = fingerprint
+		}
+
+		results = append(results, result)
+	}
+
+	return results
+}
+
+// verifyCcbApproval checks the signature of a single approval, returning the
+// fingerprint of the signing key
+func (v *Validator) verifyCcbApproval(snap *bug.Snapshot, op *bug.SetCcbOperation) (string, error) {
+	signature, err := dearmorSignature(op.Signature.Armored)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to dearmor PGP signature")
+	}
+
+	if signature.IssuerKeyId == nil {
+		return "", errors.New("signature doesn't have an issuer")
+	}
+
+	payload := bug.CcbApprovalPayload(snap.Id(), op.Ccb.Status, op.Signature.SnapshotHash)
+
+	key, err := v.searchKey(signature, payload)
+	if err != nil {
+		return "", err
+	}
+
+	if owner := v.keyOwner[key.PublicKey.KeyId]; owner != op.Ccb.User.Id() {
+		return "", fmt.Errorf("signing key doesn't belong to approver %s", op.Ccb.User.DisplayName())
+	}
+
+	for _, ei := range key.Entity.Identities {
+		start := ei.SelfSignature.CreationTime
+		if start.After(signature.CreationTime) {
+			return "", fmt.Errorf("key used to sign approval was added after the signature")
+		}
+		if ei.SelfSignature.KeyLifetimeSecs != nil {
+			expiry := start.Add(time.Duration(*ei.SelfSignature.KeyLifetimeSecs) * time.Second)
+			if expiry.Before(signature.CreationTime) {
+				return "", fmt.Errorf("key used to sign approval on %s expired on %s",
+					signature.CreationTime.Format(time.Stamp), expiry.Format(time.Stamp))
+			}
+		}
+	}
+
+	return fmt.Sprintf("%X", key.PublicKey.Fingerprint), nil
+}
@@ -0,0 +1,96 @@
+package validate
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/daedaleanai/git-ticket/bug"
+	"github.com/daedaleanai/git-ticket/cache"
+	"github.com/daedaleanai/git-ticket/config"
+	"github.com/daedaleanai/git-ticket/repository"
+)
+
+func TestVerifyCcbApprovals(t *testing.T) {
+	repo := repository.CreateTestRepo(false)
+	defer repository.CleanupTestRepos(repo)
+
+	backend, err := cache.NewRepoCache(repo, false)
+	require.NoError(t, err)
+
+	armoredPubkey := repository.SetupSigningKey(t, repo, "a@e.org")
+	id := checkAddIdentity(t, backend, "A", "a@e.org", armoredPubkey)
+	require.NoError(t, backend.SetUserIdentity(id))
+
+	err = backend.DoWithLockedConfigCache(func(c *config.ConfigCache) error {
+		if err := c.LabelConfig.AppendLabelToConfiguration(config.Label("repo:test")); err != nil {
+			return err
+		}
+		return c.LabelConfig.Store(repo)
+	})
+	require.NoError(t, err)
+
+	b, _, err := backend.NewBug(cache.NewBugOpts{
+		Title: "title", Message: "message", Workflow: "workflow:eng", Repo: "repo:test",
+	})
+	require.NoError(t, err)
+
+	// Without configuration, the approvals aren't signed
+	_, err = b.CcbAdd(id, bug.VettedStatus)
+	require.NoError(t, err)
+	unsignedOp, err := b.CcbApprove(bug.VettedStatus)
+	require.NoError(t, err)
+	require.Nil(t, unsignedOp.Signature)
+
+	// A signed approval
+	require.NoError(t, repo.LocalConfig().StoreBool("git-bug.ccb.sign-approvals", true))
+	signedOp, err := b.CcbApprove(bug.VettedStatus)
+	require.NoError(t, err)
+	require.NotNil(t, signedOp.Signature)
+
+	// An approval made without signature
+	_, err = b.CcbAdd(id, bug.InProgressStatus)
+	require.NoError(t, err)
+	_, err = b.SetCcbRaw(id, time.Now().Unix(), nil, id, bug.InProgressStatus, bug.ApprovedCcbState)
+	require.NoError(t, err)
+
+	// An approval re-using the signature of another one
+	_, err = b.CcbAdd(id, bug.InReviewStatus)
+	require.NoError(t, err)
+	_, err = b.SetCcbSignedRaw(id, time.Now().Unix(), nil, bug.InReviewStatus, signedOp.Signature)
+	require.NoError(t, err)
+
+	// A correctly signed approval over another history, as if a merge reordered the operations
+	_, err = b.CcbAdd(id, bug.ReviewedStatus)
+	require.NoError(t, err)
+	armored, err := repo.SignData(bug.CcbApprovalPayload(b.Id(), bug.ReviewedStatus, signedOp.Signature.SnapshotHash))
+	require.NoError(t, err)
+	_, err = b.SetCcbSignedRaw(id, time.Now().Unix(), nil, bug.ReviewedStatus,
+		&bug.CcbSignature{SnapshotHash: signedOp.Signature.SnapshotHash, Armored: armored})
+	require.NoError(t, err)
+
+	require.NoError(t, b.Commit())
+
+	validator, err := NewValidator(repo, backend)
+	require.NoError(t, err)
+
+	results := validator.VerifyCcbApprovals(b.Snapshot())
+	require.Len(t, results, 5)
+
+	require.Equal(t, CcbApprovalUnsigned, results[0].Status)
+
+	require.Equal(t, CcbApprovalValid, results[1].Status)
+	require.Equal(t, bug.VettedStatus, results[1].Operation.Ccb.Status)
+	require.NoError(t, results[1].Err)
+	require.NotEmpty(t, results[1].Fingerprint)
+
+	require.Equal(t, CcbApprovalUnsigned, results[2].Status)
+
+	require.Equal(t, CcbApprovalInvalid, results[3].Status)
+	require.Error(t, results[3].Err)
+
+	require.Equal(t, CcbApprovalStale, results[4].Status)
+	require.Error(t, results[4].Err)
+	require.Equal(t, results[1].Fingerprint, results[4].Fingerprint)
+}
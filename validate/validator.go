@@ -18,6 +18,7 @@ import (
 	"golang.org/x/crypto/openpgp/packet"
 
 	"github.com/daedaleanai/git-ticket/cache"
+	"github.com/daedaleanai/git-ticket/entity"
 	"github.com/daedaleanai/git-ticket/identity"
 	"github.com/daedaleanai/git-ticket/repository"
 )
@@ -35,6 +36,8 @@ type Validator struct {
 	keyring openpgp.EntityList
 	// keyCommit maps the key id to the commit which introduced that key.
 	keyCommit map[uint64]*object.Commit
+	// keyOwner maps the key id to the identity which introduced that key.
+	keyOwner map[uint64]entity.Id
 	// checkedCommits holds the valid already-checked commits.
 	checkedCommits map[repository.Hash]bool
 }
@@ -69,6 +72,7 @@ func NewValidator(repo repository.ClockedRepo, backend *cache.RepoCache) (*Valid
 		backend:        backend,
 		keyring:        make(openpgp.EntityList, 0),
 		keyCommit:      make(map[uint64]*object.Commit),
+		keyOwner:       make(map[uint64]entity.Id),
 		checkedCommits: make(map[repository.Hash]bool),
 	}
 
@@ -135,6 +139,7 @@ func (v *Validator) readVersionsInfo() ([]*versionInfo, error) {
 						return nil, fmt.Errorf("keys with identical keyId introduced in commits %s and %s", otherCommit.Hash, commit.Hash)
 					}
 					v.keyCommit[pubkey.KeyId] = commit
+					v.keyOwner[pubkey.KeyId] = identityCache.Id()
 				}
 				versionKeys[pubkey.KeyId] = key
 			}
@@ -252,6 +257,10 @@ func (v *Validator) checkCommitForKey(hash repository.Hash) error {
 		},
 	}
 
+	for _, key := range keys {
+		v.keyOwner[key.PublicKey().KeyId] = identity.Id()
+	}
+
 	v.updateKeyring(dummyVersion)
 
 	if v.FirstKey == nil {
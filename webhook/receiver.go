@@ -0,0 +1,50 @@
+package webhook
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+)
+
+// Received is a payload accepted by a Receiver
+type Received struct {
+	Delivery string
+	Event    string
+	Payload  json.RawMessage
+}
+
+// Receiver is a webhook receiver checking the signature of the payloads, to test the webhooks
+// locally
+type Receiver struct {
+	Secret string
+	// Handle is called with the payloads whose signature is valid
+	Handle func(Received)
+}
+
+func (r *Receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	payload, err := io.ReadAll(req.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !Verify(r.Secret, payload, req.Header.Get(SignatureHeader)) {
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+	if !json.Valid(payload) {
+		http.Error(w, "invalid payload", http.StatusBadRequest)
+		return
+	}
+
+	r.Handle(Received{
+		Delivery: req.Header.Get(DeliveryHeader),
+		Event:    req.Header.Get(EventHeader),
+		Payload:  payload,
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
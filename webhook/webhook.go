@@ -0,0 +1,602 @@
+// Package webhook posts signed JSON payloads of the ticket changes to the configured URLs
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/daedaleanai/git-ticket/bug"
+	"github.com/daedaleanai/git-ticket/cache"
+	"github.com/daedaleanai/git-ticket/entity"
+	"github.com/daedaleanai/git-ticket/repository"
+	"github.com/daedaleanai/git-ticket/util/lamport"
+)
+
+// configKeyPrefix is the prefix of the keys of the webhooks in the git config, the webhooks
+// being configured with git-bug.webhook.<name>.<key>
+const configKeyPrefix = "git-bug.webhook."
+
+const (
+	urlConfigKey    = "url"
+	secretConfigKey = "secret"
+	eventsConfigKey = "events"
+
+	// lamportConfigKey records the edit lamport time up to which the changes were dispatched
+	lamportConfigKey = "lamport"
+)
+
+// The events of the webhooks
+const (
+	CreateEvent  = "create"
+	UpdateEvent  = "update"
+	StatusEvent  = "status"
+	CcbEvent     = "ccb"
+	CommentEvent = "comment"
+)
+
+// AllEvents are the events a webhook receives by default
+var AllEvents = []string{CreateEvent, UpdateEvent, StatusEvent, CcbEvent, CommentEvent}
+
+// The headers of the requests
+const (
+	EventHeader     = "X-Git-Ticket-Event"
+	DeliveryHeader  = "X-Git-Ticket-Delivery"
+	SignatureHeader = "X-Git-Ticket-Signature"
+)
+
+const (
+	defaultMaxAttempts = 5
+	defaultBackoff     = time.Second
+	requestTimeout     = 10 * time.Second
+
+	// maxDeliveries is the number of deliveries kept in the log
+	maxDeliveries = 200
+
+	// pendingFile is the file of the git-bug directory of the repository saving the pending
+	// deliveries
+	pendingFile = "webhook-deliveries"
+)
+
+// Webhook is a URL receiving the events of the tickets
+type Webhook struct {
+	Name string
+	URL  string
+	// Secret is the key of the HMAC-SHA256 signature of the payloads
+	Secret string
+	Events []string
+}
+
+func (w Webhook) subscribed(event string) bool {
+	for _, e := range w.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadConfig reads the webhooks from the git config of the repository
+func LoadConfig(repo repository.RepoConfig) ([]Webhook, error) {
+	values, err := repo.LocalConfig().ReadAll(configKeyPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	webhooks := make(map[string]*Webhook)
+	for key, value := range values {
+		parts := strings.Split(strings.TrimPrefix(key, configKeyPrefix), ".")
+		if len(parts) != 2 {
+			continue
+		}
+
+		w, ok := webhooks[parts[0]]
+		if !ok {
+			w = &Webhook{Name: parts[0], Events: AllEvents}
+			webhooks[parts[0]] = w
+		}
+		switch parts[1] {
+		case urlConfigKey:
+			w.URL = value
+		case secretConfigKey:
+			w.Secret = value
+		case eventsConfigKey:
+			w.Events = strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ' ' })
+			for _, event := range w.Events {
+				if !validEvent(event) {
+					return nil, fmt.Errorf("invalid event %q of webhook %s, expected one of %s",
+						event, w.Name, strings.Join(AllEvents, ", "))
+				}
+			}
+		}
+	}
+
+	result := make([]Webhook, 0, len(webhooks))
+	for _, w := range webhooks {
+		if w.URL == "" || w.Secret == "" {
+			return nil, fmt.Errorf("webhook %s requires both %s%s.%s and %s%s.%s in the git config",
+				w.Name, configKeyPrefix, w.Name, urlConfigKey, configKeyPrefix, w.Name, secretConfigKey)
+		}
+		result = append(result, *w)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+
+	return result, nil
+}
+
+func validEvent(event string) bool {
+	for _, e := range AllEvents {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// Event returns the webhook event of an operation
+func Event(op bug.Operation) string {
+	switch op.(type) {
+	case *bug.CreateOperation:
+		return CreateEvent
+	case *bug.SetStatusOperation:
+		return StatusEvent
+	case *bug.SetCcbOperation:
+		return CcbEvent
+	case *bug.AddCommentOperation:
+		return CommentEvent
+	}
+	return UpdateEvent
+}
+
+// Payload is the JSON document posted to the webhooks
+type Payload struct {
+	Event     string        `json:"event"`
+	Ticket    entity.Id     `json:"ticket"`
+	Operation bug.Operation `json:"operation"`
+	// Snapshot is the current state of the ticket
+	Snapshot cache.HookSnapshot `json:"snapshot"`
+}
+
+// Sign returns the signature of a payload, sent in the SignatureHeader
+func Sign(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify tells if the signature of a payload is valid
+func Verify(secret string, payload []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, payload)), []byte(signature))
+}
+
+// DeliveryState is the state of the delivery of a payload
+type DeliveryState string
+
+const (
+	PendingDelivery   DeliveryState = "pending"
+	DeliveredDelivery DeliveryState = "delivered"
+	FailedDelivery    DeliveryState = "failed"
+)
+
+// Attempt is an attempt to post a payload
+type Attempt struct {
+	Time time.Time
+	// StatusCode is the HTTP status of the response, zero if there was none
+	StatusCode int
+	Error      string
+	Duration   time.Duration
+}
+
+// Delivery is the post of the payload of an event to a webhook
+type Delivery struct {
+	// Id is the id of the operation, sent in the DeliveryHeader so that the receivers can
+	// recognize the payloads they already received
+	Id       string
+	Webhook  string
+	URL      string
+	Event    string
+	Ticket   entity.Id
+	State    DeliveryState
+	Attempts []Attempt
+
+	secret  string
+	payload []byte
+}
+
+// savedDelivery is a pending delivery as saved in the git-bug directory of the repository
+type savedDelivery struct {
+	Delivery
+	Payload json.RawMessage
+}
+
+// Dispatcher posts the payloads of the ticket changes to the webhooks, retrying the failed
+// deliveries with an exponential backoff
+type Dispatcher struct {
+	// MaxAttempts is the number of attempts of a delivery
+	MaxAttempts int
+	// Backoff is the delay before the first retry, doubled for each next one
+	Backoff time.Duration
+
+	webhooks []Webhook
+	client   *http.Client
+
+	// muPoll serializes the polls of the repository
+	muPoll sync.Mutex
+	// queues are the deliveries to send in order, one per webhook. They aren't bounded so that
+	// queuing never blocks the polls.
+	muQueues sync.Mutex
+	queues   map[string][]*Delivery
+	// wake tells the worker of a webhook that deliveries were queued
+	wake    map[string]chan struct{}
+	pending sync.WaitGroup
+	workers sync.WaitGroup
+	done    chan struct{}
+
+	muLog      sync.Mutex
+	deliveries []*Delivery
+	// unfinished are the pending deliveries, in the order they were queued
+	unfinished []*Delivery
+
+	// store is the file saving the unfinished deliveries, if they are persisted
+	muStore sync.Mutex
+	store   string
+}
+
+// NewDispatcher returns a dispatcher posting to the webhooks, which should be closed
+func NewDispatcher(webhooks []Webhook) *Dispatcher {
+	d := &Dispatcher{
+		MaxAttempts: defaultMaxAttempts,
+		Backoff:     defaultBackoff,
+		webhooks:    webhooks,
+		client:      &http.Client{Timeout: requestTimeout},
+		queues:      make(map[string][]*Delivery),
+		wake:        make(map[string]chan struct{}),
+		done:        make(chan struct{}),
+	}
+
+	// the wake channels are all created before the workers start reading them
+	for _, w := range webhooks {
+		d.wake[w.Name] = make(chan struct{}, 1)
+	}
+	for _, w := range webhooks {
+		d.workers.Add(1)
+		go d.run(w.Name)
+	}
+
+	return d
+}
+
+// Persist saves the pending deliveries in the git-bug directory of the repository, so that they
+// are retried after a restart, and queues the ones saved before. It should be called before
+// polling the repository.
+func (d *Dispatcher) Persist(repo repository.Repo) error {
+	d.muStore.Lock()
+	d.store = path.Join(repo.GetPath(), "git-bug", pendingFile)
+	data, err := os.ReadFile(d.store)
+	d.muStore.Unlock()
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "can't read the pending deliveries")
+	}
+
+	var saved []savedDelivery
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return errors.Wrap(err, "invalid pending deliveries")
+	}
+	for _, s := range saved {
+		// the deliveries of the webhooks removed since are dropped
+		for _, w := range d.webhooks {
+			if w.Name != s.Webhook {
+				continue
+			}
+			delivery := s.Delivery
+			delivery.URL = w.URL
+			delivery.secret = w.Secret
+			delivery.payload = s.Payload
+			d.enqueue(&delivery)
+		}
+	}
+
+	return d.save()
+}
+
+// Poll queues the deliveries of the changes made to the tickets since the last poll. The first
+// time, there is nothing to deliver: only the later changes are. A new ticket is delivered as a
+// single create event, its snapshot including the changes made since.
+func (d *Dispatcher) Poll(repo *cache.RepoCache) error {
+	d.muPoll.Lock()
+	defer d.muPoll.Unlock()
+
+	config := repo.LocalConfig()
+	var since lamport.Time
+	initialized := false
+	value, err := config.ReadString(configKeyPrefix + lamportConfigKey)
+	if err == nil {
+		time, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return errors.Wrapf(err, "invalid %s%s", configKeyPrefix, lamportConfigKey)
+		}
+		since = lamport.Time(time)
+		initialized = true
+	} else if err != repository.ErrNoConfigEntry {
+		return err
+	}
+
+	now := since
+	var deliveries []*Delivery
+	for _, id := range repo.AllBugsIds() {
+		excerpt, err := repo.ResolveBugExcerpt(id)
+		if err != nil {
+			return err
+		}
+		if excerpt.EditLamportTime > now {
+			now = excerpt.EditLamportTime
+		}
+		if !initialized || excerpt.EditLamportTime <= since {
+			continue
+		}
+
+		b, err := repo.ResolveBug(id)
+		if err != nil {
+			return err
+		}
+		snap := b.Snapshot()
+
+		ops := b.OperationsSince(since)
+		if len(ops) > 0 && Event(ops[0]) == CreateEvent {
+			ops = ops[:1]
+		}
+		for _, op := range ops {
+			event := Event(op)
+			payload, err := json.Marshal(Payload{
+				Event:     event,
+				Ticket:    snap.Id(),
+				Operation: op,
+				Snapshot:  cache.NewHookSnapshot(snap),
+			})
+			if err != nil {
+				return err
+			}
+
+			for _, w := range d.webhooks {
+				if !w.subscribed(event) {
+					continue
+				}
+				deliveries = append(deliveries, &Delivery{
+					Id:      op.Id().String(),
+					Webhook: w.Name,
+					URL:     w.URL,
+					Event:   event,
+					Ticket:  snap.Id(),
+					State:   PendingDelivery,
+					secret:  w.Secret,
+					payload: payload,
+				})
+			}
+		}
+	}
+
+	for _, delivery := range deliveries {
+		d.enqueue(delivery)
+	}
+	if err := d.save(); err != nil {
+		return err
+	}
+
+	if !initialized || now > since {
+		if err := config.StoreString(configKeyPrefix+lamportConfigKey, strconv.FormatUint(uint64(now), 10)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// enqueue queues a delivery without waiting for the previous ones to be delivered
+func (d *Dispatcher) enqueue(delivery *Delivery) {
+	d.log(delivery)
+	d.pending.Add(1)
+
+	d.muQueues.Lock()
+	d.queues[delivery.Webhook] = append(d.queues[delivery.Webhook], delivery)
+	d.muQueues.Unlock()
+
+	select {
+	case d.wake[delivery.Webhook] <- struct{}{}:
+	default:
+	}
+}
+
+// run delivers the queued deliveries of a webhook in order, until the dispatcher is closed
+func (d *Dispatcher) run(webhook string) {
+	defer d.workers.Done()
+	for {
+		d.muQueues.Lock()
+		queue := d.queues[webhook]
+		var delivery *Delivery
+		if len(queue) > 0 {
+			delivery = queue[0]
+			d.queues[webhook] = queue[1:]
+		}
+		d.muQueues.Unlock()
+
+		if delivery == nil {
+			select {
+			case <-d.wake[webhook]:
+				continue
+			case <-d.done:
+				return
+			}
+		}
+
+		select {
+		case <-d.done:
+			return
+		default:
+		}
+
+		d.deliver(delivery)
+		d.finish(delivery)
+		d.pending.Done()
+	}
+}
+
+func (d *Dispatcher) log(delivery *Delivery) {
+	d.muLog.Lock()
+	defer d.muLog.Unlock()
+	d.deliveries = append(d.deliveries, delivery)
+	if len(d.deliveries) > maxDeliveries {
+		d.deliveries = d.deliveries[len(d.deliveries)-maxDeliveries:]
+	}
+	d.unfinished = append(d.unfinished, delivery)
+}
+
+// finish forgets a delivery which isn't pending anymore, and saves the ones which still are
+func (d *Dispatcher) finish(delivery *Delivery) {
+	d.muLog.Lock()
+	if delivery.State != PendingDelivery {
+		for i, unfinished := range d.unfinished {
+			if unfinished == delivery {
+				d.unfinished = append(d.unfinished[:i], d.unfinished[i+1:]...)
+				break
+			}
+		}
+	}
+	d.muLog.Unlock()
+
+	// a failure is repaired by the next save, which writes all the pending deliveries
+	_ = d.save()
+}
+
+// save writes the pending deliveries in the store, if they are persisted
+func (d *Dispatcher) save() error {
+	d.muStore.Lock()
+	defer d.muStore.Unlock()
+	if d.store == "" {
+		return nil
+	}
+
+	d.muLog.Lock()
+	saved := make([]savedDelivery, 0, len(d.unfinished))
+	for _, delivery := range d.unfinished {
+		s := savedDelivery{Delivery: *delivery, Payload: delivery.payload}
+		s.Attempts = append([]Attempt(nil), delivery.Attempts...)
+		saved = append(saved, s)
+	}
+	d.muLog.Unlock()
+
+	data, err := json.Marshal(saved)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(d.store+".tmp", data, 0644); err != nil {
+		return errors.Wrap(err, "can't save the pending deliveries")
+	}
+	return os.Rename(d.store+".tmp", d.store)
+}
+
+// Deliveries returns the log of the last deliveries, newest first
+func (d *Dispatcher) Deliveries() []Delivery {
+	d.muLog.Lock()
+	defer d.muLog.Unlock()
+
+	result := make([]Delivery, 0, len(d.deliveries))
+	for i := len(d.deliveries) - 1; i >= 0; i-- {
+		delivery := *d.deliveries[i]
+		delivery.Attempts = append([]Attempt(nil), delivery.Attempts...)
+		result = append(result, delivery)
+	}
+	return result
+}
+
+// deliver posts a payload until it is accepted, the receiver rejects it or the attempts are
+// exhausted
+func (d *Dispatcher) deliver(delivery *Delivery) {
+	backoff := d.Backoff
+	for attempt := len(delivery.Attempts) + 1; ; attempt++ {
+		start := time.Now()
+		code, err := d.post(delivery)
+		retry := err != nil || code >= 500 || code == http.StatusTooManyRequests
+		if err == nil && (code < 200 || code > 299) {
+			err = fmt.Errorf("unexpected status %d", code)
+		}
+
+		d.muLog.Lock()
+		record := Attempt{Time: start, StatusCode: code, Duration: time.Since(start)}
+		if err != nil {
+			record.Error = err.Error()
+		}
+		delivery.Attempts = append(delivery.Attempts, record)
+		switch {
+		case err == nil:
+			delivery.State = DeliveredDelivery
+		case !retry || attempt >= d.MaxAttempts:
+			delivery.State = FailedDelivery
+		}
+		state := delivery.State
+		d.muLog.Unlock()
+
+		if state != PendingDelivery {
+			return
+		}
+
+		select {
+		case <-time.After(backoff):
+			backoff *= 2
+		case <-d.done:
+			// the delivery stays pending, to be retried after a restart if it is persisted
+			return
+		}
+	}
+}
+
+func (d *Dispatcher) post(delivery *Delivery) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, delivery.URL, bytes.NewReader(delivery.payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "git-ticket-webhook")
+	req.Header.Set(EventHeader, delivery.Event)
+	req.Header.Set(DeliveryHeader, delivery.Id)
+	req.Header.Set(SignatureHeader, Sign(delivery.secret, delivery.payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	return resp.StatusCode, nil
+}
+
+// Wait waits for the queued deliveries to be delivered or to fail
+func (d *Dispatcher) Wait() {
+	d.pending.Wait()
+}
+
+// Close stops the retries of the deliveries and waits for the ones in progress. The deliveries
+// still pending are kept in the store, if they are persisted.
+func (d *Dispatcher) Close() {
+	close(d.done)
+	d.workers.Wait()
+}
@@ -0,0 +1,255 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/daedaleanai/git-ticket/bug"
+	"github.com/daedaleanai/git-ticket/cache"
+	"github.com/daedaleanai/git-ticket/config"
+	"github.com/daedaleanai/git-ticket/repository"
+)
+
+// testReceiver is a local webhook receiver failing the first requests it gets
+type testReceiver struct {
+	*httptest.Server
+	mu       sync.Mutex
+	failures int
+	received []Received
+}
+
+func newTestReceiver(t *testing.T, secret string) *testReceiver {
+	r := &testReceiver{}
+	receiver := &Receiver{Secret: secret, Handle: func(received Received) {
+		r.received = append(r.received, received)
+	}}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if r.failures > 0 {
+			r.failures--
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		receiver.ServeHTTP(w, req)
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+func (r *testReceiver) fail(n int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.failures = n
+}
+
+func (r *testReceiver) events() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var result []string
+	for _, received := range r.received {
+		result = append(result, received.Event)
+	}
+	r.received = nil
+	return result
+}
+
+func TestDispatcher(t *testing.T) {
+	repo := repository.CreateTestRepo(false)
+	defer repository.CleanupTestRepos(repo)
+	repository.SetupSigningKey(t, repo, "rene@descartes.fr")
+
+	repoCache, err := cache.NewRepoCache(repo, false)
+	require.NoError(t, err)
+
+	rene, err := repoCache.NewIdentity("René Descartes", "rene@descartes.fr", true, true, "")
+	require.NoError(t, err)
+	require.NoError(t, repoCache.SetUserIdentity(rene))
+
+	require.NoError(t, repoCache.DoWithLockedConfigCache(func(c *config.ConfigCache) error {
+		if err := c.LabelConfig.AppendLabelToConfiguration(config.Label("repo:test")); err != nil {
+			return err
+		}
+		return c.LabelConfig.Store(repo)
+	}))
+
+	chat := newTestReceiver(t, "chat-secret")
+	ci := newTestReceiver(t, "ci-secret")
+	require.NoError(t, repo.LocalConfig().StoreString("git-bug.webhook.chat.url", chat.URL))
+	require.NoError(t, repo.LocalConfig().StoreString("git-bug.webhook.chat.secret", "chat-secret"))
+	require.NoError(t, repo.LocalConfig().StoreString("git-bug.webhook.ci.url", ci.URL))
+	require.NoError(t, repo.LocalConfig().StoreString("git-bug.webhook.ci.secret", "ci-secret"))
+	require.NoError(t, repo.LocalConfig().StoreString("git-bug.webhook.ci.events", "status, ccb"))
+
+	webhooks, err := LoadConfig(repoCache)
+	require.NoError(t, err)
+	require.Len(t, webhooks, 2)
+	assert.Equal(t, AllEvents, webhooks[0].Events)
+	assert.Equal(t, []string{StatusEvent, CcbEvent}, webhooks[1].Events)
+
+	d := NewDispatcher(webhooks)
+	d.Backoff = time.Millisecond
+	defer d.Close()
+
+	// The first time, only the state is recorded
+	b, _, err := repoCache.NewBug(cache.NewBugOpts{Title: "Engine explodes", Message: "On ignition.", Workflow: "workflow:eng", Repo: "repo:test"})
+	require.NoError(t, err)
+	require.NoError(t, d.Poll(repoCache))
+	d.Wait()
+	assert.Empty(t, d.Deliveries())
+
+	// A new ticket is a single create event
+	b2, _, err := repoCache.NewBug(cache.NewBugOpts{Title: "Wings fall off", Message: "In flight.", Workflow: "workflow:eng", Repo: "repo:test"})
+	require.NoError(t, err)
+	require.NoError(t, d.Poll(repoCache))
+	d.Wait()
+	assert.Equal(t, []string{CreateEvent}, chat.events())
+	assert.Empty(t, ci.events())
+
+	// The failed deliveries are retried
+	chat.fail(2)
+	_, err = b.AddComment("Looking into it")
+	require.NoError(t, err)
+	_, err = b.SetStatus(bug.RejectedStatus)
+	require.NoError(t, err)
+	require.NoError(t, b.Commit())
+	require.NoError(t, d.Poll(repoCache))
+	d.Wait()
+	assert.Equal(t, []string{CommentEvent, StatusEvent}, chat.events())
+	assert.Equal(t, []string{StatusEvent}, ci.events())
+
+	deliveries := d.Deliveries()
+	require.Len(t, deliveries, 4)
+	assert.Equal(t, "ci", deliveries[0].Webhook)
+	assert.Equal(t, DeliveredDelivery, deliveries[0].State)
+	assert.Len(t, deliveries[0].Attempts, 1)
+	assert.Equal(t, CommentEvent, deliveries[2].Event)
+	assert.Equal(t, b.Id(), deliveries[2].Ticket)
+	require.Len(t, deliveries[2].Attempts, 3)
+	assert.Equal(t, http.StatusServiceUnavailable, deliveries[2].Attempts[0].StatusCode)
+	assert.Equal(t, "unexpected status 503", deliveries[2].Attempts[0].Error)
+	assert.Equal(t, http.StatusNoContent, deliveries[2].Attempts[2].StatusCode)
+	assert.Equal(t, b2.Id(), deliveries[3].Ticket)
+
+	// Until the attempts are exhausted
+	d.MaxAttempts = 2
+	chat.fail(2)
+	_, err = b.SetTitle("Engine explodes on ignition")
+	require.NoError(t, err)
+	require.NoError(t, b.Commit())
+	require.NoError(t, d.Poll(repoCache))
+	d.Wait()
+	assert.Empty(t, chat.events())
+	deliveries = d.Deliveries()
+	assert.Equal(t, UpdateEvent, deliveries[0].Event)
+	assert.Equal(t, FailedDelivery, deliveries[0].State)
+	assert.Len(t, deliveries[0].Attempts, 2)
+
+	// Nothing new
+	require.NoError(t, d.Poll(repoCache))
+	d.Wait()
+	assert.Len(t, d.Deliveries(), 5)
+}
+
+func TestDispatcherPersist(t *testing.T) {
+	repo := repository.CreateTestRepo(false)
+	defer repository.CleanupTestRepos(repo)
+	repository.SetupSigningKey(t, repo, "rene@descartes.fr")
+
+	repoCache, err := cache.NewRepoCache(repo, false)
+	require.NoError(t, err)
+
+	rene, err := repoCache.NewIdentity("René Descartes", "rene@descartes.fr", true, true, "")
+	require.NoError(t, err)
+	require.NoError(t, repoCache.SetUserIdentity(rene))
+
+	require.NoError(t, repoCache.DoWithLockedConfigCache(func(c *config.ConfigCache) error {
+		if err := c.LabelConfig.AppendLabelToConfiguration(config.Label("repo:test")); err != nil {
+			return err
+		}
+		return c.LabelConfig.Store(repo)
+	}))
+
+	chat := newTestReceiver(t, "chat-secret")
+	webhooks := []Webhook{{Name: "chat", URL: chat.URL, Secret: "chat-secret", Events: AllEvents}}
+
+	d := NewDispatcher(webhooks)
+	d.Backoff = time.Hour
+	require.NoError(t, d.Persist(repo))
+
+	b, _, err := repoCache.NewBug(cache.NewBugOpts{Title: "Engine explodes", Message: "On ignition.", Workflow: "workflow:eng", Repo: "repo:test"})
+	require.NoError(t, err)
+	require.NoError(t, d.Poll(repoCache))
+
+	// More changes than the log keeps are queued while the first delivery waits for its retry
+	chat.fail(1)
+	for i := 0; i < maxDeliveries+50; i++ {
+		_, err = b.AddComment(fmt.Sprintf("Comment %d", i))
+		require.NoError(t, err)
+	}
+	require.NoError(t, b.Commit())
+	require.NoError(t, d.Poll(repoCache))
+	d.Close()
+
+	// The pending deliveries are sent after a restart
+	d = NewDispatcher(webhooks)
+	d.Backoff = time.Millisecond
+	defer d.Close()
+	require.NoError(t, d.Persist(repo))
+	d.Wait()
+	assert.Len(t, chat.events(), maxDeliveries+50)
+	assert.Equal(t, DeliveredDelivery, d.Deliveries()[0].State)
+
+	require.NoError(t, d.Poll(repoCache))
+	d.Wait()
+	assert.Empty(t, chat.events())
+}
+
+func TestReceiver(t *testing.T) {
+	var received []Received
+	receiver := &Receiver{Secret: "secret", Handle: func(r Received) {
+		received = append(received, r)
+	}}
+
+	payload := `{"event":"comment"}`
+	post := func(signature string) int {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(payload))
+		req.Header.Set(SignatureHeader, signature)
+		req.Header.Set(EventHeader, "comment")
+		req.Header.Set(DeliveryHeader, "1234")
+		w := httptest.NewRecorder()
+		receiver.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, post(Sign("other", []byte(payload))))
+	assert.Equal(t, http.StatusNoContent, post(Sign("secret", []byte(payload))))
+	require.Len(t, received, 1)
+	assert.Equal(t, Received{Delivery: "1234", Event: "comment", Payload: json.RawMessage(payload)}, received[0])
+}
+
+func TestLoadConfig(t *testing.T) {
+	repo := repository.NewMockRepoForTest()
+
+	webhooks, err := LoadConfig(repo)
+	require.NoError(t, err)
+	assert.Empty(t, webhooks)
+
+	require.NoError(t, repo.LocalConfig().StoreString("git-bug.webhook.chat.url", "http://localhost/hook"))
+	_, err = LoadConfig(repo)
+	assert.EqualError(t, err, "webhook chat requires both git-bug.webhook.chat.url and git-bug.webhook.chat.secret in the git config")
+
+	require.NoError(t, repo.LocalConfig().StoreString("git-bug.webhook.chat.secret", "secret"))
+	require.NoError(t, repo.LocalConfig().StoreString("git-bug.webhook.chat.events", "comment,label"))
+	_, err = LoadConfig(repo)
+	assert.EqualError(t, err, `invalid event "label" of webhook chat, expected one of create, update, status, ccb, comment`)
+}
@@ -0,0 +1,96 @@
+package webui
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/daedaleanai/git-ticket/bug"
+	"github.com/daedaleanai/git-ticket/cache"
+	"github.com/daedaleanai/git-ticket/identity"
+	"github.com/daedaleanai/git-ticket/repository"
+	"github.com/daedaleanai/git-ticket/webhook"
+)
+
+// webhookPollInterval is the delay between the polls of the changes made outside of the web UI
+const webhookPollInterval = 30 * time.Second
+
+var (
+	webhooks          []webhook.Webhook
+	webhookDispatcher *webhook.Dispatcher
+
+	// muRepoCache is read-locked by the requests while they use the repo cache, the background
+	// polls of the webhooks only run when there is none
+	muRepoCache sync.RWMutex
+)
+
+// startWebhooks starts the dispatcher of the webhooks configured in the repository, if any
+func startWebhooks(repoDir string) error {
+	repo, err := repository.NewGitRepo(repoDir, []repository.ClockLoader{bug.ClockLoader, identity.ClockLoader})
+	if err != nil {
+		return fmt.Errorf("unable to open git repository: %w", err)
+	}
+
+	webhooks, err = webhook.LoadConfig(repo)
+	if err != nil {
+		return fmt.Errorf("unable to load the webhooks: %w", err)
+	}
+	if len(webhooks) == 0 {
+		return nil
+	}
+
+	webhookDispatcher = webhook.NewDispatcher(webhooks)
+	if err := webhookDispatcher.Persist(repo); err != nil {
+		return fmt.Errorf("unable to restore the pending webhook deliveries: %w", err)
+	}
+	go func() {
+		for range time.Tick(webhookPollInterval) {
+			if !muRepoCache.TryLock() {
+				continue
+			}
+			if err := pollWebhooksInBackground(repo); err != nil {
+				fmt.Println("Unable to dispatch the webhooks: ", err)
+			}
+			muRepoCache.Unlock()
+		}
+	}()
+
+	return nil
+}
+
+func pollWebhooksInBackground(repo repository.ClockedRepo) error {
+	repoCache, err := cache.NewRepoCache(repo, false)
+	if err != nil {
+		return err
+	}
+	defer repoCache.Close()
+
+	return webhookDispatcher.Poll(repoCache)
+}
+
+// pollWebhooks dispatches the changes made by a request, queuing the deliveries without waiting
+// for them
+func pollWebhooks(repo *cache.RepoCache) {
+	if webhookDispatcher == nil {
+		return
+	}
+	if err := webhookDispatcher.Poll(repo); err != nil {
+		fmt.Println("Unable to dispatch the webhooks: ", err)
+	}
+}
+
+func handleAdminWebhooks(w http.ResponseWriter, r *http.Request) {
+	var deliveries []webhook.Delivery
+	if webhookDispatcher != nil {
+		deliveries = webhookDispatcher.Deliveries()
+	}
+
+	renderTemplate(w, "admin_webhooks.html", struct {
+		Webhooks   []webhook.Webhook
+		Deliveries []webhook.Delivery
+	}{
+		Webhooks:   webhooks,
+		Deliveries: deliveries,
+	})
+}
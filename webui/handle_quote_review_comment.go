@@ -0,0 +1,75 @@
+package webui
+
+import (
+	"fmt"
+	"github.com/daedaleanai/git-ticket/bug/review"
+	"github.com/daedaleanai/git-ticket/cache"
+	"github.com/daedaleanai/git-ticket/entity"
+	http_webui "github.com/daedaleanai/git-ticket/webui/http"
+	"github.com/daedaleanai/git-ticket/webui/session"
+	"github.com/gorilla/mux"
+	"net/http"
+	"net/url"
+)
+
+type quoteReviewCommentAction struct {
+	Ticket  string
+	Review  string
+	Comment string
+}
+
+func quoteReviewCommentFromFormData(ticketId string, f url.Values) (*quoteReviewCommentAction, error) {
+	for _, field := range []string{"review", "comment"} {
+		if !f.Has(field) {
+			return nil, &http_webui.InvalidRequestError{Msg: fmt.Sprintf("missing required field [%s]", field)}
+		}
+	}
+
+	return &quoteReviewCommentAction{ticketId, f.Get("review"), f.Get("comment")}, nil
+}
+
+func handleQuoteReviewComment(w http.ResponseWriter, r *http.Request) {
+	repo := http_webui.LoadFromContext(r.Context(), &http_webui.ContextualRepoCache{}).(*http_webui.ContextualRepoCache).Repo
+	bag := http_webui.LoadFromContext(r.Context(), &session.FlashMessageBag{}).(*session.FlashMessageBag)
+
+	vars := mux.Vars(r)
+	if err := r.ParseForm(); err != nil {
+		http_webui.ErrorIntoResponse(&http_webui.MalformedRequestError{Prev: err}, w)
+		return
+	}
+
+	ticketId := vars["ticketId"]
+	action, err := quoteReviewCommentFromFormData(ticketId, r.Form)
+	if err != nil {
+		bag.AddMessage(session.NewError(err.Error()))
+		ticketRedirect(ticketId, w, r)
+		return
+	}
+	ticket, err := repo.ResolveBug(entity.Id(action.Ticket))
+	if err != nil {
+		http_webui.ErrorIntoResponse(&http_webui.InvalidRequestError{Msg: fmt.Sprintf("invalid ticket id: %s", action.Ticket)}, w)
+		return
+	}
+
+	if err := quoteReviewComment(ticket, action); err != nil {
+		bag.AddMessage(session.NewError(fmt.Sprintf("Something went wrong: %s", err)))
+	} else {
+		bag.AddMessage(session.NewSuccess("Success"))
+	}
+
+	ticketRedirect(ticket.Id().String(), w, r)
+}
+
+func quoteReviewComment(ticket *cache.BugCache, action *quoteReviewCommentAction) error {
+	r, ok := ticket.Snapshot().Reviews[action.Review]
+	if !ok {
+		return fmt.Errorf("ticket %s does not have a review %s", ticket.Id().Human(), action.Review)
+	}
+
+	message, err := review.QuoteComment(r, action.Comment)
+	if err != nil {
+		return err
+	}
+
+	return addComment(ticket, &submitCommentAction{Ticket: action.Ticket, Comment: message})
+}
@@ -15,6 +15,7 @@ import (
 	"time"
 
 	"github.com/daedaleanai/git-ticket/bug"
+	"github.com/daedaleanai/git-ticket/bug/review"
 	"github.com/daedaleanai/git-ticket/cache"
 	"github.com/daedaleanai/git-ticket/config"
 	"github.com/daedaleanai/git-ticket/entity"
@@ -22,6 +23,7 @@ import (
 	"github.com/daedaleanai/git-ticket/query"
 	"github.com/daedaleanai/git-ticket/repository"
 	"github.com/daedaleanai/git-ticket/util/timestamp"
+	"github.com/daedaleanai/git-ticket/webhook"
 	http_webui "github.com/daedaleanai/git-ticket/webui/http"
 	"github.com/daedaleanai/git-ticket/webui/session"
 
@@ -62,6 +64,9 @@ type Xref struct {
 type WebUiConfig struct {
 	Xref
 	BookmarkGroups []BookmarkGroup
+	// Moved holds the URLs of the web UIs of the repositories the tickets were moved to, by the
+	// path or the url of the repository
+	Moved map[string]string
 }
 
 type ApiActionSetStatus struct {
@@ -88,6 +93,10 @@ var (
 const flashMessageBagContextKey = "flash_message_context"
 
 func Run(repo, host string, port int) error {
+	if err := startWebhooks(repo); err != nil {
+		return err
+	}
+
 	r := mux.NewRouter()
 	r.Use(errorHandlingMiddleware)
 	r.Use(repoCacheMiddleware(repo))
@@ -98,8 +107,11 @@ func Run(repo, host string, port int) error {
 	r.HandleFunc("/ticket/new/", http_webui.WithValidatedPayload(createTicketActionFromValues, handleCreateTicket)).Methods(http.MethodGet, http.MethodPost)
 	r.HandleFunc("/ticket/{id:[0-9a-fA-F]{7,}}/", handleTicket).Methods(http.MethodGet)
 	r.HandleFunc("/ticket/{ticketId:[0-9a-fA-F]{7,}}/comment/", handleCreateComment).Methods(http.MethodPost)
+	r.HandleFunc("/ticket/{ticketId:[0-9a-fA-F]{7,}}/quote/", handleQuoteReviewComment).Methods(http.MethodPost)
 	r.HandleFunc("/checklist/", handleChecklist)
+	r.HandleFunc("/checklist/stats/", handleChecklistStats)
 	r.HandleFunc("/api/set-status", handleApiSetStatus)
+	r.HandleFunc("/admin/webhooks", handleAdminWebhooks).Methods(http.MethodGet)
 
 	http.Handle("/", r)
 	fmt.Printf("Running web-ui at http://%s:%d\n", host, port)
@@ -214,6 +226,13 @@ func handleTicket(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// A ticket moved to a repository with a known web UI is shown there
+	snap := ticket.Snapshot()
+	if webUrl := webUiConfig.Moved[snap.MovedTo]; snap.MovedTo != "" && webUrl != "" {
+		http.Redirect(w, r, movedTicketUrl(webUrl, snap.Id()), http.StatusMovedPermanently)
+		return
+	}
+
 	flashes := bag.Messages()
 	renderTemplate(w, "ticket.html", struct {
 		SideBar       SideBarData
@@ -224,11 +243,16 @@ func handleTicket(w http.ResponseWriter, r *http.Request) {
 			BookmarkGroups: webUiConfig.BookmarkGroups,
 			ColorKey:       map[string]string{},
 		},
-		ticket.Snapshot(),
+		snap,
 		flashes,
 	})
 }
 
+// movedTicketUrl returns the URL of a ticket in the web UI at the given URL
+func movedTicketUrl(webUrl string, id entity.Id) string {
+	return strings.TrimSuffix(webUrl, "/") + "/ticket/" + id.String() + "/"
+}
+
 func handleChecklist(w http.ResponseWriter, r *http.Request) {
 	repo := http_webui.LoadFromContext(r.Context(), &http_webui.ContextualRepoCache{}).(*http_webui.ContextualRepoCache).Repo
 	id := r.URL.Query().Get("id")
@@ -256,6 +280,7 @@ func handleChecklist(w http.ResponseWriter, r *http.Request) {
 	type checklistItem struct {
 		Ident     identity.Interface
 		Checklist bug.ChecklistSnapshot
+		Errors    []error
 	}
 
 	var clList []checklistItem
@@ -265,9 +290,14 @@ func handleChecklist(w http.ResponseWriter, r *http.Request) {
 			http_webui.ErrorIntoResponse(err, w)
 			return
 		}
+		// hide the sections and questions which don't apply to the ticket
+		v.Checklist = snap.EvaluateChecklist(v.Checklist)
 		clList = append(clList, checklistItem{
 			Ident:     id.Identity,
 			Checklist: v,
+			// answers are checked against their question type, as they may have been
+			// given before the rules were enforced
+			Errors: v.ValidateAnswers(),
 		})
 	}
 
@@ -287,6 +317,47 @@ func handleChecklist(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+func handleChecklistStats(w http.ResponseWriter, r *http.Request) {
+	repo := http_webui.LoadFromContext(r.Context(), &http_webui.ContextualRepoCache{}).(*http_webui.ContextualRepoCache).Repo
+
+	checklist := bug.Label(r.URL.Query().Get("checklist"))
+	if !checklist.IsChecklist() {
+		http_webui.ErrorIntoResponse(&http_webui.InvalidRequestError{Msg: fmt.Sprintf("requested checklist %s is not a checklist", checklist)}, w)
+		return
+	}
+
+	var q *query.CompiledQuery
+	qParam := r.URL.Query().Get("q")
+	if qParam != "" {
+		parser, err := query.NewParser(qParam)
+		if err != nil {
+			http_webui.ErrorIntoResponse(fmt.Errorf("unable to parse query: %w", err), w)
+			return
+		}
+		q, err = parser.Parse()
+		if err != nil {
+			http_webui.ErrorIntoResponse(&http_webui.InvalidRequestError{Msg: fmt.Sprintf("unable to parse query: %s", err)}, w)
+			return
+		}
+	}
+
+	stats, err := repo.ChecklistStats(checklist, q, 3)
+	if err != nil {
+		http_webui.ErrorIntoResponse(&http_webui.InvalidRequestError{Msg: err.Error()}, w)
+		return
+	}
+
+	renderTemplate(w, "checklist_stats.html", struct {
+		ChecklistLabel bug.Label
+		Query          string
+		Stats          bug.ChecklistStats
+	}{
+		ChecklistLabel: checklist,
+		Query:          qParam,
+		Stats:          stats,
+	})
+}
+
 func handleApiSetStatus(w http.ResponseWriter, r *http.Request) {
 	repo := http_webui.LoadFromContext(r.Context(), &http_webui.ContextualRepoCache{}).(*http_webui.ContextualRepoCache).Repo
 
@@ -378,6 +449,9 @@ func repoCacheMiddleware(repoDir string) func(handler http.Handler) http.Handler
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			if !excludeFromMiddleware(r) {
+				muRepoCache.RLock()
+				defer muRepoCache.RUnlock()
+
 				repo, err := repository.NewGitRepo(repoDir, []repository.ClockLoader{bug.ClockLoader, identity.ClockLoader})
 				if err != nil {
 					http_webui.ErrorIntoResponse(fmt.Errorf("unable to open git repository: %w", err), w)
@@ -396,6 +470,8 @@ func repoCacheMiddleware(repoDir string) func(handler http.Handler) http.Handler
 				}
 
 				defer repoCache.Close()
+				// the changes made by the request are dispatched to the webhooks once it is handled
+				defer pollWebhooks(repoCache)
 				c := http_webui.ContextualRepoCache{Repo: repoCache}
 				r = http_webui.LoadIntoContext(r, &c)
 			}
@@ -448,12 +524,14 @@ func loadConfig(repo repository.ClockedRepo) error {
 			Pattern string
 			Link    string
 		}
+		Moved map[string]string
 	}{}
 	if len(gitTicketConfig) > 0 {
 		if err := json.Unmarshal(gitTicketConfig, &xrefConfig); err != nil {
 			return fmt.Errorf("failed to unmarshal xref rules from git ticket config: %w", err)
 		}
 	}
+	webUiConfig.Moved = xrefConfig.Moved
 
 	// Verify that all regexp and templates in the xref configuration actually compile.
 	patterns := []string{}
@@ -703,6 +781,84 @@ func applyXrefs(s string, handleMatch func(match []string, link string) string)
 	return s
 }
 
+// reviewComment is a comment of a review, with the code it comments on if known, and its
+// replies. Changes which aren't comments, like commits, only have a summary.
+type reviewComment struct {
+	Author    identity.Interface
+	Timestamp timestamp.Timestamp
+	Summary   string
+	Id        string
+	Location  string
+	Body      string
+	Context   template.HTML
+	Replies   []reviewComment
+}
+
+// newReviewComment returns the comment of a thread with its replies
+func newReviewComment(t *review.CommentThread) reviewComment {
+	comment := reviewComment{
+		Author:    t.Author,
+		Timestamp: t.Timestamp,
+		Summary:   t.Comment.Summary(),
+		Id:        t.Comment.CommentId(),
+		Location:  t.Comment.Location(),
+		Body:      t.Comment.Body(),
+		Context:   codeContext(t.Comment),
+	}
+	for _, reply := range t.Replies {
+		comment.Replies = append(comment.Replies, newReviewComment(reply))
+	}
+	return comment
+}
+
+// codeContext returns the code a change comments on, with the line commented on highlighted
+func codeContext(change review.Change) template.HTML {
+	cc, ok := change.(review.CodeContextChange)
+	if !ok {
+		return ""
+	}
+
+	first, lines, line := cc.CodeContext()
+	var context strings.Builder
+	for i, l := range lines {
+		code := template.HTMLEscapeString(fmt.Sprintf("%5d | %s", first+i, l))
+		if first+i == line {
+			code = "<mark>" + code + "</mark>"
+		}
+		context.WriteString(code + "\n")
+	}
+	return template.HTML(context.String())
+}
+
+// reviewComments returns the comments of a review in chronological order, with their replies
+func reviewComments(r review.PullRequest) []reviewComment {
+	var result []reviewComment
+
+	for _, t := range review.Threads(r) {
+		result = append(result, newReviewComment(t))
+	}
+
+	for _, evt := range r.History() {
+		for _, change := range evt.Changes() {
+			if _, ok := review.AsComment(change); ok {
+				continue
+			}
+
+			result = append(result, reviewComment{
+				Author:    evt.Author(),
+				Timestamp: evt.Timestamp(),
+				Summary:   change.Summary(),
+				Context:   codeContext(change),
+			})
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Timestamp < result[j].Timestamp
+	})
+	return result
+}
+
 var templateHelpers = template.FuncMap{
 	"ccbStateColor": func(s bug.CcbState) string {
 		switch s {
@@ -714,6 +870,16 @@ var templateHelpers = template.FuncMap{
 			return "bg-secondary"
 		}
 	},
+	"deliveryStateColor": func(s webhook.DeliveryState) string {
+		switch s {
+		case webhook.DeliveredDelivery:
+			return "bg-success"
+		case webhook.FailedDelivery:
+			return "bg-danger"
+		default:
+			return "bg-secondary"
+		}
+	},
 	"checklist": func(s bug.Label) string {
 		return strings.TrimPrefix(string(s), bug.ChecklistPrefix)
 	},
@@ -752,6 +918,7 @@ var templateHelpers = template.FuncMap{
 			return "bg-secondary"
 		}
 	},
+	"reviewComments": reviewComments,
 	"getRepo": func(ticket *cache.BugExcerpt) string {
 		for _, label := range ticket.Labels {
 			if label.IsRepo() {
@@ -797,6 +964,9 @@ var templateHelpers = template.FuncMap{
 			})
 		}))
 	},
+	"quoteReviewComment": func(ticket entity.Id, review string, comment string) quoteReviewCommentAction {
+		return quoteReviewCommentAction{Ticket: ticket.String(), Review: review, Comment: comment}
+	},
 	"mdToHtml": func(s string) template.HTML {
 		w := bytes.Buffer{}
 		err := md.Convert([]byte(s), &w)
@@ -805,6 +975,29 @@ var templateHelpers = template.FuncMap{
 		}
 		return template.HTML(w.Bytes())
 	},
+	"checklistValue": func(q config.ChecklistQuestion) template.HTML {
+		switch q.Type {
+		case config.NumericQuestion:
+			value := template.HTMLEscapeString(q.Value)
+			if thresholds := q.Thresholds(); thresholds != "" {
+				value = value + fmt.Sprintf(" <small class=\"text-muted\">(expected %s)</small>", template.HTMLEscapeString(thresholds))
+			}
+			return template.HTML(value)
+		case config.LinkQuestion:
+			var links []string
+			for _, link := range strings.Fields(q.Value) {
+				link = template.HTMLEscapeString(link)
+				if strings.HasPrefix(link, "http://") || strings.HasPrefix(link, "https://") {
+					links = append(links, fmt.Sprintf("<a href=\"%s\">%s</a>", link, link))
+				} else {
+					links = append(links, fmt.Sprintf("<code>%s</code>", link))
+				}
+			}
+			return template.HTML(strings.Join(links, "<br>"))
+		default:
+			return template.HTML(strings.ReplaceAll(template.HTMLEscapeString(q.Value), "\n", "<br>"))
+		}
+	},
 	"checklistFieldStateColor": func(s config.ChecklistState) string {
 		switch s {
 		case config.Passed:
@@ -0,0 +1,103 @@
+package webui
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/daedaleanai/git-ticket/bug/review"
+	"github.com/daedaleanai/git-ticket/cache"
+	"github.com/daedaleanai/git-ticket/config"
+	"github.com/daedaleanai/git-ticket/identity"
+	"github.com/daedaleanai/git-ticket/repository"
+	http_webui "github.com/daedaleanai/git-ticket/webui/http"
+	"github.com/daedaleanai/git-ticket/webui/session"
+)
+
+func TestReviewCommentsPhabricator(t *testing.T) {
+	alice := identity.NewBare("Alice", "alice@example.com")
+	bob := identity.NewBare("Bob", "bob@example.com")
+
+	info := &review.PhabReviewInfo{
+		RevisionId: "D42",
+		Updates: []review.ReviewUpdate{
+			{PhabTransaction: review.PhabTransaction{TransId: "1", Timestamp: 100, Type: review.CommentTransaction,
+				Text: "Looks good", CommentPhid: "PHID-XCMT-1"},
+				AuthorId: bob},
+			{PhabTransaction: review.PhabTransaction{TransId: "2", Timestamp: 200, Type: review.UserStatusTransaction,
+				Status: "accepted"},
+				AuthorId: bob},
+			{PhabTransaction: review.PhabTransaction{TransId: "3", Timestamp: 300, Type: review.StatusTransaction,
+				Status: "published"},
+				AuthorId: alice},
+		},
+	}
+
+	comments := reviewComments(info)
+	require.Len(t, comments, 3)
+	assert.Equal(t, "Looks good", comments[0].Body)
+	assert.Equal(t, "PHID-XCMT-1", comments[0].Id)
+
+	// The transactions which aren't comments are still shown
+	assert.Empty(t, comments[1].Body)
+	assert.Equal(t, "[accepted]", comments[1].Summary)
+	assert.Equal(t, bob, comments[1].Author)
+	assert.EqualValues(t, 200, comments[1].Timestamp)
+	assert.Empty(t, comments[2].Body)
+	assert.Equal(t, "[published]", comments[2].Summary)
+	assert.Equal(t, alice, comments[2].Author)
+	assert.EqualValues(t, 300, comments[2].Timestamp)
+}
+
+func TestHandleMovedTicket(t *testing.T) {
+	repo := repository.CreateTestRepo(false)
+	target := repository.CreateTestRepo(false)
+	defer repository.CleanupTestRepos(repo, target)
+	repository.SetupSigningKey(t, repo, "rene@descartes.fr")
+
+	repoCache, err := cache.NewRepoCache(repo, false)
+	require.NoError(t, err)
+	rene, err := repoCache.NewIdentity("René Descartes", "rene@descartes.fr", true, true, "")
+	require.NoError(t, err)
+	require.NoError(t, repoCache.SetUserIdentity(rene))
+	require.NoError(t, repoCache.DoWithLockedConfigCache(func(c *config.ConfigCache) error {
+		if err := c.LabelConfig.AppendLabelToConfiguration(config.Label("repo:test")); err != nil {
+			return err
+		}
+		return c.LabelConfig.Store(repo)
+	}))
+
+	b, _, err := repoCache.NewBug(cache.NewBugOpts{Title: "Engine explodes", Message: "On ignition.", Workflow: "workflow:eng", Repo: "repo:test"})
+	require.NoError(t, err)
+	location, err := repoCache.MoveBug(b, target.GetPath())
+	require.NoError(t, err)
+
+	get := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/ticket/"+b.Id().String()+"/", nil)
+		bag, err := session.NewFlashMessageBag(r, w)
+		require.NoError(t, err)
+		r = http_webui.LoadIntoContext(r, &http_webui.ContextualRepoCache{Repo: repoCache})
+		r = http_webui.LoadIntoContext(r, bag)
+		handleTicket(w, mux.SetURLVars(r, map[string]string{"id": b.Id().String()}))
+		return w
+	}
+
+	// Without the web UI of the other repository, the ticket is shown with where it went
+	webUiConfig.Xref.FullPattern = regexp.MustCompile(`a^`)
+	defer func() { webUiConfig.Xref.FullPattern = nil }()
+	w := get()
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "This ticket was moved to "+location)
+
+	webUiConfig.Moved = map[string]string{location: "https://tickets.example.com/rocket/"}
+	defer func() { webUiConfig.Moved = nil }()
+	w = get()
+	assert.Equal(t, http.StatusMovedPermanently, w.Code)
+	assert.Equal(t, "https://tickets.example.com/rocket/ticket/"+b.Id().String()+"/", w.Header().Get("Location"))
+}